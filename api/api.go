@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"image"
+
+	v1 "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// APIVersion is the major version of this API.
+const APIVersion = 2
+
+type (
+	// Options configures Process; see the root package.
+	Options = v1.Options
+	// Result is the outcome of Process.
+	Result = v1.Result
+	// DetectionResult is the outcome of Detect and DetectImage.
+	DetectionResult = v1.DetectionResult
+	Confidence      = v1.Confidence
+	Info            = v1.Info
+	Warning         = v1.Warning
+	FrameResult     = v1.FrameResult
+	Profile         = v1.Profile
+	Corner          = v1.Corner
+	OutputFormat    = v1.OutputFormat
+	Resize          = v1.Resize
+	// Engine holds removal settings; build one with NewEngine.
+	Engine = v1.Engine
+	Option = v1.Option
+)
+
+const (
+	OutputPNG    = v1.OutputPNG
+	OutputJPEG   = v1.OutputJPEG
+	OutputWebP   = v1.OutputWebP
+	OutputSource = v1.OutputSource
+
+	ConfidenceLow    = v1.ConfidenceLow
+	ConfidenceMedium = v1.ConfidenceMedium
+	ConfidenceHigh   = v1.ConfidenceHigh
+)
+
+// Process removes the watermark from raw image bytes, as ProcessBytesContext
+// in the root package.
+func Process(ctx context.Context, input []byte, opts Options) (Result, error) {
+	return v1.ProcessBytesContext(ctx, input, opts)
+}
+
+// Detect checks raw image bytes for the watermark placed according to
+// opts.Profile, or the Gemini profile when it is nil, without removing it.
+// The other options do not apply.
+func Detect(ctx context.Context, input []byte, opts Options) (DetectionResult, error) {
+	return v1.DetectResultBytesContext(ctx, input, profile(opts))
+}
+
+// DetectImage checks a decoded image for the watermark placed according to
+// profile p.
+func DetectImage(img image.Image, p Profile) (DetectionResult, error) {
+	return v1.DetectResult(img, p)
+}
+
+// GeminiProfile returns the placement of Gemini's visible watermark.
+func GeminiProfile() Profile {
+	return v1.GeminiProfile()
+}
+
+// NewEngine returns an engine configured by opts, built with the With
+// functions of the root package.
+func NewEngine(opts ...Option) *Engine {
+	return v1.NewEngine(opts...)
+}
+
+// SetDefaultEngine replaces the engine Process and Detect use; nil restores
+// the default.
+func SetDefaultEngine(e *Engine) {
+	v1.SetDefaultEngine(e)
+}
+
+func profile(opts Options) Profile {
+	if opts.Profile != nil {
+		return *opts.Profile
+	}
+	return v1.GeminiProfile()
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	v1 "github.com/gcslaoli/gemini-watermark-remover-go"
+	_ "github.com/gcslaoli/gemini-watermark-remover-go/webpenc"
+)
+
+func TestProcessAndDetect(t *testing.T) {
+	marked, err := os.ReadFile("../testdata/watermarked.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clean, err := os.ReadFile("../testdata/clean.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	d, err := Detect(ctx, marked, Options{})
+	if err != nil || !d.Present {
+		t.Fatalf("Detect watermarked: present=%v err=%v", d.Present, err)
+	}
+	want, err := v1.DetectResultBytes(marked, v1.GeminiProfile())
+	if err != nil || want.Score != d.Score || want.Correlation != d.Correlation {
+		t.Fatalf("Detect differs from the root package: %+v vs %+v (%v)", d, want, err)
+	}
+	if d, err := Detect(ctx, clean, Options{}); err != nil || d.Present {
+		t.Fatalf("Detect clean: present=%v err=%v", d.Present, err)
+	}
+
+	result, err := Process(ctx, marked, Options{Output: OutputSource})
+	if err != nil || !result.Present || result.Format != "webp" {
+		t.Fatalf("Process: present=%v format=%q err=%v", result.Present, result.Format, err)
+	}
+	if d, err := Detect(ctx, result.Output, Options{}); err != nil || d.Present {
+		t.Fatalf("Detect cleaned output: present=%v err=%v", d.Present, err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Process(cancelled, marked, Options{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Process with a cancelled context: %v", err)
+	}
+}
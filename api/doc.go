@@ -0,0 +1,24 @@
+// Package api is the versioned machine API of the Gemini watermark
+// remover: byte-level processing that reports a Result and detection that
+// reports a DetectionResult, each in one struct instead of a tuple.
+//
+// Its types are aliases of those in the root package, so values pass freely
+// between code that uses either package during a migration, and engines set
+// with SetDefaultEngine apply to both. The package is versioned by
+// APIVersion rather than by its import path, which leaves /v2 free for a
+// future major version of the module itself. As in the root package, WebP
+// output needs the encoder the webpenc package registers.
+//
+// # Compatibility and deprecation
+//
+// Within APIVersion 2 the signatures below only gain fields and functions.
+// The root package keeps its v1 helpers (RemoveWatermarkBytes,
+// RemoveWatermarkBase64 and DetectWatermarkBytes with their Context
+// variants) as deprecated wrappers until the next major version of the
+// module. Building with
+//
+//	go build -tags watermark_nov1
+//
+// leaves them out, so any remaining use fails to compile ahead of their
+// removal.
+package api
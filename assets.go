@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"image"
 	"image/png"
+	"io"
 )
 
 //go:embed assets/bg_48.png assets/bg_64.png assets/bg_96.png
@@ -29,6 +30,21 @@ func decodeAlphaAsset(size int) ([]float32, error) {
 	return calculateAlphaMap(img), nil
 }
 
+// LoadAlphaMapFromPNG decodes a square watermark capture in the format of the
+// embedded assets, the logo rendered over pure black, and returns its alpha
+// map and edge length for Engine.RegisterAlphaMap.
+func LoadAlphaMapFromPNG(r io.Reader) (alpha []float32, size int, err error) {
+	img, err := png.Decode(r)
+	if err != nil {
+		return nil, 0, fmt.Errorf("decode alpha map: %w", err)
+	}
+	b := img.Bounds()
+	if b.Dx() != b.Dy() || b.Empty() {
+		return nil, 0, fmt.Errorf("alpha map capture must be square, got %dx%d", b.Dx(), b.Dy())
+	}
+	return calculateAlphaMap(img), b.Dx(), nil
+}
+
 // calculateAlphaMap extracts the maximum RGB channel per pixel and scales it to
 // [0, 1], matching the JavaScript implementation.
 func calculateAlphaMap(img image.Image) []float32 {
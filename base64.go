@@ -2,6 +2,7 @@ package watermark
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"image"
@@ -12,15 +13,14 @@ import (
 // DecodeBase64Image decodes a base64-encoded image (optionally a data URL) into
 // an image.Image. It returns the decoded image and the detected format string
 // ("png", "jpeg", "webp", etc.).
-func DecodeBase64Image(input string) (image.Image, string, error) {
-	raw := stripDataPrefix(input)
-
-	data, err := base64.StdEncoding.DecodeString(raw)
+func DecodeBase64Image(input string) (_ image.Image, _ string, err error) {
+	data, err := DecodeBase64Bytes(input)
 	if err != nil {
-		return nil, "", fmt.Errorf("decode base64: %w", err)
+		return nil, "", err
 	}
 
-	img, format, err := Decode(bytes.NewReader(data))
+	defer recoverPanic("DecodeBase64Image", data, &err)
+	img, format, err := decodeImage(bytes.NewReader(data))
 	if err != nil {
 		return nil, "", err
 	}
@@ -28,14 +28,26 @@ func DecodeBase64Image(input string) (image.Image, string, error) {
 	return img, format, nil
 }
 
+// DecodeBase64Bytes decodes a base64-encoded image, optionally a data URL,
+// into its raw bytes without decoding the image. Surrounding whitespace, such
+// as a trailing newline, is ignored.
+func DecodeBase64Bytes(input string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(stripDataPrefix(input)))
+	if err != nil {
+		return nil, fmt.Errorf("decode base64: %w", err)
+	}
+	return data, nil
+}
+
 // DecodeImageBytes decodes raw image bytes into an image.Image. It returns the
 // decoded image and detected format string.
-func DecodeImageBytes(data []byte) (image.Image, string, error) {
+func DecodeImageBytes(data []byte) (_ image.Image, _ string, err error) {
 	if len(data) == 0 {
 		return nil, "", fmt.Errorf("empty image data")
 	}
 
-	return Decode(bytes.NewReader(data))
+	defer recoverPanic("DecodeImageBytes", data, &err)
+	return decodeImage(bytes.NewReader(data))
 }
 
 // EncodePNGToBase64 encodes an image as PNG and returns a base64 string.
@@ -58,27 +70,15 @@ func EncodePNGToBytes(img image.Image) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// RemoveWatermarkBase64 removes the watermark from a base64-encoded image. It
-// returns the cleaned image as base64 PNG, whether a watermark was detected,
-// the detection score, watermark info, and an error if any.
-func RemoveWatermarkBase64(input string) (output string, present bool, score float64, info Info, err error) {
-	raw := stripDataPrefix(input)
-
-	data, err := base64.StdEncoding.DecodeString(raw)
-	if err != nil {
-		return "", false, 0, Info{}, fmt.Errorf("decode base64: %w", err)
-	}
-
-	bytesOut, present, score, info, err := RemoveWatermarkBytes(data)
-	if err != nil {
-		return "", false, 0, Info{}, err
-	}
-
-	if !present {
-		return "", false, score, info, nil
+// EncodeJPEGToBytes encodes an image as JPEG at the given quality and returns
+// the raw bytes. See EncodeJPEG.
+func EncodeJPEGToBytes(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := EncodeJPEG(&buf, img, quality); err != nil {
+		return nil, err
 	}
 
-	return base64.StdEncoding.EncodeToString(bytesOut), true, score, info, nil
+	return buf.Bytes(), nil
 }
 
 func stripDataPrefix(input string) string {
@@ -91,38 +91,66 @@ func stripDataPrefix(input string) string {
 	return input
 }
 
-// RemoveWatermarkBytes removes the watermark from raw image bytes. It returns
-// the cleaned PNG bytes when a watermark is detected, along with the detection
-// score and watermark info.
-func RemoveWatermarkBytes(input []byte) (output []byte, present bool, score float64, info Info, err error) {
-	if len(input) == 0 {
-		return nil, false, 0, Info{}, fmt.Errorf("empty image data")
+// removeAndEncode detects and removes the watermark placed according to p
+// from a decoded image and encodes the cleaned result as format ("png",
+// "jpeg" or "webp", with the quality and metadata settings of o), tagged
+// with the color space of the source bytes. 16-bit images are cleaned and
+// encoded at 16 bits as by RemoveWatermarkDepth. The result has no
+// InputSize.
+func removeAndEncode(ctx context.Context, img image.Image, source []byte, p Profile, format string, o Options) (Result, error) {
+	engine := Default()
+	engine.report(StageDetect, 0)
+	d, err := engine.DetectResult(img, p)
+	if err != nil {
+		return Result{}, err
 	}
+	engine.report(StageDetect, 100)
 
-	img, _, err := DecodeImageBytes(input)
-	if err != nil {
-		return nil, false, 0, Info{}, err
+	score, info := d.Score, d.Info
+	if !d.Present {
+		return Result{Score: score, Correlation: d.Correlation, Confidence: d.Confidence, Info: info}, nil
 	}
 
-	present, score, info, err = DetectWatermark(img)
+	engine.report(StageBlend, 0)
+	cleaned, err := engine.removeDepth(ctx, img, info, p)
 	if err != nil {
-		return nil, false, 0, Info{}, err
+		return Result{}, err
 	}
+	engine.report(StageBlend, 100)
 
-	if !present {
-		return nil, false, score, info, nil
+	engine.report(StageEncode, 0)
+	output, err := o.encode(ctx, cleaned, source, format)
+	if err != nil {
+		return Result{}, err
 	}
-
-	engine := NewEngine()
-	cleaned, err := engine.RemoveWatermark(img)
+	engine.report(StageEncode, 100)
+	output = o.tag(output, source)
+	thumb, err := o.thumbnail(ctx, cleaned, source, format)
 	if err != nil {
-		return nil, false, 0, Info{}, err
+		return Result{}, err
 	}
-
-	output, err = EncodePNGToBytes(cleaned)
+	correction, err := o.correction(ctx, img, cleaned)
 	if err != nil {
-		return nil, false, 0, Info{}, err
+		return Result{}, err
+	}
+
+	warnings := engine.RemovalWarnings(img, p, info)
+	warnings = append(warnings, o.MetadataWarnings(source, output)...)
+	if invisible := o.InvisibleWarnings(cleaned); invisible != nil {
+		info.InvisibleWatermark = true
+		warnings = append(warnings, invisible...)
 	}
+	return Result{Output: output, Thumbnail: thumb, Correction: correction, Format: format, Present: true, Score: score,
+		Correlation: d.Correlation, Confidence: d.Confidence, Info: info, Warnings: warnings}, nil
+}
 
-	return output, true, score, info, nil
+// EncodeWebPToBytes encodes an image as WebP and returns the raw bytes. See
+// EncodeWebP.
+func EncodeWebPToBytes(img image.Image, o *WebPOptions) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := EncodeWebP(&buf, img, o); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
 }
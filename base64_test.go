@@ -0,0 +1,23 @@
+package watermark
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecodeBase64Bytes(t *testing.T) {
+	want := []byte("\x89PNG\r\n\x1a\n")
+	for _, input := range []string{
+		"iVBORw0KGgo=",
+		"data:image/png;base64,iVBORw0KGgo=",
+		"DATA:image/png;base64,iVBORw0KGgo=\n",
+	} {
+		got, err := DecodeBase64Bytes(input)
+		if err != nil || !bytes.Equal(got, want) {
+			t.Errorf("DecodeBase64Bytes(%q) = %q, %v", input, got, err)
+		}
+	}
+	if _, err := DecodeBase64Bytes("data:image/png;base64,not base64!"); err == nil {
+		t.Error("invalid base64 decoded")
+	}
+}
@@ -0,0 +1,133 @@
+package watermark
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+)
+
+// BatchItem is one encoded image in a batch.
+type BatchItem struct {
+	// Name identifies the item, typically its path inside an archive. It is
+	// matched against ProfileHint patterns.
+	Name string
+	Data []byte
+}
+
+// ProfileHint routes batch items whose name matches Pattern to Profile.
+// Pattern uses path.Match syntax and is tried against both the full name and
+// its base name, so "*.png" matches "exports/a.png".
+type ProfileHint struct {
+	Pattern string
+	Profile Profile
+}
+
+// BatchOptions configures ProcessBatch.
+type BatchOptions struct {
+	// Options applies to every item. Its Profile is the fallback for items
+	// that match no hint.
+	Options Options
+	// Hints are evaluated in order; the first match wins.
+	Hints []ProfileHint
+	// ContentNames sets BatchResult.OutputName to a name derived from the
+	// output bytes (see ContentName), so identical outputs share a name.
+	ContentNames bool
+}
+
+// BatchResult is the outcome for one BatchItem.
+type BatchResult struct {
+	Name    string
+	Profile string
+	// OutputName is the content-addressed name of Result.Output. It is set
+	// only when BatchOptions.ContentNames is enabled and there is output.
+	OutputName string
+	Result     Result
+	Err        error
+}
+
+// ProcessBatch runs ProcessBytes over every item, selecting each item's
+// profile from the hints. Per-item failures are reported in the results and
+// do not stop the batch; an invalid hint pattern fails the batch up front.
+func ProcessBatch(items []BatchItem, opts BatchOptions) ([]BatchResult, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	results := make([]BatchResult, len(items))
+	for i, item := range items {
+		results[i] = opts.process(item)
+	}
+
+	return results, nil
+}
+
+// validate checks the hint patterns.
+func (o BatchOptions) validate() error {
+	for _, h := range o.Hints {
+		if _, err := path.Match(h.Pattern, ""); err != nil {
+			return fmt.Errorf("invalid profile hint pattern %q: %w", h.Pattern, err)
+		}
+	}
+	return nil
+}
+
+// process runs ProcessBytes on one item with its hinted profile.
+func (o BatchOptions) process(item BatchItem) BatchResult {
+	itemOpts := o.Options
+	profile := o.profileFor(item.Name)
+	itemOpts.Profile = &profile
+
+	result, err := ProcessBytes(item.Data, itemOpts)
+	r := BatchResult{Name: item.Name, Profile: profile.Name, Result: result, Err: err}
+	if o.ContentNames && err == nil && len(result.Output) > 0 {
+		r.OutputName = ContentName(result.Output, result.Format)
+	}
+	return r
+}
+
+// ContentName returns a content-addressed file name for data: the first 16
+// hex digits of its SHA-256 followed by the format as extension, for example
+// "3f2a9c0d1b7e4f60.png".
+func ContentName(data []byte, format string) string {
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:])[:16]
+	if format == "" {
+		return name
+	}
+	return name + "." + format
+}
+
+// WriteManifest writes a JSON object mapping each item name to its
+// OutputName, with keys sorted. Items without an OutputName are omitted.
+// Names are written verbatim, without escaping <, > and & for HTML.
+func WriteManifest(w io.Writer, results []BatchResult) error {
+	manifest := make(map[string]string, len(results))
+	for _, r := range results {
+		if r.OutputName != "" {
+			manifest[r.Name] = r.OutputName
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(manifest); err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+func (o BatchOptions) profileFor(name string) Profile {
+	for _, h := range o.Hints {
+		if ok, _ := path.Match(h.Pattern, name); ok {
+			return h.Profile
+		}
+		if ok, _ := path.Match(h.Pattern, path.Base(name)); ok {
+			return h.Profile
+		}
+	}
+	return o.Options.profile()
+}
@@ -0,0 +1,85 @@
+package watermark
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestProcessBatchProfileHints(t *testing.T) {
+	var buf bytes.Buffer
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{R: 20, G: 20, B: 60, A: 255})
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	data := buf.Bytes()
+
+	items := []BatchItem{
+		{Name: "gemini/a.png", Data: data},
+		{Name: "other/b.png", Data: data},
+		{Name: "broken.png", Data: []byte("not an image")},
+	}
+	results, err := ProcessBatch(items, BatchOptions{
+		Hints: []ProfileHint{{Pattern: "other/*", Profile: PassthroughProfile()}},
+	})
+	if err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	if r := results[0]; r.Err != nil || r.Profile != "gemini" || !r.Result.Present {
+		t.Fatalf("gemini item: %+v", r)
+	}
+	if r := results[1]; r.Err != nil || r.Profile != "none" || r.Result.Present {
+		t.Fatalf("passthrough item: %+v", r)
+	}
+	if results[2].Err == nil {
+		t.Fatalf("expected error for undecodable item")
+	}
+
+	if _, err := ProcessBatch(items, BatchOptions{Hints: []ProfileHint{{Pattern: "["}}}); err == nil {
+		t.Fatalf("expected error for malformed pattern")
+	}
+}
+
+func TestProcessBatchContentNames(t *testing.T) {
+	var buf bytes.Buffer
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{R: 20, G: 20, B: 60, A: 255})
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	items := []BatchItem{
+		{Name: "a.png", Data: buf.Bytes()},
+		{Name: "copy & 照片/a.png", Data: buf.Bytes()},
+	}
+	results, err := ProcessBatch(items, BatchOptions{ContentNames: true})
+	if err != nil {
+		t.Fatalf("ProcessBatch: %v", err)
+	}
+
+	name := results[0].OutputName
+	if len(name) != len("0123456789abcdef.png") || !strings.HasSuffix(name, ".png") {
+		t.Fatalf("unexpected content name %q", name)
+	}
+	if results[1].OutputName != name {
+		t.Fatalf("identical outputs got different names: %q vs %q", name, results[1].OutputName)
+	}
+
+	var manifest bytes.Buffer
+	if err := WriteManifest(&manifest, results); err != nil {
+		t.Fatalf("WriteManifest: %v", err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(manifest.Bytes(), &got); err != nil {
+		t.Fatalf("decode manifest: %v", err)
+	}
+	if len(got) != 2 || got["copy & 照片/a.png"] != name {
+		t.Fatalf("unexpected manifest %v", got)
+	}
+	if !strings.Contains(manifest.String(), `"copy & 照片/a.png"`) {
+		t.Errorf("name not written verbatim:\n%s", manifest.String())
+	}
+}
@@ -0,0 +1,151 @@
+package watermark
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"sync"
+)
+
+// cloneToRGBA copies the image into a mutable RGBA buffer. draw.Draw only has
+// fast paths for a few source types and falls back to an interface call per
+// pixel for the rest, so the other types produced by the registered decoders
+// are copied row by row here. Results match draw.Draw exactly.
+func cloneToRGBA(src image.Image) *image.RGBA {
+	dst := image.NewRGBA(src.Bounds())
+	copyToRGBA(dst, src, src.Bounds())
+	return dst
+}
+
+// copyToRGBA copies the area of src into dst, which must contain area.
+func copyToRGBA(dst *image.RGBA, src image.Image, area image.Rectangle) {
+	switch s := src.(type) {
+	case *image.Paletted:
+		copyPaletted(dst, s.SubImage(area).(*image.Paletted))
+	case *image.NYCbCrA:
+		copyNYCbCrA(dst, s.SubImage(area).(*image.NYCbCrA))
+	case *image.Gray16:
+		s = s.SubImage(area).(*image.Gray16)
+		copyPix(dst, s.Pix, s.Stride, s.Rect, 2, func(d, p []byte) {
+			d[0], d[1], d[2], d[3] = p[0], p[0], p[0], 0xff
+		})
+	case *image.RGBA64:
+		s = s.SubImage(area).(*image.RGBA64)
+		copyPix(dst, s.Pix, s.Stride, s.Rect, 8, func(d, p []byte) {
+			d[0], d[1], d[2], d[3] = p[0], p[2], p[4], p[6]
+		})
+	case *image.NRGBA64:
+		s = s.SubImage(area).(*image.NRGBA64)
+		copyPix(dst, s.Pix, s.Stride, s.Rect, 8, func(d, p []byte) {
+			c := color.NRGBA64{
+				R: uint16(p[0])<<8 | uint16(p[1]),
+				G: uint16(p[2])<<8 | uint16(p[3]),
+				B: uint16(p[4])<<8 | uint16(p[5]),
+				A: uint16(p[6])<<8 | uint16(p[7]),
+			}
+			r, g, b, a := c.RGBA()
+			d[0], d[1], d[2], d[3] = uint8(r>>8), uint8(g>>8), uint8(b>>8), uint8(a>>8)
+		})
+	default:
+		// RGBA, NRGBA, YCbCr, Gray and CMYK have fast paths in draw.
+		draw.Draw(dst, area, src, area.Min, draw.Src)
+	}
+}
+
+// minBandRows is the fewest rows a concurrent copy band gets, so small
+// images are not split into bands that cost more to schedule than to copy.
+const minBandRows = 64
+
+// inBands calls fn for up to n horizontal bands covering r, concurrently,
+// and waits for them.
+func inBands(r image.Rectangle, n int, fn func(band image.Rectangle)) {
+	n = min(n, r.Dy()/minBandRows)
+	if n <= 1 {
+		fn(r)
+		return
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		band := r
+		band.Min.Y = r.Min.Y + r.Dy()*i/n
+		band.Max.Y = r.Min.Y + r.Dy()*(i+1)/n
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn(band)
+		}()
+	}
+	wg.Wait()
+}
+
+// cloneToRGBAParallel is cloneToRGBA copying n bands concurrently.
+func cloneToRGBAParallel(src image.Image, n int) *image.RGBA {
+	dst := image.NewRGBA(src.Bounds())
+	inBands(src.Bounds(), n, func(band image.Rectangle) {
+		copyToRGBA(dst, src, band)
+	})
+	return dst
+}
+
+// cloneToNRGBAParallel is cloneToNRGBA copying n bands concurrently.
+func cloneToNRGBAParallel(src image.Image, n int) *image.NRGBA {
+	dst := image.NewNRGBA(src.Bounds())
+	inBands(src.Bounds(), n, func(band image.Rectangle) {
+		draw.Draw(dst, band, src, band.Min, draw.Src)
+	})
+	return dst
+}
+
+// copyPaletted converts the palette once and then copies by index.
+func copyPaletted(dst *image.RGBA, src *image.Paletted) {
+	var lut [256][4]uint8
+	for i, c := range src.Palette {
+		if i == len(lut) {
+			break
+		}
+		r, g, b, a := c.RGBA()
+		lut[i] = [4]uint8{uint8(r >> 8), uint8(g >> 8), uint8(b >> 8), uint8(a >> 8)}
+	}
+
+	bounds := src.Rect
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := src.Pix[src.PixOffset(bounds.Min.X, y):][:bounds.Dx()]
+		out := dst.Pix[dst.PixOffset(bounds.Min.X, y):]
+		for i, idx := range row {
+			// Indices past the palette, which the decoders reject, stay
+			// transparent black.
+			if int(idx) < len(src.Palette) {
+				copy(out[i*4:i*4+4], lut[idx][:])
+			}
+		}
+	}
+}
+
+func copyNYCbCrA(dst *image.RGBA, src *image.NYCbCrA) {
+	bounds := src.Rect
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		out := dst.Pix[dst.PixOffset(bounds.Min.X, y):]
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			yi, ci := src.YOffset(x, y), src.COffset(x, y)
+			c := color.NYCbCrA{
+				YCbCr: color.YCbCr{Y: src.Y[yi], Cb: src.Cb[ci], Cr: src.Cr[ci]},
+				A:     src.A[src.AOffset(x, y)],
+			}
+			r, g, b, a := c.RGBA()
+			i := (x - bounds.Min.X) * 4
+			out[i], out[i+1], out[i+2], out[i+3] = uint8(r>>8), uint8(g>>8), uint8(b>>8), uint8(a>>8)
+		}
+	}
+}
+
+// copyPix walks a packed pixel buffer with bpp bytes per pixel, letting
+// convert write each RGBA pixel.
+func copyPix(dst *image.RGBA, pix []byte, stride int, bounds image.Rectangle, bpp int, convert func(d, p []byte)) {
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		row := pix[(y-bounds.Min.Y)*stride:]
+		out := dst.Pix[dst.PixOffset(bounds.Min.X, y):]
+		for i := 0; i < bounds.Dx(); i++ {
+			convert(out[i*4:i*4+4], row[i*bpp:i*bpp+bpp])
+		}
+	}
+}
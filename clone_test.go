@@ -0,0 +1,168 @@
+package watermark
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"sync"
+	"testing"
+)
+
+// cloneSources returns one image of every type with a specialized copy,
+// filled with a gradient and cropped from width x height so the bounds do not
+// start at the origin.
+func cloneSources(width, height int) map[string]image.Image {
+	r := image.Rect(0, 0, width, height)
+	grad := image.NewNRGBA(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			grad.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 3), G: uint8(y * 5), B: uint8(x ^ y), A: uint8(128 + x + y)})
+		}
+	}
+
+	paletted := image.NewPaletted(r, palette.Plan9)
+	nycbcra := image.NewNYCbCrA(r, image.YCbCrSubsampleRatio420)
+	gray16 := image.NewGray16(r)
+	rgba64 := image.NewRGBA64(r)
+	nrgba64 := image.NewNRGBA64(r)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := grad.NRGBAAt(x, y)
+			paletted.Set(x, y, c)
+			gray16.Set(x, y, c)
+			rgba64.Set(x, y, c)
+			nrgba64.Set(x, y, color.NRGBA64{R: uint16(c.R) * 257, G: uint16(c.G)*257 + 3, B: uint16(c.B) * 257, A: uint16(c.A) * 257})
+
+			yy, cb, cr := color.RGBToYCbCr(c.R, c.G, c.B)
+			nycbcra.Y[nycbcra.YOffset(x, y)] = yy
+			nycbcra.Cb[nycbcra.COffset(x, y)] = cb
+			nycbcra.Cr[nycbcra.COffset(x, y)] = cr
+			nycbcra.A[nycbcra.AOffset(x, y)] = c.A
+		}
+	}
+
+	crop := image.Rect(5, 3, width-7, height-3)
+	return map[string]image.Image{
+		"paletted": paletted.SubImage(crop),
+		"nycbcra":  nycbcra.SubImage(crop),
+		"gray16":   gray16.SubImage(crop),
+		"rgba64":   rgba64.SubImage(crop),
+		"nrgba64":  nrgba64.SubImage(crop),
+	}
+}
+
+func TestCloneToRGBAMatchesDraw(t *testing.T) {
+	for name, src := range cloneSources(67, 41) {
+		t.Run(name, func(t *testing.T) {
+			bounds := src.Bounds()
+			want := image.NewRGBA(bounds)
+			draw.Draw(want, bounds, src, bounds.Min, draw.Src)
+
+			got := cloneToRGBA(src)
+			if got.Rect != want.Rect {
+				t.Fatalf("bounds = %v, want %v", got.Rect, want.Rect)
+			}
+			if !bytes.Equal(got.Pix, want.Pix) {
+				t.Fatalf("pixels differ from draw.Draw")
+			}
+		})
+	}
+}
+
+func TestCloneParallelMatches(t *testing.T) {
+	sources := cloneSources(131, 4*minBandRows+9)
+	sources["nrgba"] = image.NewNRGBA(image.Rect(0, 0, 50, 3*minBandRows))
+	for name, src := range sources {
+		t.Run(name, func(t *testing.T) {
+			want := cloneToRGBA(src)
+			got := cloneToRGBAParallel(src, 3)
+			if got.Rect != want.Rect || !bytes.Equal(got.Pix, want.Pix) {
+				t.Fatalf("parallel RGBA clone differs")
+			}
+			wantN := cloneToNRGBA(src)
+			gotN := cloneToNRGBAParallel(src, 3)
+			if gotN.Rect != wantN.Rect || !bytes.Equal(gotN.Pix, wantN.Pix) {
+				t.Fatalf("parallel NRGBA clone differs")
+			}
+		})
+	}
+}
+
+func TestInBands(t *testing.T) {
+	r := image.Rect(2, 7, 20, 7+5*minBandRows+3)
+	var mu sync.Mutex
+	covered := 0
+	inBands(r, 8, func(band image.Rectangle) {
+		mu.Lock()
+		defer mu.Unlock()
+		if band.Min.X != r.Min.X || band.Max.X != r.Max.X || !band.In(r) {
+			t.Errorf("band %v outside %v", band, r)
+		}
+		covered += band.Dy()
+	})
+	if covered != r.Dy() {
+		t.Fatalf("bands cover %d rows, want %d", covered, r.Dy())
+	}
+}
+
+// BenchmarkDecodeClone measures the decode and clone path that every removal
+// starts with, for the image types the registered decoders return.
+func BenchmarkDecodeClone(b *testing.B) {
+	src := watermarkedRGBA(b, 1024, 768, color.RGBA{R: 40, G: 60, B: 90, A: 255})
+
+	encoded := make(map[string][]byte)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, src); err != nil {
+		b.Fatal(err)
+	}
+	encoded["png"] = append([]byte(nil), buf.Bytes()...)
+
+	buf.Reset()
+	paletted := image.NewPaletted(src.Bounds(), palette.Plan9)
+	draw.Draw(paletted, paletted.Bounds(), src, image.Point{}, draw.Src)
+	if err := png.Encode(&buf, paletted); err != nil {
+		b.Fatal(err)
+	}
+	encoded["png paletted"] = append([]byte(nil), buf.Bytes()...)
+
+	buf.Reset()
+	if err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: 90}); err != nil {
+		b.Fatal(err)
+	}
+	encoded["jpeg"] = append([]byte(nil), buf.Bytes()...)
+
+	buf.Reset()
+	if err := gif.Encode(&buf, paletted, nil); err != nil {
+		b.Fatal(err)
+	}
+	encoded["gif"] = append([]byte(nil), buf.Bytes()...)
+
+	for _, name := range []string{"png", "png paletted", "jpeg", "gif"} {
+		data := encoded[name]
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				img, _, err := DecodeImageBytes(data)
+				if err != nil {
+					b.Fatal(err)
+				}
+				cloneToRGBA(img)
+			}
+		})
+	}
+}
+
+func BenchmarkCloneToRGBA(b *testing.B) {
+	for name, src := range cloneSources(67, 41) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				cloneToRGBA(src)
+			}
+		})
+	}
+}
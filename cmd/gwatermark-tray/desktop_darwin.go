@@ -0,0 +1,22 @@
+package main
+
+import "os/exec"
+
+// notify shows a Notification Center banner. The texts are passed as
+// arguments, so they need no AppleScript quoting.
+func notify(title, body string) error {
+	return exec.Command("osascript",
+		"-e", "on run argv",
+		"-e", "display notification (item 2 of argv) with title (item 1 of argv)",
+		"-e", "end run",
+		title, body).Run()
+}
+
+// openFolder opens dir in the Finder.
+func openFolder(dir string) error {
+	return exec.Command("open", dir).Start()
+}
+
+func trayIcon() []byte {
+	return iconPNG
+}
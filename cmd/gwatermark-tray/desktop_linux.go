@@ -0,0 +1,17 @@
+package main
+
+import "os/exec"
+
+// notify shows a desktop notification through notify-send.
+func notify(title, body string) error {
+	return exec.Command("notify-send", "-a", "gwatermark", title, body).Run()
+}
+
+// openFolder opens dir in the file manager.
+func openFolder(dir string) error {
+	return exec.Command("xdg-open", dir).Start()
+}
+
+func trayIcon() []byte {
+	return iconPNG
+}
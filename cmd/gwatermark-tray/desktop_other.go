@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import "errors"
+
+var errNoDesktop = errors.New("desktop integration is not supported on this platform")
+
+func notify(string, string) error {
+	return errNoDesktop
+}
+
+func openFolder(string) error {
+	return errNoDesktop
+}
+
+func trayIcon() []byte {
+	return iconPNG
+}
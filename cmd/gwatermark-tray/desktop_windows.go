@@ -0,0 +1,34 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// toastScript shows a toast notification with the texts of the
+// GWATERMARK_TITLE and GWATERMARK_BODY environment variables, which need no
+// PowerShell quoting.
+const toastScript = `[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null
+$t = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$x = $t.GetElementsByTagName('text')
+$x.Item(0).AppendChild($t.CreateTextNode($env:GWATERMARK_TITLE)) > $null
+$x.Item(1).AppendChild($t.CreateTextNode($env:GWATERMARK_BODY)) > $null
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('gwatermark').Show([Windows.UI.Notifications.ToastNotification]::new($t))`
+
+// notify shows a toast notification through PowerShell.
+func notify(title, body string) error {
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", toastScript)
+	cmd.Env = append(os.Environ(), "GWATERMARK_TITLE="+title, "GWATERMARK_BODY="+body)
+	return cmd.Run()
+}
+
+// openFolder opens dir in Explorer.
+func openFolder(dir string) error {
+	return exec.Command("explorer", dir).Start()
+}
+
+// trayIcon returns the icon as an ICO file, which is all the Windows tray
+// accepts.
+func trayIcon() []byte {
+	return pngToICO(iconPNG, iconSize)
+}
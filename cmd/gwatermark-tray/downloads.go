@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// downloadsDir returns the user's Downloads folder: XDG_DOWNLOAD_DIR from
+// user-dirs.dirs on desktops that set it, otherwise Downloads in the home
+// directory.
+func downloadsDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		config = filepath.Join(home, ".config")
+	}
+	if dir := xdgDownloadDir(filepath.Join(config, "user-dirs.dirs"), home); dir != "" {
+		return dir, nil
+	}
+	return filepath.Join(home, "Downloads"), nil
+}
+
+// xdgDownloadDir reads XDG_DOWNLOAD_DIR from the user-dirs.dirs file at
+// path, or returns "".
+func xdgDownloadDir(path, home string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		value, ok := strings.CutPrefix(strings.TrimSpace(sc.Text()), "XDG_DOWNLOAD_DIR=")
+		if !ok {
+			continue
+		}
+		value = strings.Trim(value, `"`)
+		if rest, ok := strings.CutPrefix(value, "$HOME"); ok {
+			value = home + rest
+		}
+		if !filepath.IsAbs(value) {
+			return ""
+		}
+		return filepath.Clean(value)
+	}
+	return ""
+}
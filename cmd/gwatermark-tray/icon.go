@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+)
+
+// iconSize is the side of the tray icon in pixels.
+const iconSize = 32
+
+// iconPNG is the tray icon: a white four-pointed sparkle on a blue disk.
+var iconPNG = drawIcon(iconSize)
+
+func drawIcon(size int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	c := float64(size-1) / 2
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			dx, dy := math.Abs(float64(x)-c), math.Abs(float64(y)-c)
+			switch {
+			case math.Sqrt(dx)+math.Sqrt(dy) <= math.Sqrt(c*0.8):
+				img.SetNRGBA(x, y, color.NRGBA{255, 255, 255, 255})
+			case math.Hypot(dx, dy) <= c:
+				img.SetNRGBA(x, y, color.NRGBA{66, 133, 244, 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
+
+// pngToICO wraps a square PNG of the given size in a single-image ICO file,
+// a format Windows Vista and later accept.
+func pngToICO(data []byte, size int) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, struct {
+		Reserved, Type, Count uint16
+		Width, Height         uint8
+		Colors, Reserved2     uint8
+		Planes, BitCount      uint16
+		Size, Offset          uint32
+	}{Type: 1, Count: 1, Width: uint8(size), Height: uint8(size), Planes: 1, BitCount: 32, Size: uint32(len(data)), Offset: 22})
+	buf.Write(data)
+	return buf.Bytes()
+}
@@ -0,0 +1,180 @@
+// Command gwatermark-tray sits in the system tray and cleans every Gemini
+// image that lands in the Downloads folder, with a desktop notification for
+// each one. The cleaned copy is written beside the download as
+// <name>_unwatermarked.<ext>; the download itself is left untouched.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"fyne.io/systray"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	_ "github.com/gcslaoli/gemini-watermark-remover-go/webpenc"
+)
+
+// go run ./cmd/gwatermark-tray
+// go run ./cmd/gwatermark-tray -dir ~/Pictures/Gemini -format png
+// go run ./cmd/gwatermark-tray -no-tray -notify=false
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	dir := flag.String("dir", "", "Folder to watch (defaults to the Downloads folder)")
+	interval := flag.Duration("interval", 2*time.Second, "How often to look for new images")
+	formatName := flag.String("format", "source", "Output format: png, jpeg, webp, or source to keep JPEG and WebP inputs in their format")
+	quality := flag.Int("quality", watermark.DefaultJPEGQuality, "JPEG and lossy WebP output quality (1-100)")
+	notifyFlag := flag.Bool("notify", true, "Show a desktop notification for every cleaned image")
+	noTray := flag.Bool("no-tray", false, "Run without the tray icon until interrupted, e.g. from an autostart script on a desktop without a tray")
+	flag.Parse()
+
+	if *dir == "" {
+		d, err := downloadsDir()
+		if err != nil {
+			return fmt.Errorf("find the Downloads folder: %w (pass -dir)", err)
+		}
+		*dir = d
+	}
+	if fi, err := os.Stat(*dir); err != nil || !fi.IsDir() {
+		return fmt.Errorf("%s is not a folder", *dir)
+	}
+	if *interval <= 0 {
+		return errors.New("-interval must be positive")
+	}
+	outFormat, ok := watermark.ParseOutputFormat(*formatName)
+	if !ok {
+		return fmt.Errorf("unknown output format %q", *formatName)
+	}
+
+	w := newWatcher(*dir, watermark.Options{Output: outFormat, JPEGQuality: *quality, WebPQuality: *quality})
+	if err := w.prime(); err != nil {
+		return err
+	}
+	w.onError = func(path string, err error) {
+		log.Printf("%s: %v", filepath.Base(path), err)
+	}
+
+	a := &app{w: w, interval: *interval, notify: *notifyFlag}
+	if *noTray {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		log.Printf("Watching %s", *dir)
+		a.loop(ctx, nil)
+		return nil
+	}
+	systray.Run(a.onReady, nil)
+	return nil
+}
+
+// app ties the watcher to the tray menu.
+type app struct {
+	w        *watcher
+	interval time.Duration
+	notify   bool
+	paused   atomic.Bool
+}
+
+func (a *app) onReady() {
+	systray.SetIcon(trayIcon())
+	systray.SetTitle("gwatermark")
+	systray.SetTooltip("Cleaning new Gemini images in " + a.w.dir)
+
+	status := systray.AddMenuItem("Watching "+a.w.dir, "")
+	status.Disable()
+	last := systray.AddMenuItem("No image cleaned yet", "")
+	last.Disable()
+	systray.AddSeparator()
+	pause := systray.AddMenuItemCheckbox("Pause", "Stop cleaning new images", false)
+	open := systray.AddMenuItem("Open folder", "")
+	systray.AddSeparator()
+	quit := systray.AddMenuItem("Quit", "")
+
+	a.w.onClean = func(in, out string, r watermark.Result) {
+		a.cleaned(in, out)
+		last.SetTitle("Last: " + filepath.Base(out))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go a.loop(ctx, func() {
+		status.SetTitle("Cannot read " + a.w.dir)
+	})
+	go func() {
+		for {
+			select {
+			case <-pause.ClickedCh:
+				if pause.Checked() {
+					pause.Uncheck()
+					status.SetTitle("Watching " + a.w.dir)
+				} else {
+					pause.Check()
+					status.SetTitle("Paused")
+				}
+				a.paused.Store(pause.Checked())
+			case <-open.ClickedCh:
+				if err := openFolder(a.w.dir); err != nil {
+					log.Printf("open folder: %v", err)
+				}
+			case <-quit.ClickedCh:
+				cancel()
+				systray.Quit()
+				return
+			}
+		}
+	}()
+}
+
+// loop polls the folder until ctx is done. Images downloaded while paused
+// are skipped rather than cleaned on resume. failed, when set, is called
+// when the folder cannot be read.
+func (a *app) loop(ctx context.Context, failed func()) {
+	if a.w.onClean == nil {
+		a.w.onClean = func(in, out string, r watermark.Result) { a.cleaned(in, out) }
+	}
+	ticker := time.NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var err error
+		if a.paused.Load() {
+			err = a.w.prime()
+		} else {
+			err = a.w.scan()
+		}
+		if err != nil {
+			log.Printf("watch %s: %v", a.w.dir, err)
+			if failed != nil {
+				failed()
+			}
+		}
+	}
+}
+
+// cleaned logs a cleaned image and notifies the user.
+func (a *app) cleaned(in, out string) {
+	log.Printf("Cleaned %s -> %s", filepath.Base(in), filepath.Base(out))
+	if !a.notify {
+		return
+	}
+	if err := notify("Watermark removed", filepath.Base(in)+" → "+filepath.Base(out)); err != nil {
+		log.Printf("notify: %v", err)
+	}
+}
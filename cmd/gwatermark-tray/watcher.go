@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// cleanedSuffix marks outputs, which are never cleaned again.
+const cleanedSuffix = "_unwatermarked"
+
+// imageExts are the extensions of the files the watcher considers. Browsers
+// download under a temporary name (.crdownload, .part, .download) and rename
+// the file when it is complete, so partial downloads are never picked up.
+var imageExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".webp": true, ".gif": true}
+
+// fileStamp identifies a version of a file.
+type fileStamp struct {
+	size int64
+	mod  time.Time
+}
+
+// watcher polls a folder and cleans the images that appear in it. A file is
+// cleaned once its size and modification time have held still for one poll,
+// so images copied in slowly are not read half-written. Files present when
+// the watcher is primed are left alone. Files are remembered only while they
+// exist, so a long-running watcher's state stays as small as the folder.
+type watcher struct {
+	dir  string
+	opts watermark.Options
+	// onClean is called for every image cleaned, and onError for every
+	// image that could not be; either may be nil.
+	onClean func(in, out string, r watermark.Result)
+	onError func(path string, err error)
+
+	pending map[string]fileStamp
+	done    map[string]bool
+}
+
+func newWatcher(dir string, opts watermark.Options) *watcher {
+	return &watcher{dir: dir, opts: opts, pending: make(map[string]fileStamp), done: make(map[string]bool)}
+}
+
+// prime marks every image currently in the folder as handled.
+func (w *watcher) prime() error {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return err
+	}
+	clear(w.pending)
+	clear(w.done)
+	for _, e := range entries {
+		if candidate(e.Name()) {
+			w.done[e.Name()] = true
+		}
+	}
+	return nil
+}
+
+// scan looks for new images once and cleans those that are complete.
+func (w *watcher) scan() error {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return err
+	}
+	present := make(map[string]bool, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		present[name] = true
+		if w.done[name] || !e.Type().IsRegular() || !candidate(name) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue // removed since ReadDir
+		}
+		stamp := fileStamp{fi.Size(), fi.ModTime()}
+		if prev, ok := w.pending[name]; !ok || prev != stamp || stamp.size == 0 {
+			w.pending[name] = stamp
+			continue
+		}
+		delete(w.pending, name)
+		w.done[name] = true
+		out, result, err := w.clean(filepath.Join(w.dir, name))
+		switch {
+		case err != nil:
+			if w.onError != nil {
+				w.onError(filepath.Join(w.dir, name), err)
+			}
+		case result.Present:
+			// The output is not a candidate, so it needs no entry.
+			if w.onClean != nil {
+				w.onClean(filepath.Join(w.dir, name), out, result)
+			}
+		}
+	}
+	w.forget(present)
+	return nil
+}
+
+// forget drops the state of files that are no longer in the folder. A file
+// that comes back under the same name is new and gets cleaned.
+func (w *watcher) forget(present map[string]bool) {
+	for name := range w.pending {
+		if !present[name] {
+			delete(w.pending, name)
+		}
+	}
+	for name := range w.done {
+		if !present[name] {
+			delete(w.done, name)
+		}
+	}
+}
+
+// clean removes the watermark from the image at path and writes the result
+// beside it. Images without a watermark are left alone and out is "".
+func (w *watcher) clean(path string) (out string, result watermark.Result, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", watermark.Result{}, err
+	}
+	result, err = watermark.ProcessBytes(data, w.opts)
+	if err != nil || !result.Present {
+		return "", result, err
+	}
+
+	out = outputPath(path, result.Format)
+	tmp := out + ".part"
+	if err := os.WriteFile(tmp, result.Output, 0o644); err != nil {
+		return "", result, fmt.Errorf("write output: %w", err)
+	}
+	if err := os.Rename(tmp, out); err != nil {
+		os.Remove(tmp)
+		return "", result, fmt.Errorf("write output: %w", err)
+	}
+	return out, result, nil
+}
+
+// candidate reports whether the file name looks like an image to clean.
+func candidate(name string) bool {
+	ext := filepath.Ext(name)
+	return imageExts[strings.ToLower(ext)] && !strings.HasSuffix(strings.TrimSuffix(name, ext), cleanedSuffix)
+}
+
+// outputPath returns <name>_unwatermarked with the extension of format,
+// keeping the input's extension when it already matches.
+func outputPath(path, format string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	switch lower := strings.ToLower(ext); {
+	case format == "jpeg" && (lower == ".jpg" || lower == ".jpeg"), lower == "."+format:
+	default:
+		ext = "." + format
+	}
+	return base + cleanedSuffix + ext
+}
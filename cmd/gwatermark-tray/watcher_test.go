@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+func copyFile(t *testing.T, src, dst string) {
+	t.Helper()
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	copyFile(t, "../../testdata/watermarked.webp", filepath.Join(dir, "old.webp"))
+
+	w := newWatcher(dir, watermark.Options{Output: watermark.OutputSource})
+	if err := w.prime(); err != nil {
+		t.Fatal(err)
+	}
+	var cleaned []string
+	w.onClean = func(in, out string, r watermark.Result) { cleaned = append(cleaned, filepath.Base(out)) }
+	w.onError = func(path string, err error) { t.Errorf("%s: %v", path, err) }
+
+	copyFile(t, "../../testdata/watermarked.webp", filepath.Join(dir, "new.webp"))
+	copyFile(t, "../../testdata/clean.webp", filepath.Join(dir, "clean.webp"))
+	copyFile(t, "../../testdata/watermarked.webp", filepath.Join(dir, "partial.webp.crdownload"))
+
+	// The first scan only sees the new files; the second cleans those that
+	// held still.
+	for i := 0; i < 3; i++ {
+		if err := w.scan(); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 && len(cleaned) != 0 {
+			t.Fatalf("cleaned %v before the files held still", cleaned)
+		}
+	}
+	if len(cleaned) != 1 || cleaned[0] != "new_unwatermarked.webp" {
+		t.Fatalf("cleaned %v, want only new_unwatermarked.webp", cleaned)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old_unwatermarked.webp")); !os.IsNotExist(err) {
+		t.Error("an image present at startup was cleaned")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "clean_unwatermarked.webp")); !os.IsNotExist(err) {
+		t.Error("an image without a watermark got an output")
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	for _, tt := range []struct{ in, format, want string }{
+		{"a/photo.jpg", "jpeg", "a/photo_unwatermarked.jpg"},
+		{"a/photo.JPEG", "jpeg", "a/photo_unwatermarked.JPEG"},
+		{"a/photo.jpg", "png", "a/photo_unwatermarked.png"},
+		{"a/photo.webp", "webp", "a/photo_unwatermarked.webp"},
+	} {
+		if got := outputPath(tt.in, tt.format); got != tt.want {
+			t.Errorf("outputPath(%q, %q) = %q, want %q", tt.in, tt.format, got, tt.want)
+		}
+	}
+	if candidate("photo_unwatermarked.png") || candidate("photo.png.part") || !candidate("Photo.PNG") {
+		t.Error("candidate misclassifies outputs, partial downloads or upper-case extensions")
+	}
+}
+
+func TestXDGDownloadDir(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "user-dirs.dirs")
+	content := "# comment\nXDG_DESKTOP_DIR=\"$HOME/Desktop\"\nXDG_DOWNLOAD_DIR=\"$HOME/Téléchargements\"\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := xdgDownloadDir(path, "/home/u"); got != "/home/u/Téléchargements" {
+		t.Errorf("xdgDownloadDir = %q", got)
+	}
+	if got := xdgDownloadDir(filepath.Join(t.TempDir(), "missing"), "/home/u"); got != "" {
+		t.Errorf("missing file gave %q", got)
+	}
+}
+
+func TestPNGToICO(t *testing.T) {
+	ico := pngToICO(iconPNG, iconSize)
+	if len(ico) != 22+len(iconPNG) || ico[2] != 1 || ico[4] != 1 || ico[6] != iconSize {
+		t.Errorf("ICO header % x", ico[:22])
+	}
+}
+
+func TestWatcherForgetsRemovedFiles(t *testing.T) {
+	dir := t.TempDir()
+	copyFile(t, "../../testdata/clean.webp", filepath.Join(dir, "old.webp"))
+
+	w := newWatcher(dir, watermark.Options{Output: watermark.OutputSource})
+	if err := w.prime(); err != nil {
+		t.Fatal(err)
+	}
+	copyFile(t, "../../testdata/clean.webp", filepath.Join(dir, "a.webp"))
+	for i := 0; i < 2; i++ {
+		if err := w.scan(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// a.webp has been handled and b.webp is still waiting to hold still
+	// when every file goes away.
+	copyFile(t, "../../testdata/clean.webp", filepath.Join(dir, "b.webp"))
+	if err := w.scan(); err != nil {
+		t.Fatal(err)
+	}
+	if !w.done["a.webp"] || w.pending["b.webp"] == (fileStamp{}) {
+		t.Fatalf("done %v, pending %v before the removal", w.done, w.pending)
+	}
+	for _, name := range []string{"old.webp", "a.webp", "b.webp"} {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.scan(); err != nil {
+		t.Fatal(err)
+	}
+	if len(w.done) != 0 || len(w.pending) != 0 {
+		t.Fatalf("state kept for removed files: done %v, pending %v", w.done, w.pending)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/gcslaoli/gemini-watermark-remover-go/video"
+)
+
+// go run . -in clip.mp4 -out clip_clean.mp4
+// go run . -in clip.mp4 -nvenc -cq 23
+// go run . -in clip.mp4 -codec libx265 -crf 22 -preset slow
+// go run . -in clip.mp4 -tmpdir /scratch
+// go run . -in clip.mp4 -region
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	input := flag.String("in", "", "Path to the watermarked video")
+	output := flag.String("out", "", "Output path (defaults to <name>_unwatermarked.<ext>)")
+	ffmpeg := flag.String("ffmpeg", "ffmpeg", "ffmpeg binary")
+	ffprobe := flag.String("ffprobe", "ffprobe", "ffprobe binary")
+	codec := flag.String("codec", "libx264", "Video encoder passed to ffmpeg -c:v")
+	crf := flag.Int("crf", 18, "Constant rate factor for libx264/libx265")
+	preset := flag.String("preset", "medium", "Encoder preset")
+	nvenc := flag.Bool("nvenc", false, "Decode with CUDA and encode with h264_nvenc (overrides -codec)")
+	cq := flag.Int("cq", 23, "Constant quality for NVENC encoders")
+	region := flag.Bool("region", false, "Clean only the watermark corner of each frame and overlay it on the original (the video is still re-encoded)")
+	tmpDir := flag.String("tmpdir", "", "Directory for the intermediate encode (defaults to the system temp dir)")
+	flag.Parse()
+
+	if *input == "" {
+		flag.Usage()
+		return errors.New("-in is required")
+	}
+
+	outPath := *output
+	if outPath == "" {
+		ext := filepath.Ext(*input)
+		outPath = strings.TrimSuffix(*input, ext) + "_unwatermarked" + ext
+	}
+
+	p := video.Pipeline{Input: *input, FFmpeg: *ffmpeg, VideoCodec: *codec, Region: *region}
+	switch {
+	case *nvenc:
+		p.HWAccel = "cuda"
+		p.VideoCodec = "h264_nvenc"
+		p.CodecArgs = []string{"-preset", "p5", "-rc", "vbr", "-cq", fmt.Sprint(*cq), "-pix_fmt", "yuv420p"}
+	case strings.Contains(*codec, "nvenc"):
+		p.CodecArgs = []string{"-rc", "vbr", "-cq", fmt.Sprint(*cq), "-pix_fmt", "yuv420p"}
+	case *codec == "libx264" || *codec == "libx265":
+		p.CodecArgs = []string{"-crf", fmt.Sprint(*crf), "-preset", *preset, "-pix_fmt", "yuv420p"}
+	}
+
+	// Cancel on SIGINT/SIGTERM so ffmpeg is stopped and the deferred
+	// cleanup below still runs.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := p.Probe(ctx, *ffprobe); err != nil {
+		return fmt.Errorf("probe input: %w", err)
+	}
+
+	// Encode into a private work directory and move the finished file into
+	// place, so an interrupted run never leaves a truncated output behind.
+	work, err := os.MkdirTemp(*tmpDir, "gwatermark-video-")
+	if err != nil {
+		return fmt.Errorf("create work dir: %w", err)
+	}
+	defer os.RemoveAll(work)
+
+	p.Output = filepath.Join(work, "clean"+filepath.Ext(outPath))
+
+	stats, err := video.Run(ctx, p)
+	if err != nil {
+		return fmt.Errorf("process video: %w", err)
+	}
+
+	if err := moveFile(p.Output, outPath); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+
+	fmt.Printf("Processed %s -> %s [%dx%d, %d frames, %d cleaned, codec %s]\n",
+		*input, outPath, p.Width, p.Height, stats.Frames, stats.Cleaned, p.VideoCodec)
+	return nil
+}
+
+// moveFile renames src to dst, falling back to copy-and-remove when they
+// live on different filesystems.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	return os.Remove(src)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMoveFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "work", "clean.mp4")
+	dst := filepath.Join(dir, "out.mp4")
+
+	if err := os.MkdirAll(filepath.Dir(src), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(src, []byte("frames"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if err := moveFile(src, dst); err != nil {
+		t.Fatalf("moveFile: %v", err)
+	}
+
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Fatalf("source still present after move: %v", err)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil || string(got) != "frames" {
+		t.Fatalf("unexpected destination content %q (%v)", got, err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// outputTarget is where the single-image mode writes its result.
+type outputTarget struct {
+	// Path is the -out path; empty means the default next to the input.
+	Path   string
+	Base64 bool
+	// Stdout writes the raw image to Writer.
+	Stdout bool
+	Writer io.Writer
+}
+
+// runAnimation cleans an animated WebP with ProcessBytes, which keeps every
+// frame, and writes it as WebP. Like single images, animations without a
+// watermark are skipped, or passed through when writing to stdout.
+func runAnimation(data []byte, opts watermark.Options, input, source string, out outputTarget) error {
+	// The growth limit is enforced below, with the same report as for single
+	// images.
+	limit := opts.MaxGrowth
+	opts.MaxGrowth = 0
+	opts.PassThrough = out.Stdout
+	result, err := watermark.ProcessBytes(data, opts)
+	if err != nil {
+		return fmt.Errorf("process animation: %w", err)
+	}
+	fmt.Printf("Animated WebP: %d frames, watermark found in %d.\n", len(result.Frames), countPresent(result.Frames))
+	for _, fr := range result.Frames {
+		if fr.Err != nil {
+			fmt.Fprintf(os.Stderr, "warning: frame %d %v: %v\n", fr.Index, fr.Outcome, fr.Err)
+		}
+	}
+	if result.Present && !checkGrowth(len(data), len(result.Output), limit) {
+		return fmt.Errorf("output exceeds -max-growth")
+	}
+
+	switch {
+	case out.Stdout:
+		if _, err := out.Writer.Write(result.Output); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+		fmt.Printf("Processed %s (animated webp) -> stdout\n", source)
+		return nil
+	case !result.Present:
+		fmt.Printf("No visible Gemini watermark detected (score %.2f). Skipping removal.\n", result.Score)
+		return nil
+	case out.Base64:
+		fmt.Println(base64.StdEncoding.EncodeToString(result.Output))
+		fmt.Printf("Processed %s (animated webp) -> base64\n", source)
+		return nil
+	}
+
+	outPath := out.Path
+	if outPath == "" {
+		outPath = defaultOutputPath(input, ".webp")
+	}
+	if err := checkFreeSpace(outPath, uint64(len(result.Output))); err != nil {
+		return fmt.Errorf("preflight: %w", err)
+	}
+	if err := os.WriteFile(outPath, result.Output, 0o644); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	fmt.Printf("Processed %s (animated webp) -> %s [watermark %dx%d at %v]\n", source, outPath, result.Info.Size, result.Info.Size, result.Info.Position)
+	return nil
+}
+
+func countPresent(frames []watermark.FrameResult) int {
+	n := 0
+	for _, fr := range frames {
+		if fr.Present {
+			n++
+		}
+	}
+	return n
+}
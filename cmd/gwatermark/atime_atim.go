@@ -0,0 +1,18 @@
+//go:build linux || openbsd || dragonfly || solaris
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// accessTime returns the last access time, falling back to the
+// modification time when the platform data is unavailable.
+func accessTime(info os.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(int64(st.Atim.Sec), int64(st.Atim.Nsec))
+	}
+	return info.ModTime()
+}
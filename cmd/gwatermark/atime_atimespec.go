@@ -0,0 +1,18 @@
+//go:build darwin || freebsd || netbsd
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// accessTime returns the last access time, falling back to the
+// modification time when the platform data is unavailable.
+func accessTime(info os.FileInfo) time.Time {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return time.Unix(int64(st.Atimespec.Sec), int64(st.Atimespec.Nsec))
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,14 @@
+//go:build !(linux || openbsd || dragonfly || solaris || darwin || freebsd || netbsd || windows)
+
+package main
+
+import (
+	"os"
+	"time"
+)
+
+// accessTime returns the modification time; this platform exposes no
+// access time.
+func accessTime(info os.FileInfo) time.Time {
+	return info.ModTime()
+}
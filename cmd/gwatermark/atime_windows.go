@@ -0,0 +1,16 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// accessTime returns the last access time, falling back to the
+// modification time when the platform data is unavailable.
+func accessTime(info os.FileInfo) time.Time {
+	if d, ok := info.Sys().(*syscall.Win32FileAttributeData); ok {
+		return time.Unix(0, d.LastAccessTime.Nanoseconds())
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// batchConfig holds the settings of -dir mode.
+type batchConfig struct {
+	Dir string
+	// OutDir receives the mirrored tree. Empty means <Dir>_unwatermarked.
+	OutDir   string
+	Walk     walkOptions
+	Options  watermark.Options
+	Preserve preserveOptions
+	// Sidecars copies XMP sidecars next to each output; see copySidecars.
+	Sidecars bool
+	// Thumb, when positive, writes a thumbnail next to each output; see
+	// thumbPath.
+	Thumb int
+	// Settings fingerprints the options that affect outputs, for the rerun
+	// manifest. Force ignores the manifest and processes every input.
+	Settings string
+	Force    bool
+	// Progress draws a progressBar on stderr while the run goes.
+	Progress bool
+	// Engine configures the engine a run with Progress installs as Default
+	// while it goes, so the bar follows that engine alone.
+	Engine []watermark.Option
+	// Rules are evaluated against each input before it is cleaned; see
+	// parseRules.
+	Rules []batchRule
+	// ImageTimeout fails an input whose decoding and cleaning take longer,
+	// so a malformed file cannot hang the run; see watermark.Watchdog.
+	ImageTimeout time.Duration
+}
+
+// batchSummary counts the outcome of every file seen by runBatch.
+type batchSummary struct {
+	Processed int
+	// UpToDate counts inputs unchanged since a previous run, whose outputs
+	// were left untouched.
+	UpToDate int
+	Skipped  int
+	Failed   int
+}
+
+// runBatch cleans every image under cfg.Dir into cfg.OutDir, keeping the
+// relative layout and replacing each extension with the output format.
+// Files without a watermark are skipped; failures are reported and do not
+// stop the run. It prints a summary and statistics and returns false when
+// anything failed.
+func runBatch(cfg batchConfig) bool {
+	if cfg.OutDir == "" {
+		cfg.OutDir = defaultOutDir(cfg.Dir)
+	}
+	summary, stats, err := processDir(cfg)
+	fmt.Printf("Processed %d, up to date %d, skipped %d, failed %d.\n", summary.Processed, summary.UpToDate, summary.Skipped, summary.Failed)
+	runSummary.Input, runSummary.Output = cfg.Dir, cfg.OutDir
+	runSummary.Processed, runSummary.UpToDate, runSummary.Skipped, runSummary.Failed = summary.Processed, summary.UpToDate, summary.Skipped, summary.Failed
+	if stats != nil {
+		fmt.Println(stats)
+		runSummary.Detected = stats.Detected
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "batch: %v\n", err)
+		return false
+	}
+	return summary.Failed == 0
+}
+
+// defaultOutDir is the output directory of a batch over dir without -out.
+func defaultOutDir(dir string) string {
+	return filepath.Clean(dir) + "_unwatermarked"
+}
+
+// processDir runs the batch and returns its statistics, which are nil when
+// it failed before processing any file.
+func processDir(cfg batchConfig) (batchSummary, *batchStats, error) {
+	start := time.Now()
+	var summary batchSummary
+	if cfg.OutDir == "" {
+		cfg.OutDir = defaultOutDir(cfg.Dir)
+	}
+
+	// Collect the whole tree first, so the preflight can size it and the
+	// outputs written below are never walked themselves.
+	var entries []walkEntry
+	var inputBytes uint64
+	err := walkImages(cfg.Dir, cfg.Walk, func(e walkEntry) error {
+		entries = append(entries, e)
+		if e.Skip == "" && e.LinkOf == "" {
+			if info, err := os.Stat(e.Path); err == nil {
+				inputBytes += uint64(info.Size())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return summary, nil, err
+	}
+
+	if err := os.MkdirAll(cfg.OutDir, 0o755); err != nil {
+		return summary, nil, err
+	}
+	// Outputs are usually at least as large as the inputs, since lossy
+	// inputs are re-encoded losslessly by default.
+	if err := checkFreeSpaceIn(cfg.OutDir, inputBytes); err != nil {
+		return summary, nil, err
+	}
+
+	manifest := loadManifest(cfg.OutDir)
+	if cfg.Force || manifest.Settings != cfg.Settings {
+		manifest = &runManifest{Settings: cfg.Settings, Files: make(map[string]manifestRecord)}
+	}
+
+	var bar *progressBar
+	if cfg.Progress {
+		bar = newProgressBar(os.Stderr, len(entries))
+		defer bar.clear()
+		prev := watermark.Default()
+		watermark.SetDefaultEngine(watermark.NewEngine(append(cfg.Engine, watermark.WithProgress(bar.progress))...))
+		defer watermark.SetDefaultEngine(prev)
+	}
+
+	// outputs maps each input's Rel to its written output, for hard links;
+	// written catches two inputs mapping to one output, like a.jpg and a.png.
+	outputs := make(map[string]string)
+	written := make(map[string]string)
+	stats := &batchStats{}
+	for i, e := range entries {
+		bar.startFile(i, e.Rel)
+		if e.Skip != "" {
+			bar.printf(os.Stdout, "skip %s: %s\n", e.Rel, e.Skip)
+			summary.Skipped++
+			continue
+		}
+
+		if e.LinkOf != "" {
+			first, ok := outputs[e.LinkOf]
+			if !ok {
+				bar.printf(os.Stdout, "skip %s: same file as %s\n", e.Rel, e.LinkOf)
+				summary.Skipped++
+				continue
+			}
+			out := filepath.Join(cfg.OutDir, strings.TrimSuffix(e.Rel, filepath.Ext(e.Rel))+filepath.Ext(first))
+			err := linkOutput(first, out, e.Rel, written)
+			if err == nil && cfg.Thumb > 0 {
+				err = linkOutput(thumbPath(first), thumbPath(out), e.Rel, written)
+			}
+			if err == nil && cfg.Options.Correction {
+				err = linkOutput(correctionPath(first), correctionPath(out), e.Rel, written)
+			}
+			if err == nil && cfg.Sidecars {
+				_, err = copySidecars(e.Path, out)
+			}
+			if err != nil {
+				bar.printf(os.Stderr, "fail %s: %v\n", e.Rel, err)
+				summary.Failed++
+				continue
+			}
+			bar.printf(os.Stdout, "link %s -> %s\n", e.Rel, out)
+			summary.Processed++
+			continue
+		}
+
+		out, cached, err := cleanFile(cfg, e, written, manifest, stats, bar)
+		var skip *ruleSkipError
+		switch {
+		case errors.As(err, &skip):
+			bar.printf(os.Stdout, "skip %s: %v\n", e.Rel, skip)
+			summary.Skipped++
+		case err != nil:
+			bar.printf(os.Stderr, "fail %s: %v\n", e.Rel, err)
+			summary.Failed++
+		case cached:
+			if out != "" {
+				outputs[e.Rel] = out
+			}
+			summary.UpToDate++
+		case out == "":
+			bar.printf(os.Stdout, "skip %s: no watermark\n", e.Rel)
+			summary.Skipped++
+		default:
+			bar.printf(os.Stdout, "done %s -> %s\n", e.Rel, out)
+			outputs[e.Rel] = out
+			summary.Processed++
+		}
+	}
+	stats.finish(time.Since(start))
+	manifest.Summary = stats
+	return summary, stats, manifest.save(cfg.OutDir)
+}
+
+// cleanFile processes one entry and returns its output path, or "" when no
+// watermark was found. Inputs the manifest lists as unchanged are neither
+// processed nor re-encoded, keeping outputs byte-stable across runs; cached
+// reports that case. Inputs a skip rule matches fail with a *ruleSkipError.
+// Decoded images are counted in stats, and warnings are printed above bar.
+func cleanFile(cfg batchConfig, e walkEntry, written map[string]string, manifest *runManifest, stats *batchStats, bar *progressBar) (out string, cached bool, err error) {
+	data, err := os.ReadFile(e.Path)
+	if err != nil {
+		return "", false, err
+	}
+
+	inputHash := hashBytes(data)
+	if out, ok := manifest.upToDate(cfg.Settings, cfg.OutDir, e.Rel, inputHash); ok {
+		if out != "" {
+			if err := claimOutput(out, e.Rel, written); err != nil {
+				return "", false, err
+			}
+		}
+		return out, true, nil
+	}
+
+	var result watermark.Result
+	var skip *batchRule
+	err = watermark.Watchdog(context.Background(), cfg.ImageTimeout, func(ctx context.Context) error {
+		opts := cfg.Options
+		opts.Thumbnail = cfg.Thumb
+		if len(cfg.Rules) > 0 {
+			profile := watermark.GeminiProfile()
+			if opts.Profile != nil {
+				profile = *opts.Profile
+			}
+			report, err := watermark.InspectBytesProfile(data, profile)
+			if err != nil {
+				return err
+			}
+			skip, err = matchRules(cfg.Rules, newRuleEnv(e.Rel, data, report), &opts)
+			if err != nil || skip != nil {
+				return err
+			}
+		}
+		r, err := watermark.ProcessBytesContext(ctx, data, opts)
+		result = r
+		return err
+	})
+	if err != nil {
+		return "", false, err
+	}
+	if skip != nil {
+		return "", false, &ruleSkipError{rule: skip.Text}
+	}
+	stats.add(result)
+	if !result.Present {
+		return "", false, manifest.record(cfg.OutDir, e.Rel, inputHash, "", nil)
+	}
+	for _, w := range result.Warnings {
+		bar.printf(os.Stderr, "warning %s: %v\n", e.Rel, w)
+	}
+
+	out = filepath.Join(cfg.OutDir, strings.TrimSuffix(e.Rel, filepath.Ext(e.Rel))+outputExt(result.Format, e.Rel))
+	if err := claimOutput(out, e.Rel, written); err != nil {
+		return "", false, err
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+		return "", false, err
+	}
+	if err := os.WriteFile(out, result.Output, 0o644); err != nil {
+		return "", false, err
+	}
+	if result.Thumbnail != nil {
+		thumb := thumbPath(out)
+		if err := claimOutput(thumb, e.Rel, written); err != nil {
+			return "", false, err
+		}
+		if err := os.WriteFile(thumb, result.Thumbnail, 0o644); err != nil {
+			return "", false, err
+		}
+	}
+	if result.Correction != nil {
+		layer := correctionPath(out)
+		if err := claimOutput(layer, e.Rel, written); err != nil {
+			return "", false, err
+		}
+		if err := os.WriteFile(layer, result.Correction, 0o644); err != nil {
+			return "", false, err
+		}
+	}
+	if err := cfg.Preserve.apply(e.Path, out); err != nil {
+		return "", false, fmt.Errorf("preserve attributes: %w", err)
+	}
+	if cfg.Sidecars {
+		if _, err := copySidecars(e.Path, out); err != nil {
+			return "", false, err
+		}
+	}
+	return out, false, manifest.record(cfg.OutDir, e.Rel, inputHash, out, result.Output)
+}
+
+// linkOutput hard-links an existing output to out.
+func linkOutput(existing, out, rel string, written map[string]string) error {
+	if err := claimOutput(out, rel, written); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+		return err
+	}
+	// Replace the output of an earlier run, which os.Link refuses to.
+	if err := os.Remove(out); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Link(existing, out)
+}
+
+// claimOutput records that rel writes out, failing if another input in this
+// run already did.
+func claimOutput(out, rel string, written map[string]string) error {
+	if other, ok := written[out]; ok {
+		return fmt.Errorf("output %s already written for %s", out, other)
+	}
+	written[out] = rel
+	return nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	"github.com/gcslaoli/gemini-watermark-remover-go/testutil"
+)
+
+func TestProcessDir(t *testing.T) {
+	watermarked := testutil.WatermarkedPNG(t)
+	clean := testutil.CleanJPEG(t)
+
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"a.png":       watermarked,
+		"clean.jpg":   clean,
+		"notes.txt":   []byte("not an image"),
+		"broken.png":  []byte("not a png"),
+		"sub/b.png":   watermarked,
+		"sub/c.jpeg2": watermarked,
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, "in", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out := filepath.Join(dir, "out")
+	cfg := batchConfig{Dir: filepath.Join(dir, "in"), OutDir: out}
+
+	summary, stats, err := processDir(cfg)
+	if err != nil {
+		t.Fatalf("processDir: %v", err)
+	}
+	if want := (batchSummary{Processed: 1, Skipped: 1, Failed: 1}); summary != want {
+		t.Fatalf("flat summary = %+v, want %+v", summary, want)
+	}
+	// broken.png fails to decode and is not examined.
+	if stats.Examined != 2 || stats.Detected != 1 || stats.DetectionRate != 0.5 {
+		t.Errorf("stats = %+v, want 1 of 2 detected", stats)
+	}
+	if stats.InputBytes != int64(len(watermarked)) || stats.BytesDelta != stats.OutputBytes-stats.InputBytes {
+		t.Errorf("stats bytes = %d in, %d out, %d delta", stats.InputBytes, stats.OutputBytes, stats.BytesDelta)
+	}
+	if m := loadManifest(out); m.Summary == nil || m.Summary.Detected != 1 {
+		t.Errorf("manifest summary = %+v", m.Summary)
+	}
+
+	cfg.Walk.Recursive = true
+	summary, _, err = processDir(cfg)
+	if err != nil {
+		t.Fatalf("processDir recursive: %v", err)
+	}
+	// a.png and clean.jpg are unchanged since the first run.
+	if want := (batchSummary{Processed: 1, UpToDate: 2, Failed: 1}); summary != want {
+		t.Fatalf("recursive summary = %+v, want %+v", summary, want)
+	}
+
+	stamp := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(out, "a.png"), stamp, stamp); err != nil {
+		t.Fatal(err)
+	}
+	summary, _, err = processDir(cfg)
+	if err != nil {
+		t.Fatalf("processDir rerun: %v", err)
+	}
+	if want := (batchSummary{UpToDate: 3, Failed: 1}); summary != want {
+		t.Fatalf("rerun summary = %+v, want %+v", summary, want)
+	}
+	if info, err := os.Stat(filepath.Join(out, "a.png")); err != nil || !info.ModTime().Equal(stamp) {
+		t.Fatalf("up-to-date output was rewritten")
+	}
+
+	cfg.Force = true
+	summary, _, err = processDir(cfg)
+	if err != nil {
+		t.Fatalf("processDir force: %v", err)
+	}
+	if want := (batchSummary{Processed: 2, Skipped: 1, Failed: 1}); summary != want {
+		t.Fatalf("forced summary = %+v, want %+v", summary, want)
+	}
+
+	for _, name := range []string{"a.png", "sub/b.png"} {
+		if _, err := os.Stat(filepath.Join(out, name)); err != nil {
+			t.Errorf("missing output %s: %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(out, "clean.png")); !os.IsNotExist(err) {
+		t.Errorf("clean input produced an output")
+	}
+}
+
+// spinMagic starts inputs of a test-only image format whose decoder hangs,
+// like one stuck on a malformed file, until the test holding it ends.
+const spinMagic = "SPIN!!"
+
+var spin struct {
+	sync.Mutex
+	release chan struct{}
+}
+
+func init() {
+	image.RegisterFormat("spin", spinMagic, func(io.Reader) (image.Image, error) {
+		spin.Lock()
+		release := spin.release
+		spin.Unlock()
+		if release != nil {
+			<-release
+		}
+		return nil, errors.New("spin decoder released")
+	}, func(io.Reader) (image.Config, error) {
+		return image.Config{Width: 1, Height: 1}, nil
+	})
+}
+
+// holdSpin makes spin decoders hang until t ends, so abandoned decodes
+// don't outlive the test.
+func holdSpin(t *testing.T) {
+	release := make(chan struct{})
+	spin.Lock()
+	spin.release = release
+	spin.Unlock()
+	t.Cleanup(func() { close(release) })
+}
+
+func TestProcessDirImageTimeout(t *testing.T) {
+	holdSpin(t)
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in")
+	if err := os.MkdirAll(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// A small fixture keeps the healthy file well inside the timeout, even
+	// under the race detector.
+	healthy := testutil.Fixture{Width: 256, Height: 256, Background: testutil.Gradient, Watermark: true}.Bytes(t)
+	files := map[string][]byte{"a.png": healthy, "stuck.png": []byte(spinMagic)}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(in, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// With Progress the run reports to an engine of its own, leaving the
+	// Default engine alone for the decode it abandons.
+	engine := watermark.Default()
+	start := time.Now()
+	summary, _, err := processDir(batchConfig{Dir: in, OutDir: filepath.Join(dir, "out"), ImageTimeout: 5 * time.Second, Progress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (batchSummary{Processed: 1, Failed: 1}); summary != want {
+		t.Fatalf("summary = %+v, want %+v", summary, want)
+	}
+	if took := time.Since(start); took > 30*time.Second {
+		t.Errorf("run took %v", took)
+	}
+	if watermark.Default() != engine {
+		t.Error("Default engine not restored after the run")
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// runConvert implements "gwatermark convert": it re-encodes an image with
+// the output options of the main command, without detecting or removing
+// anything, so pipelines can use one binary for both steps.
+func runConvert(args []string) error {
+	fs := flag.NewFlagSet("convert", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gwatermark convert -in INPUT [-out OUTPUT] [-format png|jpeg|webp|source] [options]")
+		fs.PrintDefaults()
+	}
+	input := fs.String("in", "", "Path or http(s) URL of the image (png/jpg/webp/gif, first frame only), or - for stdin")
+	output := fs.String("out", "", "Output path, or - for stdout (defaults to <name>.<format ext> beside the input; stdout for -in -)")
+	formatName := fs.String("format", "png", "Output format: png, jpeg, webp, or source to keep JPEG and WebP inputs in their format")
+	quality := fs.Int("quality", watermark.DefaultJPEGQuality, "JPEG and lossy WebP output quality (1-100)")
+	lossy := fs.Bool("lossy", false, "With -format webp, encode lossy WebP at -quality instead of lossless")
+	resize := fs.String("resize", "", "Fit the image inside WIDTHxHEIGHT (Lanczos, aspect ratio kept; 1024x or x768 constrain one side)")
+	maxDim := fs.Int("max-dim", 0, "Shrink the image so the longer side is at most this many pixels (0 keeps the size)")
+	web := fs.Bool("web", false, "Delivery preset for publishing: lossy WebP at quality 82, no EXIF/XMP, longer side at most 2048 (explicit flags win)")
+	stripMetadata := fs.Bool("strip-metadata", false, "Drop the input's EXIF and XMP instead of copying them (color profiles are always kept)")
+	retries := fs.Int("retries", 2, "Retry URL inputs this many times on network errors, timeouts and 5xx/429 responses")
+	netTimeout := fs.Duration("timeout", 30*time.Second, "Timeout of each attempt to download a URL input (0 for none)")
+	offline := fs.Bool("offline", false, "Disable every network-touching feature (URL input)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if *web {
+		if err := applyWebPreset(fs); err != nil {
+			return fmt.Errorf("-web: %w", err)
+		}
+	}
+	if *input == "" || fs.NArg() > 0 {
+		fs.Usage()
+		return errors.New("convert needs -in and no positional arguments")
+	}
+
+	watermark.SetOffline(*offline)
+
+	outFormat, ok := watermark.ParseOutputFormat(*formatName)
+	if !ok {
+		return fmt.Errorf("unknown output format %q", *formatName)
+	}
+	resizeTo, err := parseResize(*resize, *maxDim)
+	if err != nil {
+		return err
+	}
+	opts := watermark.Options{
+		Output:        outFormat,
+		JPEGQuality:   *quality,
+		WebPLossy:     *lossy,
+		WebPQuality:   *quality,
+		StripMetadata: *stripMetadata,
+		Resize:        resizeTo,
+	}
+	retry := watermark.RetryPolicy{Attempts: max(*retries, 0) + 1, Backoff: 500 * time.Millisecond, Timeout: *netTimeout}
+
+	data, err := readInputBytes(*input, "", retry)
+	if err != nil {
+		return fmt.Errorf("read input: %w", err)
+	}
+	img, format, err := watermark.DecodeImageBytes(data)
+	if err != nil {
+		return fmt.Errorf("decode input: %w", err)
+	}
+	encoded, encoding, err := opts.Encode(img, data, format)
+	if err != nil {
+		return fmt.Errorf("encode output: %w", err)
+	}
+	for _, w := range opts.MetadataWarnings(data, encoded) {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", w)
+	}
+
+	source := *input
+	if source == "-" {
+		source = "stdin"
+	}
+	if *output == "-" || *output == "" && *input == "-" {
+		if _, err := os.Stdout.Write(encoded); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Converted %s (%s) -> stdout (%s)\n", source, format, encoding)
+		return nil
+	}
+
+	outPath := *output
+	if outPath == "" {
+		outPath = convertOutputPath(*input, outputExt(encoding, *input))
+		if outPath == *input {
+			return fmt.Errorf("output %s would overwrite the input; pass -out", outPath)
+		}
+	}
+	if err := checkFreeSpace(outPath, uint64(len(encoded))); err != nil {
+		return fmt.Errorf("preflight: %w", err)
+	}
+	if err := os.WriteFile(outPath, encoded, 0o644); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	fmt.Printf("Converted %s (%s) -> %s (%s)\n", source, format, outPath, encoding)
+	return nil
+}
+
+// convertOutputPath returns the input path, or the base name of a URL, with
+// its extension replaced by ext.
+func convertOutputPath(input, ext string) string {
+	if watermark.IsURL(input) {
+		name := "output"
+		if u, err := url.Parse(input); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+			name = strings.TrimSuffix(path.Base(u.Path), path.Ext(u.Path))
+		}
+		return name + ext
+	}
+	return strings.TrimSuffix(input, filepath.Ext(input)) + ext
+}
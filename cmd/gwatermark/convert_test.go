@@ -0,0 +1,91 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	"github.com/gcslaoli/gemini-watermark-remover-go/testutil"
+)
+
+func TestRunConvert(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "photo.jpg")
+	data := testutil.Fixture{Width: 1024, Height: 1024, Background: testutil.Texture, Watermark: true, JPEGQuality: 90}.Bytes(t)
+	if err := os.WriteFile(in, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runConvert([]string{"-in", in, "-format", "webp"}); err != nil {
+		t.Fatalf("convert: %v", err)
+	}
+	out, err := os.ReadFile(filepath.Join(dir, "photo.webp"))
+	if err != nil {
+		t.Fatalf("default output: %v", err)
+	}
+	img, format, err := watermark.DecodeImageBytes(out)
+	if err != nil || format != "webp" {
+		t.Fatalf("output decodes as %q: %v", format, err)
+	}
+	src, _, err := watermark.DecodeImageBytes(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != src.Bounds() {
+		t.Fatalf("bounds %v, want %v", img.Bounds(), src.Bounds())
+	}
+
+	if err := runConvert([]string{"-in", in, "-out", filepath.Join(dir, "small.png"), "-max-dim", "100"}); err != nil {
+		t.Fatalf("convert -max-dim: %v", err)
+	}
+	small, err := readImage(filepath.Join(dir, "small.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := small.Bounds().Size(); max(got.X, got.Y) != 100 {
+		t.Fatalf("-max-dim 100 gave %v", got)
+	}
+
+	if err := runConvert([]string{"-in", in, "-format", "source"}); err == nil {
+		t.Fatalf("expected refusal to overwrite the input")
+	}
+	if err := runConvert([]string{"-in", in, "-format", "bmp"}); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
+
+func TestConvertOutputPath(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"a/b.jpg", "a/b.png"},
+		{"https://example.com/x/pic.webp?s=1", "pic.png"},
+		{"https://example.com/", "output.png"},
+	} {
+		if got := convertOutputPath(tc.in, ".png"); got != tc.want {
+			t.Errorf("convertOutputPath(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func TestParseResize(t *testing.T) {
+	for _, tc := range []struct {
+		box    string
+		maxDim int
+		want   watermark.Resize
+		ok     bool
+	}{
+		{"", 0, watermark.Resize{}, true},
+		{"1024x768", 0, watermark.Resize{Width: 1024, Height: 768}, true},
+		{"1024X", 2048, watermark.Resize{Width: 1024, MaxDim: 2048}, true},
+		{"x768", 0, watermark.Resize{Height: 768}, true},
+		{"x", 0, watermark.Resize{}, false},
+		{"1024", 0, watermark.Resize{}, false},
+		{"0x10", 0, watermark.Resize{}, false},
+		{"", -1, watermark.Resize{}, false},
+	} {
+		got, err := parseResize(tc.box, tc.maxDim)
+		if (err == nil) != tc.ok || got != tc.want {
+			t.Errorf("parseResize(%q, %d) = %+v, %v", tc.box, tc.maxDim, got, err)
+		}
+	}
+}
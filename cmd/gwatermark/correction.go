@@ -0,0 +1,31 @@
+package main
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// correctionPath returns the correction layer path of output: photo.jpg gets
+// photo_correction.png, in the same directory. The layer is always PNG.
+func correctionPath(output string) string {
+	return strings.TrimSuffix(output, filepath.Ext(output)) + "_correction.png"
+}
+
+// writeCorrection writes the watermark.CorrectionLayer from original to
+// cleaned to correctionPath(output) and returns the path written.
+func writeCorrection(output string, original, cleaned image.Image) (string, error) {
+	layer, err := watermark.CorrectionLayer(original, cleaned)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := watermark.EncodePNGToBytes(layer)
+	if err != nil {
+		return "", err
+	}
+	name := correctionPath(output)
+	return name, os.WriteFile(name, encoded, 0o644)
+}
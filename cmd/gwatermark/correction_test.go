@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	"github.com/gcslaoli/gemini-watermark-remover-go/imagecmp"
+	"github.com/gcslaoli/gemini-watermark-remover-go/testutil"
+)
+
+func TestProcessDirCorrection(t *testing.T) {
+	watermarked := testutil.WatermarkedPNG(t)
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in")
+	if err := os.MkdirAll(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(in, "a.png"), watermarked, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "out")
+	cfg := batchConfig{Dir: in, OutDir: out, Options: watermark.Options{Correction: true}}
+	if _, _, err := processDir(cfg); err != nil {
+		t.Fatalf("processDir: %v", err)
+	}
+	if got := correctionPath(filepath.Join(out, "a.png")); got != filepath.Join(out, "a_correction.png") {
+		t.Fatalf("correctionPath = %q", got)
+	}
+	layer, err := readImage(filepath.Join(out, "a_correction.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleaned, err := readImage(filepath.Join(out, "a.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, _, err := watermark.DecodeImageBytes(watermarked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := watermark.UndoCorrection(cleaned, layer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := imagecmp.Diff(original, restored, imagecmp.Options{}); err != nil {
+		t.Fatalf("original not reconstructed: %v", err)
+	}
+}
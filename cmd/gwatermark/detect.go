@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"os"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// runDetect prints an inspection report for the input without removing
+// anything, so misses can be triaged from the decoded geometry.
+func runDetect(input, inputBase64 string, profile watermark.Profile, retry watermark.RetryPolicy) {
+	data, err := readInputBytes(input, inputBase64, retry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read input: %v\n", err)
+		exit(1)
+	}
+
+	r, err := watermark.InspectBytesProfile(data, profile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "decode input: %v\n", err)
+		exit(1)
+	}
+
+	orientation := "none"
+	if r.Orientation != 0 {
+		orientation = fmt.Sprint(r.Orientation)
+		if r.Orientation != 1 {
+			orientation += " (displayed rotated/mirrored; detection uses stored pixels)"
+		}
+	}
+
+	fmt.Printf("Dimensions:  %dx%d\n", r.Width, r.Height)
+	fmt.Printf("Format:      %s\n", r.Format)
+	fmt.Printf("Color model: %s\n", r.ColorModel)
+	fmt.Printf("Orientation: %s\n", orientation)
+
+	var geomErr *watermark.GeometryError
+	switch {
+	case errors.As(r.DetectErr, &geomErr):
+		fmt.Printf("Watermark:   image too small for expected placement %v\n", geomErr.Rect)
+	case r.DetectErr != nil:
+		fmt.Printf("Watermark:   detection failed: %v\n", r.DetectErr)
+	case r.Present:
+		fmt.Printf("Watermark:   present (score %.2f, correlation %.2f, %v confidence), %dx%d at %v (corner %v)\n",
+			r.Score, r.Correlation, r.Confidence, r.Info.Size, r.Info.Size, r.Info.Position, r.Info.Corner)
+	default:
+		fmt.Printf("Watermark:   not detected (score %.2f, correlation %.2f, %v confidence), expected %dx%d at %v\n",
+			r.Score, r.Correlation, r.Confidence, r.Info.Size, r.Info.Size, r.Info.Position)
+	}
+}
+
+// readInputBytes returns the raw bytes of the -in path or URL, or of the
+// decoded -inbase64 payload.
+func readInputBytes(input, inputBase64 string, retry watermark.RetryPolicy) ([]byte, error) {
+	switch {
+	case inputBase64 != "":
+		return watermark.DecodeBase64Bytes(inputBase64)
+	case input == "-":
+		return io.ReadAll(os.Stdin)
+	case watermark.IsURL(input):
+		return watermark.FetchImageRetry(context.Background(), input, retry)
+	default:
+		return os.ReadFile(input)
+	}
+}
+
+// Exit statuses of "gwatermark detect".
+const (
+	detectExitClean = 0
+	detectExitFound = 1
+	detectExitError = 2
+)
+
+// detectRecord is one line of "gwatermark detect -json".
+type detectRecord struct {
+	Path        string               `json:"path"`
+	Present     bool                 `json:"present"`
+	Score       float64              `json:"score"`
+	Correlation float64              `json:"correlation"`
+	Confidence  watermark.Confidence `json:"confidence"`
+	Size        int                  `json:"size,omitempty"`
+	Position    *image.Rectangle     `json:"position,omitempty"`
+	Corner      *watermark.Corner    `json:"corner,omitempty"`
+	Error       string               `json:"error,omitempty"`
+}
+
+// runDetectCommand implements "gwatermark detect": it reports detection for
+// files and directories without writing anything, as a CI gate. The exit
+// status is 1 when any watermark is found, 2 when a file could not be read
+// or decoded (or on usage errors) and none was found, and 0 otherwise.
+func runDetectCommand(args []string) int {
+	fs := flag.NewFlagSet("detect", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gwatermark detect [-recursive] [-json] PATH...")
+		fs.PrintDefaults()
+	}
+	recursive := fs.Bool("recursive", false, "Descend into subdirectories of directory arguments")
+	asJSON := fs.Bool("json", false, "Print one JSON object per file instead of text")
+	quiet := fs.Bool("quiet", false, "Print only the files with a watermark and failures")
+	profileFile := fs.String("profile-file", "", "JSON watermark profile to use instead of the built-in Gemini profile")
+	cornerName := fs.String("corner", "", "Watermark corner: br, bl, tr, tl, or auto (defaults to the profile's)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return detectExitClean
+		}
+		return detectExitError
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return detectExitError
+	}
+
+	profile := watermark.GeminiProfile()
+	if *profileFile != "" {
+		loaded, err := watermark.LoadProfileFile(*profileFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "load profile: %v\n", err)
+			return detectExitError
+		}
+		profile = loaded
+	}
+	if *cornerName != "" {
+		corner, ok := watermark.ParseCorner(*cornerName)
+		if !ok {
+			fmt.Fprintf(os.Stderr, "unknown corner %q\n", *cornerName)
+			return detectExitError
+		}
+		profile.Corner = corner
+	}
+
+	out := detectOutput{w: os.Stdout, json: *asJSON, quiet: *quiet}
+	found, failed := detectPaths(fs.Args(), walkOptions{Recursive: *recursive}, profile, out)
+	switch {
+	case found > 0:
+		return detectExitFound
+	case failed > 0:
+		return detectExitError
+	default:
+		return detectExitClean
+	}
+}
+
+// detectOutput selects how detectPaths reports each file.
+type detectOutput struct {
+	w     io.Writer
+	json  bool
+	quiet bool
+}
+
+// detectPaths runs detection on every file argument and every image in the
+// directory arguments, reporting each to out. It returns how many files
+// carry a watermark and how many failed.
+func detectPaths(paths []string, walk walkOptions, profile watermark.Profile, out detectOutput) (found, failed int) {
+	check := func(path string) {
+		rec := detectFile(path, profile)
+		switch {
+		case rec.Error != "":
+			failed++
+		case rec.Present:
+			found++
+		case out.quiet:
+			return
+		}
+		if !out.print(rec) && rec.Error == "" {
+			failed++
+		}
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err == nil && info.IsDir() {
+			err = walkImages(path, walk, func(e walkEntry) error {
+				if e.Skip == "" {
+					check(e.Path)
+				}
+				return nil
+			})
+		} else if err == nil {
+			check(path)
+		}
+		if err != nil {
+			failed++
+			out.print(detectRecord{Path: path, Error: err.Error()})
+		}
+	}
+	return found, failed
+}
+
+func detectFile(path string, profile watermark.Profile) detectRecord {
+	rec := detectRecord{Path: path}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		rec.Error = err.Error()
+		return rec
+	}
+	r, err := watermark.InspectBytesProfile(data, profile)
+	if err == nil {
+		err = r.DetectErr
+	}
+	// Images too small for the watermark cannot carry it.
+	var geomErr *watermark.GeometryError
+	if err != nil && !errors.As(err, &geomErr) {
+		rec.Error = err.Error()
+		return rec
+	}
+	rec.Present, rec.Score, rec.Correlation, rec.Confidence = r.Present, r.Score, r.Correlation, r.Confidence
+	if r.Present {
+		rec.Size, rec.Position, rec.Corner = r.Info.Size, &r.Info.Position, &r.Info.Corner
+	}
+	return rec
+}
+
+// print reports rec. It returns false when rec could not be encoded and an
+// error was reported for its file instead.
+func (o detectOutput) print(rec detectRecord) bool {
+	if o.json {
+		rec.Path, rec.Error = utf8Safe(rec.Path), utf8Safe(rec.Error)
+		data, err := marshalJSON(rec, false)
+		if err != nil {
+			// A record of two valid strings always encodes.
+			data, _ = marshalJSON(detectRecord{Path: rec.Path, Error: fmt.Sprintf("encode result: %v", err)}, false)
+		}
+		o.w.Write(data)
+		return err == nil
+	}
+	switch {
+	case rec.Error != "":
+		fmt.Fprintf(o.w, "error  %s: %s\n", rec.Path, rec.Error)
+	case rec.Present:
+		fmt.Fprintf(o.w, "found  %s (score %.2f, correlation %.2f, %v confidence) %dx%d at %v corner %v\n",
+			rec.Path, rec.Score, rec.Correlation, rec.Confidence, rec.Size, rec.Size, *rec.Position, *rec.Corner)
+	default:
+		fmt.Fprintf(o.w, "clean  %s (score %.2f)\n", rec.Path, rec.Score)
+	}
+	return true
+}
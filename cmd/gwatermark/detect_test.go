@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	"github.com/gcslaoli/gemini-watermark-remover-go/testutil"
+)
+
+func TestDetectPaths(t *testing.T) {
+	dir := t.TempDir()
+	samples := map[string][]byte{"image.png": testutil.WatermarkedPNG(t), "nowater.jpg": testutil.CleanJPEG(t)}
+	for name, data := range samples {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "broken.png"), []byte("not an image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	found, failed := detectPaths([]string{dir}, walkOptions{}, watermark.GeminiProfile(), detectOutput{w: &buf, json: true})
+	if found != 1 || failed != 1 {
+		t.Fatalf("found, failed = %d, %d, want 1, 1\n%s", found, failed, buf.String())
+	}
+	recs := map[string]detectRecord{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec detectRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		recs[filepath.Base(rec.Path)] = rec
+	}
+	if rec := recs["image.png"]; !rec.Present || rec.Position == nil || rec.Corner == nil || *rec.Corner != watermark.CornerBottomRight {
+		t.Errorf("image.png = %+v", rec)
+	}
+	if rec := recs["nowater.jpg"]; rec.Present || rec.Error != "" || rec.Position != nil {
+		t.Errorf("nowater.jpg = %+v", rec)
+	}
+	if rec := recs["broken.png"]; rec.Error == "" {
+		t.Errorf("broken.png = %+v", rec)
+	}
+
+	buf.Reset()
+	found, failed = detectPaths([]string{filepath.Join(dir, "nowater.jpg"), filepath.Join(dir, "missing.png")}, walkOptions{}, watermark.GeminiProfile(), detectOutput{w: &buf, quiet: true})
+	if found != 0 || failed != 1 {
+		t.Errorf("found, failed = %d, %d, want 0, 1", found, failed)
+	}
+	if out := buf.String(); strings.Contains(out, "nowater.jpg") || !strings.HasPrefix(out, "error  ") {
+		t.Errorf("quiet output = %q", out)
+	}
+}
+
+func TestDetectOutputEncodeError(t *testing.T) {
+	var buf bytes.Buffer
+	out := detectOutput{w: &buf, json: true}
+	if out.print(detectRecord{Path: "nan.png", Score: math.NaN()}) {
+		t.Fatal("unencodable record reported as printed")
+	}
+	var rec detectRecord
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil || rec.Path != "nan.png" || !strings.Contains(rec.Error, "encode result") {
+		t.Fatalf("output %q: %+v, %v", buf.String(), rec, err)
+	}
+}
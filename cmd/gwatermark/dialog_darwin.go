@@ -0,0 +1,22 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+)
+
+var errNoDialog = errors.New("no dialog available")
+
+// showDialog shows an alert through AppleScript. The texts are passed as
+// arguments, so they need no quoting.
+func showDialog(title, msg string, isError bool) error {
+	as := "informational"
+	if isError {
+		as = "critical"
+	}
+	return exec.Command("osascript",
+		"-e", "on run argv",
+		"-e", "display alert (item 1 of argv) message (item 2 of argv) as "+as,
+		"-e", "end run",
+		title, msg).Run()
+}
@@ -0,0 +1,35 @@
+//go:build !darwin && !windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errNoDialog = errors.New("no dialog available")
+
+// showDialog shows a message box with zenity or kdialog when a desktop
+// session has one of them, for file manager actions.
+func showDialog(title, msg string, isError bool) error {
+	if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+		return errNoDialog
+	}
+	kind := "--info"
+	if isError {
+		kind = "--error"
+	}
+	if path, err := exec.LookPath("zenity"); err == nil {
+		return exec.Command(path, kind, "--title", title, "--no-markup", "--text", msg).Run()
+	}
+	if isError {
+		kind = "--error"
+	} else {
+		kind = "--msgbox"
+	}
+	if path, err := exec.LookPath("kdialog"); err == nil {
+		return exec.Command(path, "--title", title, kind, msg).Run()
+	}
+	return errNoDialog
+}
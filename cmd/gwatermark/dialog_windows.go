@@ -0,0 +1,26 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+var errNoDialog = errors.New("no dialog available")
+
+// messageBoxScript shows a message box with the texts of the
+// GWATERMARK_TITLE and GWATERMARK_MESSAGE environment variables, which need
+// no PowerShell quoting.
+const messageBoxScript = `Add-Type -AssemblyName System.Windows.Forms
+[System.Windows.Forms.MessageBox]::Show($env:GWATERMARK_MESSAGE, $env:GWATERMARK_TITLE, 'OK', $env:GWATERMARK_ICON) > $null`
+
+// showDialog shows a message box through PowerShell.
+func showDialog(title, msg string, isError bool) error {
+	icon := "Information"
+	if isError {
+		icon = "Error"
+	}
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", messageBoxScript)
+	cmd.Env = append(os.Environ(), "GWATERMARK_TITLE="+title, "GWATERMARK_MESSAGE="+msg, "GWATERMARK_ICON="+icon)
+	return cmd.Run()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"html/template"
+	"image"
+	"os"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// diffPage is a self-contained before/after slider. Both images are embedded
+// as data URLs so the file can be shared without the originals.
+var diffPage = template.Must(template.New("diff").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { margin: 0; padding: 24px; background: #1e1e1e; color: #ddd; font: 14px sans-serif; }
+.compare { position: relative; display: inline-block; max-width: 100%; }
+.compare img { display: block; max-width: 100%; }
+.compare .after { position: absolute; top: 0; left: 0; width: 100%; height: 100%; clip-path: inset(0 0 0 50%); }
+.compare input { width: 100%; margin: 12px 0 0; }
+.labels { display: flex; justify-content: space-between; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>Watermark {{.Size}}x{{.Size}} at {{.Position}}. Drag the slider to compare.</p>
+<div class="compare">
+<img class="before" src="{{.Original}}" alt="original">
+<img class="after" id="after" src="{{.Cleaned}}" alt="cleaned">
+<div class="labels"><span>Original</span><span>Cleaned</span></div>
+<input type="range" min="0" max="100" value="50" id="slider">
+</div>
+<script>
+document.getElementById("slider").addEventListener("input", function (e) {
+  document.getElementById("after").style.clipPath = "inset(0 0 0 " + e.target.value + "%)";
+});
+</script>
+</body>
+</html>
+`))
+
+// writeDiffHTML renders the original/cleaned comparison page to path.
+func writeDiffHTML(path, title string, original, cleaned image.Image, info watermark.Info) error {
+	originalURL, err := pngDataURL(original)
+	if err != nil {
+		return err
+	}
+	cleanedURL, err := pngDataURL(cleaned)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	err = diffPage.Execute(&buf, struct {
+		Title             string
+		Size              int
+		Position          image.Rectangle
+		Original, Cleaned template.URL
+	}{title, info.Size, info.Position, originalURL, cleanedURL})
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+func pngDataURL(img image.Image) (template.URL, error) {
+	data, err := watermark.EncodePNGToBytes(img)
+	if err != nil {
+		return "", err
+	}
+	return template.URL("data:image/png;base64," + base64.StdEncoding.EncodeToString(data)), nil
+}
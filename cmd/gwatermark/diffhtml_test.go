@@ -0,0 +1,33 @@
+package main
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+func TestWriteDiffHTML(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "diff.html")
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	info := watermark.Info{Size: 48, Position: image.Rect(1, 2, 49, 50)}
+
+	if err := writeDiffHTML(path, "a <b>.png", img, img, info); err != nil {
+		t.Fatalf("writeDiffHTML: %v", err)
+	}
+
+	page, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read page: %v", err)
+	}
+	html := string(page)
+	if strings.Count(html, `src="data:image/png;base64,`) != 2 {
+		t.Fatalf("expected two embedded PNG data URLs")
+	}
+	if !strings.Contains(html, "a &lt;b&gt;.png") {
+		t.Fatalf("title not escaped")
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+// errFreeSpaceUnknown is returned by freeSpace on platforms without a
+// free-space query; the preflight check is skipped there.
+var errFreeSpaceUnknown = errors.New("free space unknown")
+
+// spaceReserve is left free on the destination volume on top of the
+// estimated output size, so the check does not fill the disk to the last
+// byte.
+const spaceReserve = 16 << 20
+
+// checkFreeSpace fails before anything is written when the volume holding
+// the file path has less than need bytes (plus spaceReserve) available.
+func checkFreeSpace(path string, need uint64) error {
+	return checkFreeSpaceIn(filepath.Dir(path), need)
+}
+
+// checkFreeSpaceIn is checkFreeSpace for the volume holding directory dir.
+func checkFreeSpaceIn(dir string, need uint64) error {
+	free, err := freeSpace(dir)
+	if errors.Is(err, errFreeSpaceUnknown) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("check free space in %s: %w", dir, err)
+	}
+	if free < need+spaceReserve {
+		return fmt.Errorf("%s has %d bytes free, need about %d", dir, free, need+spaceReserve)
+	}
+	return nil
+}
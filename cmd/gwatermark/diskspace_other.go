@@ -0,0 +1,7 @@
+//go:build !(linux || darwin || freebsd || dragonfly || windows)
+
+package main
+
+func freeSpace(string) (uint64, error) {
+	return 0, errFreeSpaceUnknown
+}
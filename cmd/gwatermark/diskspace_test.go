@@ -0,0 +1,21 @@
+package main
+
+import (
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestCheckFreeSpace(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out.png")
+	if err := checkFreeSpace(out, 1); err != nil {
+		t.Fatalf("checkFreeSpace small: %v", err)
+	}
+
+	if _, err := freeSpace(filepath.Dir(out)); err == errFreeSpaceUnknown {
+		t.Skipf("no free-space query on %s", runtime.GOOS)
+	}
+	if err := checkFreeSpace(out, 1<<62); err == nil {
+		t.Fatalf("checkFreeSpace accepted an impossible size")
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// corpusManifest is testdata/corpus.json: the sample images of one corpus
+// version and the base URL they are hosted under, a release of the
+// repository, so the images stay out of it.
+type corpusManifest struct {
+	Version string       `json:"version"`
+	URL     string       `json:"url,omitempty"`
+	Files   []corpusFile `json:"files"`
+}
+
+// corpusFile is one sample and its expected detection verdict. Name is a
+// slash-separated path relative to both the corpus directory and the URL.
+type corpusFile struct {
+	Name        string `json:"name"`
+	SHA256      string `json:"sha256"`
+	Size        int64  `json:"size"`
+	Watermarked bool   `json:"watermarked"`
+}
+
+// runFetchTestdata implements "gwatermark fetch-testdata": it checks every
+// file of the manifest's corpus in a directory against its size and
+// SHA-256, downloading the ones missing or damaged when the manifest or -url
+// names where the corpus is hosted. With -update it instead rewrites the
+// manifest's sizes and checksums from the files in the directory, to
+// prepare a new version.
+func runFetchTestdata(args []string) error {
+	fs := flag.NewFlagSet("fetch-testdata", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gwatermark fetch-testdata [-manifest testdata/corpus.json] [-dir testdata/corpus] [options]")
+		fs.PrintDefaults()
+	}
+	manifestPath := fs.String("manifest", filepath.Join("testdata", "corpus.json"), "Corpus manifest listing the files and their checksums")
+	dir := fs.String("dir", filepath.Join("testdata", "corpus"), "Directory to keep the corpus in")
+	baseURL := fs.String("url", "", "Download from this base URL instead of the manifest's, e.g. a mirror")
+	update := fs.Bool("update", false, "Rewrite the manifest's sizes and checksums from the files in -dir instead of downloading")
+	retries := fs.Int("retries", 2, "Retry each download this many times on network errors, timeouts and 5xx/429 responses")
+	netTimeout := fs.Duration("timeout", 2*time.Minute, "Timeout of each download attempt (0 for none)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return errors.New("fetch-testdata takes no positional arguments")
+	}
+
+	m, err := loadCorpusManifest(*manifestPath)
+	if err != nil {
+		return err
+	}
+	if *update {
+		return updateCorpusManifest(*manifestPath, m, *dir)
+	}
+	if *baseURL != "" {
+		m.URL = *baseURL
+	}
+	retry := watermark.RetryPolicy{Attempts: max(*retries, 0) + 1, Backoff: time.Second, Timeout: *netTimeout}
+	return fetchCorpus(context.Background(), m, *dir, retry)
+}
+
+func loadCorpusManifest(name string) (corpusManifest, error) {
+	var m corpusManifest
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return m, err
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, fmt.Errorf("%s: %w", name, err)
+	}
+	for _, f := range m.Files {
+		// Names become both paths and URLs, and must stay below both.
+		if f.Name == "" || f.Name != path.Clean(f.Name) || path.IsAbs(f.Name) || f.Name == ".." ||
+			strings.HasPrefix(f.Name, "../") || strings.ContainsAny(f.Name, `\:`) {
+			return m, fmt.Errorf("%s: invalid file name %q", name, f.Name)
+		}
+	}
+	return m, nil
+}
+
+// fetchCorpus downloads the files of m missing from dir or damaged there.
+// Without a URL it only reports them.
+func fetchCorpus(ctx context.Context, m corpusManifest, dir string, retry watermark.RetryPolicy) error {
+	var fetched, kept int
+	for _, f := range m.Files {
+		dst := filepath.Join(dir, filepath.FromSlash(f.Name))
+		data, err := os.ReadFile(dst)
+		if err == nil {
+			err = f.check(data)
+		}
+		if err == nil {
+			kept++
+			continue
+		}
+		if m.URL == "" {
+			return fmt.Errorf("%s: %w, and the manifest has no url to fetch it from", dst, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return err
+		}
+
+		url := strings.TrimSuffix(m.URL, "/") + "/" + f.Name
+		fmt.Printf("fetch %s\n", url)
+		data, err = watermark.FetchImageRetry(ctx, url, retry)
+		if err != nil {
+			return err
+		}
+		if err := f.check(data); err != nil {
+			return fmt.Errorf("%s: %w", url, err)
+		}
+		if err := writeFileAtomic(dst, data); err != nil {
+			return err
+		}
+		fetched++
+	}
+	fmt.Printf("Corpus %s in %s: fetched %d, already present %d.\n", m.Version, dir, fetched, kept)
+	return nil
+}
+
+// check verifies data against the manifest entry.
+func (f corpusFile) check(data []byte) error {
+	if int64(len(data)) != f.Size {
+		return fmt.Errorf("size %d, want %d", len(data), f.Size)
+	}
+	if sum := hashBytes(data); sum != f.SHA256 {
+		return fmt.Errorf("sha256 %s, want %s", sum, f.SHA256)
+	}
+	return nil
+}
+
+// updateCorpusManifest rewrites the sizes and checksums of m from the files
+// in dir and saves it to name.
+func updateCorpusManifest(name string, m corpusManifest, dir string) error {
+	for i, f := range m.Files {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f.Name)))
+		if err != nil {
+			return err
+		}
+		m.Files[i].Size, m.Files[i].SHA256 = int64(len(data)), hashBytes(data)
+	}
+	data, err := marshalJSON(m, true)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(name, data); err != nil {
+		return err
+	}
+	fmt.Printf("Updated %s: %d files for corpus %s.\n", name, len(m.Files), m.Version)
+	return nil
+}
+
+// writeFileAtomic writes data to name through a temporary file, so readers
+// never see a partial file. The file gets mode 0644 rather than the 0600 of
+// the temporary file.
+func writeFileAtomic(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(0o644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), name)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
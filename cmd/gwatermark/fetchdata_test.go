@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+func TestFetchCorpus(t *testing.T) {
+	files := map[string][]byte{"a.png": []byte("first sample"), "b.jpg": []byte("second sample")}
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		data, ok := files[strings.TrimPrefix(r.URL.Path, "/v1/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	m := corpusManifest{Version: "v1", URL: srv.URL + "/v1/"}
+	for _, name := range []string{"a.png", "b.jpg"} {
+		m.Files = append(m.Files, corpusFile{Name: name, Size: int64(len(files[name])), SHA256: hashBytes(files[name])})
+	}
+	dir := filepath.Join(t.TempDir(), "corpus")
+	retry := watermark.RetryPolicy{Attempts: 1}
+
+	if err := fetchCorpus(context.Background(), m, dir, retry); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range files {
+		if got, err := os.ReadFile(filepath.Join(dir, name)); err != nil || string(got) != string(want) {
+			t.Fatalf("%s = %q, %v", name, got, err)
+		}
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "a.png")); err != nil || fi.Mode().Perm()&0o044 != 0o044 {
+		t.Errorf("fetched file is not world readable: %v, %v", fi.Mode(), err)
+	}
+
+	// Intact files are kept, damaged ones fetched again.
+	if err := os.WriteFile(filepath.Join(dir, "b.jpg"), []byte("damaged"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	requests = 0
+	if err := fetchCorpus(context.Background(), m, dir, retry); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("rerun made %d requests, want 1", requests)
+	}
+
+	files["a.png"] = []byte("tampered sample")
+	os.Remove(filepath.Join(dir, "a.png"))
+	if err := fetchCorpus(context.Background(), m, dir, retry); err == nil || !strings.Contains(err.Error(), "size") {
+		t.Errorf("tampered download: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "a.png")); !os.IsNotExist(err) {
+		t.Error("tampered download was written")
+	}
+
+	// Without a URL missing files are reported, not fetched.
+	m.URL = ""
+	if err := fetchCorpus(context.Background(), m, t.TempDir(), retry); err == nil || !strings.Contains(err.Error(), "no url") {
+		t.Errorf("missing file without a url: %v", err)
+	}
+}
+
+func TestLoadCorpusManifest(t *testing.T) {
+	m, err := loadCorpusManifest(filepath.Join("..", "..", "testdata", "corpus.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version == "" || m.URL == "" || len(m.Files) == 0 {
+		t.Fatalf("manifest = %+v", m)
+	}
+	for _, f := range m.Files {
+		if f.Size <= 0 || len(f.SHA256) != 64 {
+			t.Errorf("%s has no checksum", f.Name)
+		}
+	}
+
+	bad := filepath.Join(t.TempDir(), "corpus.json")
+	for _, name := range []string{"../escape.png", "/abs.png", "a/../../b.png", `dir\\c.png`} {
+		os.WriteFile(bad, []byte(`{"files": [{"name": "`+name+`"}]}`), 0o644)
+		if _, err := loadCorpusManifest(bad); err == nil {
+			t.Errorf("file name %q was accepted", name)
+		}
+	}
+	os.WriteFile(bad, []byte(`{"files": [{"name": "samples/a.png"}]}`), 0o644)
+	if _, err := loadCorpusManifest(bad); err != nil {
+		t.Errorf("relative path rejected: %v", err)
+	}
+}
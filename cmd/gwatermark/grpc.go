@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	"github.com/gcslaoli/gemini-watermark-remover-go/server"
+)
+
+// runServeGRPC implements "gwatermark serve-grpc": it serves the gRPC API
+// of server.RegisterGRPC until SIGINT or SIGTERM, then lets in-flight calls
+// finish.
+func runServeGRPC(args []string) error {
+	fs := flag.NewFlagSet("serve-grpc", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gwatermark serve-grpc [-addr :50051] [options]")
+		fs.PrintDefaults()
+	}
+	addr := fs.String("addr", ":50051", "Address to listen on")
+	formatName := fs.String("format", "png", "Output format of Remove: png, jpeg, webp, or source to keep JPEG and WebP inputs in their format")
+	quality := fs.Int("quality", watermark.DefaultJPEGQuality, "JPEG and lossy WebP output quality (1-100)")
+	maxImageBytes := fs.Int64("max-image-bytes", server.DefaultMaxBodyBytes, "Reject uploaded images larger than this many bytes")
+	imageTimeout := fs.Duration("image-timeout", 2*time.Minute, "Fail calls whose image takes longer to decode and clean (0 for no limit)")
+	maxConcurrency := fs.Int("max-concurrency", 0, "Run at most this many goroutines of pixel work at once across all calls (0 for no limit)")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return errors.New("serve-grpc takes no positional arguments")
+	}
+
+	outFormat, ok := watermark.ParseOutputFormat(*formatName)
+	if !ok {
+		return fmt.Errorf("unknown output format %q", *formatName)
+	}
+	opts := watermark.Options{Output: outFormat, JPEGQuality: *quality, WebPQuality: *quality}
+	watermark.SetDefaultEngine(watermark.NewEngine(watermark.WithMaxConcurrency(*maxConcurrency)))
+
+	lis, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	sched := newScheduler()
+	defer sched.Close()
+	srv := grpc.NewServer()
+	server.RegisterGRPC(srv, server.GRPCConfig{Options: opts, Scheduler: sched, MaxImageBytes: *maxImageBytes, ImageTimeout: *imageTimeout})
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.Serve(lis)
+	}()
+	fmt.Printf("Serving gRPC gwatermark.v1.Watermark (Detect, Remove) on %s\n", lis.Addr())
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		srv.Stop()
+	}
+	return <-errc
+}
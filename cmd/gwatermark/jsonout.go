@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// marshalJSON encodes v for the JSON files and lines the CLI writes. The
+// output is UTF-8 whatever the locale, numbers use Go's locale-independent
+// formatting, and <, > and & are left as they are instead of being escaped
+// for HTML, so paths read the same in every parser. The result ends in a
+// newline.
+func marshalJSON(v any, indent bool) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if indent {
+		enc.SetIndent("", "  ")
+	}
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// utf8Safe prepares a path, or a message that may contain one, for JSON
+// output. Valid UTF-8, which includes every Windows path since Go converts
+// them from UTF-16, is returned unchanged. Unix file names may hold any
+// bytes; those that are not UTF-8 become \xNN escapes rather than the
+// U+FFFD that encoding/json would substitute, so distinct files keep
+// distinct names.
+func utf8Safe(s string) string {
+	if utf8.ValidString(s) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			fmt.Fprintf(&b, `\x%02x`, s[i])
+		} else {
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+func TestUTF8Safe(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"photos/image.png", "photos/image.png"},
+		{"照片/Ünïcode & <1>.png", "照片/Ünïcode & <1>.png"},
+		{`C:\Users\José\图像.png`, `C:\Users\José\图像.png`},
+		{"latin1-\xe9t\xe9.png", `latin1-\xe9t\xe9.png`},
+		{"cut-\xe5\x9b.png", `cut-\xe5\x9b.png`},
+	} {
+		if got := utf8Safe(tc.in); got != tc.want {
+			t.Errorf("utf8Safe(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+	if utf8Safe("a\xff") == utf8Safe("a\xfe") {
+		t.Error("distinct invalid names collide")
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	v := map[string]any{"path": "a&b/<c>.png", "rate": 0.1, "tiny": 1e-7, "big": 123456789.0, "n": 3}
+	data, err := marshalJSON(v, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"big":123456789,"n":3,"path":"a&b/<c>.png","rate":0.1,"tiny":1e-7}` + "\n"
+	if string(data) != want {
+		t.Errorf("marshalJSON = %s, want %s", data, want)
+	}
+}
+
+func TestManifestNonASCIIPaths(t *testing.T) {
+	out := t.TempDir()
+	names := []string{"照片/图像 1.png", "Ünïcode & <x>.png", "\xe9t\xe9.png"}
+	m := loadManifest(out)
+	for _, name := range names {
+		dst := filepath.Join(out, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(dst, []byte(name), 0o644); err != nil {
+			t.Skipf("file system rejects %q: %v", name, err)
+		}
+		if err := m.record(out, filepath.FromSlash(name), "in", dst, []byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m.Settings = "s"
+	if err := m.save(out); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(out, manifestName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(data) || !bytes.Contains(data, []byte(`"照片/图像 1.png"`)) || !bytes.Contains(data, []byte(`"Ünïcode & <x>.png"`)) {
+		t.Fatalf("manifest:\n%s", data)
+	}
+	reloaded := loadManifest(out)
+	if len(reloaded.Files) != len(names) {
+		t.Fatalf("reloaded %d files, want %d", len(reloaded.Files), len(names))
+	}
+	for _, name := range names[:2] {
+		if _, ok := reloaded.upToDate("s", out, filepath.FromSlash(name), "in"); !ok {
+			t.Errorf("%s not up to date after reload", name)
+		}
+	}
+}
+
+func TestDetectJSONPath(t *testing.T) {
+	var buf bytes.Buffer
+	detectOutput{w: &buf, json: true}.print(detectRecord{Path: "dir/\xff & 图.png", Confidence: watermark.ConfidenceLow})
+	var rec detectRecord
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("%s: %v", buf.Bytes(), err)
+	}
+	if want := `dir/\xff & 图.png`; rec.Path != want {
+		t.Errorf("path = %q, want %q", rec.Path, want)
+	}
+	if strings.Contains(buf.String(), `\u0026`) {
+		t.Errorf("HTML-escaped output: %s", buf.String())
+	}
+}
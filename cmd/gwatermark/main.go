@@ -1,14 +1,21 @@
 package main
 
 import (
+	"encoding/base64"
+	"errors"
 	"flag"
 	"fmt"
 	"image"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	_ "github.com/gcslaoli/gemini-watermark-remover-go/webpenc"
 )
 
 // go run main.go -in image.png -out image_unwatermarked.png
@@ -20,94 +27,833 @@ import (
 // go run main.go -in nowater.jpg --out nowater_unwatermarked.png
 
 func main() {
-	input := flag.String("in", "", "Path to the watermarked image (png/jpg/webp)")
+	if len(os.Args) > 1 && os.Args[1] == "convert" {
+		if err := runConvert(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "convert: %v\n", err)
+			exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "quick" {
+		if !runQuick(os.Args[2:]) {
+			exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "regress" {
+		exit(runRegress(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "detect" {
+		exit(runDetectCommand(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "fetch-testdata" {
+		if err := runFetchTestdata(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "fetch-testdata: %v\n", err)
+			exit(1)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "serve-grpc" {
+		if err := runServeGRPC(os.Args[2:]); err != nil {
+			fmt.Fprintf(os.Stderr, "serve-grpc: %v\n", err)
+			exit(1)
+		}
+		return
+	}
+
+	input := flag.String("in", "", "Path or http(s) URL of the watermarked image (png/jpg/webp), or - for stdin")
 	inputBase64 := flag.String("inbase64", "", "Base64 image input (optionally data URL)")
-	output := flag.String("out", "", "Output path (defaults to <name>_unwatermarked.png)")
+	output := flag.String("out", "", "Output path, or - for stdout (defaults to <name>_unwatermarked.png, or .jpg for JPEG output; stdout for -in -)")
 	outputBase64 := flag.Bool("outbase64", false, "Write cleaned PNG as base64 to stdout instead of file")
+	rawVideo := flag.String("rawvideo", "", "Filter raw rgb24 frames of size WIDTHxHEIGHT from stdin to stdout")
+	rawVideoRegion := flag.String("rawvideo-region", "", "With -rawvideo, the full WIDTHxHEIGHT of frames cropped to the watermark corner box")
+	ffmpegCmd := flag.Bool("ffmpeg-cmd", false, "Print the ffmpeg pipeline that cleans the -in video into -out")
+	ffmpegRegion := flag.Bool("ffmpeg-region", false, "With -ffmpeg-cmd, pipe only the watermark corner box and overlay it on the original frames")
+	excludeMask := flag.String("exclude-mask", "", "Image whose non-transparent pixels must never be modified")
+	retries := flag.Int("retries", 2, "Retry URL inputs this many times on network errors, timeouts and 5xx/429 responses")
+	retryBackoff := flag.Duration("retry-backoff", 500*time.Millisecond, "Delay before the first retry, doubled for each further one")
+	netTimeout := flag.Duration("timeout", 30*time.Second, "Timeout of each attempt to download a URL input (0 for none)")
+	offline := flag.Bool("offline", false, "Disable every network-touching feature (URL input)")
+	rounding := flag.String("rounding", "half-up", "Rounding of recovered pixels: half-up, truncate, or half-even")
+	detectOnly := flag.Bool("detect", false, "Only report dimensions, format, color model, EXIF orientation and detection result")
+	diffHTML := flag.String("diff-html", "", "Write an HTML page with an original/cleaned slider comparison")
+	diffOut := flag.String("diff-out", "", "Write a PNG of amplified per-pixel differences between input and output")
+	debugOut := flag.String("debug-out", "", "Write a PNG showing the examined rectangle, the logo mask outline and a heat map of luma residuals, also when no watermark is detected")
+	assertRegion := flag.Bool("assert-region-only", false, "Fail if any pixel outside the watermark rectangle differs in the encoded output")
+	verify := flag.String("verify", "", "Compare the encoded output with this reference image, such as the clean original, and print PSNR and SSIM")
+	tiled := flag.Bool("tiled", false, "Stream a large PNG row by row with bounded memory (8-bit RGB/RGBA, non-interlaced)")
+	matchNoise := flag.Bool("match-noise", false, "Add grain to the cleaned area to match the surrounding noise level")
+	noiseSeed := flag.Int64("noise-seed", 0, "Seed for -match-noise; equal seeds give identical outputs")
+	dehalo := flag.Float64("dehalo", 0, "Smooth the halo JPEG compression leaves around the removed logo of JPEG and lossy WebP inputs, at this strength (0-1, 0 off)")
+	dehaloRadius := flag.Int("dehalo-radius", watermark.DefaultHaloRadius, "Half-width in pixels of the -dehalo smoothing window")
+	inpaint := flag.Bool("inpaint-clipped", false, "Inpaint watermark pixels clipped to black or white, which reverse blending cannot recover, from their surroundings")
+	minScore := flag.Float64("min-score", 0, "Brightness lift the watermark must show to be detected, unless the profile sets one (0 for the default 6)")
+	minCorrelation := flag.Float64("min-correlation", 0, "Correlation with the logo shape required for detection, unless the profile sets one (0 for the default 0.30)")
+	logoValue := flag.Float64("logo-value", 255, "Channel value (1-255) of the white logo removed, for logos composited slightly gray")
+	parallelism := flag.Int("parallelism", 1, "Copy large images in this many concurrent bands (0 for one per CPU)")
+	maxConcurrency := flag.Int("max-concurrency", 0, "Run at most this many goroutines of pixel work at once across all requests and workers (0 for no limit)")
+	wmX := flag.Int("x", 0, "With -size, left edge of the watermark in pixels, for cropped or padded images")
+	wmY := flag.Int("y", 0, "With -size, top edge of the watermark in pixels")
+	wmSize := flag.Int("size", 0, "Remove a watermark of this size at -x/-y instead of detecting it at the standard placement")
+	search := flag.Bool("search", false, "Search the bottom-right quadrant for the watermark instead of checking the standard placement, for cropped, resized or letterboxed images")
+	searchWhole := flag.Bool("search-whole", false, "Like -search, but over the whole image")
+	cornerName := flag.String("corner", "br", "Watermark corner: br, bl, tr, tl, or auto to pick the best match")
+	profileFile := flag.String("profile-file", "", "JSON watermark profile to use instead of the built-in Gemini profile")
+	maxGrowth := flag.Float64("max-growth", 0, "Fail when the output is more than this many times the input size (0 only warns)")
+	dir := flag.String("dir", "", "Clean every supported image in this directory into a mirrored output directory (-out, default <dir>_unwatermarked)")
+	recursive := flag.Bool("recursive", false, "With -dir, descend into subdirectories")
+	followSymlinks := flag.Bool("follow-symlinks", false, "With -dir, follow symlinks to files and directories instead of skipping them")
+	preserveHardlinks := flag.Bool("preserve-hardlinks", false, "With -dir, hard-link outputs of files that are the same input instead of cleaning them again")
+	sidecars := flag.Bool("sidecars", false, "Copy XMP sidecars (photo.xmp, photo.jpg.xmp) of local inputs next to the outputs, renamed to match")
+	formatName := flag.String("format", "png", "Output format: png, jpeg, webp, or source to keep JPEG and WebP inputs in their format")
+	quality := flag.Int("quality", watermark.DefaultJPEGQuality, "JPEG and lossy WebP output quality (1-100)")
+	lossy := flag.Bool("lossy", false, "With -format webp, encode lossy WebP at -quality instead of lossless")
+	resize := flag.String("resize", "", "Fit cleaned images inside WIDTHxHEIGHT (Lanczos, aspect ratio kept; 1024x or x768 constrain one side)")
+	maxDim := flag.Int("max-dim", 0, "Shrink cleaned images so the longer side is at most this many pixels (0 keeps the size)")
+	thumb := flag.Int("thumb", 0, "Also write a thumbnail of the cleaned image, at most this many pixels on its longer side, next to the output as <name>_thumb.<ext>")
+	correction := flag.Bool("correction", false, "Also write the exact per-pixel correction applied, a 16-bit PNG of signed differences biased by 32768, next to the output as <name>_correction.png")
+	web := flag.Bool("web", false, "Delivery preset for publishing: lossy WebP at quality 82, no EXIF/XMP, longer side at most 2048 (explicit flags win)")
+	checkInvisible := flag.Bool("check-invisible", false, "Warn when the cleaned image likely still carries an invisible watermark such as SynthID (spectrum heuristic)")
+	salvage := flag.Bool("salvage", false, "Clean truncated or damaged PNG and JPEG inputs from their intact rows when those hold the watermark; lost rows come out gray")
+	stripMetadata := flag.Bool("strip-metadata", false, "Drop the input's EXIF and XMP from outputs instead of copying them (color profiles are always kept)")
+	force := flag.Bool("force", false, "With -dir, reprocess inputs the output manifest lists as unchanged")
+	serve := flag.String("serve", "", "Serve the REST API (POST /detect, POST /preview, POST /remove) on this address, e.g. :8080")
+	stdio := flag.Bool("stdio", false, "Speak the JSON-RPC preview protocol (LSP framing) on stdin and stdout, for editor plugins")
+	lowPriority := flag.Bool("low-priority", false, "Lower CPU and I/O priority so background cleaning does not starve interactive work")
+	preserveTimes := flag.Bool("preserve-times", false, "Copy the input file's access and modification times to the output")
+	preserveMode := flag.Bool("preserve-mode", false, "Copy the input file's permission bits to the output")
+	preserveXattrs := flag.Bool("preserve-xattrs", false, "Copy extended attributes such as Finder tags to the output (Linux: user.* only)")
+	quarantine := flag.String("quarantine", "keep", "With -preserve-xattrs, keep or drop the macOS com.apple.quarantine attribute")
+	rulesFlag := flag.String("rules", "", "With -dir, per-file rules such as 'skip if width < 600 or score < 8; format jpeg if format == \"jpeg\"' (@file reads them from a file)")
+	imageTimeout := flag.Duration("image-timeout", 2*time.Minute, "With -dir, -serve or -stdio, give up on an image whose decoding and cleaning take longer (0 for no limit)")
+	progress := flag.Bool("progress", false, "With -dir, draw a progress bar with the current file and stage on stderr")
+	summaryJSON := flag.Bool("summary-json", false, "End with a one-line JSON summary (status, exit code, reason, counts) on stderr, for wrappers that pipe the image through stdout")
+	capabilities := flag.Bool("capabilities", false, "Print the detected CPU features and the active pixel kernel, then exit")
+	var pluginPaths stringList
+	flag.Var(&pluginPaths, "plugin", "Run this plugin executable (JSON-RPC over stdio) as a detector, remover or post-processor; repeatable")
 	flag.Parse()
+	if *summaryJSON {
+		if err := startSummary(); err != nil {
+			fmt.Fprintf(os.Stderr, "-summary-json: %v\n", err)
+			exit(1)
+		}
+		defer finishSummary(0)
+	}
+	if *web {
+		if err := applyWebPreset(flag.CommandLine); err != nil {
+			fmt.Fprintf(os.Stderr, "-web: %v\n", err)
+			exit(1)
+		}
+	}
+
+	watermark.SetOffline(*offline)
+
+	if *lowPriority {
+		if err := lowerPriority(); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: lower priority: %v\n", err)
+		}
+	}
+
+	if *rawVideo != "" {
+		runRawVideo(*rawVideo, *rawVideoRegion)
+		return
+	}
+
+	if *ffmpegCmd {
+		printFFmpegCommand(*input, *output, *ffmpegRegion)
+		return
+	}
 
-	if *input == "" && *inputBase64 == "" {
+	if *capabilities {
+		printCapabilities(watermark.Capabilities())
+		return
+	}
+
+	if *input == "" && *inputBase64 == "" && *dir == "" && *serve == "" && !*stdio {
+		runSummary.Reason = "no input: need -in, -inbase64, -dir, -serve or -stdio"
 		flag.Usage()
-		os.Exit(1)
+		exit(1)
 	}
 
-	var (
-		img    image.Image
-		format string
-		source string
-		err    error
-	)
+	dropQuarantine, err := parseQuarantine(*quarantine)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+	preserve := preserveOptions{Times: *preserveTimes, Mode: *preserveMode, Xattrs: *preserveXattrs, DropQuarantine: dropQuarantine}
+	if (preserve.Times || preserve.Mode || preserve.Xattrs) && *dir == "" && (*input == "" || *input == "-" || watermark.IsURL(*input)) {
+		fmt.Fprintln(os.Stderr, "warning: -preserve-times, -preserve-mode and -preserve-xattrs need a local -in file; ignoring")
+		preserve = preserveOptions{}
+	}
+
+	if *retries < 0 {
+		fmt.Fprintln(os.Stderr, "-retries must not be negative")
+		exit(1)
+	}
+	retry := watermark.RetryPolicy{Attempts: *retries + 1, Backoff: *retryBackoff, Timeout: *netTimeout}
+
+	if *wmSize < 0 || *wmSize > 0 && (*dir != "" || *serve != "" || *stdio || *tiled || *detectOnly) {
+		fmt.Fprintln(os.Stderr, "-x/-y/-size need a positive size and apply to a single -in image only")
+		exit(1)
+	}
+	if (*search || *searchWhole) && (*wmSize > 0 || *dir != "" || *serve != "" || *stdio || *tiled || *detectOnly) {
+		fmt.Fprintln(os.Stderr, "-search and -search-whole apply to a single -in image without -size only")
+		exit(1)
+	}
+
+	if len(pluginPaths) > 0 && (*dir != "" || *serve != "" || *stdio || *tiled || *detectOnly) {
+		fmt.Fprintln(os.Stderr, "-plugin applies to a single -in image only")
+		exit(1)
+	}
+
+	if *tiled && *dir == "" && *serve == "" && !*stdio {
+		runTiled(*input, *output, preserve)
+		return
+	}
+
+	profile := watermark.GeminiProfile()
+	if *profileFile != "" {
+		loaded, err := watermark.LoadProfileFile(*profileFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "load profile: %v\n", err)
+			exit(1)
+		}
+		profile = loaded
+	}
+
+	corner, ok := watermark.ParseCorner(*cornerName)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown corner %q\n", *cornerName)
+		exit(1)
+	}
+	if flagSet("corner") || *profileFile == "" {
+		profile.Corner = corner
+	}
+	corner = profile.Corner
+
+	outFormat, ok := watermark.ParseOutputFormat(*formatName)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown output format %q\n", *formatName)
+		exit(1)
+	}
+	resizeTo, err := parseResize(*resize, *maxDim)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+	if *thumb < 0 || *thumb > 0 && (*tiled || *outputBase64 || *serve != "" || *stdio) {
+		fmt.Fprintln(os.Stderr, "-thumb needs a positive size and an output file; it does not apply to -tiled, -outbase64, -serve or -stdio")
+		exit(1)
+	}
+	if *correction && (*tiled || *outputBase64 || *serve != "" || *stdio) {
+		fmt.Fprintln(os.Stderr, "-correction needs an output file; it does not apply to -tiled, -outbase64, -serve or -stdio")
+		exit(1)
+	}
+	if *assertRegion && !resizeTo.IsZero() {
+		fmt.Fprintln(os.Stderr, "-assert-region-only compares pixels with the input and cannot be combined with -resize or -max-dim")
+		exit(1)
+	}
+	opts := watermark.Options{
+		Profile:        &profile,
+		MaxGrowth:      *maxGrowth,
+		Output:         outFormat,
+		JPEGQuality:    *quality,
+		WebPLossy:      *lossy,
+		WebPQuality:    *quality,
+		StripMetadata:  *stripMetadata,
+		CheckInvisible: *checkInvisible,
+		Resize:         resizeTo,
+		Salvage:        *salvage,
+	}
+
+	var noise *watermark.NoiseMatch
+	if *matchNoise {
+		noise = &watermark.NoiseMatch{Seed: *noiseSeed}
+	}
+	var halo *watermark.HaloCleanup
+	if *dehalo < 0 || *dehalo > 1 || *dehaloRadius < 1 {
+		fmt.Fprintln(os.Stderr, "-dehalo takes a strength from 0 to 1 and -dehalo-radius a positive radius")
+		exit(1)
+	}
+	if *dehalo > 0 {
+		halo = &watermark.HaloCleanup{Strength: *dehalo, Radius: *dehaloRadius}
+	}
+	var clipInpaint *watermark.ClipInpaint
+	if *inpaint {
+		clipInpaint = &watermark.ClipInpaint{}
+	}
+	engineOpts, err := engineOptions(*rounding, noise, *excludeMask,
+		watermark.WithDetectionThresholds(*minScore, *minCorrelation),
+		watermark.WithLogoValue(*logoValue),
+		watermark.WithParallelism(*parallelism),
+		watermark.WithMaxConcurrency(*maxConcurrency),
+		watermark.WithHaloCleanup(halo),
+		watermark.WithClipInpaint(clipInpaint))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+	// The package-level detection helpers use the Default engine's
+	// thresholds.
+	engine := watermark.NewEngine(engineOpts...)
+	watermark.SetDefaultEngine(engine)
+
+	if *stdio {
+		if err := runStdio(opts, *imageTimeout); err != nil {
+			fmt.Fprintf(os.Stderr, "stdio: %v\n", err)
+			exit(1)
+		}
+		return
+	}
 
-	if *inputBase64 != "" {
-		img, format, err = watermark.DecodeBase64Image(*inputBase64)
+	if *serve != "" {
+		if err := runServe(*serve, opts, *imageTimeout); err != nil {
+			fmt.Fprintf(os.Stderr, "serve: %v\n", err)
+			exit(1)
+		}
+		return
+	}
+
+	if *rulesFlag != "" && *dir == "" {
+		fmt.Fprintln(os.Stderr, "-rules applies to -dir only")
+		exit(1)
+	}
+
+	if *dir != "" {
+		rules, err := parseRules(*rulesFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-rules: %v\n", err)
+			exit(1)
+		}
+		if len(rules) > 0 {
+			// Rules inspect each input before it is cleaned; keep its decode.
+			watermark.SetDecodeCache(watermark.NewDecodeCache(1))
+		}
+		cfg := batchConfig{
+			Dir:    *dir,
+			OutDir: *output,
+			Walk: walkOptions{
+				Recursive:      *recursive,
+				FollowSymlinks: *followSymlinks,
+				HardLinks:      *preserveHardlinks,
+			},
+			Options:  opts,
+			Preserve: preserve,
+			Thumb:    *thumb,
+			Sidecars: *sidecars,
+			Settings: fmt.Sprintf("profile=%s file=%s corner=%v rounding=%s noise=%v/%d mask=%s format=%v/%d/%v strip=%v",
+				profile.Name, *profileFile, profile.Corner, *rounding, *matchNoise, *noiseSeed, *excludeMask, outFormat, *quality, *lossy, *stripMetadata),
+			Force:        *force,
+			Progress:     *progress,
+			Engine:       engineOpts,
+			Rules:        rules,
+			ImageTimeout: *imageTimeout,
+		}
+		// Only when set, so manifests of earlier runs stay valid.
+		if !resizeTo.IsZero() {
+			cfg.Settings += fmt.Sprintf(" resize=%dx%d/%d", resizeTo.Width, resizeTo.Height, resizeTo.MaxDim)
+		}
+		if *thumb > 0 {
+			cfg.Settings += fmt.Sprintf(" thumb=%d", *thumb)
+		}
+		if *salvage {
+			cfg.Settings += " salvage"
+		}
+		if halo != nil {
+			cfg.Settings += fmt.Sprintf(" dehalo=%g/%d", halo.Strength, halo.Radius)
+		}
+		if *inpaint {
+			cfg.Settings += " inpaint"
+		}
+		if *correction {
+			cfg.Options.Correction = true
+			cfg.Settings += " correction"
+		}
+		for _, r := range rules {
+			cfg.Settings += fmt.Sprintf(" rule=%q", r.Text)
+		}
+		if *minScore > 0 || *minCorrelation > 0 || *logoValue != 255 {
+			cfg.Settings += fmt.Sprintf(" gates=%g/%g logo=%g", *minScore, *minCorrelation, *logoValue)
+		}
+		if !runBatch(cfg) {
+			exit(1)
+		}
+		return
+	}
+
+	plugins, err := startPlugins(pluginPaths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
+	}
+	defer plugins.close()
+
+	if *detectOnly {
+		runDetect(*input, *inputBase64, profile, retry)
+		return
+	}
+
+	source := *input
+	switch {
+	case *inputBase64 != "":
 		source = "base64"
-	} else {
-		inFile, openErr := os.Open(*input)
-		if openErr != nil {
-			fmt.Fprintf(os.Stderr, "open input: %v\n", openErr)
-			os.Exit(1)
+	case *input == "-":
+		source = "stdin"
+	}
+	runSummary.Input = source
+
+	// With -out - the image is the only thing written to stdout, so status
+	// messages move to stderr and pipelines get a clean stream.
+	stdout := os.Stdout
+	toStdout := !*outputBase64 && (*output == "-" || *output == "" && *input == "-")
+	if toStdout {
+		os.Stdout = os.Stderr
+		if *thumb > 0 || *correction {
+			fmt.Fprintln(os.Stderr, "-thumb and -correction need an output file and do not apply to stdout output")
+			exit(1)
 		}
-		defer inFile.Close()
+	}
 
-		img, format, err = watermark.Decode(inFile)
-		source = *input
+	data, err := readInputBytes(*input, *inputBase64, retry)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read input: %v\n", err)
+		exit(1)
+	}
+
+	if watermark.IsAnimatedWebP(data) {
+		if *wmSize > 0 || *search || *searchWhole || *assertRegion || *diffHTML != "" || *diffOut != "" || *debugOut != "" || len(pluginPaths) > 0 {
+			fmt.Fprintln(os.Stderr, "-x/-y/-size, -search, -assert-region-only, -diff-html, -diff-out, -debug-out and -plugin do not support animated WebP inputs")
+			exit(1)
+		}
+		if !resizeTo.IsZero() || *thumb > 0 {
+			fmt.Fprintln(os.Stderr, "warning: -resize, -max-dim and -thumb do not apply to animations; keeping the size")
+		}
+		if *correction {
+			fmt.Fprintln(os.Stderr, "warning: -correction does not apply to animations")
+		}
+		out := outputTarget{Path: *output, Base64: *outputBase64, Stdout: toStdout, Writer: stdout}
+		if err := runAnimation(data, opts, *input, source, out); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			exit(1)
+		}
+		return
 	}
 
+	img, format, err := watermark.DecodeImageBytes(data)
+	if err != nil && *salvage {
+		var damage *watermark.CorruptImageError
+		img, format, damage, err = watermark.SalvageImageBytes(data, profile)
+		if err == nil {
+			fmt.Fprintf(os.Stderr, "warning: %v; cleaning the intact rows\n", damage)
+		}
+	}
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "decode input: %v\n", err)
-		os.Exit(1)
+		exit(1)
+	}
+
+	// Before removal, so misses can be diagnosed too.
+	if *debugOut != "" {
+		if err := writeDebugPNG(*debugOut, engine, img, profile); err != nil {
+			fmt.Fprintf(os.Stderr, "write debug image: %v\n", err)
+			exit(1)
+		}
 	}
 
-	present, score, info, err := watermark.DetectWatermark(img)
+	var (
+		cleaned image.Image
+		info    watermark.Info
+	)
+	if *wmSize > 0 {
+		info.Size = *wmSize
+		info.Position = image.Rect(*wmX, *wmY, *wmX+*wmSize, *wmY+*wmSize).Add(img.Bounds().Min)
+		info.Corner = watermark.NearestCorner(info.Position, img.Bounds())
+		fmt.Printf("Removing %dx%d watermark at %v as given by -x/-y/-size.\n", info.Size, info.Size, info.Position)
+		cleaned, err = plugins.remove(engine, img, info, profile)
+		var geomErr *watermark.GeometryError
+		if errors.As(err, &geomErr) {
+			fmt.Fprintf(os.Stderr, "Watermark %v lies outside the image %v.\n", geomErr.Rect, geomErr.Bounds)
+			exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "remove watermark: %v\n", err)
+			exit(1)
+		}
+	} else {
+		var (
+			present bool
+			score   float64
+		)
+		if *search || *searchWhole {
+			var m watermark.SearchMatch
+			m, err = watermark.DetectSearch(img, watermark.SearchOptions{WholeImage: *searchWhole})
+			present, score, info = m.Present, m.Score, m.Info
+		} else if plugins.detects() {
+			present, score, info, err = plugins.detect(img)
+		} else {
+			present, score, info, err = watermark.DetectWatermarkProfile(img, profile)
+		}
+		var geomErr *watermark.GeometryError
+		if errors.As(err, &geomErr) {
+			fmt.Fprintf(os.Stderr, "Image %v is too small for the expected watermark at %v.\n", geomErr.Bounds, geomErr.Rect)
+			if geomErr.Fits() {
+				fmt.Fprintf(os.Stderr, "Nearest valid placement: %v.\n", geomErr.Nearest)
+			}
+			exit(1)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "detect watermark: %v\n", err)
+			exit(1)
+		}
+		fmt.Printf("Detected visible Gemini watermark (score %.2f) at %dx%d position %v (corner %v).\n", score, info.Size, info.Size, info.Position, info.Corner)
+
+		if !present {
+			fmt.Printf("No visible Gemini watermark detected (score %.2f). Skipping removal.\n", score)
+			runSummary.Status, runSummary.Skipped = "no-watermark", 1
+			if toStdout {
+				// The next command in the pipeline still gets the image.
+				if _, err := stdout.Write(data); err != nil {
+					fmt.Fprintf(os.Stderr, "write output: %v\n", err)
+					exit(1)
+				}
+			}
+			exit(0)
+		}
+
+		// 16-bit PNGs stay 16-bit; the detected placement covers
+		// upscaled and searched watermarks.
+		cleaned, err = plugins.remove(engine, img, info, profile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "remove watermark: %v\n", err)
+			exit(1)
+		}
+	}
+
+	cleaned, err = plugins.postProcess(cleaned)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "detect watermark: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, err)
+		exit(1)
 	}
-	fmt.Printf("Detected visible Gemini watermark (score %.2f) at %dx%d position %v.\n", score, info.Size, info.Size, info.Position)
 
-	if !present {
-		fmt.Printf("No visible Gemini watermark detected (score %.2f). Skipping removal.\n", score)
-		os.Exit(0)
+	if *diffHTML != "" {
+		if err := writeDiffHTML(*diffHTML, source, img, cleaned, info); err != nil {
+			fmt.Fprintf(os.Stderr, "write diff html: %v\n", err)
+			exit(1)
+		}
 	}
 
-	engine := watermark.NewEngine()
-	cleaned, err := engine.RemoveWatermark(img)
+	if *diffOut != "" {
+		if err := writeDiffPNG(*diffOut, img, cleaned); err != nil {
+			fmt.Fprintf(os.Stderr, "write diff: %v\n", err)
+			exit(1)
+		}
+	}
+
+	encoded, encoding, err := opts.Encode(cleaned, data, format)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "remove watermark: %v\n", err)
-		os.Exit(1)
+		fmt.Fprintf(os.Stderr, "encode output: %v\n", err)
+		exit(1)
+	}
+	if dx, dy := cleaned.Bounds().Dx(), cleaned.Bounds().Dy(); !resizeTo.IsZero() {
+		if w, h := resizeTo.Size(dx, dy); w != dx || h != dy {
+			fmt.Printf("Resized %dx%d -> %dx%d.\n", dx, dy, w, h)
+		}
+	}
+	warnings := append(engine.RemovalWarnings(img, profile, info), opts.MetadataWarnings(data, encoded)...)
+	if *wmSize > 0 {
+		warnings = append(warnings, watermark.Warning{Code: watermark.WarnForced, Message: "removed at -x/-y/-size without detection"})
+	}
+	for _, w := range append(warnings, opts.InvisibleWarnings(cleaned)...) {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", w)
+	}
+	// Lossy WebP kept from a lossy source is caught by the check itself.
+	if *assertRegion && (encoding == "jpeg" || outFormat == watermark.OutputWebP && *lossy) {
+		fmt.Fprintln(os.Stderr, "assert region only: needs lossless output, lossy re-encoding changes every pixel")
+		exit(1)
+	}
+	if *assertRegion {
+		region := info.Position
+		if corner != watermark.CornerAuto && *wmSize == 0 && !*search && !*searchWhole {
+			placement, _ := profile.Placement(img.Bounds().Dx(), img.Bounds().Dy())
+			region = placement.Position.Add(img.Bounds().Min)
+		}
+		if err := assertRegionOnly(img, encoded, region); err != nil {
+			fmt.Fprintf(os.Stderr, "assert region only: %v\n", err)
+			exit(1)
+		}
+	}
+	if *verify != "" {
+		region := info.Position
+		if !resizeTo.IsZero() {
+			// The watermark moved with the resize.
+			region = image.Rectangle{}
+		}
+		if err := verifyOutput(os.Stdout, *verify, encoded, region); err != nil {
+			fmt.Fprintf(os.Stderr, "verify: %v\n", err)
+			exit(1)
+		}
+	}
+	if !checkGrowth(len(data), len(encoded), *maxGrowth) {
+		exit(1)
 	}
 
+	runSummary.Processed, runSummary.Detected = 1, 1
 	if *outputBase64 {
-		encoded, encErr := watermark.EncodePNGToBase64(cleaned)
-		if encErr != nil {
-			fmt.Fprintf(os.Stderr, "encode base64 output: %v\n", encErr)
-			os.Exit(1)
-		}
-		fmt.Println(encoded)
+		fmt.Println(base64.StdEncoding.EncodeToString(encoded))
+		runSummary.Output = "base64"
 		fmt.Printf("Processed %s (%s) -> base64 [watermark %dx%d at %v]\n", source, format, info.Size, info.Size, info.Position)
 		return
 	}
 
+	if toStdout {
+		if _, err := stdout.Write(encoded); err != nil {
+			fmt.Fprintf(os.Stderr, "write output: %v\n", err)
+			exit(1)
+		}
+		fmt.Printf("Processed %s (%s) -> stdout (%s) [watermark %dx%d at %v]\n", source, format, encoding, info.Size, info.Size, info.Position)
+		runSummary.Output = "-"
+		return
+	}
+
 	outPath := *output
 	if outPath == "" {
-		base := "output"
-		if *input != "" {
-			base = strings.TrimSuffix(filepath.Base(*input), filepath.Ext(*input))
+		outPath = defaultOutputPath(*input, outputExt(encoding, *input))
+	}
+
+	if err := checkFreeSpace(outPath, uint64(len(encoded))); err != nil {
+		fmt.Fprintf(os.Stderr, "preflight: %v\n", err)
+		exit(1)
+	}
+
+	if err := os.WriteFile(outPath, encoded, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "write output: %v\n", err)
+		exit(1)
+	}
+	if err := preserve.apply(*input, outPath); err != nil {
+		fmt.Fprintf(os.Stderr, "preserve attributes: %v\n", err)
+		exit(1)
+	}
+	if *thumb > 0 {
+		name, err := writeThumb(outPath, cleaned, data, format, opts, *thumb)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "write thumbnail: %v\n", err)
+			exit(1)
+		}
+		fmt.Printf("Thumbnail -> %s\n", name)
+	}
+	if *correction {
+		name, err := writeCorrection(outPath, img, cleaned)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "write correction layer: %v\n", err)
+			exit(1)
+		}
+		fmt.Printf("Correction layer -> %s\n", name)
+	}
+	if *sidecars && *input != "" && !watermark.IsURL(*input) {
+		written, err := copySidecars(*input, outPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "copy sidecars: %v\n", err)
+			exit(1)
+		}
+		for _, name := range written {
+			fmt.Printf("Sidecar -> %s\n", name)
+		}
+	}
+
+	fmt.Printf("Processed %s (%s) -> %s [watermark %dx%d at %v]\n", source, format, outPath, info.Size, info.Size, info.Position)
+	runSummary.Output = outPath
+}
+
+// printCapabilities prints the report of -capabilities.
+func printCapabilities(c watermark.CPUCapabilities) {
+	fmt.Printf("arch: %s\navx2: %v\nneon: %v\nkernel: %s\navailable: %s\n",
+		c.GOARCH, c.AVX2, c.NEON, c.Kernel, strings.Join(c.Available, ", "))
+	if c.Override != "" {
+		fmt.Printf("%s: %s\n", watermark.KernelEnv, c.Override)
+	}
+	if c.Note != "" {
+		fmt.Printf("note: %s\n", c.Note)
+	}
+}
+
+// engineOptions returns the options of the removal engine for the
+// command-line settings, with extra options applied last.
+func engineOptions(rounding string, noise *watermark.NoiseMatch, excludeMask string, extra ...watermark.Option) ([]watermark.Option, error) {
+	mode, ok := watermark.ParseRoundingMode(rounding)
+	if !ok {
+		return nil, fmt.Errorf("unknown rounding mode %q", rounding)
+	}
+	opts := []watermark.Option{watermark.WithRoundingMode(mode), watermark.WithNoiseMatch(noise)}
+
+	if excludeMask != "" {
+		mask, err := readImage(excludeMask)
+		if err != nil {
+			return nil, fmt.Errorf("read exclusion mask: %w", err)
 		}
-		outPath = filepath.Join(filepath.Dir(*input), base+"_unwatermarked.png")
+		opts = append(opts, watermark.WithExclusionMask(mask))
 	}
+	return append(opts, extra...), nil
+}
+
+// lowNice is the nice value used by -low-priority on Unix systems.
+const lowNice = 10
 
-	outFile, err := os.Create(outPath)
+// flagSet reports whether the named flag was passed on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// warnGrowth is the output/input size ratio above which a warning is printed
+// even without -max-growth.
+const warnGrowth = 1.5
+
+// checkGrowth reports the input and output sizes and warns when re-encoding
+// grew the file. It returns false when the growth exceeds a positive limit.
+func checkGrowth(inputSize, outputSize int, limit float64) bool {
+	if inputSize <= 0 {
+		return true
+	}
+
+	growth := float64(outputSize) / float64(inputSize)
+	fmt.Printf("Size: %d -> %d bytes (%.2fx).\n", inputSize, outputSize, growth)
+
+	if limit > 0 && growth > limit {
+		fmt.Fprintf(os.Stderr, "output is %.2fx the input size, above -max-growth %.2f\n", growth, limit)
+		return false
+	}
+	if growth > warnGrowth {
+		fmt.Fprintf(os.Stderr, "warning: output is %.2fx the input size (try -format source or a lower -quality)\n", growth)
+	}
+	return true
+}
+
+// diffGain amplifies -diff-out differences so single-level changes are
+// visible.
+const diffGain = 16
+
+// writeDebugPNG writes the detection overlay of Engine.DebugRender.
+func writeDebugPNG(path string, engine *watermark.Engine, img image.Image, profile watermark.Profile) error {
+	overlay, err := engine.DebugRender(img, profile)
+	if err != nil {
+		return err
+	}
+	data, err := watermark.EncodePNGToBytes(overlay)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Debug image written to %s.\n", path)
+	return nil
+}
+
+// writeDiffPNG writes the amplified difference image and reports the area
+// that changed.
+func writeDiffPNG(path string, original, cleaned image.Image) error {
+	diff, changed, err := watermark.DiffImage(original, cleaned, diffGain)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "create output: %v\n", err)
-		os.Exit(1)
+		return err
 	}
-	defer outFile.Close()
 
-	if err := watermark.EncodePNG(outFile, cleaned); err != nil {
-		fmt.Fprintf(os.Stderr, "encode output: %v\n", err)
-		os.Exit(1)
+	data, err := watermark.EncodePNGToBytes(diff)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
 	}
 
-	fmt.Printf("Processed %s (%s) -> %s [watermark %dx%d at %v]\n", source, format, outPath, info.Size, info.Size, info.Position)
+	fmt.Printf("Diff written to %s (changed area %v).\n", path, changed)
+	return nil
+}
+
+// assertRegionOnly decodes the encoded output again, so encoder effects are
+// included, and verifies it differs from the input only inside region, the
+// watermark rectangle that removal targets.
+func assertRegionOnly(original image.Image, encoded []byte, region image.Rectangle) error {
+	output, _, err := watermark.DecodeImageBytes(encoded)
+	if err != nil {
+		return fmt.Errorf("decode output: %w", err)
+	}
+	return watermark.VerifyRegionOnly(original, output, region)
+}
+
+func readImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	img, _, err := watermark.Decode(f)
+	return img, err
+}
+
+// defaultOutputPath derives <name>_unwatermarked<ext> next to a local input,
+// or in the working directory for URL and base64 inputs.
+func defaultOutputPath(input, ext string) string {
+	if input == "" {
+		return "output_unwatermarked" + ext
+	}
+
+	if watermark.IsURL(input) {
+		name := "output"
+		if u, err := url.Parse(input); err == nil && path.Base(u.Path) != "/" && path.Base(u.Path) != "." {
+			name = strings.TrimSuffix(path.Base(u.Path), path.Ext(u.Path))
+		}
+		return name + "_unwatermarked" + ext
+	}
+
+	base := strings.TrimSuffix(filepath.Base(input), filepath.Ext(input))
+	return filepath.Join(filepath.Dir(input), base+"_unwatermarked"+ext)
+}
+
+// outputExt returns the file extension for an output encoded as format. A
+// JPEG input keeps its own spelling (.jpg, .jpeg, .JPG) for JPEG output.
+func outputExt(format, input string) string {
+	if format != "jpeg" {
+		return "." + format
+	}
+	switch ext := filepath.Ext(input); strings.ToLower(ext) {
+	case ".jpg", ".jpeg":
+		return ext
+	}
+	return ".jpg"
+}
+
+// parseResize builds the output resize from -resize WIDTHxHEIGHT, where one
+// side may be left empty, and -max-dim.
+func parseResize(box string, maxDim int) (watermark.Resize, error) {
+	if maxDim < 0 {
+		return watermark.Resize{}, fmt.Errorf("-max-dim must not be negative")
+	}
+	r := watermark.Resize{MaxDim: maxDim}
+	if box == "" {
+		return r, nil
+	}
+	w, h, ok := strings.Cut(strings.ToLower(box), "x")
+	if !ok || w == "" && h == "" {
+		return watermark.Resize{}, fmt.Errorf("invalid -resize %q, want WIDTHxHEIGHT, WIDTHx or xHEIGHT", box)
+	}
+	for _, side := range []struct {
+		s string
+		v *int
+	}{{w, &r.Width}, {h, &r.Height}} {
+		if side.s == "" {
+			continue
+		}
+		n, err := strconv.Atoi(side.s)
+		if err != nil || n <= 0 {
+			return watermark.Resize{}, fmt.Errorf("invalid -resize %q, sides must be positive integers", box)
+		}
+		*side.v = n
+	}
+	return r, nil
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// manifestName is the file in the output directory that records what each
+// input produced, so reruns can skip unchanged inputs.
+const manifestName = ".gwatermark-manifest.json"
+
+// runManifest records the outcome of a -dir run.
+type runManifest struct {
+	// Settings fingerprints the options that affect outputs. A rerun with
+	// different settings reprocesses everything.
+	Settings string                    `json:"settings"`
+	Files    map[string]manifestRecord `json:"files"`
+	// Summary holds the statistics of the run that wrote the manifest.
+	Summary *batchStats `json:"summary,omitempty"`
+}
+
+// manifestRecord is keyed by manifestKey of the input's relative path.
+type manifestRecord struct {
+	Input string `json:"input"`
+	// Output is the output path relative to the output directory, empty
+	// when no watermark was found.
+	Output     string `json:"output,omitempty"`
+	OutputHash string `json:"outputHash,omitempty"`
+}
+
+// loadManifest reads the manifest in dir. A missing or unreadable manifest
+// yields an empty one, so everything is processed.
+func loadManifest(dir string) *runManifest {
+	m := &runManifest{Files: make(map[string]manifestRecord)}
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		return m
+	}
+	if err := json.Unmarshal(data, m); err != nil || m.Files == nil {
+		return &runManifest{Files: make(map[string]manifestRecord)}
+	}
+	return m
+}
+
+// save writes the manifest atomically.
+func (m *runManifest) save(dir string) error {
+	data, err := marshalJSON(m, true)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, manifestName+".*")
+	if err != nil {
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(dir, manifestName))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write manifest: %w", err)
+	}
+	return nil
+}
+
+// upToDate reports whether rel, whose content hashes to inputHash, was
+// already handled with the same settings. For inputs that produced output,
+// the output must still exist unmodified. It returns the output path, or ""
+// for inputs without a watermark.
+func (m *runManifest) upToDate(settings, outDir, rel, inputHash string) (string, bool) {
+	rec, ok := m.Files[manifestKey(rel)]
+	if !ok || m.Settings != settings || rec.Input != inputHash {
+		return "", false
+	}
+	if rec.Output == "" {
+		return "", true
+	}
+
+	out := filepath.Join(outDir, filepath.FromSlash(rec.Output))
+	data, err := os.ReadFile(out)
+	if err != nil || hashBytes(data) != rec.OutputHash {
+		return "", false
+	}
+	return out, true
+}
+
+// record stores the outcome for rel. out is empty when no watermark was
+// found.
+func (m *runManifest) record(outDir, rel, inputHash, out string, output []byte) error {
+	rec := manifestRecord{Input: inputHash}
+	if out != "" {
+		relOut, err := filepath.Rel(outDir, out)
+		if err != nil {
+			return err
+		}
+		rec.Output = manifestKey(relOut)
+		rec.OutputHash = hashBytes(output)
+	}
+	m.Files[manifestKey(rel)] = rec
+	return nil
+}
+
+// manifestKey returns rel slash-separated, so a manifest written on Windows
+// matches on Unix and the other way round, and with any bytes that are not
+// UTF-8 escaped by utf8Safe. An output whose name needed escaping is not
+// found again under it and is rebuilt on the next run.
+func manifestKey(rel string) string {
+	return utf8Safe(filepath.ToSlash(rel))
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"image"
+	"strings"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	"github.com/gcslaoli/gemini-watermark-remover-go/plugin"
+)
+
+// stringList is a flag that may be repeated.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// pluginSet holds the -plugin processes. Detectors replace the built-in
+// detection, the first remover replaces the built-in removal, and every
+// post-processor runs on the cleaned image in flag order. A nil set has no
+// plugins.
+type pluginSet struct {
+	all []*plugin.Plugin
+}
+
+func startPlugins(paths []string) (*pluginSet, error) {
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	s := &pluginSet{}
+	for _, path := range paths {
+		p, err := plugin.Start(context.Background(), path)
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+		s.all = append(s.all, p)
+	}
+	return s, nil
+}
+
+func (s *pluginSet) close() {
+	if s == nil {
+		return
+	}
+	for _, p := range s.all {
+		p.Close()
+	}
+}
+
+func (s *pluginSet) with(method string) []*plugin.Plugin {
+	if s == nil {
+		return nil
+	}
+	var ps []*plugin.Plugin
+	for _, p := range s.all {
+		if p.Has(method) {
+			ps = append(ps, p)
+		}
+	}
+	return ps
+}
+
+// detects reports whether a plugin replaces the built-in detection.
+func (s *pluginSet) detects() bool {
+	return len(s.with(plugin.MethodDetect)) > 0
+}
+
+// detect asks the detector plugins in order and returns the first
+// watermark found, or the highest score when none finds one.
+func (s *pluginSet) detect(img image.Image) (present bool, score float64, info watermark.Info, err error) {
+	for i, p := range s.with(plugin.MethodDetect) {
+		d, err := p.Detect(context.Background(), img)
+		if err != nil {
+			return false, 0, watermark.Info{}, err
+		}
+		if d.Present {
+			info = watermark.Info{Size: d.Rect.Dx(), Position: d.Rect, Corner: watermark.NearestCorner(d.Rect, img.Bounds())}
+			return true, d.Score, info, nil
+		}
+		if i == 0 || d.Score > score {
+			score = d.Score
+		}
+	}
+	return false, score, watermark.Info{}, nil
+}
+
+// remove cleans the watermark at info with the first remover plugin, or
+// with engine and the logo of profile when there is none.
+func (s *pluginSet) remove(engine *watermark.Engine, img image.Image, info watermark.Info, profile watermark.Profile) (image.Image, error) {
+	if removers := s.with(plugin.MethodRemove); len(removers) > 0 {
+		return removers[0].Remove(context.Background(), img, info.Position)
+	}
+	return engine.RemoveWatermarkDepthInfo(img, info, profile)
+}
+
+// removes reports whether a plugin replaces the built-in removal.
+func (s *pluginSet) removes() bool {
+	return len(s.with(plugin.MethodRemove)) > 0
+}
+
+// postProcess runs every post-processor plugin on img in turn.
+func (s *pluginSet) postProcess(img image.Image) (image.Image, error) {
+	for _, p := range s.with(plugin.MethodPostProcess) {
+		var err error
+		if img, err = p.PostProcess(context.Background(), img); err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	"github.com/gcslaoli/gemini-watermark-remover-go/imagecmp"
+	"github.com/gcslaoli/gemini-watermark-remover-go/testutil"
+)
+
+func TestPluginSetRemoveUsesProfile(t *testing.T) {
+	img, _, err := watermark.DecodeImageBytes(testutil.WatermarkedPNG(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := watermark.WatermarkInfo(img.Bounds().Dx(), img.Bounds().Dy())
+	grey := watermark.GeminiProfile()
+	grey.LogoColor = color.Gray{Y: 192}
+
+	// Without remover plugins the engine cleans with the active profile's
+	// logo, as the -search path relies on.
+	var plugins *pluginSet
+	engine := watermark.NewEngine()
+	got, err := plugins.remove(engine, img, info, grey)
+	if err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	want, err := engine.RemoveWatermarkDepthInfo(img, info, grey)
+	if err != nil {
+		t.Fatalf("RemoveWatermarkDepthInfo: %v", err)
+	}
+	if err := imagecmp.Diff(want, got, imagecmp.Options{}); err != nil {
+		t.Fatalf("plugin-less removal ignored the profile: %v", err)
+	}
+
+	gemini, err := engine.RemoveWatermarkDepthInfo(img, info, watermark.GeminiProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imagecmp.Diff(gemini, got, imagecmp.Options{}) == nil {
+		t.Fatalf("grey logo removal matches the white Gemini logo")
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+)
+
+// preserveOptions selects which attributes of an input file are copied to
+// its output, for backup and sync tools that compare them.
+type preserveOptions struct {
+	Times bool
+	Mode  bool
+	// Xattrs copies extended attributes on Linux and macOS; see copyXattrs.
+	Xattrs         bool
+	DropQuarantine bool
+}
+
+// apply copies the selected attributes of the local file src to dst. The
+// times are set last, since changing the mode does not touch them but
+// writing does.
+func (p preserveOptions) apply(src, dst string) error {
+	if !p.Times && !p.Mode && !p.Xattrs {
+		return nil
+	}
+
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if p.Mode {
+		if err := os.Chmod(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if p.Xattrs {
+		if err := copyXattrs(src, dst, p.DropQuarantine); err != nil {
+			return err
+		}
+	}
+	if p.Times {
+		if err := os.Chtimes(dst, accessTime(info), info.ModTime()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestPreserveOptionsApply(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "in.png"), filepath.Join(dir, "out.png")
+	for _, name := range []string{src, dst} {
+		if err := os.WriteFile(name, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mtime := time.Date(2020, 5, 17, 8, 30, 0, 0, time.UTC)
+	if err := os.Chtimes(src, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(src, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := (preserveOptions{Times: true, Mode: true}).apply(src, dst); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Errorf("mtime = %v, want %v", info.ModTime(), mtime)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+}
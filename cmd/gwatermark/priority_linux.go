@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassIdle  = 3
+	ioprioClassShift = 13
+)
+
+// lowerPriority renices the process to lowNice and moves it to the idle I/O
+// class. Linux applies both per thread, so every existing thread is updated;
+// threads started later inherit the setting from their creator.
+func lowerPriority() error {
+	tasks, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, task := range tasks {
+		tid, err := strconv.Atoi(task.Name())
+		if err != nil {
+			continue
+		}
+		if err := syscall.Setpriority(syscall.PRIO_PROCESS, tid, lowNice); err != nil {
+			errs = append(errs, err)
+		}
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), ioprioClassIdle<<ioprioClassShift); errno != 0 {
+			errs = append(errs, errno)
+		}
+	}
+	return errors.Join(errs...)
+}
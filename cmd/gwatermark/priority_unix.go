@@ -0,0 +1,11 @@
+//go:build unix && !linux
+
+package main
+
+import "syscall"
+
+// lowerPriority renices the whole process to lowNice. The BSDs and macOS have
+// no portable I/O priority call, so only CPU priority changes.
+func lowerPriority() error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, 0, lowNice)
+}
@@ -0,0 +1,19 @@
+package main
+
+import "syscall"
+
+// processModeBackgroundBegin lowers both CPU and I/O priority of the process.
+const processModeBackgroundBegin = 0x00100000
+
+// lowerPriority switches the process into background processing mode.
+func lowerPriority() error {
+	proc, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return err
+	}
+	r, _, err := syscall.NewLazyDLL("kernel32.dll").NewProc("SetPriorityClass").Call(uintptr(proc), processModeBackgroundBegin)
+	if r == 0 {
+		return err
+	}
+	return nil
+}
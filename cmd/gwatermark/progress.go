@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// progressWidth is the number of cells in the bar.
+const progressWidth = 30
+
+// progressBar keeps a one-line progress bar for -dir -progress at the
+// bottom of the terminal:
+//
+//	[=========>                    ]  12/40  photos/beach.png  blend 50%
+//
+// Lines printed with printf appear above it. A nil *progressBar prints
+// lines plainly and draws nothing, so callers need not check.
+type progressBar struct {
+	mu    sync.Mutex
+	w     io.Writer
+	total int
+	done  int
+	file  string
+	stage string
+	pct   float64
+	drawn time.Time
+	// cleared stops redraws once the run is over, for reports of work it
+	// abandoned; see batchConfig.ImageTimeout.
+	cleared bool
+}
+
+func newProgressBar(w io.Writer, total int) *progressBar {
+	return &progressBar{w: w, total: total}
+}
+
+// startFile shows rel as the file being processed, after done others.
+func (b *progressBar) startFile(done int, rel string) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.done, b.file, b.stage, b.pct = done, rel, "", 0
+	b.draw()
+}
+
+// progress follows the engine's stages; see watermark.Engine.SetProgressFunc.
+// Redraws are limited to ten a second.
+func (b *progressBar) progress(stage string, pct float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.stage, b.pct = stage, pct
+	if !b.cleared && time.Since(b.drawn) >= 100*time.Millisecond {
+		b.draw()
+	}
+}
+
+// printf prints a line to w above the bar.
+func (b *progressBar) printf(w io.Writer, format string, args ...any) {
+	if b == nil {
+		fmt.Fprintf(w, format, args...)
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fmt.Fprint(b.w, "\r\033[K")
+	fmt.Fprintf(w, format, args...)
+	b.draw()
+}
+
+// clear removes the bar, at the end of the run.
+func (b *progressBar) clear() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.cleared = true
+	fmt.Fprint(b.w, "\r\033[K")
+}
+
+func (b *progressBar) draw() {
+	b.drawn = time.Now()
+	fmt.Fprint(b.w, "\r\033[K"+b.line())
+}
+
+// line renders the bar without control characters.
+func (b *progressBar) line() string {
+	filled := 0
+	if b.total > 0 {
+		filled = b.done * progressWidth / b.total
+	}
+	bar := strings.Repeat("=", filled)
+	if filled < progressWidth {
+		bar += ">" + strings.Repeat(" ", progressWidth-filled-1)
+	}
+	line := fmt.Sprintf("[%s] %3d/%d", bar, b.done, b.total)
+	if b.file != "" {
+		line += "  " + b.file
+	}
+	if b.stage != "" {
+		line += fmt.Sprintf("  %s %.0f%%", b.stage, b.pct)
+	}
+	return line
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProgressBar(t *testing.T) {
+	var term, out bytes.Buffer
+	b := newProgressBar(&term, 4)
+	b.startFile(2, "a/photo.png")
+	b.progress("blend", 50)
+	if got, want := b.line(), "[===============>              ]   2/4  a/photo.png  blend 50%"; got != want {
+		t.Errorf("line = %q, want %q", got, want)
+	}
+
+	b.printf(&out, "done %s\n", "a/photo.png")
+	if out.String() != "done a/photo.png\n" {
+		t.Errorf("printed %q", out.String())
+	}
+	// The bar is cleared before the line and drawn again after it.
+	if !strings.HasSuffix(term.String(), "\r\033[K"+b.line()) {
+		t.Errorf("bar not redrawn after printf: %q", term.String())
+	}
+
+	var nilBar *progressBar
+	out.Reset()
+	nilBar.startFile(0, "x")
+	nilBar.printf(&out, "skip %s\n", "x")
+	nilBar.clear()
+	if out.String() != "skip x\n" {
+		t.Errorf("nil bar printed %q", out.String())
+	}
+}
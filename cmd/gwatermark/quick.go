@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// runQuick implements "gwatermark quick FILE...": the zero-flag command for
+// OS automation hooks (Shortcuts and Automator quick actions, Explorer's
+// Send To menu, PowerToys shortcuts). Each file is cleaned into
+// <name>_unwatermarked.<ext> beside it, keeping its format. Since such hooks
+// have no terminal, files without a watermark and failures are reported in
+// a message box as well as on stderr. It returns false when any file failed.
+func runQuick(files []string) bool {
+	if len(files) == 0 {
+		quickAlert("usage: gwatermark quick FILE...", true)
+		return false
+	}
+
+	var failed, clean []string
+	for _, path := range files {
+		out, present, err := quickClean(path)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "fail %s: %v\n", path, err)
+			failed = append(failed, fmt.Sprintf("%s: %v", filepath.Base(path), err))
+		case !present:
+			fmt.Printf("skip %s: no watermark\n", path)
+			clean = append(clean, filepath.Base(path))
+		default:
+			fmt.Printf("done %s -> %s\n", path, out)
+		}
+	}
+
+	var msg []string
+	if len(failed) > 0 {
+		msg = append(msg, "Could not clean:\n"+strings.Join(failed, "\n"))
+	}
+	if len(clean) > 0 {
+		msg = append(msg, "No Gemini watermark found in:\n"+strings.Join(clean, "\n"))
+	}
+	if len(msg) > 0 {
+		quickAlert(strings.Join(msg, "\n\n"), len(failed) > 0)
+	}
+	return len(failed) == 0
+}
+
+// quickClean cleans one file and returns the output path, or present=false
+// when the image has no watermark.
+func quickClean(path string) (out string, present bool, err error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	result, err := watermark.ProcessBytes(data, watermark.Options{Output: watermark.OutputSource})
+	if err != nil || !result.Present {
+		return "", false, err
+	}
+	out = strings.TrimSuffix(path, filepath.Ext(path)) + "_unwatermarked" + outputExt(result.Format, path)
+	if err := checkFreeSpace(out, uint64(len(result.Output))); err != nil {
+		return "", false, err
+	}
+	if err := os.WriteFile(out, result.Output, 0o644); err != nil {
+		return "", false, err
+	}
+	return out, true, nil
+}
+
+// quickAlert shows msg in a message box, or prints it to stderr where no
+// dialog can be shown.
+func quickAlert(msg string, isError bool) {
+	if err := showDialog("gwatermark", msg, isError); err != nil {
+		if !errors.Is(err, errNoDialog) {
+			fmt.Fprintf(os.Stderr, "dialog: %v\n", err)
+		}
+		fmt.Fprintln(os.Stderr, msg)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gcslaoli/gemini-watermark-remover-go/testutil"
+)
+
+func TestQuickClean(t *testing.T) {
+	dir := t.TempDir()
+	samples := map[string][]byte{"image.png": testutil.WatermarkedPNG(t), "nowater.jpg": testutil.CleanJPEG(t)}
+	for name, data := range samples {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	out, present, err := quickClean(filepath.Join(dir, "image.png"))
+	if err != nil || !present {
+		t.Fatalf("quickClean(image.png) = %v, %v", present, err)
+	}
+	if want := filepath.Join(dir, "image_unwatermarked.png"); out != want {
+		t.Errorf("output = %s, want %s", out, want)
+	}
+	if _, err := os.Stat(out); err != nil {
+		t.Error(err)
+	}
+
+	if _, present, err := quickClean(filepath.Join(dir, "nowater.jpg")); err != nil || present {
+		t.Errorf("quickClean(nowater.jpg) = %v, %v, want no watermark", present, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "nowater_unwatermarked.jpg")); !os.IsNotExist(err) {
+		t.Error("clean image was written")
+	}
+	if _, _, err := quickClean(filepath.Join(dir, "missing.png")); err == nil {
+		t.Error("missing file: no error")
+	}
+}
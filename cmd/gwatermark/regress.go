@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// Exit statuses of "gwatermark regress".
+const (
+	regressExitOK        = 0
+	regressExitRegressed = 1
+	regressExitError     = 2
+)
+
+// regressBaseline is the file "gwatermark regress" compares against: the
+// metrics of one corpus version and the per-file results behind them.
+type regressBaseline struct {
+	Corpus  string         `json:"corpus"`
+	Metrics regressMetrics `json:"metrics"`
+	Files   []regressFile  `json:"files"`
+}
+
+// regressMetrics summarizes a corpus run. Precision and recall grade
+// detection against the manifest's verdicts. The pixel metrics grade
+// removal on the watermarked samples: the detection score left in the
+// cleaned image, and how many cleaned images changed outside the watermark
+// rectangle.
+type regressMetrics struct {
+	Files          int     `json:"files"`
+	TruePositives  int     `json:"truePositives"`
+	FalsePositives int     `json:"falsePositives"`
+	FalseNegatives int     `json:"falseNegatives"`
+	Precision      float64 `json:"precision"`
+	Recall         float64 `json:"recall"`
+	MeanResidual   float64 `json:"meanResidual"`
+	MaxResidual    float64 `json:"maxResidual"`
+	Spill          int     `json:"spill"`
+}
+
+// regressFile is the result for one sample.
+type regressFile struct {
+	Name        string  `json:"name"`
+	Watermarked bool    `json:"watermarked"`
+	Present     bool    `json:"present"`
+	Score       float64 `json:"score"`
+	Residual    float64 `json:"residual,omitempty"`
+	Spill       bool    `json:"spill,omitempty"`
+}
+
+// runRegress implements "gwatermark regress": it measures detection and
+// removal on the sample corpus and compares the result with a stored
+// baseline, so algorithm changes can be checked before they are submitted.
+// The exit status is 1 on a regression and 2 when the run itself fails.
+// With -update it writes the baseline instead.
+func runRegress(args []string) int {
+	fs := flag.NewFlagSet("regress", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: gwatermark regress [-baseline testdata/baseline.json] [-corpus testdata/corpus] [options]")
+		fs.PrintDefaults()
+	}
+	baselinePath := fs.String("baseline", filepath.Join("testdata", "baseline.json"), "Baseline metrics to compare against")
+	dir := fs.String("corpus", filepath.Join("testdata", "corpus"), "Directory the corpus was fetched into (see fetch-testdata)")
+	manifestPath := fs.String("manifest", filepath.Join("testdata", "corpus.json"), "Corpus manifest with the expected verdicts")
+	update := fs.Bool("update", false, "Write the current metrics to -baseline instead of comparing")
+	tolerance := fs.Float64("tolerance", 1, "Residual score increase allowed before it counts as a regression")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return regressExitOK
+		}
+		return regressExitError
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return regressExitError
+	}
+
+	m, err := loadCorpusManifest(*manifestPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return regressExitError
+	}
+	current, err := measureCorpus(m, *dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v (run gwatermark fetch-testdata)\n", err)
+		return regressExitError
+	}
+
+	if *update {
+		data, err := marshalJSON(current, true)
+		if err == nil {
+			err = writeFileAtomic(*baselinePath, data)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return regressExitError
+		}
+		fmt.Printf("Baseline written to %s.\n", *baselinePath)
+		return regressExitOK
+	}
+
+	var base regressBaseline
+	data, err := os.ReadFile(*baselinePath)
+	if err == nil {
+		err = json.Unmarshal(data, &base)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "read baseline: %v\n", err)
+		return regressExitError
+	}
+	if base.Corpus != current.Corpus {
+		fmt.Fprintf(os.Stderr, "baseline is for corpus %s, not %s; rerun with -update on the base revision\n", base.Corpus, current.Corpus)
+		return regressExitError
+	}
+	if !compareBaseline(os.Stdout, base, current, *tolerance) {
+		return regressExitRegressed
+	}
+	return regressExitOK
+}
+
+// measureCorpus detects the watermark in every file of m in dir and removes
+// it from the watermarked ones.
+func measureCorpus(m corpusManifest, dir string) (regressBaseline, error) {
+	run := regressBaseline{Corpus: m.Version}
+	for _, f := range m.Files {
+		rf, err := measureFile(filepath.Join(dir, filepath.FromSlash(f.Name)), f.Watermarked)
+		if err != nil {
+			return run, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		rf.Name = f.Name
+		run.Files = append(run.Files, rf)
+	}
+	run.Metrics = summarizeRegress(run.Files)
+	return run, nil
+}
+
+func measureFile(path string, watermarked bool) (regressFile, error) {
+	rf := regressFile{Watermarked: watermarked}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rf, err
+	}
+	d, err := watermark.DetectResultBytes(data, watermark.GeminiProfile())
+	if err != nil {
+		return rf, err
+	}
+	rf.Present, rf.Score = d.Present, d.Score
+	if !watermarked {
+		return rf, nil
+	}
+
+	// PNG output keeps encoder loss out of the pixel metrics.
+	r, err := watermark.ProcessBytes(data, watermark.Options{Output: watermark.OutputPNG})
+	if err != nil {
+		return rf, err
+	}
+	after, err := watermark.DetectResultBytes(r.Output, watermark.GeminiProfile())
+	if err != nil {
+		return rf, fmt.Errorf("detect in output: %w", err)
+	}
+	rf.Residual = after.Score
+	if r.Present {
+		original, _, err := watermark.DecodeImageBytes(data)
+		if err != nil {
+			return rf, err
+		}
+		err = assertRegionOnly(original, r.Output, r.Info.Position)
+		if err != nil && !errors.Is(err, watermark.ErrOutsideRegion) {
+			return rf, err
+		}
+		rf.Spill = err != nil
+	}
+	return rf, nil
+}
+
+func summarizeRegress(files []regressFile) regressMetrics {
+	var m regressMetrics
+	var residuals int
+	for _, f := range files {
+		m.Files++
+		switch {
+		case f.Present && f.Watermarked:
+			m.TruePositives++
+		case f.Present:
+			m.FalsePositives++
+		case f.Watermarked:
+			m.FalseNegatives++
+		}
+		if f.Watermarked {
+			if residuals == 0 || f.Residual > m.MaxResidual {
+				m.MaxResidual = f.Residual
+			}
+			residuals++
+			m.MeanResidual += f.Residual
+		}
+		if f.Spill {
+			m.Spill++
+		}
+	}
+	// With nothing to get wrong, precision and recall are perfect.
+	m.Precision, m.Recall = 1, 1
+	if n := m.TruePositives + m.FalsePositives; n > 0 {
+		m.Precision = float64(m.TruePositives) / float64(n)
+	}
+	if n := m.TruePositives + m.FalseNegatives; n > 0 {
+		m.Recall = float64(m.TruePositives) / float64(n)
+	}
+	if residuals > 0 {
+		m.MeanResidual /= float64(residuals)
+	}
+	return m
+}
+
+// compareBaseline prints every metric of current next to base, followed by
+// the files whose verdict or cleanliness got worse, and reports whether
+// nothing regressed. Residual scores may rise by up to tolerance.
+func compareBaseline(w io.Writer, base, current regressBaseline, tolerance float64) bool {
+	ok := true
+	line := func(name, format string, got, want float64, worse bool) {
+		mark := ""
+		if worse {
+			mark, ok = "  REGRESSED", false
+		}
+		fmt.Fprintf(w, "%-14s "+format+"  (baseline "+format+")%s\n", name, got, want, mark)
+	}
+	b, c := base.Metrics, current.Metrics
+	line("precision", "%.3f", c.Precision, b.Precision, c.Precision < b.Precision)
+	line("recall", "%.3f", c.Recall, b.Recall, c.Recall < b.Recall)
+	line("mean residual", "%.2f", c.MeanResidual, b.MeanResidual, c.MeanResidual > b.MeanResidual+tolerance)
+	line("max residual", "%.2f", c.MaxResidual, b.MaxResidual, c.MaxResidual > b.MaxResidual+tolerance)
+	line("spill", "%.0f", float64(c.Spill), float64(b.Spill), c.Spill > b.Spill)
+
+	before := make(map[string]regressFile, len(base.Files))
+	for _, f := range base.Files {
+		before[f.Name] = f
+	}
+	for _, f := range current.Files {
+		was, known := before[f.Name]
+		switch {
+		case !known:
+		case was.Present == was.Watermarked && f.Present != f.Watermarked:
+			fmt.Fprintf(w, "  %s: present = %v (score %.2f), was %v (score %.2f)\n", f.Name, f.Present, f.Score, was.Present, was.Score)
+		case f.Spill && !was.Spill:
+			fmt.Fprintf(w, "  %s: now changes pixels outside the watermark rectangle\n", f.Name)
+		case f.Residual > was.Residual+tolerance:
+			fmt.Fprintf(w, "  %s: residual %.2f, was %.2f\n", f.Name, f.Residual, was.Residual)
+		}
+	}
+	if ok {
+		fmt.Fprintln(w, "No regressions.")
+	}
+	return ok
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompareBaseline(t *testing.T) {
+	files := []regressFile{
+		{Name: "a.png", Watermarked: true, Present: true, Score: 90, Residual: 0.5},
+		{Name: "b.png", Watermarked: true, Present: true, Score: 60, Residual: -2},
+		{Name: "c.jpg", Present: false, Score: 5},
+	}
+	base := regressBaseline{Corpus: "v1", Files: files}
+	base.Metrics = summarizeRegress(base.Files)
+	if m := base.Metrics; m.Precision != 1 || m.Recall != 1 || m.MaxResidual != 0.5 || m.MeanResidual != -0.75 {
+		t.Fatalf("metrics = %+v", m)
+	}
+
+	var out bytes.Buffer
+	if !compareBaseline(&out, base, base, 1) {
+		t.Errorf("identical run regressed:\n%s", out.String())
+	}
+
+	worse := regressBaseline{Corpus: "v1", Files: append([]regressFile(nil), files...)}
+	worse.Files[1].Present = false
+	worse.Files[0].Spill = true
+	worse.Metrics = summarizeRegress(worse.Files)
+	out.Reset()
+	if compareBaseline(&out, base, worse, 1) {
+		t.Errorf("worse run passed:\n%s", out.String())
+	}
+	for _, want := range []string{"recall         0.500  (baseline 1.000)  REGRESSED", "spill          1  (baseline 0)  REGRESSED", "b.png: present = false", "a.png: now changes pixels"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "precision      1.000  (baseline 1.000)  REGRESSED") {
+		t.Errorf("precision marked regressed:\n%s", out.String())
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// batchRule is one statement of -rules:
+//
+//	skip [if COND]           leave the file out of the run
+//	format FORMAT [if COND]  encode the file as png, jpeg, webp or source
+//
+// COND is an expr-lang expression over ruleEnv.
+type batchRule struct {
+	Text   string
+	Action string
+	Format watermark.OutputFormat
+	cond   *vm.Program
+}
+
+// ruleEnv is what rule conditions see of each file: its name and size, its
+// decoded properties and the detection result.
+type ruleEnv struct {
+	Path        string  `expr:"path"`
+	Name        string  `expr:"name"`
+	Ext         string  `expr:"ext"`
+	Bytes       int     `expr:"bytes"`
+	Width       int     `expr:"width"`
+	Height      int     `expr:"height"`
+	Format      string  `expr:"format"`
+	ColorModel  string  `expr:"colorModel"`
+	Orientation int     `expr:"orientation"`
+	Present     bool    `expr:"present"`
+	Score       float64 `expr:"score"`
+	Correlation float64 `expr:"correlation"`
+	Confidence  string  `expr:"confidence"`
+	Corner      string  `expr:"corner"`
+	Size        int     `expr:"size"`
+}
+
+var ruleSyntax = regexp.MustCompile(`^(skip|format\s+(\S+))(?:\s+if\s+(.+))?$`)
+
+// parseRules parses rules separated by newlines or semicolons; # starts a
+// comment line. A value starting with @ names a file to read them from.
+func parseRules(src string) ([]batchRule, error) {
+	if name, ok := strings.CutPrefix(src, "@"); ok {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		src = string(data)
+	}
+
+	var rules []batchRule
+	for _, text := range splitRules(src) {
+		m := ruleSyntax.FindStringSubmatch(text)
+		if m == nil {
+			return nil, fmt.Errorf("rule %q: want \"skip [if COND]\" or \"format FORMAT [if COND]\"", text)
+		}
+		r := batchRule{Text: text, Action: m[1]}
+		if m[2] != "" {
+			f, ok := watermark.ParseOutputFormat(m[2])
+			if !ok {
+				return nil, fmt.Errorf("rule %q: unknown output format %q", text, m[2])
+			}
+			r.Action, r.Format = "format", f
+		}
+		if m[3] != "" {
+			prog, err := expr.Compile(m[3], expr.Env(ruleEnv{}), expr.AsBool())
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %w", text, err)
+			}
+			r.cond = prog
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// splitRules splits src at newlines and at semicolons outside quotes, and
+// drops blank and comment lines.
+func splitRules(src string) []string {
+	var rules []string
+	var cur strings.Builder
+	var quote rune
+	flush := func() {
+		if text := strings.TrimSpace(cur.String()); text != "" && !strings.HasPrefix(text, "#") {
+			rules = append(rules, text)
+		}
+		cur.Reset()
+	}
+	for _, c := range src {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\'' || c == '`'):
+			quote = c
+		case quote == 0 && (c == ';' || c == '\n'):
+			flush()
+			continue
+		}
+		cur.WriteRune(c)
+	}
+	flush()
+	return rules
+}
+
+// matchRules evaluates rules in order against env. It returns the first
+// matching skip rule, if any, and otherwise applies the first matching
+// format rule to opts.
+func matchRules(rules []batchRule, env ruleEnv, opts *watermark.Options) (*batchRule, error) {
+	formatSet := false
+	for i := range rules {
+		r := &rules[i]
+		if r.Action == "format" && formatSet {
+			continue
+		}
+		if r.cond != nil {
+			out, err := expr.Run(r.cond, env)
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: %w", r.Text, err)
+			}
+			if !out.(bool) {
+				continue
+			}
+		}
+		if r.Action == "skip" {
+			return r, nil
+		}
+		opts.Output, formatSet = r.Format, true
+	}
+	return nil, nil
+}
+
+// newRuleEnv describes the input at rel for rule conditions.
+func newRuleEnv(rel string, data []byte, report watermark.Report) ruleEnv {
+	env := ruleEnv{
+		Path:        filepath.ToSlash(rel),
+		Name:        filepath.Base(rel),
+		Ext:         strings.ToLower(strings.TrimPrefix(filepath.Ext(rel), ".")),
+		Bytes:       len(data),
+		Width:       report.Width,
+		Height:      report.Height,
+		Format:      report.Format,
+		ColorModel:  report.ColorModel,
+		Orientation: report.Orientation,
+		Present:     report.Present,
+		Score:       report.Score,
+		Correlation: report.Correlation,
+		Confidence:  report.Confidence.String(),
+	}
+	if report.Present {
+		env.Corner, env.Size = report.Info.Corner.String(), report.Info.Size
+	}
+	return env
+}
+
+// ruleSkipError reports a file left out by a skip rule.
+type ruleSkipError struct {
+	rule string
+}
+
+func (e *ruleSkipError) Error() string {
+	return fmt.Sprintf("rule %q", e.rule)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	"github.com/gcslaoli/gemini-watermark-remover-go/testutil"
+)
+
+func TestParseRules(t *testing.T) {
+	rules, err := parseRules("# small ones\nskip if width < 600 or score < 8; format jpeg if name contains \";\"\n\nformat webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 || rules[0].Action != "skip" || rules[1].Format != watermark.OutputJPEG || rules[2].cond != nil {
+		t.Fatalf("rules = %+v", rules)
+	}
+
+	for _, bad := range []string{"delete if width > 1", "format gif", "skip if width >", "skip if name", "skip if nosuch > 1"} {
+		if _, err := parseRules(bad); err == nil {
+			t.Errorf("parseRules(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestMatchRules(t *testing.T) {
+	rules, err := parseRules(`format png if ext == "png"; skip if width < 600 or score < 8; format jpeg; format webp`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		env    ruleEnv
+		skip   bool
+		format watermark.OutputFormat
+	}{
+		{ruleEnv{Ext: "jpg", Width: 500, Score: 20}, true, watermark.OutputSource},
+		{ruleEnv{Ext: "jpg", Width: 800, Score: 5}, true, watermark.OutputSource},
+		{ruleEnv{Ext: "jpg", Width: 800, Score: 20}, false, watermark.OutputJPEG},
+		{ruleEnv{Ext: "png", Width: 800, Score: 20}, false, watermark.OutputPNG},
+	} {
+		opts := watermark.Options{Output: watermark.OutputSource}
+		skip, err := matchRules(rules, tc.env, &opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (skip != nil) != tc.skip || !tc.skip && opts.Output != tc.format {
+			t.Errorf("%+v: skip %v, format %v; want %v, %v", tc.env, skip, opts.Output, tc.skip, tc.format)
+		}
+	}
+}
+
+func TestProcessDirRules(t *testing.T) {
+	watermarked := testutil.WatermarkedPNG(t)
+	dir := t.TempDir()
+	for _, name := range []string{"keep.png", "drop.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), watermarked, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rules, err := parseRules(`skip if name startsWith "drop" and present`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	summary, _, err := processDir(batchConfig{Dir: dir, OutDir: out, Rules: rules})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (batchSummary{Processed: 1, Skipped: 1}); summary != want {
+		t.Fatalf("summary = %+v, want %+v", summary, want)
+	}
+	if _, err := os.Stat(filepath.Join(out, "drop.png")); !os.IsNotExist(err) {
+		t.Error("skipped input was written")
+	}
+}
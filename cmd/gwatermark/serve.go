@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+	"time"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	"github.com/gcslaoli/gemini-watermark-remover-go/server"
+)
+
+// runServe serves the REST API on addr until SIGINT or SIGTERM, then lets
+// in-flight requests finish. Images taking longer than imageTimeout fail.
+func runServe(addr string, opts watermark.Options, imageTimeout time.Duration) error {
+	sched := newScheduler()
+	defer sched.Close()
+
+	handler := server.NewHandler(server.HandlerConfig{Options: opts, ImageTimeout: imageTimeout})
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           server.Limiter{Scheduler: sched}.Wrap(handler),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errc := make(chan error, 1)
+	go func() {
+		errc <- srv.ListenAndServe()
+	}()
+	fmt.Printf("Serving POST /detect, POST /preview and POST /remove on %s\n", addr)
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// runStdio speaks the JSON-RPC protocol of server.ServeRPC on stdin and
+// stdout until the client sends exit or closes stdin. Images taking longer
+// than imageTimeout fail.
+func runStdio(opts watermark.Options, imageTimeout time.Duration) error {
+	sched := newScheduler()
+	defer sched.Close()
+	return server.ServeRPC(os.Stdin, os.Stdout, server.RPCConfig{Options: opts, Scheduler: sched, ImageTimeout: imageTimeout})
+}
+
+// newScheduler sizes the worker pools to the machine, leaving batch work
+// half the CPUs. It also installs a decode cache with an entry per worker,
+// so uploads detected or previewed and then cleaned are decoded once.
+func newScheduler() *server.Scheduler {
+	workers := runtime.NumCPU()
+	watermark.SetDecodeCache(watermark.NewDecodeCache(workers + max(1, workers/2)))
+	return server.NewScheduler(server.SchedulerConfig{
+		Interactive: server.PoolConfig{Workers: workers, QueueLimit: 4 * workers},
+		Batch:       server.PoolConfig{Workers: max(1, workers/2), QueueLimit: 16 * workers},
+	})
+}
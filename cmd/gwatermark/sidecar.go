@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// rawExts lists camera RAW extensions. A RAW next to a JPEG with the same base
+// name forms a RAW+JPEG pair, and the shared base.xmp holds the RAW's edits.
+var rawExts = []string{".dng", ".cr2", ".cr3", ".nef", ".arw", ".raf", ".orf", ".rw2", ".pef", ".srw"}
+
+// copySidecars copies the XMP sidecars of input next to output so photo
+// catalogs pick up the cleaned asset with its edits. Both naming schemes are
+// handled: Lightroom's photo.xmp and darktable's photo.jpg.xmp. References to
+// the input file name inside a sidecar are rewritten to the output name. A
+// photo.xmp that belongs to a RAW of the same base name is left alone, since
+// its edits describe the RAW. It returns the sidecars written.
+func copySidecars(input, output string) ([]string, error) {
+	inName, outName := filepath.Base(input), filepath.Base(output)
+	inBase := strings.TrimSuffix(inName, filepath.Ext(inName))
+	outBase := strings.TrimSuffix(outName, filepath.Ext(outName))
+	inDir, outDir := filepath.Dir(input), filepath.Dir(output)
+
+	type pair struct{ src, dst string }
+	var pairs []pair
+	for _, ext := range []string{".xmp", ".XMP"} {
+		pairs = append(pairs, pair{filepath.Join(inDir, inName+ext), filepath.Join(outDir, outName+ext)})
+		if !hasRAWCompanion(inDir, inBase) {
+			pairs = append(pairs, pair{filepath.Join(inDir, inBase+ext), filepath.Join(outDir, outBase+ext)})
+		}
+	}
+
+	var written []string
+	for _, p := range pairs {
+		data, err := os.ReadFile(p.src)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return written, fmt.Errorf("read sidecar: %w", err)
+		}
+		data = bytes.ReplaceAll(data, []byte(`"`+inName+`"`), []byte(`"`+outName+`"`))
+		data = bytes.ReplaceAll(data, []byte(">"+inName+"<"), []byte(">"+outName+"<"))
+		if err := os.WriteFile(p.dst, data, 0o644); err != nil {
+			return written, fmt.Errorf("write sidecar: %w", err)
+		}
+		written = append(written, p.dst)
+	}
+	return written, nil
+}
+
+func hasRAWCompanion(dir, base string) bool {
+	for _, ext := range rawExts {
+		for _, e := range []string{ext, strings.ToUpper(ext)} {
+			if _, err := os.Stat(filepath.Join(dir, base+e)); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
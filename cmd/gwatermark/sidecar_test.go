@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCopySidecars(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  map[string]string
+	}{
+		{
+			name:  "lightroom",
+			files: map[string]string{"photo.xmp": `<x xmpMM:DerivedFrom="photo.jpg"/>`},
+			want:  map[string]string{"photo.xmp": `<x xmpMM:DerivedFrom="photo.png"/>`},
+		},
+		{
+			name:  "darktable",
+			files: map[string]string{"photo.jpg.xmp": `<x><xmpMM:DerivedFrom>photo.jpg</xmpMM:DerivedFrom></x>`},
+			want:  map[string]string{"photo.png.xmp": `<x><xmpMM:DerivedFrom>photo.png</xmpMM:DerivedFrom></x>`},
+		},
+		{
+			name:  "raw pair",
+			files: map[string]string{"photo.CR2": "raw", "photo.xmp": "raw edits"},
+			want:  map[string]string{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			in, out := t.TempDir(), t.TempDir()
+			for name, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(in, name), []byte(content), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			written, err := copySidecars(filepath.Join(in, "photo.jpg"), filepath.Join(out, "photo.png"))
+			if err != nil {
+				t.Fatalf("copySidecars: %v", err)
+			}
+			if len(written) != len(tc.want) {
+				t.Fatalf("wrote %v, want %d sidecars", written, len(tc.want))
+			}
+			for name, content := range tc.want {
+				got, err := os.ReadFile(filepath.Join(out, name))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(got) != content {
+					t.Errorf("%s = %q, want %q", name, got, content)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// batchStats aggregates the images a -dir run decoded. Inputs the manifest
+// lists as up to date are not decoded and not counted. It is printed after
+// the run and saved as the manifest's summary.
+type batchStats struct {
+	// Examined counts decoded images and Detected those with a watermark.
+	Examined      int     `json:"examined"`
+	Detected      int     `json:"detected"`
+	DetectionRate float64 `json:"detectionRate"`
+	// MeanScore is the mean detection score over every examined image.
+	MeanScore float64 `json:"meanScore"`
+	// Clipped counts cleaned images with a clipping warning; ClippingRate
+	// is its share of Detected.
+	Clipped      int     `json:"clipped"`
+	ClippingRate float64 `json:"clippingRate"`
+	// InputBytes and OutputBytes total the cleaned images before and after;
+	// BytesDelta is their difference, negative when outputs are smaller.
+	InputBytes  int64 `json:"inputBytes"`
+	OutputBytes int64 `json:"outputBytes"`
+	BytesDelta  int64 `json:"bytesDelta"`
+	// Seconds is the wall time of the run and ImagesPerSecond Examined
+	// over it.
+	Seconds         float64 `json:"seconds"`
+	ImagesPerSecond float64 `json:"imagesPerSecond"`
+
+	scoreSum float64
+}
+
+// add counts the outcome of one decoded image.
+func (s *batchStats) add(r watermark.Result) {
+	s.Examined++
+	s.scoreSum += r.Score
+	if !r.Present {
+		return
+	}
+	s.Detected++
+	s.InputBytes += int64(r.InputSize)
+	s.OutputBytes += int64(len(r.Output))
+	for _, w := range r.Warnings {
+		if w.Code == watermark.WarnClipped {
+			s.Clipped++
+			break
+		}
+	}
+}
+
+// finish derives the rates once the run took elapsed.
+func (s *batchStats) finish(elapsed time.Duration) {
+	s.Seconds = elapsed.Seconds()
+	s.BytesDelta = s.OutputBytes - s.InputBytes
+	if s.Examined > 0 {
+		s.DetectionRate = float64(s.Detected) / float64(s.Examined)
+		s.MeanScore = s.scoreSum / float64(s.Examined)
+	}
+	if s.Detected > 0 {
+		s.ClippingRate = float64(s.Clipped) / float64(s.Detected)
+	}
+	if s.Seconds > 0 {
+		s.ImagesPerSecond = float64(s.Examined) / s.Seconds
+	}
+}
+
+func (s *batchStats) String() string {
+	size := fmt.Sprintf("%s saved", formatBytes(-s.BytesDelta))
+	if s.BytesDelta > 0 {
+		size = fmt.Sprintf("%s added", formatBytes(s.BytesDelta))
+	}
+	return fmt.Sprintf("Detected %d of %d (%.1f%%), mean score %.2f, clipped %d (%.1f%%), %s, %.1f images/s.",
+		s.Detected, s.Examined, 100*s.DetectionRate, s.MeanScore,
+		s.Clipped, 100*s.ClippingRate, size, s.ImagesPerSecond)
+}
+
+// formatBytes formats n with a binary unit.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// exitSummary is the JSON line -summary-json writes last on stderr, so
+// wrappers that pipe the image through stdout still get a structured
+// status. Counts stay zero in modes that do not clean images one by one.
+type exitSummary struct {
+	// Status is "ok", "no-watermark" or "error".
+	Status string `json:"status"`
+	Exit   int    `json:"exit"`
+	// Reason is the last line written to stderr before a failing exit,
+	// which is the error message, unless the run set a shorter one.
+	Reason string `json:"reason,omitempty"`
+	Input  string `json:"input,omitempty"`
+	// Output is the path written, "-" for stdout or "base64".
+	Output    string `json:"output,omitempty"`
+	Processed int    `json:"processed"`
+	Detected  int    `json:"detected"`
+	UpToDate  int    `json:"upToDate"`
+	Skipped   int    `json:"skipped"`
+	Failed    int    `json:"failed"`
+}
+
+// runSummary collects the exitSummary of the run while -summary-json is on.
+var runSummary struct {
+	exitSummary
+	enabled bool
+	// stderr is the real standard error; os.Stderr is the write end of a
+	// pipe whose reader copies everything to stderr and returns the last
+	// line on lastLine once the pipe is closed.
+	stderr   *os.File
+	lastLine chan string
+}
+
+// startSummary routes os.Stderr through a pipe that remembers the last line
+// written, for the Reason of a failing exit.
+func startSummary() error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	runSummary.enabled = true
+	runSummary.stderr = os.Stderr
+	runSummary.lastLine = make(chan string, 1)
+	os.Stderr = w
+	go func() {
+		runSummary.lastLine <- copyLastLine(runSummary.stderr, r)
+		r.Close()
+	}()
+	return nil
+}
+
+// copyLastLine copies r to w and returns the last non-empty line read. A
+// line rewritten in place with \r, as the progress bar does, counts as
+// what was written last.
+func copyLastLine(w io.Writer, r io.Reader) string {
+	var last, pending []byte
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			w.Write(buf[:n])
+			pending = append(pending, buf[:n]...)
+			for {
+				i := bytes.IndexByte(pending, '\n')
+				if i < 0 {
+					break
+				}
+				if line := lastSegment(pending[:i]); len(line) > 0 {
+					last = append(last[:0], line...)
+				}
+				pending = pending[i+1:]
+			}
+			if len(pending) > 4096 {
+				pending = pending[len(pending)-4096:]
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	if line := lastSegment(pending); len(line) > 0 {
+		last = line
+	}
+	return string(last)
+}
+
+// lastSegment returns what a terminal would show of line: the text after
+// its last \r, trimmed.
+func lastSegment(line []byte) []byte {
+	if i := bytes.LastIndexByte(bytes.TrimRight(line, "\r"), '\r'); i >= 0 {
+		line = line[i+1:]
+	}
+	return bytes.TrimSpace(line)
+}
+
+// finishSummary restores stderr and writes the summary for exit code code.
+// It does nothing unless startSummary ran, and only once.
+func finishSummary(code int) {
+	if !runSummary.enabled {
+		return
+	}
+	runSummary.enabled = false
+	pipe := os.Stderr
+	os.Stderr = runSummary.stderr
+	pipe.Close()
+	last := <-runSummary.lastLine
+
+	s := runSummary.exitSummary
+	s.Exit = code
+	switch {
+	case code != 0:
+		s.Status = "error"
+		if s.Reason == "" {
+			s.Reason = last
+		}
+	case s.Status == "":
+		s.Status = "ok"
+	}
+	s.Reason, s.Input, s.Output = utf8Safe(s.Reason), utf8Safe(s.Input), utf8Safe(s.Output)
+	if line, err := marshalJSON(s, false); err == nil {
+		os.Stderr.Write(line)
+	}
+}
+
+// exit ends the process with code after writing the -summary-json line.
+func exit(code int) {
+	finishSummary(code)
+	os.Exit(code)
+}
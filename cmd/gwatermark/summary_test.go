@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestCopyLastLine(t *testing.T) {
+	for _, tc := range []struct{ in, want string }{
+		{"", ""},
+		{"read input: missing\n", "read input: missing"},
+		{"warning: a\ndecode input: bad\n\n", "decode input: bad"},
+		{"no newline at the end", "no newline at the end"},
+		// The progress bar redraws its line with \r.
+		{"[===  ] 3/5\r[=====] 5/5\rbatch: disk full\n", "batch: disk full"},
+		{"done\n[===  ] 3/5\r", "[===  ] 3/5"},
+	} {
+		var copied bytes.Buffer
+		if got := copyLastLine(&copied, strings.NewReader(tc.in)); got != tc.want {
+			t.Errorf("copyLastLine(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+		if copied.String() != tc.in {
+			t.Errorf("copyLastLine(%q) copied %q", tc.in, copied.String())
+		}
+	}
+}
+
+func TestExitSummaryJSON(t *testing.T) {
+	line, err := marshalJSON(exitSummary{Status: "ok", Input: "in.png", Output: "-", Processed: 1, Detected: 1}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Count(line, []byte("\n")) != 1 || line[len(line)-1] != '\n' {
+		t.Fatalf("summary is not a single line: %q", line)
+	}
+	var fields map[string]any
+	if err := json.Unmarshal(line, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := fields["reason"]; ok {
+		t.Errorf("empty reason written: %s", line)
+	}
+	for _, key := range []string{"status", "exit", "processed", "detected", "upToDate", "skipped", "failed"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("%s missing: %s", key, line)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"image"
+	"os"
+	"path/filepath"
+	"strings"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// thumbPath returns the thumbnail path of output: photo.png gets
+// photo_thumb.png, in the same directory and format.
+func thumbPath(output string) string {
+	ext := filepath.Ext(output)
+	return strings.TrimSuffix(output, ext) + "_thumb" + ext
+}
+
+// writeThumb encodes cleaned shrunk to at most size pixels on its longer
+// side, in the format and with the metadata opts gives the output, and writes
+// it to thumbPath(output). The output's own -resize does not apply. It
+// returns the path written.
+func writeThumb(output string, cleaned image.Image, source []byte, inputFormat string, opts watermark.Options, size int) (string, error) {
+	opts.Resize = watermark.Resize{MaxDim: size}
+	encoded, _, err := opts.Encode(cleaned, source, inputFormat)
+	if err != nil {
+		return "", err
+	}
+	name := thumbPath(output)
+	return name, os.WriteFile(name, encoded, 0o644)
+}
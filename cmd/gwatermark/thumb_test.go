@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gcslaoli/gemini-watermark-remover-go/testutil"
+)
+
+func TestThumbPath(t *testing.T) {
+	tests := map[string]string{
+		"photo.png":           "photo_thumb.png",
+		"out/a.b/photo.jpg":   "out/a.b/photo_thumb.jpg",
+		"noext":               "noext_thumb",
+		"dir/photo.clean.png": "dir/photo.clean_thumb.png",
+	}
+	for in, want := range tests {
+		if got := thumbPath(in); got != want {
+			t.Errorf("thumbPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestProcessDirThumb(t *testing.T) {
+	watermarked := testutil.WatermarkedPNG(t)
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in")
+	if err := os.MkdirAll(in, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(in, "a.png"), watermarked, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "out")
+	if _, _, err := processDir(batchConfig{Dir: in, OutDir: out, Thumb: 32}); err != nil {
+		t.Fatalf("processDir: %v", err)
+	}
+	img, err := readImage(filepath.Join(out, "a_thumb.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); max(b.Dx(), b.Dy()) != 32 {
+		t.Fatalf("thumbnail is %v, want a longer side of 32", b)
+	}
+}
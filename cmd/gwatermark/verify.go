@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"math"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// verifyOutput compares the encoded output with the reference image at
+// refPath, typically the clean original, and prints PSNR and SSIM for the
+// whole image and, when it is not empty, for region.
+func verifyOutput(w io.Writer, refPath string, encoded []byte, region image.Rectangle) error {
+	ref, err := readImage(refPath)
+	if err != nil {
+		return fmt.Errorf("read reference: %w", err)
+	}
+	output, _, err := watermark.DecodeImageBytes(encoded)
+	if err != nil {
+		return fmt.Errorf("decode output: %w", err)
+	}
+	if ref.Bounds().Size() != output.Bounds().Size() {
+		return fmt.Errorf("reference is %dx%d, output %dx%d",
+			ref.Bounds().Dx(), ref.Bounds().Dy(), output.Bounds().Dx(), output.Bounds().Dy())
+	}
+
+	psnr, ssim, err := compareImages(ref, output)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Verify against %s: PSNR %s, SSIM %.4f", refPath, formatPSNR(psnr), ssim)
+	region = region.Sub(output.Bounds().Min)
+	if !region.Empty() && region.In(image.Rect(0, 0, ref.Bounds().Dx(), ref.Bounds().Dy())) {
+		psnr, ssim, err = compareImages(subImage(ref, region), subImage(output, region))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, " (watermark region: PSNR %s, SSIM %.4f)", formatPSNR(psnr), ssim)
+	}
+	fmt.Fprintln(w, ".")
+	return nil
+}
+
+func compareImages(a, b image.Image) (psnr, ssim float64, err error) {
+	if psnr, err = watermark.ComparePSNR(a, b); err != nil {
+		return 0, 0, err
+	}
+	if ssim, err = watermark.CompareSSIM(a, b); err != nil {
+		return 0, 0, err
+	}
+	return psnr, ssim, nil
+}
+
+// subImage returns the part of img at r, given relative to the top left of
+// its bounds.
+func subImage(img image.Image, r image.Rectangle) image.Image {
+	r = r.Add(img.Bounds().Min)
+	if s, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r)
+	}
+	out := image.NewRGBA(r)
+	draw.Draw(out, r, img, r.Min, draw.Src)
+	return out
+}
+
+func formatPSNR(psnr float64) string {
+	if math.IsInf(psnr, 1) {
+		return "inf (identical)"
+	}
+	return fmt.Sprintf("%.2f dB", psnr)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	"github.com/gcslaoli/gemini-watermark-remover-go/testutil"
+)
+
+func TestVerifyOutput(t *testing.T) {
+	fixture := testutil.Fixture{Width: 1024, Height: 1024, Background: testutil.Texture, Noise: 2, Watermark: true}
+	marked, err := fixture.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixture.Watermark = false
+	ref := filepath.Join(t.TempDir(), "clean.png")
+	if err := os.WriteFile(ref, fixture.Bytes(t), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cleaned, err := watermark.RemoveWatermark(marked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, cleaned); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if err := verifyOutput(&out, ref, encoded.Bytes(), image.Rect(944, 944, 992, 992)); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, "PSNR") || !strings.Contains(got, "watermark region: PSNR") {
+		t.Errorf("output %q", got)
+	}
+
+	// The reference compared with itself.
+	out.Reset()
+	if err := verifyOutput(&out, ref, fixture.Bytes(t), image.Rectangle{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); !strings.Contains(got, "PSNR inf (identical), SSIM 1.0000.") || strings.Contains(got, "region") {
+		t.Errorf("output %q", got)
+	}
+
+	small := testutil.Fixture{Width: 64, Height: 64}.Bytes(t)
+	if err := verifyOutput(&out, ref, small, image.Rectangle{}); err == nil || !strings.Contains(err.Error(), "64x64") {
+		t.Errorf("size mismatch: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	"github.com/gcslaoli/gemini-watermark-remover-go/video"
+)
+
+// runRawVideo filters raw rgb24 frames from stdin to stdout. Progress goes to
+// stderr because stdout carries the frames. A non-empty frame is the full
+// size of frames cropped to their video.Region box.
+func runRawVideo(size, frame string) {
+	width, height, err := video.ParseSize(size)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rawvideo: %v\n", err)
+		exit(1)
+	}
+	profile := watermark.GeminiProfile()
+	if frame != "" {
+		profile, err = regionProfile(frame, width, height)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "rawvideo: %v\n", err)
+			exit(1)
+		}
+	}
+
+	in := bufio.NewReaderSize(os.Stdin, width*height*3)
+	out := bufio.NewWriterSize(os.Stdout, width*height*3)
+
+	stats, err := video.FilterProfile(in, out, width, height, profile)
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "rawvideo: %v\n", err)
+		exit(1)
+	}
+
+	fmt.Fprintf(os.Stderr, "Filtered %d frames (%d cleaned, %d cached detections)\n", stats.Frames, stats.Cleaned, stats.CacheHits)
+}
+
+// regionProfile returns the profile for frames of width x height cropped
+// from frames of size frame, which must be the video.Region box.
+func regionProfile(frame string, width, height int) (watermark.Profile, error) {
+	fw, fh, err := video.ParseSize(frame)
+	if err != nil {
+		return watermark.Profile{}, err
+	}
+	region, profile, ok := video.Region(fw, fh)
+	if !ok {
+		return watermark.Profile{}, fmt.Errorf("%dx%d frames are too small for a watermark", fw, fh)
+	}
+	if region.Dx() != width || region.Dy() != height {
+		return watermark.Profile{}, fmt.Errorf("region of %dx%d frames is %dx%d, not %dx%d", fw, fh, region.Dx(), region.Dy(), width, height)
+	}
+	return profile, nil
+}
+
+// printFFmpegCommand probes the input video and prints the shell pipeline
+// that routes its frames, or only their watermark corner with region set,
+// through -rawvideo mode.
+func printFFmpegCommand(input, output string, region bool) {
+	if input == "" {
+		fmt.Fprintln(os.Stderr, "ffmpeg-cmd: -in is required")
+		exit(1)
+	}
+	if output == "" {
+		ext := filepath.Ext(input)
+		output = strings.TrimSuffix(input, ext) + "_unwatermarked" + ext
+	}
+
+	p := video.Pipeline{Input: input, Output: output, Region: region}
+	if err := p.Probe(context.Background(), ""); err != nil {
+		fmt.Fprintf(os.Stderr, "ffmpeg-cmd: %v\n", err)
+		exit(1)
+	}
+
+	fmt.Println(p.Command())
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// walkOptions controls how walkImages descends a directory tree.
+type walkOptions struct {
+	Recursive bool
+	// FollowSymlinks treats links to files and directories like their
+	// targets. Otherwise links are reported as skipped.
+	FollowSymlinks bool
+	// HardLinks reports later paths to an already seen file through
+	// walkEntry.LinkOf, so outputs can be linked instead of recomputed.
+	HardLinks bool
+}
+
+// walkEntry is one candidate image found by walkImages.
+type walkEntry struct {
+	Path string
+	// Rel is Path relative to the walk root, used to mirror the tree.
+	Rel string
+	// LinkOf is the Rel of the first entry that is the same file, set only
+	// with walkOptions.HardLinks.
+	LinkOf string
+	// Skip explains why the entry is not processed, such as a symlink that
+	// is not followed or a directory cycle. Empty for regular entries.
+	Skip string
+}
+
+// imageExts lists the extensions handled by the registered decoders.
+var imageExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true}
+
+func isImageName(name string) bool {
+	return imageExts[strings.ToLower(filepath.Ext(name))]
+}
+
+// walkImages calls fn for every image file under root in lexical order.
+// Directory symlinks are followed only with FollowSymlinks, and a link back
+// to a directory on the current path is reported as a cycle instead of
+// being descended. An error from fn stops the walk.
+func walkImages(root string, opts walkOptions, fn func(walkEntry) error) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	w := &walker{opts: opts, fn: fn, seen: make(map[fileKey]string)}
+	return w.dir(root, "", []os.FileInfo{info})
+}
+
+type walker struct {
+	opts walkOptions
+	fn   func(walkEntry) error
+	// seen maps multiply linked files to the Rel they were first found at.
+	seen map[fileKey]string
+}
+
+func (w *walker) dir(path, rel string, ancestors []os.FileInfo) error {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		entry := walkEntry{Path: filepath.Join(path, e.Name()), Rel: filepath.Join(rel, e.Name())}
+
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			if !w.opts.FollowSymlinks {
+				if isImageName(e.Name()) || w.opts.Recursive {
+					entry.Skip = "symlink"
+					if err := w.fn(entry); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if info, err = os.Stat(entry.Path); err != nil {
+				entry.Skip = "broken symlink"
+				if err := w.fn(entry); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if info.IsDir() {
+			if !w.opts.Recursive {
+				continue
+			}
+			if onPath(ancestors, info) {
+				entry.Skip = "directory cycle"
+				if err := w.fn(entry); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := w.dir(entry.Path, entry.Rel, append(ancestors[:len(ancestors):len(ancestors)], info)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !info.Mode().IsRegular() || !isImageName(e.Name()) {
+			continue
+		}
+		if w.opts.HardLinks {
+			if key, ok := linkKey(info); ok {
+				if first, dup := w.seen[key]; dup {
+					entry.LinkOf = first
+				} else {
+					w.seen[key] = entry.Rel
+				}
+			}
+		}
+		if err := w.fn(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func onPath(ancestors []os.FileInfo, info os.FileInfo) bool {
+	for _, a := range ancestors {
+		if os.SameFile(a, info) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+type fileKey struct{}
+
+// linkKey reports no identity, so every path is processed separately.
+func linkKey(os.FileInfo) (fileKey, bool) {
+	return fileKey{}, false
+}
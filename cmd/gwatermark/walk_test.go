@@ -0,0 +1,92 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestWalkImages(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symlinks need privileges on Windows")
+	}
+
+	root := t.TempDir()
+	mustWrite := func(name string) {
+		t.Helper()
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mustWrite("a.png")
+	mustWrite("notes.txt")
+	mustWrite("sub/b.JPG")
+	for _, link := range []struct{ target, name string }{
+		{"sub", "alias"},
+		{"..", "sub/loop"},
+		{"a.png", "c.png"},
+	} {
+		if err := os.Symlink(link.target, filepath.Join(root, link.name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Link(filepath.Join(root, "a.png"), filepath.Join(root, "sub/hard.png")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts walkOptions
+		want []string
+	}{
+		{
+			name: "flat",
+			opts: walkOptions{},
+			want: []string{"a.png", "c.png skip=symlink"},
+		},
+		{
+			name: "skip symlinks",
+			opts: walkOptions{Recursive: true},
+			want: []string{"a.png", "alias skip=symlink", "c.png skip=symlink", "sub/b.JPG", "sub/hard.png", "sub/loop skip=symlink"},
+		},
+		{
+			name: "follow symlinks",
+			opts: walkOptions{Recursive: true, FollowSymlinks: true},
+			want: []string{"a.png", "alias/b.JPG", "alias/hard.png", "alias/loop skip=directory cycle", "c.png", "sub/b.JPG", "sub/hard.png", "sub/loop skip=directory cycle"},
+		},
+		{
+			name: "hard links",
+			opts: walkOptions{Recursive: true, FollowSymlinks: true, HardLinks: true},
+			want: []string{"a.png", "alias/b.JPG", "alias/hard.png link=a.png", "alias/loop skip=directory cycle", "c.png link=a.png", "sub/b.JPG link=alias/b.JPG", "sub/hard.png link=a.png", "sub/loop skip=directory cycle"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			err := walkImages(root, tc.opts, func(e walkEntry) error {
+				s := filepath.ToSlash(e.Rel)
+				if e.Skip != "" {
+					s += " skip=" + e.Skip
+				}
+				if e.LinkOf != "" {
+					s += " link=" + filepath.ToSlash(e.LinkOf)
+				}
+				got = append(got, s)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("walkImages: %v", err)
+			}
+			if strings.Join(got, "\n") != strings.Join(tc.want, "\n") {
+				t.Fatalf("entries:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+}
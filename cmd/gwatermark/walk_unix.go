@@ -0,0 +1,21 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileKey identifies a file independently of the path it was reached by.
+type fileKey struct{ dev, ino uint64 }
+
+// linkKey returns the identity used to recognize one file reached under
+// several names, through hard links or followed symlinks.
+func linkKey(info os.FileInfo) (fileKey, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileKey{}, false
+	}
+	return fileKey{dev: uint64(st.Dev), ino: uint64(st.Ino)}, true
+}
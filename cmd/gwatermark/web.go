@@ -0,0 +1,30 @@
+package main
+
+import "flag"
+
+// webPreset lists the output flags -web sets: lossy WebP at a quality that
+// hides compression on photos, no EXIF or XMP (camera and location data
+// rarely belong on a blog), and a longer side that fits large screens.
+var webPreset = []struct{ name, value string }{
+	{"format", "webp"},
+	{"lossy", "true"},
+	{"quality", "82"},
+	{"strip-metadata", "true"},
+	{"max-dim", "2048"},
+}
+
+// applyWebPreset sets the -web preset on fs, keeping every flag that was
+// passed explicitly. An explicit -resize replaces the preset's -max-dim.
+func applyWebPreset(fs *flag.FlagSet) error {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for _, p := range webPreset {
+		if set[p.name] || p.name == "max-dim" && set["resize"] {
+			continue
+		}
+		if err := fs.Set(p.name, p.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
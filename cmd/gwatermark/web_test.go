@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"testing"
+)
+
+func TestApplyWebPreset(t *testing.T) {
+	newFlags := func() (*flag.FlagSet, map[string]*string) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.SetOutput(io.Discard)
+		values := make(map[string]*string)
+		for _, name := range []string{"format", "lossy", "quality", "strip-metadata", "max-dim", "resize"} {
+			values[name] = fs.String(name, "", "")
+		}
+		return fs, values
+	}
+
+	fs, v := newFlags()
+	if err := fs.Parse([]string{"-quality", "90", "-resize", "800x"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyWebPreset(fs); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"format": "webp", "lossy": "true", "quality": "90", "strip-metadata": "true", "max-dim": "", "resize": "800x"}
+	for name, w := range want {
+		if got := *v[name]; got != w {
+			t.Errorf("-%s = %q, want %q", name, got, w)
+		}
+	}
+
+	fs, v = newFlags()
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyWebPreset(fs); err != nil {
+		t.Fatal(err)
+	}
+	if *v["max-dim"] != "2048" {
+		t.Errorf("-max-dim = %q, want the preset 2048", *v["max-dim"])
+	}
+}
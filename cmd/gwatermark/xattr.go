@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// quarantineAttr is the macOS Gatekeeper quarantine flag set on downloads.
+const quarantineAttr = "com.apple.quarantine"
+
+// copyXattrs copies the extended attributes of src, such as Finder tags, to
+// dst. The quarantine flag is left off when dropQuarantine is set. On Linux
+// only the user namespace is copied; the others need privileges or describe
+// the file itself (security labels, ACLs).
+func copyXattrs(src, dst string, dropQuarantine bool) error {
+	names, err := listXattrs(src)
+	if err != nil {
+		return fmt.Errorf("list xattrs: %w", err)
+	}
+
+	for _, name := range names {
+		if name == quarantineAttr && dropQuarantine {
+			continue
+		}
+		if !xattrCopyable(name) {
+			continue
+		}
+		value, err := getXattr(src, name)
+		if err != nil {
+			return fmt.Errorf("read xattr %s: %w", name, err)
+		}
+		if err := setXattr(dst, name, value); err != nil {
+			return fmt.Errorf("write xattr %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// splitXattrNames splits the NUL-terminated name list returned by
+// listxattr.
+func splitXattrNames(buf []byte) []string {
+	var names []string
+	for _, name := range bytes.Split(buf, []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names
+}
+
+// parseQuarantine parses the -quarantine policy.
+func parseQuarantine(s string) (drop bool, err error) {
+	switch strings.ToLower(s) {
+	case "keep":
+		return false, nil
+	case "drop":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown quarantine policy %q (want keep or drop)", s)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// All attributes are copied on macOS; Finder tags live in
+// com.apple.metadata:_kMDItemUserTags and com.apple.FinderInfo.
+func xattrCopyable(string) bool {
+	return true
+}
+
+func listXattrs(path string) ([]string, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		size, _, errno := syscall.Syscall6(syscall.SYS_LISTXATTR, uintptr(unsafe.Pointer(p)), 0, 0, 0, 0, 0)
+		if errno != 0 {
+			return nil, errno
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size)
+		n, _, errno := syscall.Syscall6(syscall.SYS_LISTXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&buf[0])), size, 0, 0, 0)
+		if errno == syscall.ERANGE {
+			continue
+		}
+		if errno != 0 {
+			return nil, errno
+		}
+		return splitXattrNames(buf[:n]), nil
+	}
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return nil, err
+	}
+	a, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		size, _, errno := syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)), 0, 0, 0, 0)
+		if errno != 0 {
+			return nil, errno
+		}
+		if size == 0 {
+			return []byte{}, nil
+		}
+		buf := make([]byte, size)
+		n, _, errno := syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)), uintptr(unsafe.Pointer(&buf[0])), size, 0, 0)
+		if errno == syscall.ERANGE {
+			continue
+		}
+		if errno != 0 {
+			return nil, errno
+		}
+		return buf[:n], nil
+	}
+}
+
+func setXattr(path, name string, value []byte) error {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	a, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return err
+	}
+	var v unsafe.Pointer
+	if len(value) > 0 {
+		v = unsafe.Pointer(&value[0])
+	}
+	if _, _, errno := syscall.Syscall6(syscall.SYS_SETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)), uintptr(v), uintptr(len(value)), 0, 0); errno != 0 {
+		return errno
+	}
+	return nil
+}
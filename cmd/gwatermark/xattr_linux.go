@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"syscall"
+)
+
+func xattrCopyable(name string) bool {
+	return strings.HasPrefix(name, "user.")
+}
+
+func listXattrs(path string) ([]string, error) {
+	for {
+		size, err := syscall.Listxattr(path, nil)
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := syscall.Listxattr(path, buf)
+		if err == syscall.ERANGE {
+			// The list grew between the two calls.
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return splitXattrNames(buf[:n]), nil
+	}
+}
+
+func getXattr(path, name string) ([]byte, error) {
+	for {
+		size, err := syscall.Getxattr(path, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := syscall.Getxattr(path, name, buf)
+		if err == syscall.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
+
+func setXattr(path, name string, value []byte) error {
+	return syscall.Setxattr(path, name, value, 0)
+}
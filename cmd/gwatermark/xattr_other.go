@@ -0,0 +1,23 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+var errNoXattrs = errors.New("extended attributes are not supported on this platform")
+
+func xattrCopyable(string) bool {
+	return false
+}
+
+func listXattrs(string) ([]string, error) {
+	return nil, errNoXattrs
+}
+
+func getXattr(string, string) ([]byte, error) {
+	return nil, errNoXattrs
+}
+
+func setXattr(string, string, []byte) error {
+	return errNoXattrs
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"testing"
+)
+
+func TestCopyXattrs(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skipf("no xattr support on %s", runtime.GOOS)
+	}
+
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "in.png"), filepath.Join(dir, "out.png")
+	for _, name := range []string{src, dst} {
+		if err := os.WriteFile(name, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tag := "user.xdg.tags"
+	if runtime.GOOS == "darwin" {
+		tag = "com.apple.metadata:_kMDItemUserTags"
+	}
+	if err := setXattr(src, tag, []byte("red")); err != nil {
+		if errors.Is(err, syscall.ENOTSUP) {
+			t.Skip("file system does not support xattrs")
+		}
+		t.Fatal(err)
+	}
+	if runtime.GOOS == "darwin" {
+		if err := setXattr(src, quarantineAttr, []byte("0081;")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := copyXattrs(src, dst, true); err != nil {
+		t.Fatalf("copyXattrs: %v", err)
+	}
+	if got, err := getXattr(dst, tag); err != nil || string(got) != "red" {
+		t.Fatalf("%s = %q, %v; want red", tag, got, err)
+	}
+	if runtime.GOOS == "darwin" {
+		if _, err := getXattr(dst, quarantineAttr); err == nil {
+			t.Fatalf("quarantine copied despite drop policy")
+		}
+	}
+}
@@ -0,0 +1,234 @@
+package watermark
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// TagColorSpace returns the PNG, JPEG or WebP out with color space
+// information taken from source, so color-managed software renders the
+// cleaned image like the original. An ICC profile embedded in a PNG (iCCP),
+// JPEG (APP2) or WebP (ICCP) source is copied; otherwise PNG output gets an
+// sRGB chunk, which is what untagged web images are assumed to be, and JPEG
+// and WebP output stay untagged with the same meaning. out is returned
+// unchanged when it is none of these formats or already carries color space
+// information.
+func TagColorSpace(out, source []byte) []byte {
+	if len(out) >= 2 && out[0] == 0xFF && out[1] == 0xD8 {
+		return tagJPEGColorSpace(out, source)
+	}
+	if _, _, ok := webpSize(out); ok {
+		return tagWebPColorSpace(out, source)
+	}
+	if !bytes.HasPrefix(out, pngSignature) || len(out) < 33 {
+		return out
+	}
+	if _, ok := findPNGChunk(out, "iCCP", "sRGB"); ok {
+		return out
+	}
+
+	chunk, ok := findPNGChunk(source, "iCCP", "sRGB")
+	if !ok {
+		if profile := sourceICCProfile(source); profile != nil {
+			chunk = iccpChunk(profile)
+		} else {
+			// Rendering intent 0: perceptual.
+			chunk = pngChunk("sRGB", []byte{0})
+		}
+	}
+
+	// Color space chunks must precede PLTE and IDAT; the IHDR chunk always
+	// occupies the first 25 bytes after the signature.
+	const ihdrEnd = 8 + 25
+	tagged := make([]byte, 0, len(out)+len(chunk))
+	tagged = append(tagged, out[:ihdrEnd]...)
+	tagged = append(tagged, chunk...)
+	return append(tagged, out[ihdrEnd:]...)
+}
+
+// tagJPEGColorSpace inserts the source's ICC profile as APP2 segments right
+// after the SOI marker of the JPEG out.
+func tagJPEGColorSpace(out, source []byte) []byte {
+	if jpegICCProfile(out) != nil {
+		return out
+	}
+	profile := sourceICCProfile(source)
+	if profile == nil {
+		return out
+	}
+
+	const tag = "ICC_PROFILE\x00"
+	// A segment length counts itself and must fit 16 bits.
+	const maxChunk = 0xFFFF - 2 - len(tag) - 2
+	count := (len(profile) + maxChunk - 1) / maxChunk
+	if count > 255 {
+		return out
+	}
+
+	tagged := make([]byte, 0, len(out)+len(profile)+count*(4+len(tag)+2))
+	tagged = append(tagged, out[:2]...)
+	for seq := 1; len(profile) > 0; seq++ {
+		n := min(len(profile), maxChunk)
+		tagged = append(tagged, 0xFF, 0xE2)
+		tagged = binary.BigEndian.AppendUint16(tagged, uint16(2+len(tag)+2+n))
+		tagged = append(tagged, tag...)
+		tagged = append(tagged, byte(seq), byte(count))
+		tagged = append(tagged, profile[:n]...)
+		profile = profile[n:]
+	}
+	return append(tagged, out[2:]...)
+}
+
+// tagWebPColorSpace adds the source's ICC profile to the WebP out as an ICCP
+// chunk, which requires the extended (VP8X) header.
+func tagWebPColorSpace(out, source []byte) []byte {
+	if webpICCProfile(out) != nil {
+		return out
+	}
+	profile := sourceICCProfile(source)
+	if profile == nil {
+		return out
+	}
+
+	// The ICC profile chunk follows VP8X and precedes the image data.
+	return extendWebP(out, 0x20, [][]byte{webpChunk("ICCP", profile)}, nil)
+}
+
+// extendWebP rebuilds the WebP out with a VP8X header that adds flags to
+// its own, the chunks lead right after that header and tail after the image
+// data.
+func extendWebP(out []byte, flags byte, lead, tail [][]byte) []byte {
+	// Only lossy images with an ALPH chunk set the alpha flag, and they
+	// already have a VP8X header; decoders reject it in front of VP8L.
+	width, height, _ := webpSize(out)
+	var chunks [][]byte
+	webpChunks(out, func(fourcc string, body []byte) bool {
+		if fourcc == "VP8X" {
+			flags |= body[0]
+		} else {
+			chunks = append(chunks, webpChunk(fourcc, body))
+		}
+		return true
+	})
+	all := append([][]byte{webpChunk("VP8X", vp8xPayload(flags, width, height))}, lead...)
+	all = append(all, chunks...)
+	return riffWebP(append(all, tail...)...)
+}
+
+// sourceICCProfile returns the ICC profile embedded in a JPEG, PNG or WebP
+// source, or nil.
+func sourceICCProfile(source []byte) []byte {
+	if profile := jpegICCProfile(source); profile != nil {
+		return profile
+	}
+	if profile := pngICCProfile(source); profile != nil {
+		return profile
+	}
+	return webpICCProfile(source)
+}
+
+// pngICCProfile decompresses the ICC profile of a PNG iCCP chunk. It returns
+// nil when there is none or it is corrupt.
+func pngICCProfile(data []byte) []byte {
+	chunk, ok := findPNGChunk(data, "iCCP")
+	if !ok {
+		return nil
+	}
+	body := chunk[8 : len(chunk)-4]
+	// Profile name, NUL, compression method, then the zlib stream.
+	name := bytes.IndexByte(body, 0)
+	if name < 0 || name+2 > len(body) {
+		return nil
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(body[name+2:]))
+	if err != nil {
+		return nil
+	}
+	profile, err := io.ReadAll(zr)
+	if err != nil {
+		return nil
+	}
+	return profile
+}
+
+// findPNGChunk returns the first complete chunk (length, type, data and CRC)
+// with one of the given types that appears before the image data.
+func findPNGChunk(data []byte, types ...string) ([]byte, bool) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, false
+	}
+
+	for pos := len(pngSignature); pos+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, false
+		}
+		typ := string(data[pos+4 : pos+8])
+		if typ == "IDAT" {
+			return nil, false
+		}
+		for _, t := range types {
+			if typ == t {
+				return data[pos:end], true
+			}
+		}
+		pos = end
+	}
+	return nil, false
+}
+
+// jpegICCProfile reassembles an ICC profile split across APP2 ICC_PROFILE
+// segments. It returns nil when the data carries no complete profile.
+func jpegICCProfile(data []byte) []byte {
+	const tag = "ICC_PROFILE\x00"
+
+	var parts [][]byte
+	jpegSegments(data, func(marker byte, segment []byte) bool {
+		if marker != 0xE2 || len(segment) < len(tag)+2 || string(segment[:len(tag)]) != tag {
+			return true
+		}
+		seq, count := int(segment[len(tag)]), int(segment[len(tag)+1])
+		if parts == nil {
+			parts = make([][]byte, count)
+		}
+		if seq >= 1 && seq <= len(parts) {
+			parts[seq-1] = segment[len(tag)+2:]
+		}
+		return true
+	})
+
+	var profile []byte
+	for _, p := range parts {
+		if p == nil {
+			return nil
+		}
+		profile = append(profile, p...)
+	}
+	return profile
+}
+
+// iccpChunk wraps an ICC profile in a zlib-compressed iCCP chunk.
+func iccpChunk(profile []byte) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("ICC profile\x00")
+	buf.WriteByte(0) // compression method: deflate
+	zw := zlib.NewWriter(&buf)
+	zw.Write(profile)
+	zw.Close()
+	return pngChunk("iCCP", buf.Bytes())
+}
+
+// pngChunk serializes a chunk with its length prefix and CRC.
+func pngChunk(typ string, data []byte) []byte {
+	chunk := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], typ)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
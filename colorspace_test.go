@@ -0,0 +1,128 @@
+package watermark
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func TestTagColorSpace(t *testing.T) {
+	out, err := EncodePNGToBytes(image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	tagged := TagColorSpace(out, []byte("untagged source"))
+	if chunk, ok := findPNGChunk(tagged, "sRGB"); !ok || len(chunk) != 13 {
+		t.Fatalf("expected sRGB chunk in untagged output")
+	}
+	if _, _, err := DecodeImageBytes(tagged); err != nil {
+		t.Fatalf("tagged PNG does not decode: %v", err)
+	}
+	if again := TagColorSpace(tagged, nil); !bytes.Equal(again, tagged) {
+		t.Fatalf("already tagged output was modified")
+	}
+
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	profile := bytes.Repeat([]byte("icc"), 100)
+	src := withICCProfile(jpg.Bytes(), profile)
+
+	if got := jpegICCProfile(src); !bytes.Equal(got, profile) {
+		t.Fatalf("profile not reassembled: %d bytes", len(got))
+	}
+	tagged = TagColorSpace(out, src)
+	if _, ok := findPNGChunk(tagged, "iCCP"); !ok {
+		t.Fatalf("expected iCCP chunk copied from JPEG source")
+	}
+	if _, _, err := DecodeImageBytes(tagged); err != nil {
+		t.Fatalf("tagged PNG does not decode: %v", err)
+	}
+}
+
+// withICCProfile inserts profile after the JPEG SOI marker, split across two
+// APP2 ICC_PROFILE segments.
+func withICCProfile(jpg, profile []byte) []byte {
+	out := append([]byte{}, jpg[:2]...)
+	half := len(profile) / 2
+	for i, part := range [][]byte{profile[:half], profile[half:]} {
+		payload := append([]byte("ICC_PROFILE\x00"), byte(i+1), 2)
+		payload = append(payload, part...)
+		segment := []byte{0xFF, 0xE2, 0, 0}
+		binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+		out = append(out, segment...)
+		out = append(out, payload...)
+	}
+	return append(out, jpg[2:]...)
+}
+
+func TestTagColorSpaceJPEG(t *testing.T) {
+	out, err := EncodeJPEGToBytes(image.NewRGBA(image.Rect(0, 0, 4, 4)), 90)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	if got := TagColorSpace(out, []byte("untagged source")); !bytes.Equal(got, out) {
+		t.Fatalf("untagged source changed the JPEG output")
+	}
+
+	// A profile larger than one APP2 segment, taken from a PNG source.
+	profile := bytes.Repeat([]byte("0123456789abcdef"), 5000)
+	png, err := EncodePNGToBytes(image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	if err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	src := append(append(append([]byte{}, png[:33]...), iccpChunk(profile)...), png[33:]...)
+
+	tagged := TagColorSpace(out, src)
+	if got := jpegICCProfile(tagged); !bytes.Equal(got, profile) {
+		t.Fatalf("profile not copied: %d bytes, want %d", len(got), len(profile))
+	}
+	if _, _, err := DecodeImageBytes(tagged); err != nil {
+		t.Fatalf("tagged JPEG does not decode: %v", err)
+	}
+}
+
+func TestTagColorSpaceWebP(t *testing.T) {
+	src := gradientNRGBA(9, 5, true)
+	profile := []byte("odd-length ICC profile")
+	jpg, err := EncodeJPEGToBytes(src, 90)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	jpg = withICCProfile(jpg, profile)
+
+	for _, o := range []*WebPOptions{nil, {Lossy: true}} {
+		out, err := EncodeWebPToBytes(src, o)
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if got := TagColorSpace(out, []byte("untagged source")); !bytes.Equal(got, out) {
+			t.Fatalf("untagged source changed the WebP output")
+		}
+
+		tagged := TagColorSpace(out, jpg)
+		if got := webpICCProfile(tagged); !bytes.Equal(got, profile) {
+			t.Fatalf("profile not copied: %q", got)
+		}
+		if w, h, ok := webpSize(tagged); !ok || w != 9 || h != 5 {
+			t.Fatalf("VP8X header = %dx%d", w, h)
+		}
+		decoded := decodeWebP(t, tagged)
+		if c := color.NRGBAModel.Convert(decoded.At(1, 0)).(color.NRGBA); c.A != src.NRGBAAt(1, 0).A {
+			t.Fatalf("alpha lost after tagging: %v", c)
+		}
+		// And back: a WebP source's profile reaches PNG output.
+		png, err := EncodePNGToBytes(src)
+		if err != nil {
+			t.Fatalf("encode png: %v", err)
+		}
+		if got := pngICCProfile(TagColorSpace(png, tagged)); !bytes.Equal(got, profile) {
+			t.Fatalf("profile not copied to PNG: %q", got)
+		}
+	}
+}
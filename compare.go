@@ -0,0 +1,159 @@
+package watermark
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// ComparePSNR returns the peak signal-to-noise ratio between a and b in
+// decibels, over the 8-bit red, green and blue channels compared without
+// alpha premultiplication. Higher is closer; identical images give +Inf, and
+// cleaned watermarks typically score above 40 dB against the original. The
+// images must have the same size but may have different bounds origins, so
+// sub-images such as the watermark region can be compared too.
+func ComparePSNR(a, b image.Image) (float64, error) {
+	if err := compareSizes(a, b); err != nil {
+		return 0, err
+	}
+	an, bn := cloneToNRGBA(a), cloneToNRGBA(b)
+	ab, bb := a.Bounds(), b.Bounds()
+	var sum float64
+	for y := 0; y < ab.Dy(); y++ {
+		ap := an.Pix[an.PixOffset(ab.Min.X, ab.Min.Y+y):][:4*ab.Dx()]
+		bp := bn.Pix[bn.PixOffset(bb.Min.X, bb.Min.Y+y):][:4*ab.Dx()]
+		for i := 0; i < len(ap); i += 4 {
+			for c := 0; c < 3; c++ {
+				d := float64(ap[i+c]) - float64(bp[i+c])
+				sum += d * d
+			}
+		}
+	}
+	if sum == 0 {
+		return math.Inf(1), nil
+	}
+	mse := sum / float64(3*ab.Dx()*ab.Dy())
+	return 10 * math.Log10(255*255/mse), nil
+}
+
+// ssimWindow is the side of the Gaussian window CompareSSIM averages over,
+// with standard deviation ssimSigma, as in Wang et al.
+const (
+	ssimWindow = 11
+	ssimSigma  = 1.5
+)
+
+// CompareSSIM returns the mean structural similarity index of b against a,
+// computed on luma over 11x11 Gaussian windows with the constants of Wang et
+// al. (2004). It ranges up to 1 for identical images and, unlike PSNR,
+// weighs differences by how visible they are against the local structure.
+// Images smaller than the window are compared as a single window. The
+// images must have the same size; see ComparePSNR.
+func CompareSSIM(a, b image.Image) (float64, error) {
+	if err := compareSizes(a, b); err != nil {
+		return 0, err
+	}
+	w, h := a.Bounds().Dx(), a.Bounds().Dy()
+	la, lb := lumaPlane(a), lumaPlane(b)
+	aa, bb, ab := make([]float64, w*h), make([]float64, w*h), make([]float64, w*h)
+	for i := range la {
+		aa[i], bb[i], ab[i] = la[i]*la[i], lb[i]*lb[i], la[i]*lb[i]
+	}
+
+	if w < ssimWindow || h < ssimWindow {
+		mean := func(p []float64) float64 {
+			var sum float64
+			for _, v := range p {
+				sum += v
+			}
+			return sum / float64(len(p))
+		}
+		return ssim(mean(la), mean(lb), mean(aa), mean(bb), mean(ab)), nil
+	}
+
+	kernel := make([]float64, ssimWindow)
+	var total float64
+	for i := range kernel {
+		d := float64(i - ssimWindow/2)
+		kernel[i] = math.Exp(-d * d / (2 * ssimSigma * ssimSigma))
+		total += kernel[i]
+	}
+	for i := range kernel {
+		kernel[i] /= total
+	}
+	ma, mb := gaussValid(la, w, h, kernel), gaussValid(lb, w, h, kernel)
+	maa, mbb, mab := gaussValid(aa, w, h, kernel), gaussValid(bb, w, h, kernel), gaussValid(ab, w, h, kernel)
+	var sum float64
+	for i := range ma {
+		sum += ssim(ma[i], mb[i], maa[i], mbb[i], mab[i])
+	}
+	return sum / float64(len(ma)), nil
+}
+
+// ssim combines the weighted means of a, b, a², b² and ab over a window
+// into its SSIM.
+func ssim(ma, mb, maa, mbb, mab float64) float64 {
+	const (
+		c1 = (0.01 * 255) * (0.01 * 255)
+		c2 = (0.03 * 255) * (0.03 * 255)
+	)
+	varA, varB, cov := maa-ma*ma, mbb-mb*mb, mab-ma*mb
+	return (2*ma*mb + c1) * (2*cov + c2) / ((ma*ma + mb*mb + c1) * (varA + varB + c2))
+}
+
+// gaussValid filters the w x h plane with the separable kernel in both
+// directions, keeping only the positions where the kernel fits entirely.
+func gaussValid(plane []float64, w, h int, kernel []float64) []float64 {
+	k := len(kernel)
+	ow, oh := w-k+1, h-k+1
+	rows := make([]float64, ow*h)
+	for y := 0; y < h; y++ {
+		in := plane[y*w:][:w]
+		out := rows[y*ow:][:ow]
+		for x := range out {
+			var v float64
+			for i, kv := range kernel {
+				v += kv * in[x+i]
+			}
+			out[x] = v
+		}
+	}
+	out := make([]float64, ow*oh)
+	for y := 0; y < oh; y++ {
+		for i, kv := range kernel {
+			in := rows[(y+i)*ow:][:ow]
+			dst := out[y*ow:][:ow]
+			for x := range dst {
+				dst[x] += kv * in[x]
+			}
+		}
+	}
+	return out
+}
+
+// lumaPlane returns the LumaGamma luma of img, row by row from the top left
+// of its bounds.
+func lumaPlane(img image.Image) []float64 {
+	bounds := img.Bounds()
+	plane := make([]float64, bounds.Dx()*bounds.Dy())
+	for y := 0; y < bounds.Dy(); y++ {
+		LumaGamma.lumaRow(img, bounds.Min.Y+y, bounds.Min.X, plane[y*bounds.Dx():][:bounds.Dx()])
+	}
+	return plane
+}
+
+// compareSizes reports an error unless a and b are non-empty images of the
+// same size.
+func compareSizes(a, b image.Image) error {
+	if a == nil || b == nil {
+		return fmt.Errorf("nil image provided")
+	}
+	as, bs := a.Bounds().Size(), b.Bounds().Size()
+	if as != bs {
+		return fmt.Errorf("image sizes differ: %dx%d vs %dx%d", as.X, as.Y, bs.X, bs.Y)
+	}
+	if as.X <= 0 || as.Y <= 0 {
+		return fmt.Errorf("invalid image dimensions %dx%d", as.X, as.Y)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package watermark
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestComparePSNR(t *testing.T) {
+	a := texturedRGBA(64, 48, 0, 0, 0)
+	if psnr, err := ComparePSNR(a, a); err != nil || !math.IsInf(psnr, 1) {
+		t.Fatalf("identical images: %v, %v", psnr, err)
+	}
+
+	// A uniform shift of 5 levels is an MSE of 25; the texture stays well
+	// inside 0-255.
+	b := image.NewRGBA(a.Bounds())
+	copy(b.Pix, a.Pix)
+	for i := range b.Pix {
+		if i%4 != 3 {
+			b.Pix[i] -= 5
+		}
+	}
+	want := 10 * math.Log10(255*255/25.0)
+	psnr, err := ComparePSNR(a, b)
+	if err != nil || math.Abs(psnr-want) > 1e-9 {
+		t.Errorf("shifted image: PSNR %.2f, want about %.2f (%v)", psnr, want, err)
+	}
+
+	// Sub-images compare by position within their bounds.
+	region := image.Rect(16, 8, 48, 40)
+	sub := a.SubImage(region)
+	moved := image.NewRGBA(image.Rect(0, 0, 32, 32))
+	for y := 0; y < 32; y++ {
+		for x := 0; x < 32; x++ {
+			moved.Set(x, y, a.At(region.Min.X+x, region.Min.Y+y))
+		}
+	}
+	if psnr, err := ComparePSNR(sub, moved); err != nil || !math.IsInf(psnr, 1) {
+		t.Errorf("sub-image: %v, %v", psnr, err)
+	}
+
+	if _, err := ComparePSNR(a, image.NewRGBA(image.Rect(0, 0, 48, 64))); err == nil {
+		t.Error("size mismatch not reported")
+	}
+}
+
+func TestCompareSSIM(t *testing.T) {
+	a := texturedRGBA(96, 80, 0, 0, 0)
+	if s, err := CompareSSIM(a, a); err != nil || math.Abs(s-1) > 1e-9 {
+		t.Fatalf("identical images: %v, %v", s, err)
+	}
+
+	// Removing a watermark well scores closer than leaving it.
+	marked := stampWatermark(t, texturedRGBA(1024, 1024, 0, 0, 0))
+	clean := texturedRGBA(1024, 1024, 0, 0, 0)
+	cleaned, err := NewEngine().RemoveWatermark(marked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	region := image.Rect(944, 944, 992, 992)
+	before, err := CompareSSIM(clean.SubImage(region), marked.SubImage(region))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := CompareSSIM(clean.SubImage(region), cleaned.SubImage(region))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !(after > 0.95 && after > before) {
+		t.Errorf("SSIM of watermark region %.4f before removal, %.4f after", before, after)
+	}
+
+	// Smaller than the window: one global comparison.
+	tiny := image.NewGray(image.Rect(0, 0, 4, 4))
+	flat := image.NewGray(tiny.Bounds())
+	for i := range tiny.Pix {
+		tiny.Pix[i] = uint8(16 * i)
+		flat.Pix[i] = 120
+	}
+	if s, err := CompareSSIM(tiny, flat); err != nil || s >= 0.5 {
+		t.Errorf("tiny images: %v, %v", s, err)
+	}
+	if _, err := CompareSSIM(tiny, image.NewUniform(color.Black)); err == nil {
+		t.Error("size mismatch not reported")
+	}
+}
@@ -0,0 +1,75 @@
+package watermark
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gcslaoli/gemini-watermark-remover-go/imagecmp"
+)
+
+func TestJSCompatMatchesReferenceOutput(t *testing.T) {
+	img, err := readSample(filepath.Join("cmd", "gwatermark", "image.png"))
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+	expected, err := readSample(filepath.Join("cmd", "gwatermark", "image_unwatermarked.png"))
+	if err != nil {
+		t.Fatalf("read expected: %v", err)
+	}
+
+	engine := NewEngine()
+	engine.SetJSCompat(true)
+
+	cleaned, err := engine.RemoveWatermark(img)
+	if err != nil {
+		t.Fatalf("RemoveWatermark: %v", err)
+	}
+
+	// The reference output is opaque, where both arithmetic paths agree.
+	if err := imagecmp.Diff(expected, cleaned, imagecmp.Options{}); err != nil {
+		t.Fatalf("JS-compatible output differs from reference: %v", err)
+	}
+}
+
+func TestJSCompatSkipsPostProcessing(t *testing.T) {
+	img, err := readSample(filepath.Join("cmd", "gwatermark", "image4.jpg"))
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+
+	plain := NewEngine()
+	plain.SetJSCompat(true)
+	want, err := plain.RemoveWatermark(img)
+	if err != nil {
+		t.Fatalf("RemoveWatermark: %v", err)
+	}
+
+	engine := NewEngine()
+	engine.SetJSCompat(true)
+	engine.SetClipInpaint(&ClipInpaint{})
+	engine.SetHaloCleanup(&HaloCleanup{AllInputs: true})
+	engine.SetNoiseMatch(&NoiseMatch{Seed: 1})
+	got, err := engine.RemoveWatermark(img)
+	if err != nil {
+		t.Fatalf("RemoveWatermark with post-processing: %v", err)
+	}
+	if err := imagecmp.Diff(want, got, imagecmp.Options{}); err != nil {
+		t.Fatalf("post-processing changed the JS-compatible output: %v", err)
+	}
+}
+
+func TestJSCompatTable(t *testing.T) {
+	skip, table := jsTables()
+
+	if !skip[0] {
+		t.Fatalf("alpha level 0 must be skipped")
+	}
+	// level 128: alpha ≈ 0.50196, so (200 - 128.0) / 0.49804 ≈ 144.57 → 145.
+	if got := table[128][200]; got != 145 {
+		t.Fatalf("table[128][200] = %d, want 145", got)
+	}
+	// Values darker than the logo contribution clamp to 0.
+	if got := table[255][10]; got != 0 {
+		t.Fatalf("table[255][10] = %d, want 0", got)
+	}
+}
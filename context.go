@@ -0,0 +1,79 @@
+package watermark
+
+import (
+	"context"
+	"crypto/sha256"
+	"image"
+	"io"
+)
+
+// ctxReader fails every read once ctx is done, so a decoder stops at its
+// next read instead of decoding the rest of a large image.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// ctxWriter fails every write once ctx is done, stopping encoders that
+// write as they go.
+type ctxWriter struct {
+	ctx context.Context
+	w   io.Writer
+}
+
+func (w ctxWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return w.w.Write(p)
+}
+
+// ctxErr returns ctx.Err() in place of err once ctx is done: decoders and
+// encoders may wrap or replace the error of a failed read or write, and
+// callers should see the cancellation.
+func ctxErr(ctx context.Context, err error) error {
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// decodeContext is DecodeImageBytes reading through ctx, served from the
+// decode cache when one is installed, with StageDecode progress reported to
+// the Default engine. The image may be shared, so callers must not modify
+// it.
+func decodeContext(ctx context.Context, data []byte) (image.Image, string, error) {
+	if len(data) == 0 {
+		return DecodeImageBytes(data)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
+	}
+	engine := Default()
+	engine.report(StageDecode, 0)
+	cache := currentDecodeCache()
+	var key [sha256.Size]byte
+	if cache != nil {
+		key = sha256.Sum256(data)
+		if img, format, ok := cache.get(key); ok {
+			engine.report(StageDecode, 100)
+			return img, format, nil
+		}
+	}
+	img, format, err := decodeImage(ctxReader{ctx, engine.decodeReader(data)})
+	if err = ctxErr(ctx, err); err != nil {
+		return nil, "", err
+	}
+	if cache != nil {
+		cache.put(key, img, format)
+	}
+	engine.report(StageDecode, 100)
+	return img, format, nil
+}
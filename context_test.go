@@ -0,0 +1,81 @@
+package watermark
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/color"
+	"io"
+	"testing"
+)
+
+func TestContextVariantsCancelled(t *testing.T) {
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{40, 60, 80, 255})
+	data, err := EncodePNGToBytes(img)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	animated := encodeTestGIF(t, 320, 240, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := RemoveWatermarkContext(ctx, img); !errors.Is(err, context.Canceled) {
+		t.Errorf("RemoveWatermarkContext: got %v, want context.Canceled", err)
+	}
+	if _, _, _, err := DetectWatermarkContext(ctx, img); !errors.Is(err, context.Canceled) {
+		t.Errorf("DetectWatermarkContext: got %v, want context.Canceled", err)
+	}
+	for name, input := range map[string][]byte{"png": data, "gif": animated} {
+		if _, err := ProcessBytesContext(ctx, input, Options{}); !errors.Is(err, context.Canceled) {
+			t.Errorf("ProcessBytesContext(%s): got %v, want context.Canceled", name, err)
+		}
+	}
+}
+
+func TestContextVariantsMatch(t *testing.T) {
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{40, 60, 80, 255})
+	ctx := context.Background()
+
+	want, err := RemoveWatermark(img)
+	if err != nil {
+		t.Fatalf("RemoveWatermark: %v", err)
+	}
+	got, err := RemoveWatermarkContext(ctx, img)
+	if err != nil {
+		t.Fatalf("RemoveWatermarkContext: %v", err)
+	}
+	if !bytes.Equal(got.Pix, want.Pix) {
+		t.Errorf("RemoveWatermarkContext differs from RemoveWatermark")
+	}
+}
+
+// cancelAfter cancels a context once n bytes have been read through it.
+type cancelAfter struct {
+	r      io.Reader
+	n      int
+	cancel context.CancelFunc
+}
+
+func (c *cancelAfter) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	if c.n -= n; c.n <= 0 {
+		c.cancel()
+	}
+	return n, err
+}
+
+func TestCtxReaderStopsDecode(t *testing.T) {
+	data, err := EncodePNGToBytes(texturedRGBA(512, 512, 0, 0, 0))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	src := &cancelAfter{r: bytes.NewReader(data), n: len(data) / 4, cancel: cancel}
+	_, _, err = Decode(ctxReader{ctx, src})
+	if err := ctxErr(ctx, err); !errors.Is(err, context.Canceled) {
+		t.Fatalf("decode: got %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,53 @@
+//go:build !watermark_nov1
+
+package watermark
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image/color"
+	"testing"
+)
+
+func TestBytesContextVariantsCancelled(t *testing.T) {
+	data, err := EncodePNGToBytes(watermarkedRGBA(t, 320, 240, color.RGBA{40, 60, 80, 255}))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, _, err := DetectWatermarkBytesContext(ctx, data); !errors.Is(err, context.Canceled) {
+		t.Errorf("DetectWatermarkBytesContext: got %v, want context.Canceled", err)
+	}
+	if _, _, _, _, err := RemoveWatermarkBytesContext(ctx, data); !errors.Is(err, context.Canceled) {
+		t.Errorf("RemoveWatermarkBytesContext: got %v, want context.Canceled", err)
+	}
+}
+
+func TestBytesContextVariantsMatch(t *testing.T) {
+	data, err := EncodePNGToBytes(watermarkedRGBA(t, 320, 240, color.RGBA{40, 60, 80, 255}))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	ctx := context.Background()
+
+	wantOut, _, wantScore, _, err := RemoveWatermarkBytes(data)
+	if err != nil {
+		t.Fatalf("RemoveWatermarkBytes: %v", err)
+	}
+	gotOut, present, gotScore, _, err := RemoveWatermarkBytesContext(ctx, data)
+	if err != nil {
+		t.Fatalf("RemoveWatermarkBytesContext: %v", err)
+	}
+	if !present || gotScore != wantScore || !bytes.Equal(gotOut, wantOut) {
+		t.Errorf("RemoveWatermarkBytesContext differs from RemoveWatermarkBytes (present=%v score %.3f vs %.3f)", present, gotScore, wantScore)
+	}
+
+	present, score, _, err := DetectWatermarkBytesContext(ctx, data)
+	if err != nil || !present || score != wantScore {
+		t.Errorf("DetectWatermarkBytesContext: present=%v score=%.3f err=%v, want score %.3f", present, score, err, wantScore)
+	}
+}
@@ -0,0 +1,112 @@
+package watermark
+
+import (
+	"fmt"
+	"image"
+	"strings"
+)
+
+// Corner selects the image corner a watermark is anchored to. Margins are
+// measured from the two image edges that meet at the corner.
+type Corner int
+
+const (
+	// CornerBottomRight is Gemini's placement and the default.
+	CornerBottomRight Corner = iota
+	CornerBottomLeft
+	CornerTopRight
+	CornerTopLeft
+	// CornerAuto tries all four corners and picks the placement that
+	// correlates best with the logo shape.
+	CornerAuto
+)
+
+// fixedCorners lists the concrete corners CornerAuto tries, in order of
+// preference for ties.
+var fixedCorners = []Corner{CornerBottomRight, CornerBottomLeft, CornerTopRight, CornerTopLeft}
+
+// String returns the short corner name accepted by ParseCorner.
+func (c Corner) String() string {
+	switch c {
+	case CornerBottomRight:
+		return "br"
+	case CornerBottomLeft:
+		return "bl"
+	case CornerTopRight:
+		return "tr"
+	case CornerTopLeft:
+		return "tl"
+	case CornerAuto:
+		return "auto"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseCorner parses br, bl, tr, tl or auto, case-insensitively.
+func ParseCorner(s string) (Corner, bool) {
+	switch strings.ToLower(s) {
+	case "br", "bottom-right":
+		return CornerBottomRight, true
+	case "bl", "bottom-left":
+		return CornerBottomLeft, true
+	case "tr", "top-right":
+		return CornerTopRight, true
+	case "tl", "top-left":
+		return CornerTopLeft, true
+	case "auto":
+		return CornerAuto, true
+	default:
+		return CornerBottomRight, false
+	}
+}
+
+// MarshalText encodes the corner as its short name, so JSON reports read
+// "br" rather than a number.
+func (c Corner) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText decodes a name accepted by ParseCorner.
+func (c *Corner) UnmarshalText(text []byte) error {
+	parsed, ok := ParseCorner(string(text))
+	if !ok {
+		return fmt.Errorf("unknown corner %q", text)
+	}
+	*c = parsed
+	return nil
+}
+
+// NearestCorner returns the corner of bounds closest to the center of rect,
+// for watermarks found at a position no profile placed, such as by search or
+// a plugin.
+func NearestCorner(rect, bounds image.Rectangle) Corner {
+	center := rect.Min.Add(rect.Max)
+	mid := bounds.Min.Add(bounds.Max)
+	left, top := center.X < mid.X, center.Y < mid.Y
+	switch {
+	case left && top:
+		return CornerTopLeft
+	case top:
+		return CornerTopRight
+	case left:
+		return CornerBottomLeft
+	default:
+		return CornerBottomRight
+	}
+}
+
+// origin returns the top-left point of a size x size logo anchored to corner
+// c of bounds with the given horizontal and vertical margins. CornerAuto is
+// placed like CornerBottomRight.
+func (c Corner) origin(bounds image.Rectangle, size, marginX, marginY int) image.Point {
+	x := bounds.Max.X - marginX - size
+	y := bounds.Max.Y - marginY - size
+	if c == CornerBottomLeft || c == CornerTopLeft {
+		x = bounds.Min.X + marginX
+	}
+	if c == CornerTopRight || c == CornerTopLeft {
+		y = bounds.Min.Y + marginY
+	}
+	return image.Pt(x, y)
+}
@@ -0,0 +1,94 @@
+package watermark
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+	"testing"
+)
+
+func TestCornerAutoFindsBottomLeft(t *testing.T) {
+	const width, height = 320, 240
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: 40, G: 60, B: 90, A: 255}}, image.Point{}, draw.Src)
+
+	want := image.Rect(32, height-32-48, 32+48, height-32)
+	alphaMap, err := detectAlphaMap(48)
+	if err != nil {
+		t.Fatalf("alpha map: %v", err)
+	}
+	for row := 0; row < 48; row++ {
+		for col := 0; col < 48; col++ {
+			alpha := float64(alphaMap[row*48+col])
+			offset := img.PixOffset(want.Min.X+col, want.Min.Y+row)
+			for c := 0; c < 3; c++ {
+				img.Pix[offset+c] = uint8(alpha*logoValue + (1-alpha)*float64(img.Pix[offset+c]) + 0.5)
+			}
+		}
+	}
+
+	if present, _, _, _ := DetectWatermark(img); present {
+		t.Fatalf("bottom-right profile should not match a bottom-left logo")
+	}
+
+	p := GeminiProfile()
+	p.Corner = CornerAuto
+	present, _, info, err := DetectWatermarkProfile(img, p)
+	if err != nil {
+		t.Fatalf("DetectWatermarkProfile: %v", err)
+	}
+	if !present || info.Position != want || info.Corner != CornerBottomLeft {
+		t.Fatalf("auto corner: present=%v at %v, want %v", present, info.Position, want)
+	}
+
+	cleaned, err := NewEngine().RemoveWatermarkProfile(img, p)
+	if err != nil {
+		t.Fatalf("RemoveWatermarkProfile: %v", err)
+	}
+	if err := VerifyRegionOnly(img, cleaned, want); err != nil {
+		t.Fatalf("auto corner removal: %v", err)
+	}
+	if present, _, _, _ := DetectWatermarkProfile(cleaned, p); present {
+		t.Fatalf("watermark still detected after auto corner removal")
+	}
+}
+
+func TestParseCorner(t *testing.T) {
+	for _, c := range append(fixedCorners, CornerAuto) {
+		got, ok := ParseCorner(c.String())
+		if !ok || got != c {
+			t.Fatalf("ParseCorner(%q) = %v, %v", c.String(), got, ok)
+		}
+	}
+	data, err := json.Marshal(Info{Corner: CornerTopLeft})
+	if err != nil || !strings.Contains(string(data), `"Corner":"tl"`) {
+		t.Fatalf("unexpected JSON %s (%v)", data, err)
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil || info.Corner != CornerTopLeft {
+		t.Fatalf("round trip: %v %v", info.Corner, err)
+	}
+
+	if _, ok := ParseCorner("middle"); ok {
+		t.Fatalf("expected unknown corner to fail")
+	}
+}
+
+func TestNearestCorner(t *testing.T) {
+	bounds := image.Rect(0, 0, 1000, 800)
+	for _, tc := range []struct {
+		rect image.Rectangle
+		want Corner
+	}{
+		{image.Rect(900, 700, 948, 748), CornerBottomRight},
+		{image.Rect(32, 700, 80, 748), CornerBottomLeft},
+		{image.Rect(900, 32, 948, 80), CornerTopRight},
+		{image.Rect(32, 32, 80, 80), CornerTopLeft},
+	} {
+		if got := NearestCorner(tc.rect, bounds); got != tc.want {
+			t.Errorf("NearestCorner(%v) = %v, want %v", tc.rect, got, tc.want)
+		}
+	}
+}
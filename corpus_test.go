@@ -0,0 +1,63 @@
+package watermark
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCorpusAccuracy checks detection and removal on the sample corpus
+// listed in testdata/corpus.json. The corpus is hosted outside the
+// repository; files not fetched into testdata/corpus are skipped.
+func TestCorpusAccuracy(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "corpus.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest struct {
+		Files []struct {
+			Name        string `json:"name"`
+			Watermarked bool   `json:"watermarked"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range manifest.Files {
+		t.Run(f.Name, func(t *testing.T) {
+			data, err := os.ReadFile(filepath.Join("testdata", "corpus", filepath.FromSlash(f.Name)))
+			if errors.Is(err, fs.ErrNotExist) {
+				t.Skip("corpus file missing; run gwatermark fetch-testdata")
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			d, err := DetectResultBytes(data, GeminiProfile())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Present != f.Watermarked {
+				t.Fatalf("present = %v (score %.2f, correlation %.2f), want %v", d.Present, d.Score, d.Correlation, f.Watermarked)
+			}
+			if !f.Watermarked {
+				return
+			}
+
+			r, err := ProcessBytes(data, Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, err := DetectResultBytes(r.Output, GeminiProfile())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after.Present {
+				t.Errorf("watermark still detected after removal (score %.2f)", after.Score)
+			}
+		})
+	}
+}
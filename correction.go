@@ -0,0 +1,80 @@
+package watermark
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// CorrectionBias is the channel value of a correction layer that encodes
+// no change.
+const CorrectionBias = 32768
+
+// CorrectionLayer returns the exact correction that turns before into
+// after, typically the input and the cleaned image, as an opaque 16-bit
+// image: each red, green and blue channel holds CorrectionBias plus the
+// signed difference after - before of the 8-bit channel values, compared
+// without alpha premultiplication. Unchanged pixels are uniform gray, and
+// encoded as PNG the layer is lossless, so ApplyCorrection and
+// UndoCorrection reconstruct either image from the other exactly. 16-bit
+// images are compared at 8-bit precision. Both images must share bounds and
+// alpha, which watermark removal never changes.
+func CorrectionLayer(before, after image.Image) (*image.RGBA64, error) {
+	bounds := before.Bounds()
+	if after.Bounds() != bounds {
+		return nil, fmt.Errorf("image bounds differ: %v vs %v", bounds, after.Bounds())
+	}
+
+	a, b := cloneToNRGBA(before), cloneToNRGBA(after)
+	layer := image.NewRGBA64(bounds)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			pa, pb := a.NRGBAAt(x, y), b.NRGBAAt(x, y)
+			if pa.A != pb.A {
+				return nil, fmt.Errorf("alpha differs at (%d,%d): %d vs %d", x, y, pa.A, pb.A)
+			}
+			layer.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(CorrectionBias + int(pb.R) - int(pa.R)),
+				G: uint16(CorrectionBias + int(pb.G) - int(pa.G)),
+				B: uint16(CorrectionBias + int(pb.B) - int(pa.B)),
+				A: 0xffff,
+			})
+		}
+	}
+	return layer, nil
+}
+
+// ApplyCorrection adds a CorrectionLayer to img, turning the image the
+// layer was made from into the cleaned one.
+func ApplyCorrection(img, layer image.Image) (*image.NRGBA, error) {
+	return correct(img, layer, 1)
+}
+
+// UndoCorrection subtracts a CorrectionLayer from img, turning the cleaned
+// image back into the one the layer was made from.
+func UndoCorrection(img, layer image.Image) (*image.NRGBA, error) {
+	return correct(img, layer, -1)
+}
+
+func correct(img, layer image.Image, sign int) (*image.NRGBA, error) {
+	bounds := img.Bounds()
+	if layer.Bounds() != bounds {
+		return nil, fmt.Errorf("image bounds differ: %v vs %v", bounds, layer.Bounds())
+	}
+
+	out := cloneToNRGBA(img)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := layer.At(x, y).RGBA()
+			p := out.Pix[out.PixOffset(x, y):][:3]
+			for c, v := range [3]uint32{r, g, b} {
+				n := int(p[c]) + sign*(int(v)-CorrectionBias)
+				if n < 0 || n > 255 {
+					return nil, fmt.Errorf("correction at (%d,%d) leaves 0-255; the layer does not belong to this image", x, y)
+				}
+				p[c] = uint8(n)
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,88 @@
+package watermark
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+
+	"github.com/gcslaoli/gemini-watermark-remover-go/imagecmp"
+)
+
+func TestCorrectionLayer(t *testing.T) {
+	marked := watermarkedRGBA(t, 1024, 1024, color.RGBA{40, 90, 160, 255})
+	cleaned, err := NewEngine().RemoveWatermark(marked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, err := CorrectionLayer(marked, cleaned)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the watermark region carries a correction, and removing a white
+	// logo only darkens.
+	for _, p := range []image.Point{{0, 0}, {500, 900}, {1023, 1023}} {
+		if c := layer.RGBA64At(p.X, p.Y); c != (color.RGBA64{CorrectionBias, CorrectionBias, CorrectionBias, 0xffff}) {
+			t.Errorf("layer at %v = %v, want no change", p, c)
+		}
+	}
+	if c := layer.RGBA64At(968, 968); c.R >= CorrectionBias {
+		t.Errorf("layer at the logo center = %v, want a negative red correction", c)
+	}
+
+	// The layer survives PNG exactly and reconstructs both images.
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, layer); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	applied, err := ApplyCorrection(marked, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := imagecmp.Diff(cleaned, applied, imagecmp.Options{}); err != nil {
+		t.Errorf("ApplyCorrection: %v", err)
+	}
+	undone, err := UndoCorrection(cleaned, decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := imagecmp.Diff(marked, undone, imagecmp.Options{}); err != nil {
+		t.Errorf("UndoCorrection: %v", err)
+	}
+
+	// Darkening a black image leaves the range.
+	if _, err := ApplyCorrection(image.NewRGBA(marked.Bounds()), decoded); err == nil {
+		t.Error("layer applied to an unrelated image without error")
+	}
+	if _, err := CorrectionLayer(marked, image.NewRGBA(image.Rect(0, 0, 10, 10))); err == nil {
+		t.Error("bounds mismatch not reported")
+	}
+}
+
+func TestProcessBytesCorrection(t *testing.T) {
+	var input bytes.Buffer
+	if err := png.Encode(&input, watermarkedRGBA(t, 1024, 1024, color.RGBA{40, 90, 160, 255})); err != nil {
+		t.Fatal(err)
+	}
+	result, err := ProcessBytes(input.Bytes(), Options{Correction: true, Output: OutputJPEG})
+	if err != nil {
+		t.Fatal(err)
+	}
+	layer, format, err := image.Decode(bytes.NewReader(result.Correction))
+	if err != nil || format != "png" {
+		t.Fatalf("correction layer: %v, %q", err, format)
+	}
+	if layer.Bounds().Dx() != 1024 {
+		t.Errorf("layer bounds %v", layer.Bounds())
+	}
+
+	if result, err := ProcessBytes(input.Bytes(), Options{}); err != nil || result.Correction != nil {
+		t.Errorf("correction without Options.Correction: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package watermark
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+
+	"golang.org/x/sys/cpu"
+)
+
+// Pixel kernel names, as reported by Capabilities and accepted by the
+// GWATERMARK_KERNEL environment variable.
+const (
+	KernelGeneric = "generic"
+	KernelAVX2    = "avx2"
+	KernelNEON    = "neon"
+)
+
+// KernelEnv names the environment variable that overrides kernel selection
+// at startup, e.g. GWATERMARK_KERNEL=generic to rule out a SIMD kernel when
+// chasing a difference in output.
+const KernelEnv = "GWATERMARK_KERNEL"
+
+// kernel is one implementation of the reverse-alpha blend over a band of
+// rows, with a check for the CPU feature it needs.
+type kernel struct {
+	supported func() bool
+	rows      func(img *image.RGBA, alphaMap []float32, rect, band image.Rectangle, logo [3]float64, mode RoundingMode)
+}
+
+// kernels lists the kernels built into the binary. Architecture-specific
+// files register their SIMD kernels here; the portable kernel is always
+// available, and is currently the only one built in.
+var kernels = map[string]kernel{
+	KernelGeneric: {supported: func() bool { return true }, rows: applyReverseAlphaRows},
+}
+
+// kernelPreference is the order auto-selection tries kernels in.
+var kernelPreference = []string{KernelAVX2, KernelNEON, KernelGeneric}
+
+// CPUCapabilities reports the CPU features the pixel kernels can use and
+// the kernel selected for this process.
+type CPUCapabilities struct {
+	GOARCH string
+	// AVX2 and NEON report what the CPU supports, whether or not a kernel
+	// using it is built in.
+	AVX2 bool
+	NEON bool
+	// Kernel is the active kernel and Available every kernel built into
+	// the binary that this CPU can run.
+	Kernel    string
+	Available []string
+	// Override is the value of GWATERMARK_KERNEL, and Note explains why it
+	// was not honored, if it was not.
+	Override string
+	Note     string
+}
+
+var capabilities = selectKernel(os.Getenv(KernelEnv))
+
+// reverseAlphaRows is the blend of the selected kernel, which
+// applyReverseAlpha runs on every band.
+var reverseAlphaRows = kernels[capabilities.Kernel].rows
+
+// Capabilities reports the detected CPU features and the active kernel,
+// chosen once at startup: the fastest kernel the CPU supports unless
+// GWATERMARK_KERNEL names another available one. Unknown or unsupported
+// overrides fall back to auto-selection and say so in Note.
+func Capabilities() CPUCapabilities {
+	c := capabilities
+	c.Available = append([]string(nil), c.Available...)
+	return c
+}
+
+// selectKernel picks the kernel for override, "" or "auto" for the
+// fastest one available.
+func selectKernel(override string) CPUCapabilities {
+	c := CPUCapabilities{
+		GOARCH:   runtime.GOARCH,
+		AVX2:     cpu.X86.HasAVX2,
+		NEON:     cpu.ARM64.HasASIMD,
+		Override: override,
+	}
+	for _, name := range kernelPreference {
+		if k, ok := kernels[name]; ok && k.supported() {
+			c.Available = append(c.Available, name)
+		}
+	}
+
+	want := strings.ToLower(strings.TrimSpace(override))
+	if want != "" && want != "auto" {
+		if slices.Contains(c.Available, want) {
+			c.Kernel = want
+			return c
+		}
+		switch _, built := kernels[want]; {
+		case !slices.Contains(kernelPreference, want):
+			c.Note = fmt.Sprintf("unknown kernel %q", override)
+		case !built:
+			c.Note = fmt.Sprintf("the %s kernel is not built into this binary", want)
+		default:
+			c.Note = fmt.Sprintf("the %s kernel is not supported by this CPU", want)
+		}
+	}
+	c.Kernel = c.Available[0]
+	return c
+}
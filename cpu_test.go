@@ -0,0 +1,58 @@
+package watermark
+
+import (
+	"image"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelectKernel(t *testing.T) {
+	auto := selectKernel("")
+	if auto.Kernel != auto.Available[0] || auto.Note != "" {
+		t.Fatalf("auto selection = %+v, want the first available kernel", auto)
+	}
+	if c := selectKernel(" Generic "); c.Kernel != KernelGeneric || c.Note != "" {
+		t.Errorf("override generic = %+v", c)
+	}
+	if c := selectKernel("auto"); c.Kernel != auto.Kernel || c.Note != "" {
+		t.Errorf("override auto = %+v, want %s", c, auto.Kernel)
+	}
+
+	c := selectKernel("sse9")
+	if c.Kernel != auto.Kernel || !strings.Contains(c.Note, "unknown kernel") {
+		t.Errorf("unknown override = %+v, want a fallback with a note", c)
+	}
+	if _, built := kernels[KernelAVX2]; !built {
+		c := selectKernel(KernelAVX2)
+		if c.Kernel != auto.Kernel || !strings.Contains(c.Note, "not built") {
+			t.Errorf("avx2 override without the kernel = %+v", c)
+		}
+	}
+}
+
+func TestCapabilitiesCopy(t *testing.T) {
+	c := Capabilities()
+	c.Available[0] = "changed"
+	if Capabilities().Available[0] == "changed" {
+		t.Error("Capabilities shares its Available slice")
+	}
+}
+
+func TestReverseAlphaUsesSelectedKernel(t *testing.T) {
+	if got := reflect.ValueOf(reverseAlphaRows).Pointer(); got != reflect.ValueOf(kernels[Capabilities().Kernel].rows).Pointer() {
+		t.Fatalf("blend does not run on the %s kernel", Capabilities().Kernel)
+	}
+
+	saved := reverseAlphaRows
+	defer func() { reverseAlphaRows = saved }()
+	calls := 0
+	reverseAlphaRows = func(img *image.RGBA, alphaMap []float32, rect, band image.Rectangle, logo [3]float64, mode RoundingMode) {
+		calls++
+	}
+	rect := image.Rect(0, 0, 4, 4)
+	applyReverseAlpha(image.NewRGBA(rect), make([]float32, 16), rect, whiteLogo, RoundHalfUp, 1)
+	if calls != 1 {
+		t.Fatalf("kernel ran on %d bands, want 1", calls)
+	}
+}
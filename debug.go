@@ -0,0 +1,143 @@
+package watermark
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Colors of the DebugRender overlay.
+var (
+	debugPresent  = color.RGBA{G: 255, A: 255}
+	debugAbsent   = color.RGBA{R: 255, A: 255}
+	debugSurround = color.RGBA{R: 160, G: 160, B: 160, A: 255}
+	debugContour  = color.RGBA{G: 255, B: 255, A: 255}
+)
+
+// debugContourAlpha is the logo alpha DebugRender outlines.
+const debugContourAlpha = 0.2
+
+// DebugRender draws what detection measured onto a copy of img, using the
+// default engine and the Gemini profile. See Engine.DebugRender.
+func DebugRender(img image.Image) (*image.RGBA, error) {
+	return Default().DebugRender(img, GeminiProfile())
+}
+
+// DebugRender detects the watermark placed according to p and draws the
+// measurements behind the verdict onto a copy of img, for diagnosing false
+// positives and misses:
+//
+//   - inside the examined rectangle, each pixel's luma residual against the
+//     surrounding background as a heat map: red to yellow for brighter than
+//     the background, as the logo makes pixels, blue for darker;
+//   - the outline of the logo's alpha mask in cyan, which the bright
+//     residuals should follow when a watermark is there;
+//   - the rectangle in green when a watermark was detected, red when not,
+//     and the background band around it in gray.
+//
+// Images for which p has no placement return an error.
+func (e *Engine) DebugRender(img image.Image, p Profile) (*image.RGBA, error) {
+	d, err := e.DetectResult(img, p)
+	if err != nil {
+		return nil, err
+	}
+	bounds := img.Bounds()
+	cfg, ok := p.config(bounds.Dx(), bounds.Dy())
+	if !ok || d.Info.Size == 0 {
+		return nil, fmt.Errorf("no watermark placement for a %dx%d image", bounds.Dx(), bounds.Dy())
+	}
+	cfg.LogoSize = d.Info.Size
+	alphaMap, err := cfg.detectAlpha(e)
+	if err != nil {
+		return nil, err
+	}
+
+	rect := d.Info.Position
+	outer := surround(rect, bounds)
+	mode := e.detectParams(p).luminance
+	bgMean, _ := meanLuma(img, outer, rect, mode)
+
+	stride := rect.Dx()
+	residuals := make([]float64, stride*rect.Dy())
+	peak := 1.0
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		row := residuals[(y-rect.Min.Y)*stride:][:stride]
+		mode.lumaRow(img, y, rect.Min.X, row)
+		for i := range row {
+			row[i] -= bgMean
+			peak = math.Max(peak, math.Abs(row[i]))
+		}
+	}
+
+	out := cloneToRGBA(img)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			idx := (y-rect.Min.Y)*stride + x - rect.Min.X
+			off := out.PixOffset(x, y)
+			if onAlphaContour(alphaMap, stride, x-rect.Min.X, y-rect.Min.Y) {
+				setRGBA(out.Pix[off:off+4], debugContour)
+				continue
+			}
+			t := residuals[idx] / peak
+			blendRGBA(out.Pix[off:off+4], heatColor(t), 0.35+0.5*math.Abs(t))
+		}
+	}
+
+	drawOutline(out, outer, debugSurround)
+	if d.Present {
+		drawOutline(out, rect, debugPresent)
+	} else {
+		drawOutline(out, rect, debugAbsent)
+	}
+	return out, nil
+}
+
+// heatColor maps t in [-1, 1] to blue for negative values and red through
+// yellow for positive ones.
+func heatColor(t float64) color.RGBA {
+	if t < 0 {
+		return color.RGBA{G: uint8(128 * -t), B: 255, A: 255}
+	}
+	return color.RGBA{R: 255, G: uint8(255 * t), A: 255}
+}
+
+// onAlphaContour reports whether the mask pixel at (x, y) reaches
+// debugContourAlpha while a 4-neighbor, or the mask edge, does not.
+func onAlphaContour(alphaMap []float32, size, x, y int) bool {
+	if alphaMap[y*size+x] < debugContourAlpha {
+		return false
+	}
+	for _, n := range [4][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		nx, ny := x+n[0], y+n[1]
+		if nx < 0 || ny < 0 || nx >= size || ny >= len(alphaMap)/size || alphaMap[ny*size+nx] < debugContourAlpha {
+			return true
+		}
+	}
+	return false
+}
+
+// drawOutline draws the one-pixel border of r, clipped to img.
+func drawOutline(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	for x := r.Min.X; x < r.Max.X; x++ {
+		img.SetRGBA(x, r.Min.Y, c)
+		img.SetRGBA(x, r.Max.Y-1, c)
+	}
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		img.SetRGBA(r.Min.X, y, c)
+		img.SetRGBA(r.Max.X-1, y, c)
+	}
+}
+
+func setRGBA(p []uint8, c color.RGBA) {
+	p[0], p[1], p[2], p[3] = c.R, c.G, c.B, c.A
+}
+
+// blendRGBA composites opaque c over the pixel p with opacity a.
+func blendRGBA(p []uint8, c color.RGBA, a float64) {
+	a = math.Min(a, 1)
+	for i, v := range [3]uint8{c.R, c.G, c.B} {
+		p[i] = uint8(float64(p[i])*(1-a) + float64(v)*a + 0.5)
+	}
+	p[3] = 255
+}
@@ -0,0 +1,50 @@
+package watermark
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDebugRender(t *testing.T) {
+	bg := color.RGBA{R: 40, G: 60, B: 90, A: 255}
+	for _, tc := range []struct {
+		name    string
+		img     *image.RGBA
+		outline color.RGBA
+	}{
+		{"watermarked", watermarkedRGBA(t, 320, 240, bg), debugPresent},
+		{"clean", uniformRGBA(320, 240, bg), debugAbsent},
+	} {
+		out, err := DebugRender(tc.img)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		d, err := DetectResult(tc.img, GeminiProfile())
+		if err != nil {
+			t.Fatal(err)
+		}
+		rect := d.Info.Position
+		if got := out.RGBAAt(rect.Min.X, rect.Min.Y); got != tc.outline {
+			t.Errorf("%s: rectangle outline = %v, want %v", tc.name, got, tc.outline)
+		}
+		if got := out.RGBAAt(0, 0); got != tc.img.RGBAAt(0, 0) {
+			t.Errorf("%s: pixel outside the overlay changed to %v", tc.name, got)
+		}
+		if got, want := out.RGBAAt(surround(rect, out.Rect).Min.X, rect.Min.Y), debugSurround; got != want {
+			t.Errorf("%s: surround outline = %v, want %v", tc.name, got, want)
+		}
+	}
+
+	if _, err := DebugRender(image.NewRGBA(image.Rect(0, 0, 20, 20))); err == nil {
+		t.Error("image without a placement rendered without error")
+	}
+}
+
+func uniformRGBA(w, h int, c color.RGBA) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = c.R, c.G, c.B, c.A
+	}
+	return img
+}
@@ -1,12 +1,17 @@
 package watermark
 
 import (
+	"bufio"
 	"image"
+	"image/jpeg"
 	"image/png"
 	"io"
+	"math"
+	"sync"
 
-	// Register common decoders, including WebP via x/image/webp.
-	_ "golang.org/x/image/webp"
+	"golang.org/x/image/webp"
+
+	// Register common decoders.
 	_ "image/gif"
 	_ "image/jpeg"
 	_ "image/png"
@@ -14,11 +19,92 @@ import (
 
 // Decode reads an image from the reader, returning the decoded image and the
 // detected format string ("png", "jpeg", "webp", etc.).
-func Decode(r io.Reader) (image.Image, string, error) {
-	return image.Decode(r)
+func Decode(r io.Reader) (_ image.Image, _ string, err error) {
+	defer recoverPanic("Decode", nil, &err)
+	return decodeImage(r)
+}
+
+// decodeImage is image.Decode, except that WebP always decodes with
+// x/image/webp. The WebP encoder registers its own decoder for the format,
+// which image.Decode would otherwise pick, returning other image types.
+func decodeImage(r io.Reader) (image.Image, string, error) {
+	br := bufio.NewReader(r)
+	if isWebP(br) {
+		img, err := webp.Decode(br)
+		if err != nil {
+			return nil, "webp", err
+		}
+		return webpFullRange(img), "webp", nil
+	}
+	return image.Decode(br)
+}
+
+// webpFullRange rescales the planes of a lossy WebP, which x/image/webp
+// returns with VP8's studio-range Y (16-235) and chroma (16-240), to the
+// full range image.YCbCr converts to RGB with, so colors come out as libwebp
+// decodes them. Lossless images are returned unchanged.
+func webpFullRange(img image.Image) image.Image {
+	var ycc *image.YCbCr
+	switch m := img.(type) {
+	case *image.YCbCr:
+		ycc = m
+	case *image.NYCbCrA:
+		ycc = &m.YCbCr
+	default:
+		return img
+	}
+	lumaLUT, chromaLUT := studioToFull()
+	for i, v := range ycc.Y {
+		ycc.Y[i] = lumaLUT[v]
+	}
+	for i := range ycc.Cb {
+		ycc.Cb[i], ycc.Cr[i] = chromaLUT[ycc.Cb[i]], chromaLUT[ycc.Cr[i]]
+	}
+	return img
+}
+
+// studioToFull returns the lookup tables from studio-range luma and chroma
+// to full range.
+var studioToFull = sync.OnceValues(func() (luma, chroma [256]uint8) {
+	for v := range 256 {
+		luma[v] = uint8(max(0, min(255, math.Round(float64(v-16)*255/219))))
+		chroma[v] = uint8(max(0, min(255, math.Round(128+float64(v-128)*255/224))))
+	}
+	return luma, chroma
+})
+
+// decodeConfig is image.DecodeConfig with WebP read as decodeImage does.
+func decodeConfig(r io.Reader) (image.Config, string, error) {
+	br := bufio.NewReader(r)
+	if isWebP(br) {
+		cfg, err := webp.DecodeConfig(br)
+		return cfg, "webp", err
+	}
+	return image.DecodeConfig(br)
+}
+
+// isWebP reports whether br starts with a RIFF WEBP header.
+func isWebP(br *bufio.Reader) bool {
+	head, _ := br.Peek(12)
+	return len(head) == 12 && string(head[:4]) == "RIFF" && string(head[8:]) == "WEBP"
 }
 
 // EncodePNG writes the provided image to the writer as PNG.
 func EncodePNG(w io.Writer, img image.Image) error {
 	return png.Encode(w, img)
 }
+
+// DefaultJPEGQuality is used by EncodeJPEG for qualities outside 1-100. It is
+// higher than image/jpeg's default so a cleaned photo loses little detail
+// on its second encode.
+const DefaultJPEGQuality = 92
+
+// EncodeJPEG writes the provided image to the writer as JPEG at the given
+// quality (1-100). JPEG has no alpha channel; translucent pixels are
+// composited onto black.
+func EncodeJPEG(w io.Writer, img image.Image, quality int) error {
+	if quality < 1 || quality > 100 {
+		quality = DefaultJPEGQuality
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: quality})
+}
@@ -0,0 +1,103 @@
+package watermark
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"image"
+	"sync"
+)
+
+// DecodeCache keeps the most recently decoded images of the byte-level
+// helpers, keyed by a SHA-256 of the encoded bytes. Servers typically detect
+// an upload and then clean it, or preview it and then clean it; with a
+// cache installed the second call reuses the first call's decode.
+//
+// Cached images are shared and never modified by the helpers. Each entry
+// holds a whole decoded image, so keep the capacity near the number of
+// requests in flight. A DecodeCache is safe for concurrent use.
+type DecodeCache struct {
+	mu      sync.Mutex
+	entries int
+	lru     *list.List // of *decodeEntry, most recent first
+	byKey   map[[sha256.Size]byte]*list.Element
+
+	hits, misses int
+}
+
+type decodeEntry struct {
+	key    [sha256.Size]byte
+	img    image.Image
+	format string
+}
+
+// NewDecodeCache returns a cache of up to entries decoded images.
+func NewDecodeCache(entries int) *DecodeCache {
+	return &DecodeCache{
+		entries: max(entries, 1),
+		lru:     list.New(),
+		byKey:   make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// Stats reports how many decodes the cache saved and how many it could not.
+func (c *DecodeCache) Stats() (hits, misses int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses
+}
+
+// Len reports the number of cached images.
+func (c *DecodeCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+func (c *DecodeCache) get(key [sha256.Size]byte) (image.Image, string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.byKey[key]
+	if !ok {
+		c.misses++
+		return nil, "", false
+	}
+	c.hits++
+	c.lru.MoveToFront(el)
+	e := el.Value.(*decodeEntry)
+	return e.img, e.format, true
+}
+
+func (c *DecodeCache) put(key [sha256.Size]byte, img image.Image, format string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.byKey[key]; ok {
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.byKey[key] = c.lru.PushFront(&decodeEntry{key: key, img: img, format: format})
+	for c.lru.Len() > c.entries {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.byKey, oldest.Value.(*decodeEntry).key)
+	}
+}
+
+var decodeCache struct {
+	mu    sync.RWMutex
+	cache *DecodeCache
+}
+
+// SetDecodeCache installs a process-wide cache of decoded images for
+// ProcessBytes, DetectResultBytes, InspectBytes, PreviewBytes and the v1
+// byte helpers. A nil cache, the default, decodes every call afresh.
+func SetDecodeCache(c *DecodeCache) {
+	decodeCache.mu.Lock()
+	decodeCache.cache = c
+	decodeCache.mu.Unlock()
+}
+
+func currentDecodeCache() *DecodeCache {
+	decodeCache.mu.RLock()
+	defer decodeCache.mu.RUnlock()
+	return decodeCache.cache
+}
@@ -0,0 +1,67 @@
+package watermark
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDecodeCacheEviction(t *testing.T) {
+	c := NewDecodeCache(2)
+	keys := [][sha256.Size]byte{{1}, {2}, {3}}
+	img := image.NewGray(image.Rect(0, 0, 1, 1))
+	c.put(keys[0], img, "png")
+	c.put(keys[1], img, "png")
+	if _, _, ok := c.get(keys[0]); !ok {
+		t.Fatal("first entry missing")
+	}
+	// keys[1] is now the least recently used.
+	c.put(keys[2], img, "png")
+	if _, _, ok := c.get(keys[1]); ok {
+		t.Error("least recently used entry survived eviction")
+	}
+	if _, format, ok := c.get(keys[0]); !ok || format != "png" {
+		t.Errorf("recently used entry: ok=%v format=%q", ok, format)
+	}
+	if c.Len() != 2 {
+		t.Errorf("Len = %d, want 2", c.Len())
+	}
+	if hits, misses := c.Stats(); hits != 2 || misses != 1 {
+		t.Errorf("Stats = %d hits, %d misses, want 2 and 1", hits, misses)
+	}
+}
+
+func TestDecodeCacheDetectThenProcess(t *testing.T) {
+	data, err := EncodePNGToBytes(watermarkedRGBA(t, 320, 240, color.RGBA{40, 60, 80, 255}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ProcessBytes(data, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewDecodeCache(4)
+	SetDecodeCache(cache)
+	defer SetDecodeCache(nil)
+
+	if r, err := DetectResultBytes(data, GeminiProfile()); err != nil || !r.Present {
+		t.Fatalf("DetectResultBytes: present=%v err=%v", r.Present, err)
+	}
+	got, err := ProcessBytes(data, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits, misses := cache.Stats(); hits != 1 || misses != 1 {
+		t.Errorf("Stats = %d hits, %d misses, want the second decode skipped", hits, misses)
+	}
+	if !bytes.Equal(got.Output, want.Output) {
+		t.Error("output from the cached decode differs")
+	}
+	// The shared image must come through removal untouched.
+	if again, err := ProcessBytes(data, Options{}); err != nil || !bytes.Equal(again.Output, want.Output) {
+		t.Errorf("repeated removal differs (err=%v)", err)
+	}
+}
@@ -0,0 +1,162 @@
+package watermark
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+)
+
+// is16Bit reports whether img holds 16 bits per channel, as the PNG decoder
+// returns for 16-bit PNGs.
+func is16Bit(img image.Image) bool {
+	switch img.(type) {
+	case *image.RGBA64, *image.NRGBA64, *image.Gray16:
+		return true
+	}
+	return false
+}
+
+// RemoveWatermarkDepth removes the watermark placed according to profile p
+// without losing precision to the representation of the result. 16-bit
+// images (*image.RGBA64, *image.NRGBA64 and *image.Gray16) are reverse
+// blended at 16 bits per channel, which EncodePNG writes as a 16-bit PNG.
+// Translucent images come back with straight alpha, as *image.NRGBA or
+// *image.NRGBA64, so encoding them does not round semi-transparent pixels
+// through premultiplied values; opaque ones as *image.RGBA or
+// *image.RGBA64. Noise matching and the JS-compatible blend are defined on
+// 8-bit values, so engines using them always return 8 bits.
+func (e *Engine) RemoveWatermarkDepth(img image.Image, p Profile) (image.Image, error) {
+	info, err := e.placement(img, p)
+	if err != nil {
+		return nil, err
+	}
+	return e.removeDepth(context.Background(), img, info, p)
+}
+
+// RemoveWatermarkDepthInfo is RemoveWatermarkDepth at the placement info,
+// typically as reported by detection, instead of the standard one, so
+// watermarks found at another size, such as on upscaled images, are removed
+// where they were found. The logo capture and color come from profile p;
+// info.Position must be a square of info.Size inside the image.
+func (e *Engine) RemoveWatermarkDepthInfo(img image.Image, info Info, p Profile) (image.Image, error) {
+	if img == nil {
+		return nil, fmt.Errorf("nil image provided")
+	}
+	rect := info.Position
+	if info.Size <= 0 || rect.Dx() != info.Size || rect.Dy() != info.Size {
+		return nil, fmt.Errorf("watermark rectangle %v must be a %dx%d square", rect, info.Size, info.Size)
+	}
+	if bounds := img.Bounds(); !rect.In(bounds) {
+		return nil, &GeometryError{Rect: rect, Bounds: bounds, Nearest: nearestPlacement(rect, bounds)}
+	}
+	return e.removeDepth(context.Background(), img, info, p)
+}
+
+// removeDepth is removeAt returning the representation RemoveWatermarkDepth
+// documents.
+func (e *Engine) removeDepth(ctx context.Context, img image.Image, info Info, p Profile) (image.Image, error) {
+	deep := is16Bit(img) && e.noise == nil && !e.halo.applies(img) && e.inpaint == nil && !e.jsCompat
+	if !deep && isOpaque(img) {
+		return e.removeAt(ctx, img, info, p)
+	}
+
+	bounds := img.Bounds()
+	alphaMap, logo, err := e.blendParams(p, bounds.Dx(), bounds.Dy(), info)
+	if err != nil {
+		return nil, err
+	}
+	bands, release, err := e.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if !deep {
+		return e.removeNRGBA(ctx, img, alphaMap, info.Position, logo, bands)
+	}
+	if isOpaque(img) {
+		rgba := cloneToRGBA64Parallel(img, bands)
+		applyReverseAlpha64(rgba, alphaMap, info.Position, logo, e.rounding)
+		return rgba, nil
+	}
+
+	// NRGBA64 shares the RGBA64 pixel layout, so the blend runs on a view
+	// of the straight-color buffer.
+	nrgba := image.NewNRGBA64(bounds)
+	inBands(bounds, bands, func(band image.Rectangle) {
+		draw.Draw(nrgba, band, img, band.Min, draw.Src)
+	})
+	applyReverseAlpha64(&image.RGBA64{Pix: nrgba.Pix, Stride: nrgba.Stride, Rect: nrgba.Rect}, alphaMap, info.Position, logo, e.rounding)
+	return nrgba, nil
+}
+
+// removeNRGBA reverse blends the watermark at rect into a straight-color
+// copy of img and returns it, with clipped pixels inpainted, the halo
+// suppressed and noise matched when the engine asks for it and is not in
+// JS-compatible mode. Unlike reverseAlphaClone it does not convert the
+// result back to premultiplied RGBA.
+func (e *Engine) removeNRGBA(ctx context.Context, img image.Image, alphaMap []float32, rect image.Rectangle, logo [3]float64, bands int) (*image.NRGBA, error) {
+	nrgba := cloneToNRGBAParallel(img, bands)
+	if e.jsCompat && logo == whiteLogo {
+		applyReverseAlphaJS(nrgba, alphaMap, rect)
+		return nrgba, nil
+	}
+	view := &image.RGBA{Pix: nrgba.Pix, Stride: nrgba.Stride, Rect: nrgba.Rect}
+	applyReverseAlpha(view, alphaMap, rect, logo, e.rounding, bands)
+	if err := e.postProcess(ctx, view, img, alphaMap, rect, logo); err != nil {
+		return nil, err
+	}
+	return nrgba, nil
+}
+
+// isOpaque reports whether every pixel of img is known to be opaque.
+func isOpaque(img image.Image) bool {
+	o, ok := img.(interface{ Opaque() bool })
+	return ok && o.Opaque()
+}
+
+// cloneToRGBA64Parallel copies src into a new *image.RGBA64 in n bands
+// concurrently.
+func cloneToRGBA64Parallel(src image.Image, n int) *image.RGBA64 {
+	dst := image.NewRGBA64(src.Bounds())
+	inBands(src.Bounds(), n, func(band image.Rectangle) {
+		draw.Draw(dst, band, src, band.Min, draw.Src)
+	})
+	return dst
+}
+
+// applyReverseAlpha64 is applyReverseAlpha on 16-bit channels. The logo
+// color stays on the 0-255 scale and is widened here.
+func applyReverseAlpha64(img *image.RGBA64, alphaMap []float32, rect image.Rectangle, logo [3]float64, mode RoundingMode) {
+	stride := rect.Dx()
+
+	for row := 0; row < rect.Dy(); row++ {
+		for col := 0; col < rect.Dx(); col++ {
+			alpha := float64(alphaMap[row*stride+col])
+			if alpha < alphaThreshold {
+				continue
+			}
+			alpha = math.Min(alpha, maxAlpha)
+
+			offset := img.PixOffset(rect.Min.X+col, rect.Min.Y+row)
+			for c := 0; c < 3; c++ {
+				p := img.Pix[offset+2*c:]
+				v := reverseBlend16(uint16(p[0])<<8|uint16(p[1]), alpha, logo[c]*0x101, mode)
+				p[0], p[1] = uint8(v>>8), uint8(v)
+			}
+		}
+	}
+}
+
+// reverseBlend16 is reverseBlend for a 16-bit channel and logo value.
+func reverseBlend16(watermarked uint16, alpha, logo float64, mode RoundingMode) uint16 {
+	original := (float64(watermarked) - alpha*logo) / (1.0 - alpha)
+
+	original = math.Max(0, math.Min(0xffff, original))
+	return uint16(mode.round(original))
+}
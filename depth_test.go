@@ -0,0 +1,202 @@
+package watermark
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// watermarkedNRGBA64 returns a 16-bit image of bg with the Gemini logo
+// blended in at 16-bit precision.
+func watermarkedNRGBA64(t *testing.T, width, height int, bg color.NRGBA64) *image.NRGBA64 {
+	t.Helper()
+
+	img := image.NewNRGBA64(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetNRGBA64(x, y, bg)
+		}
+	}
+	cfg := DetectWatermarkConfig(width, height)
+	rect, err := calculateWatermarkRect(img.Bounds(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alphaMap, err := detectAlphaMap(cfg.LogoSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for row := 0; row < rect.Dy(); row++ {
+		for col := 0; col < rect.Dx(); col++ {
+			alpha := float64(alphaMap[row*rect.Dx()+col])
+			blend := func(v uint16) uint16 { return uint16(alpha*0xffff + (1-alpha)*float64(v) + 0.5) }
+			img.SetNRGBA64(rect.Min.X+col, rect.Min.Y+row, color.NRGBA64{R: blend(bg.R), G: blend(bg.G), B: blend(bg.B), A: bg.A})
+		}
+	}
+	return img
+}
+
+// maxDiff64 returns the largest straight-color channel difference, in 16-bit
+// units, between img and c.
+func maxDiff64(img image.Image, c color.NRGBA64) int {
+	worst := 0
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			got := color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+			for i, v := range [3]uint16{got.R, got.G, got.B} {
+				worst = max(worst, abs(int(v)-int([3]uint16{c.R, c.G, c.B}[i])))
+			}
+		}
+	}
+	return worst
+}
+
+func TestRemoveWatermarkDepth(t *testing.T) {
+	bg := color.NRGBA64{R: 10000, G: 20000, B: 30000, A: 0xffff}
+	src := watermarkedNRGBA64(t, 320, 240, bg)
+	opaque := image.NewRGBA64(src.Bounds())
+	copy(opaque.Pix, src.Pix)
+
+	for name, img := range map[string]image.Image{"NRGBA64": src, "RGBA64": opaque} {
+		cleaned, err := NewEngine().RemoveWatermarkDepth(img, GeminiProfile())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := cleaned.(*image.RGBA64); !ok {
+			t.Fatalf("%s: cleaned is %T, want *image.RGBA64", name, cleaned)
+		}
+		// Half a 16-bit step of blending error grows by up to 1/(1-alpha);
+		// the 8-bit path is off by whole 8-bit steps.
+		if d := maxDiff64(cleaned, bg); d >= 0x101 {
+			t.Errorf("%s: 16-bit removal is off by %d", name, d)
+		}
+	}
+
+	eight, err := NewEngine().RemoveWatermarkProfile(src, GeminiProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := maxDiff64(eight, bg); d < 0x101 {
+		t.Errorf("8-bit removal is off by only %d; the test image does not tell the paths apart", d)
+	}
+
+	// Noise matching works on 8-bit values.
+	cleaned, err := NewEngine(WithNoiseMatch(&NoiseMatch{})).RemoveWatermarkDepth(src, GeminiProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cleaned.(*image.RGBA); !ok {
+		t.Errorf("with noise matching, cleaned is %T, want *image.RGBA", cleaned)
+	}
+}
+
+func TestRemoveWatermarkDepthTranslucent(t *testing.T) {
+	bg := color.NRGBA64{R: 10000, G: 20000, B: 30000, A: 0xc000}
+	cleaned, err := NewEngine().RemoveWatermarkDepth(watermarkedNRGBA64(t, 320, 240, bg), GeminiProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cleaned.(*image.NRGBA64); !ok {
+		t.Fatalf("cleaned is %T, want *image.NRGBA64", cleaned)
+	}
+	if d := maxDiff64(cleaned, bg); d >= 0x101 {
+		t.Errorf("translucent 16-bit removal is off by %d", d)
+	}
+}
+
+func TestProcessBytes16Bit(t *testing.T) {
+	var buf bytes.Buffer
+	bg := color.NRGBA64{R: 10000, G: 20000, B: 30000, A: 0xffff}
+	if err := png.Encode(&buf, watermarkedNRGBA64(t, 320, 240, bg)); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ProcessBytes(buf.Bytes(), Options{})
+	if err != nil {
+		t.Fatalf("ProcessBytes: %v", err)
+	}
+	if !result.Present {
+		t.Fatalf("expected the watermark to be found")
+	}
+	img, err := png.Decode(bytes.NewReader(result.Output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !is16Bit(img) {
+		t.Fatalf("output decodes as %T, want a 16-bit image", img)
+	}
+	if d := maxDiff64(img, bg); d >= 0x101 {
+		t.Errorf("16-bit output is off by %d", d)
+	}
+
+	resized, err := ProcessBytes(buf.Bytes(), Options{Resize: Resize{MaxDim: 160}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img, err := png.Decode(bytes.NewReader(resized.Output)); err != nil || !is16Bit(img) {
+		t.Fatalf("resized output decodes as %T (%v), want a 16-bit image", img, err)
+	}
+}
+
+func TestProcessBytesKeepsStraightAlpha(t *testing.T) {
+	const width, height = 320, 240
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := color.NRGBA{R: 40, G: 60, B: 90, A: 200}
+			if x < 64 {
+				// Premultiplying at this alpha keeps barely a bit of color.
+				c = color.NRGBA{R: 123, G: 45, B: 201, A: 3}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	info := WatermarkInfo(width, height)
+	alphaMap, err := detectAlphaMap(info.Size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rect := info.Position
+	for row := 0; row < rect.Dy(); row++ {
+		for col := 0; col < rect.Dx(); col++ {
+			alpha := float64(alphaMap[row*rect.Dx()+col])
+			offset := img.PixOffset(rect.Min.X+col, rect.Min.Y+row)
+			for c := 0; c < 3; c++ {
+				img.Pix[offset+c] = uint8(alpha*logoValue + (1-alpha)*float64(img.Pix[offset+c]) + 0.5)
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ProcessBytes(buf.Bytes(), Options{})
+	if err != nil || !result.Present {
+		t.Fatalf("ProcessBytes: present=%v err=%v", result.Present, err)
+	}
+	out, err := png.Decode(bytes.NewReader(result.Output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleaned, ok := out.(*image.NRGBA)
+	if !ok {
+		t.Fatalf("output decodes as %T, want *image.NRGBA", out)
+	}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			got, want := cleaned.NRGBAAt(x, y), img.NRGBAAt(x, y)
+			if image.Pt(x, y).In(rect) {
+				want = color.NRGBA{R: 40, G: 60, B: 90, A: 200}
+				if absDiff(got.R, want.R) > 3 || absDiff(got.G, want.G) > 3 || absDiff(got.B, want.B) > 3 || got.A != want.A {
+					t.Fatalf("watermark pixel (%d,%d) = %v, want about %v", x, y, got, want)
+				}
+			} else if got != want {
+				t.Fatalf("pixel (%d,%d) = %v, want %v unchanged", x, y, got, want)
+			}
+		}
+	}
+}
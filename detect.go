@@ -1,12 +1,15 @@
 package watermark
 
 import (
+	"context"
 	"fmt"
 	"image"
 	"math"
 	"sync"
 )
 
+// Default detection gates, used when neither the Profile nor the Engine (see
+// WithDetectionThresholds) sets thresholds of its own.
 const (
 	// Brightness difference threshold to consider a watermark present.
 	// The watermark is white on darker pixels, so the mean luma in the
@@ -18,9 +21,12 @@ const (
 )
 
 var detectAlphaCache = struct {
+	mu   sync.RWMutex
 	once map[int]*sync.Once
 	maps map[int][]float32
 	errs map[int]error
+
+	scaled scaledAlphaCache
 }{
 	once: map[int]*sync.Once{
 		48: new(sync.Once),
@@ -35,63 +41,208 @@ var detectAlphaCache = struct {
 // surrounding band and gates on correlation with the watermark alpha mask so
 // bright corners without the watermark are not misclassified.
 func DetectWatermark(img image.Image) (present bool, score float64, info Info, err error) {
-	if img == nil {
-		return false, 0, Info{}, fmt.Errorf("nil image provided")
-	}
+	return DetectWatermarkProfile(img, GeminiProfile())
+}
 
-	bounds := img.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
-	if width <= 0 || height <= 0 {
-		return false, 0, Info{}, fmt.Errorf("invalid image dimensions %dx%d", width, height)
+// DetectWatermarkContext is DetectWatermark returning ctx.Err() instead of a
+// result once ctx is done. Detection reads only the corner regions, so it is
+// checked before and after rather than interrupted.
+func DetectWatermarkContext(ctx context.Context, img image.Image) (present bool, score float64, info Info, err error) {
+	if err := ctx.Err(); err != nil {
+		return false, 0, Info{}, err
+	}
+	present, score, info, err = DetectWatermark(img)
+	if err == nil {
+		err = ctx.Err()
 	}
-
-	cfg := DetectWatermarkConfig(width, height)
-	rect, err := calculateWatermarkRect(bounds, cfg)
 	if err != nil {
 		return false, 0, Info{}, err
 	}
+	return present, score, info, nil
+}
+
+// DetectWatermarkProfile runs DetectWatermark for the watermark placement
+// described by profile p. Images for which p has no variant report no
+// watermark.
+func DetectWatermarkProfile(img image.Image, p Profile) (present bool, score float64, info Info, err error) {
+	return Default().DetectWatermarkProfile(img, p)
+}
+
+// DetectWatermark is the package-level DetectWatermark with the detection
+// thresholds of e.
+func (e *Engine) DetectWatermark(img image.Image) (present bool, score float64, info Info, err error) {
+	return e.DetectWatermarkProfile(img, GeminiProfile())
+}
 
-	alphaMap, err := detectAlphaMap(cfg.LogoSize)
+// DetectWatermarkProfile is the package-level DetectWatermarkProfile with the
+// detection thresholds of e filling those p leaves unset.
+func (e *Engine) DetectWatermarkProfile(img image.Image, p Profile) (present bool, score float64, info Info, err error) {
+	r, err := e.DetectResult(img, p)
 	if err != nil {
 		return false, 0, Info{}, err
 	}
+	return r.Present, r.Score, r.Info, nil
+}
 
-	// Use a surrounding band to approximate the background without the watermark.
-	band := cfg.LogoSize / 3
-	if band < 8 {
-		band = 8
+// detectParams carries the profile settings that tune detection.
+type detectParams struct {
+	luminance LuminanceMode
+	// minScore and minCorrelation override the package thresholds when
+	// positive. They come from the profile, else from the engine.
+	minScore       float64
+	minCorrelation float64
+	// engine supplies the alpha maps registered with RegisterAlphaMap; nil
+	// means the Default engine.
+	engine *Engine
+}
+
+// accepts applies the detection gates to a score and correlation.
+func (p detectParams) accepts(score, corr float64) bool {
+	minScore, minCorr := p.gates()
+	return score > minScore && corr > minCorr
+}
+
+// gates returns the luma score and correlation thresholds in effect.
+func (p detectParams) gates() (minScore, minCorr float64) {
+	minScore, minCorr = detectionLumaThreshold, detectionCorrelationThreshold
+	if p.minScore > 0 {
+		minScore = p.minScore
 	}
+	if p.minCorrelation > 0 {
+		minCorr = p.minCorrelation
+	}
+	return minScore, minCorr
+}
 
-	outer := rect.Inset(-band).Intersect(bounds)
+// detectPlacement scores the placement described by cfg, falling back to the
+// upscaled placements when the native one does not match. With CornerAuto
+// every corner is scored and the best-correlated placement wins, preferring
+// corners where a watermark was detected.
+func detectPlacement(img image.Image, cfg watermarkConfig, params detectParams) (present bool, score float64, info Info, err error) {
+	d, err := detectBest(img, cfg, params)
+	return d.present, d.score, d.info, err
+}
 
-	_, bgCount := meanLuma(img, rect, image.Rectangle{})
-	bgMean, outerCount := meanLuma(img, outer, rect)
+// detectBest is detectPlacement returning the full detection.
+func detectBest(img image.Image, cfg watermarkConfig, params detectParams) (detection, error) {
+	if cfg.Corner != CornerAuto {
+		return detectCorner(img, cfg, params)
+	}
 
-	if bgCount == 0 || outerCount == 0 {
-		return false, 0, Info{}, fmt.Errorf("insufficient pixels to evaluate watermark")
+	var (
+		best     detection
+		found    bool
+		firstErr error
+	)
+	for _, c := range fixedCorners {
+		cornerCfg := cfg
+		cornerCfg.Corner = c
+		d, err := detectCorner(img, cornerCfg, params)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if !found || (d.present && !best.present) || (d.present == best.present && d.corr > best.corr) {
+			best, found = d, true
+		}
+	}
+	if !found {
+		return detection{}, firstErr
 	}
+	return best, nil
+}
 
-	score, corr, err := scoreWatermark(img, rect, alphaMap, bgMean)
+// detectCorner scores the placement of cfg at its fixed corner, probing the
+// upscaled placements when the native one does not match.
+func detectCorner(img image.Image, cfg watermarkConfig, params detectParams) (detection, error) {
+	d, err := detectWithConfig(img, cfg, params)
 	if err != nil {
-		return false, 0, Info{}, err
+		return detection{}, err
+	}
+	if d.present {
+		return d, nil
+	}
+
+	// Images upscaled after generation carry a proportionally larger
+	// watermark; probe the scaled placements before giving up.
+	for _, factor := range upscaleFactors {
+		scaled, scaledErr := detectWithConfig(img, cfg.scaled(factor), params)
+		if scaledErr == nil && scaled.present {
+			return scaled, nil
+		}
 	}
 
-	present = score > detectionLumaThreshold && corr > detectionCorrelationThreshold
+	return d, nil
+}
 
-	info = Info{Size: cfg.LogoSize, Position: rect}
-	return present, score, info, nil
+// detection is the outcome of scoring one candidate placement.
+type detection struct {
+	present bool
+	score   float64
+	corr    float64
+	info    Info
+}
+
+// detectWithConfig scores the watermark placement described by cfg.
+func detectWithConfig(img image.Image, cfg watermarkConfig, params detectParams) (detection, error) {
+	bounds := img.Bounds()
+	rect, err := calculateWatermarkRect(bounds, cfg)
+	if err != nil {
+		return detection{}, err
+	}
+
+	alphaMap, err := cfg.detectAlpha(params.engine)
+	if err != nil {
+		return detection{}, err
+	}
+
+	score, corr, err := scoreRect(img, rect, alphaMap, params.luminance)
+	if err != nil {
+		return detection{}, err
+	}
+
+	return detection{
+		present: params.accepts(score, corr),
+		score:   score,
+		corr:    corr,
+		info:    Info{Size: cfg.LogoSize, Position: rect, Corner: cfg.Corner},
+	}, nil
+}
+
+// detectAlpha returns the alpha map detection compares against: the custom
+// logo capture when the profile supplies one, a map registered with engine e
+// (the Default engine if nil), or the embedded capture.
+func (c watermarkConfig) detectAlpha(e *Engine) ([]float32, error) {
+	if c.mask != nil {
+		return maskAlpha(c.mask, c.LogoSize), nil
+	}
+	if e == nil {
+		e = Default()
+	}
+	if alpha, ok := e.registeredAlpha(c.LogoSize); ok {
+		return alpha, nil
+	}
+	return detectAlphaMap(c.LogoSize)
 }
 
 func detectAlphaMap(size int) ([]float32, error) {
 	once, ok := detectAlphaCache.once[size]
 	if !ok {
-		return nil, fmt.Errorf("unsupported watermark size %d", size)
+		return detectAlphaCache.scaled.get(size, detectAlphaMap)
 	}
 
 	once.Do(func() {
-		detectAlphaCache.maps[size], detectAlphaCache.errs[size] = decodeAlphaAsset(size)
+		alpha, err := decodeAlphaAsset(size)
+		detectAlphaCache.mu.Lock()
+		detectAlphaCache.maps[size], detectAlphaCache.errs[size] = alpha, err
+		detectAlphaCache.mu.Unlock()
 	})
 
+	detectAlphaCache.mu.RLock()
+	defer detectAlphaCache.mu.RUnlock()
+
 	if err := detectAlphaCache.errs[size]; err != nil {
 		return nil, err
 	}
@@ -104,9 +255,35 @@ func detectAlphaMap(size int) ([]float32, error) {
 	return alpha, nil
 }
 
+// surround returns rect grown by the band whose luma stands in for the
+// background, clipped to bounds.
+func surround(rect, bounds image.Rectangle) image.Rectangle {
+	band := rect.Dx() / 3
+	if band < 8 {
+		band = 8
+	}
+	return rect.Inset(-band).Intersect(bounds)
+}
+
+// scoreRect scores the watermark candidate at rect against the mean luma of
+// a surrounding band, which approximates the background without the
+// watermark.
+func scoreRect(img image.Image, rect image.Rectangle, alphaMap []float32, mode LuminanceMode) (score, corr float64, err error) {
+	outer := surround(rect, img.Bounds())
+
+	_, bgCount := meanLuma(img, rect, image.Rectangle{}, mode)
+	bgMean, outerCount := meanLuma(img, outer, rect, mode)
+
+	if bgCount == 0 || outerCount == 0 {
+		return 0, 0, fmt.Errorf("insufficient pixels to evaluate watermark")
+	}
+
+	return scoreWatermark(img, rect, alphaMap, bgMean, mode)
+}
+
 // scoreWatermark compares the expected watermark alpha mask with the image
 // brightness to produce a luma delta and a shape correlation score.
-func scoreWatermark(img image.Image, rect image.Rectangle, alphaMap []float32, bgMean float64) (delta float64, corr float64, err error) {
+func scoreWatermark(img image.Image, rect image.Rectangle, alphaMap []float32, bgMean float64, mode LuminanceMode) (delta float64, corr float64, err error) {
 	stride := rect.Dx()
 	if stride <= 0 || rect.Dy() <= 0 {
 		return 0, 0, fmt.Errorf("invalid watermark rectangle %v", rect)
@@ -131,11 +308,10 @@ func scoreWatermark(img image.Image, rect image.Rectangle, alphaMap []float32, b
 
 	idx := 0
 	for y := rect.Min.Y; y < rect.Max.Y; y++ {
-		for x := rect.Min.X; x < rect.Max.X; x++ {
-			r, g, b, _ := img.At(x, y).RGBA()
-			luma := 0.2126*float64(r)/257.0 + 0.7152*float64(g)/257.0 + 0.0722*float64(b)/257.0
-
-			residual := luma - bgMean
+		row := residuals[idx : idx+stride]
+		mode.lumaRow(img, y, rect.Min.X, row)
+		for x := range row {
+			residual := row[x] - bgMean
 			residuals[idx] = residual
 
 			alpha := float64(alphaMap[idx])
@@ -193,19 +369,18 @@ func scoreWatermark(img image.Image, rect image.Rectangle, alphaMap []float32, b
 
 // meanLuma computes the average luma for pixels in region. If exclude is not
 // empty, pixels inside exclude are skipped.
-func meanLuma(img image.Image, region image.Rectangle, exclude image.Rectangle) (float64, int) {
+func meanLuma(img image.Image, region image.Rectangle, exclude image.Rectangle, mode LuminanceMode) (float64, int) {
 	var sum float64
 	var count int
 
+	row := make([]float64, max(region.Dx(), 0))
 	for y := region.Min.Y; y < region.Max.Y; y++ {
-		for x := region.Min.X; x < region.Max.X; x++ {
-			if exclude != (image.Rectangle{}) && (image.Point{X: x, Y: y}).In(exclude) {
+		mode.lumaRow(img, y, region.Min.X, row)
+		for i, luma := range row {
+			if exclude != (image.Rectangle{}) && (image.Point{X: region.Min.X + i, Y: y}).In(exclude) {
 				continue
 			}
 
-			r, g, b, _ := img.At(x, y).RGBA()
-			// Convert to luma in [0, 255].
-			luma := 0.2126*float64(r)/257.0 + 0.7152*float64(g)/257.0 + 0.0722*float64(b)/257.0
 			sum += luma
 			count++
 		}
@@ -1,3 +1,5 @@
+//go:build !watermark_nov1
+
 package watermark
 
 import (
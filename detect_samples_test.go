@@ -48,13 +48,13 @@ func TestDetectWatermarkSampleImages(t *testing.T) {
 			}
 			outer := rect.Inset(-band).Intersect(bounds)
 
-			_, innerCount := meanLuma(img, rect, image.Rectangle{})
-			bgMean, outerCount := meanLuma(img, outer, rect)
+			_, innerCount := meanLuma(img, rect, image.Rectangle{}, LumaGamma)
+			bgMean, outerCount := meanLuma(img, outer, rect, LumaGamma)
 			if innerCount == 0 || outerCount == 0 {
 				t.Fatalf("insufficient pixels")
 			}
 
-			score, corr, err := scoreWatermark(img, rect, alpha, bgMean)
+			score, corr, err := scoreWatermark(img, rect, alpha, bgMean, LumaGamma)
 			if err != nil {
 				t.Fatalf("score: %v", err)
 			}
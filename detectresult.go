@@ -0,0 +1,175 @@
+package watermark
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"time"
+)
+
+// Confidence grades how clearly a detection verdict, present or not, was
+// reached.
+type Confidence int
+
+const (
+	// ConfidenceLow means a measurement lies close to its gate, so a
+	// re-encoded or lightly edited copy may get the opposite verdict.
+	ConfidenceLow Confidence = iota
+	// ConfidenceMedium means the verdict holds with some room to spare.
+	ConfidenceMedium
+	// ConfidenceHigh means the measurements are far from the gates.
+	ConfidenceHigh
+)
+
+// Margins, relative to the detection gates, that separate the confidence
+// tiers of DetectionResult.
+const (
+	highPresentMargin   = 1.5
+	mediumPresentMargin = 1.2
+	highAbsentMargin    = 0.5
+	mediumAbsentMargin  = 0.8
+)
+
+// String returns "low", "medium" or "high".
+func (c Confidence) String() string {
+	switch c {
+	case ConfidenceLow:
+		return "low"
+	case ConfidenceMedium:
+		return "medium"
+	case ConfidenceHigh:
+		return "high"
+	default:
+		return fmt.Sprintf("Confidence(%d)", int(c))
+	}
+}
+
+// MarshalText encodes the confidence as its name.
+func (c Confidence) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText decodes a confidence name written by MarshalText.
+func (c *Confidence) UnmarshalText(text []byte) error {
+	for _, v := range []Confidence{ConfidenceLow, ConfidenceMedium, ConfidenceHigh} {
+		if v.String() == string(text) {
+			*c = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown confidence %q", text)
+}
+
+// DetectionResult is the full outcome of DetectResult.
+type DetectionResult struct {
+	Present bool
+	// Score is the luma delta between the logo-weighted and the clear
+	// pixels of the rectangle, the score DetectWatermark reports.
+	Score float64
+	// Correlation is the correlation, -1 to 1, between the luma of the
+	// rectangle and the logo's alpha mask.
+	Correlation float64
+	// Margin is the smaller of Score and Correlation divided by its
+	// detection gate; the watermark is present when it exceeds 1.
+	Margin float64
+	// Confidence grades Margin: how far the verdict is from flipping.
+	Confidence Confidence
+	// Info holds the size, rectangle and corner of the placement examined.
+	Info Info
+	// Duration is the time detection took.
+	Duration time.Duration
+}
+
+// DetectResult runs DetectWatermarkProfile with the default engine and
+// reports every measurement behind the verdict. See Engine.DetectResult.
+func DetectResult(img image.Image, p Profile) (DetectionResult, error) {
+	return Default().DetectResult(img, p)
+}
+
+// DetectResultBytes decodes raw image bytes and runs DetectResult with the
+// default engine. It replaces DetectWatermarkBytes.
+func DetectResultBytes(data []byte, p Profile) (DetectionResult, error) {
+	return DetectResultBytesContext(context.Background(), data, p)
+}
+
+// DetectResultBytesContext is DetectResultBytes with cancellation: waiting
+// for the memory budget and decoding stop once ctx is done, and ctx.Err() is
+// returned.
+func DetectResultBytesContext(ctx context.Context, data []byte, p Profile) (_ DetectionResult, err error) {
+	defer recoverPanic("DetectResultBytes", data, &err)
+	if len(data) == 0 {
+		return DetectionResult{}, fmt.Errorf("empty image data")
+	}
+
+	release, err := reserveDecode(ctx, data)
+	if err != nil {
+		return DetectionResult{}, err
+	}
+	defer release()
+
+	img, _, err := decodeContext(ctx, data)
+	if err != nil {
+		return DetectionResult{}, err
+	}
+	Default().report(StageDetect, 0)
+	r, err := DetectResult(img, p)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		return DetectionResult{}, err
+	}
+	Default().report(StageDetect, 100)
+	return r, nil
+}
+
+// DetectResult runs DetectWatermarkProfile and reports the correlation,
+// margin and confidence tier alongside the score and placement. Images for
+// which p has no variant report no watermark with high confidence.
+func (e *Engine) DetectResult(img image.Image, p Profile) (DetectionResult, error) {
+	start := time.Now()
+	if img == nil {
+		return DetectionResult{}, fmt.Errorf("nil image provided")
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 {
+		return DetectionResult{}, fmt.Errorf("invalid image dimensions %dx%d", width, height)
+	}
+
+	cfg, ok := p.config(width, height)
+	if !ok {
+		return DetectionResult{Confidence: ConfidenceHigh, Duration: time.Since(start)}, nil
+	}
+
+	params := e.detectParams(p)
+	d, err := detectBest(img, cfg, params)
+	if err != nil {
+		return DetectionResult{}, err
+	}
+	minScore, minCorr := params.gates()
+	r := DetectionResult{
+		Present:     d.present,
+		Score:       d.score,
+		Correlation: d.corr,
+		Margin:      math.Min(d.score/minScore, d.corr/minCorr),
+		Info:        d.info,
+	}
+	r.Confidence = confidence(r.Present, r.Margin)
+	r.Duration = time.Since(start)
+	return r, nil
+}
+
+// confidence grades a detection margin for the verdict present.
+func confidence(present bool, margin float64) Confidence {
+	switch {
+	case present && margin >= highPresentMargin, !present && margin <= highAbsentMargin:
+		return ConfidenceHigh
+	case present && margin >= mediumPresentMargin, !present && margin <= mediumAbsentMargin:
+		return ConfidenceMedium
+	default:
+		return ConfidenceLow
+	}
+}
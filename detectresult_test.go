@@ -0,0 +1,72 @@
+package watermark
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDetectResult(t *testing.T) {
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{40, 60, 80, 255})
+
+	r, err := DetectResult(img, GeminiProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.Present || r.Confidence != ConfidenceHigh {
+		t.Fatalf("watermarked: present=%v confidence=%v margin=%.2f", r.Present, r.Confidence, r.Margin)
+	}
+	if r.Correlation <= 0.3 || r.Margin <= 1 || r.Duration <= 0 {
+		t.Fatalf("watermarked: correlation=%.2f margin=%.2f duration=%v", r.Correlation, r.Margin, r.Duration)
+	}
+
+	present, score, info, err := DetectWatermark(img)
+	if err != nil || present != r.Present || score != r.Score || info != r.Info {
+		t.Fatalf("DetectWatermark disagrees: %v %.2f %+v %v, result %+v", present, score, info, err, r)
+	}
+
+	plain := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	r, err = DetectResult(plain, GeminiProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Present || r.Margin >= 1 {
+		t.Fatalf("plain: present=%v margin=%.2f", r.Present, r.Margin)
+	}
+
+	if _, err := DetectResult(nil, GeminiProfile()); err == nil {
+		t.Fatal("nil image: expected an error")
+	}
+}
+
+func TestConfidence(t *testing.T) {
+	tests := []struct {
+		present bool
+		margin  float64
+		want    Confidence
+	}{
+		{true, 2, ConfidenceHigh},
+		{true, 1.5, ConfidenceHigh},
+		{true, 1.3, ConfidenceMedium},
+		{true, 1.05, ConfidenceLow},
+		{false, -1, ConfidenceHigh},
+		{false, 0.5, ConfidenceHigh},
+		{false, 0.7, ConfidenceMedium},
+		{false, 0.95, ConfidenceLow},
+	}
+	for _, tt := range tests {
+		if got := confidence(tt.present, tt.margin); got != tt.want {
+			t.Errorf("confidence(%v, %g) = %v, want %v", tt.present, tt.margin, got, tt.want)
+		}
+	}
+	if text, _ := ConfidenceMedium.MarshalText(); string(text) != "medium" {
+		t.Errorf("MarshalText = %q", text)
+	}
+	var c Confidence
+	if err := c.UnmarshalText([]byte("high")); err != nil || c != ConfidenceHigh {
+		t.Errorf("UnmarshalText(high) = %v, %v", c, err)
+	}
+	if err := c.UnmarshalText([]byte("sure")); err == nil {
+		t.Error("UnmarshalText(sure): expected an error")
+	}
+}
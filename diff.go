@@ -0,0 +1,68 @@
+package watermark
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// ErrOutsideRegion is returned by VerifyRegionOnly when pixels outside the
+// allowed region changed.
+var ErrOutsideRegion = errors.New("pixels changed outside the watermark region")
+
+// DiffImage renders the per-channel absolute difference between before and
+// after, multiplied by gain and clamped to 255, on an opaque black
+// background. It also returns the bounding box of all changed pixels, which
+// is empty when the images are identical. Both images must share bounds.
+func DiffImage(before, after image.Image, gain float64) (*image.RGBA, image.Rectangle, error) {
+	bounds := before.Bounds()
+	if after.Bounds() != bounds {
+		return nil, image.Rectangle{}, fmt.Errorf("image bounds differ: %v vs %v", bounds, after.Bounds())
+	}
+	if gain <= 0 {
+		gain = 1
+	}
+
+	a, b := cloneToRGBA(before), cloneToRGBA(after)
+	diff := image.NewRGBA(bounds)
+	var changed image.Rectangle
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			offset := a.PixOffset(x, y)
+			moved := false
+			for c := 0; c < 4; c++ {
+				d := int(a.Pix[offset+c]) - int(b.Pix[offset+c])
+				if d < 0 {
+					d = -d
+				}
+				if d == 0 {
+					continue
+				}
+				moved = true
+				if c < 3 {
+					diff.Pix[offset+c] = uint8(min(255, float64(d)*gain))
+				}
+			}
+			diff.Pix[offset+3] = 255
+			if moved {
+				changed = changed.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+
+	return diff, changed, nil
+}
+
+// VerifyRegionOnly checks that before and after differ only inside region,
+// guarding against removal touching any other part of the image.
+func VerifyRegionOnly(before, after image.Image, region image.Rectangle) error {
+	_, changed, err := DiffImage(before, after, 1)
+	if err != nil {
+		return err
+	}
+	if !changed.Empty() && !changed.In(region) {
+		return fmt.Errorf("%w: changes span %v, allowed %v", ErrOutsideRegion, changed, region)
+	}
+	return nil
+}
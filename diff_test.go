@@ -0,0 +1,41 @@
+package watermark
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestDiffImageHighlightsWatermarkOnly(t *testing.T) {
+	before := watermarkedRGBA(t, 320, 240, color.RGBA{R: 40, G: 60, B: 90, A: 255})
+	after, err := RemoveWatermark(before)
+	if err != nil {
+		t.Fatalf("RemoveWatermark: %v", err)
+	}
+
+	diff, changed, err := DiffImage(before, after, 10)
+	if err != nil {
+		t.Fatalf("DiffImage: %v", err)
+	}
+
+	info := WatermarkInfo(320, 240)
+	if changed.Empty() || !changed.In(info.Position) {
+		t.Fatalf("changed area %v outside watermark %v", changed, info.Position)
+	}
+	if c := diff.RGBAAt(0, 0); c != (color.RGBA{A: 255}) {
+		t.Fatalf("unchanged pixel rendered as %v", c)
+	}
+
+	if err := VerifyRegionOnly(before, after, info.Position); err != nil {
+		t.Fatalf("VerifyRegionOnly: %v", err)
+	}
+	after.Set(0, 0, color.White)
+	if err := VerifyRegionOnly(before, after, info.Position); !errors.Is(err, ErrOutsideRegion) {
+		t.Fatalf("expected ErrOutsideRegion, got %v", err)
+	}
+
+	if _, _, err := DiffImage(before, image.NewRGBA(image.Rect(0, 0, 1, 1)), 1); err == nil {
+		t.Fatalf("expected error for mismatched bounds")
+	}
+}
@@ -4,4 +4,7 @@
 // project and ships with embedded watermark alpha maps for the 48x48 and 96x96
 // logos used by Gemini. The package works entirely in memory; no network or GPU
 // is required.
+//
+// WebP decodes in pure Go. Encoding WebP needs an encoder registered with
+// RegisterWebPEncoder, which importing the webpenc subpackage does.
 package watermark
@@ -1,11 +1,12 @@
 package watermark
 
 import (
+	"context"
 	"fmt"
 	"image"
-	"image/draw"
 	"math"
 	"sync"
+	"sync/atomic"
 )
 
 const (
@@ -14,134 +15,414 @@ const (
 	logoValue      = 255.0
 )
 
+// whiteLogo is the per-channel color of the Gemini logo.
+var whiteLogo = [3]float64{logoValue, logoValue, logoValue}
+
 type watermarkConfig struct {
 	LogoSize     int
 	MarginRight  int
 	MarginBottom int
+	Corner       Corner
+
+	// mask is a custom logo capture; nil selects the embedded alpha maps.
+	mask image.Image
+}
+
+// maskAlpha derives the alpha map of a custom logo capture at the given size.
+func maskAlpha(mask image.Image, size int) []float32 {
+	alpha := calculateAlphaMap(mask)
+	if from := mask.Bounds().Dx(); from != size || mask.Bounds().Dy() != size {
+		return scaleAlphaMap(alpha, from, size)
+	}
+	return alpha
 }
 
 // Info captures the watermark size and placement for a given image.
 type Info struct {
 	Size     int
 	Position image.Rectangle
+	// Corner is the corner the watermark is anchored to. With CornerAuto
+	// profiles it reports the corner that matched.
+	Corner Corner
+	// InvisibleWatermark reports that an invisible watermark likely remains
+	// after removal. It is only set by ProcessBytes with
+	// Options.CheckInvisible.
+	InvisibleWatermark bool
 }
 
 // Engine holds cached alpha maps and performs reverse alpha blending.
 type Engine struct {
+	// mu guards alphaMaps, alphaErrs and custom, which sizes fill
+	// independently.
+	mu        sync.RWMutex
 	alphaMaps map[int][]float32
 	alphaErrs map[int]error
 	once      map[int]*sync.Once
+	scaled    scaledAlphaCache
+	// custom holds maps from RegisterAlphaMap, which take precedence over
+	// the embedded captures.
+	custom map[int][]float32
+
+	exclude  image.Image
+	jsCompat bool
+	rounding RoundingMode
+	noise    *NoiseMatch
+	halo     *HaloCleanup
+	inpaint  *ClipInpaint
+
+	// minScore and minCorrelation override the package detection gates
+	// when positive; logoValue overrides logoValue when positive.
+	minScore       float64
+	minCorrelation float64
+	logoValue      float64
+	// parallelism is the number of bands images are copied and blended in.
+	parallelism int
+	// slots bounds the pixel work running at once when non-nil; each
+	// removal holds one slot per band it copies in.
+	slots chan struct{}
+	// progress follows the byte-level calls; see SetProgressFunc.
+	progress func(stage string, pct float64)
 }
 
-// NewEngine constructs an Engine with lazily loaded alpha maps.
-func NewEngine() *Engine {
-	return &Engine{
+// NewEngine constructs an Engine with lazily loaded alpha maps, configured
+// by opts.
+func NewEngine(opts ...Option) *Engine {
+	e := &Engine{
 		alphaMaps: make(map[int][]float32),
 		alphaErrs: make(map[int]error),
 		once: map[int]*sync.Once{
 			48: new(sync.Once),
 			96: new(sync.Once),
 		},
+		parallelism: 1,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	return e
+}
+
+// SetExclusionMask protects regions of the image from modification. Any pixel
+// where mask is not fully transparent is left untouched, even inside the
+// watermark rectangle. The mask is sampled in image coordinates, so it should
+// share the bounds of the images passed to RemoveWatermark. A nil mask
+// clears the exclusion. SetExclusionMask must not be called concurrently with
+// removal.
+func (e *Engine) SetExclusionMask(mask image.Image) {
+	e.exclude = mask
+}
+
+var defaultEngine atomic.Pointer[Engine]
+
+// Default returns the engine used by the package-level helpers
+// (RemoveWatermark, RemoveWithMask, and the byte and base64 APIs). It is
+// created lazily on first use.
+func Default() *Engine {
+	if e := defaultEngine.Load(); e != nil {
+		return e
 	}
+	defaultEngine.CompareAndSwap(nil, NewEngine())
+	return defaultEngine.Load()
 }
 
-var defaultEngine struct {
-	once sync.Once
-	eng  *Engine
+// SetDefaultEngine replaces the engine returned by Default, letting
+// applications configure global behavior once at startup. Calls already in
+// progress keep the engine they started with. Passing nil restores a fresh
+// unconfigured engine on next use. The engine must not be reconfigured after
+// it is installed.
+func SetDefaultEngine(e *Engine) {
+	defaultEngine.Store(e)
 }
 
 // RemoveWatermark applies the default engine to the provided image.
 func RemoveWatermark(img image.Image) (*image.RGBA, error) {
-	defaultEngine.once.Do(func() {
-		defaultEngine.eng = NewEngine()
-	})
-
-	return defaultEngine.eng.RemoveWatermark(img)
+	return Default().RemoveWatermark(img)
 }
 
 // RemoveWatermark applies reverse alpha blending to remove the Gemini
 // watermark. The result is returned as a new *image.RGBA.
 func (e *Engine) RemoveWatermark(img image.Image) (*image.RGBA, error) {
+	return e.RemoveWatermarkProfile(img, GeminiProfile())
+}
+
+// RemoveWatermarkContext applies the default engine with cancellation. See
+// Engine.RemoveWatermarkContext.
+func RemoveWatermarkContext(ctx context.Context, img image.Image) (*image.RGBA, error) {
+	return Default().RemoveWatermarkContext(ctx, img)
+}
+
+// RemoveWatermarkContext is RemoveWatermark for servers and other callers
+// that need to bound the work on very large images. ctx is checked before
+// placement, copying and noise matching, and its error is returned once it
+// is done.
+func (e *Engine) RemoveWatermarkContext(ctx context.Context, img image.Image) (*image.RGBA, error) {
+	return e.removeProfile(ctx, img, GeminiProfile())
+}
+
+// RemoveWatermarkProfile removes the watermark placed according to profile p.
+// The result is returned as a new *image.RGBA.
+func (e *Engine) RemoveWatermarkProfile(img image.Image, p Profile) (*image.RGBA, error) {
+	return e.removeProfile(context.Background(), img, p)
+}
+
+func (e *Engine) removeProfile(ctx context.Context, img image.Image, p Profile) (*image.RGBA, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	info, err := e.placement(img, p)
+	if err != nil {
+		return nil, err
+	}
+	return e.removeAt(ctx, img, info, p)
+}
+
+// RemoveWatermarkAt applies the default engine at an explicit rectangle. See
+// Engine.RemoveWatermarkAt.
+func RemoveWatermarkAt(img image.Image, rect image.Rectangle) (*image.RGBA, error) {
+	return Default().RemoveWatermarkAt(img, rect)
+}
+
+// RemoveWatermarkAt removes the Gemini watermark from the square rect, in
+// image coordinates, instead of the standard placement, for images that
+// were cropped or padded after generation. No detection is run. Sizes other
+// than 48 and 96 use resampled captures; rect must lie inside the image.
+func (e *Engine) RemoveWatermarkAt(img image.Image, rect image.Rectangle) (*image.RGBA, error) {
 	if img == nil {
 		return nil, fmt.Errorf("nil image provided")
 	}
+	if rect.Empty() || rect.Dx() != rect.Dy() {
+		return nil, fmt.Errorf("watermark rectangle %v must be a non-empty square", rect)
+	}
+	bounds := img.Bounds()
+	if !rect.In(bounds) {
+		return nil, &GeometryError{Rect: rect, Bounds: bounds, Nearest: nearestPlacement(rect, bounds)}
+	}
+	return e.removeAt(context.Background(), img, Info{Size: rect.Dx(), Position: rect, Corner: NearestCorner(rect, bounds)}, GeminiProfile())
+}
+
+// placement returns where profile p puts the watermark in img, running
+// detection only when the corner is CornerAuto.
+func (e *Engine) placement(img image.Image, p Profile) (Info, error) {
+	if img == nil {
+		return Info{}, fmt.Errorf("nil image provided")
+	}
 
 	bounds := img.Bounds()
 	width, height := bounds.Dx(), bounds.Dy()
 	if width <= 0 || height <= 0 {
-		return nil, fmt.Errorf("invalid image dimensions %dx%d", width, height)
+		return Info{}, fmt.Errorf("invalid image dimensions %dx%d", width, height)
+	}
+
+	cfg, ok := p.config(width, height)
+	if !ok {
+		return Info{}, p.noVariantError(width, height)
+	}
+	if cfg.Corner == CornerAuto {
+		// The corner is only known once detection has compared them.
+		_, _, info, err := detectPlacement(img, cfg, e.detectParams(p))
+		return info, err
 	}
 
-	cfg := DetectWatermarkConfig(width, height)
 	rect, err := calculateWatermarkRect(bounds, cfg)
 	if err != nil {
-		return nil, err
+		return Info{}, err
 	}
+	return Info{Size: cfg.LogoSize, Position: rect, Corner: cfg.Corner}, nil
+}
+
+// removeAt reverse blends the watermark described by info, typically as
+// reported by detection, into a new *image.RGBA. The logo capture and color
+// come from profile p. It gives up with ctx.Err() between stages once ctx is
+// done.
+func (e *Engine) removeAt(ctx context.Context, img image.Image, info Info, p Profile) (*image.RGBA, error) {
+	return e.removeWithin(ctx, img, img.Bounds(), info, p)
+}
 
-	alphaMap, err := e.getAlphaMap(cfg.LogoSize)
+// removeWithin is removeAt restricted to region, which must contain the
+// watermark rectangle: only that part of img is copied and cleaned, and the
+// result keeps img's coordinates.
+func (e *Engine) removeWithin(ctx context.Context, img image.Image, region image.Rectangle, info Info, p Profile) (*image.RGBA, error) {
+	bounds := img.Bounds()
+	alphaMap, logo, err := e.blendParams(p, bounds.Dx(), bounds.Dy(), info)
 	if err != nil {
 		return nil, err
 	}
-
-	expected := rect.Dx() * rect.Dy()
-	if len(alphaMap) != expected {
-		return nil, fmt.Errorf("alpha map size mismatch: have %d, want %d", len(alphaMap), expected)
+	if region != bounds {
+		img = subImage(img, region)
+	}
+	// Copying dominates on large images; the blend itself only touches
+	// the watermark rectangle.
+	bands, release, err := e.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	rgba := cloneToRGBA(img)
-	applyReverseAlpha(rgba, alphaMap, rect)
-
+	if e.jsCompat && logo == whiteLogo {
+		// The extension has no post-processing, so the blend is the output.
+		nrgba := cloneToNRGBAParallel(img, bands)
+		applyReverseAlphaJS(nrgba, alphaMap, info.Position)
+		return cloneToRGBAParallel(nrgba, bands), nil
+	}
+	rgba := e.reverseAlphaClone(img, alphaMap, info.Position, logo, bands)
+	if err := e.postProcess(ctx, rgba, img, alphaMap, info.Position, logo); err != nil {
+		return nil, err
+	}
 	return rgba, nil
 }
 
+// postProcess runs the engine's cleanup on dst, the reverse blend of src
+// at rect: inpainting of clipped pixels, halo suppression and noise
+// matching, each as configured. It gives up with ctx.Err() before each
+// stage once ctx is done.
+func (e *Engine) postProcess(ctx context.Context, dst *image.RGBA, src image.Image, alphaMap []float32, rect image.Rectangle, logo [3]float64) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	e.inpaint.inpaintClipped(dst, src, alphaMap, rect, logo)
+	if e.halo.applies(src) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		e.halo.suppressHalo(dst, alphaMap, rect)
+	}
+	if e.noise != nil {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		e.noise.matchNoise(dst, alphaMap, rect)
+	}
+	return nil
+}
+
 // WatermarkInfo reports the detected watermark size and rectangle for display.
 func WatermarkInfo(width, height int) Info {
-	cfg := DetectWatermarkConfig(width, height)
-	rect, _ := calculateWatermarkRect(image.Rect(0, 0, width, height), cfg)
-	return Info{Size: cfg.LogoSize, Position: rect}
+	info, ok := GeminiProfile().Placement(width, height)
+	if !ok {
+		// Keep reporting the size for images too small for the rectangle.
+		info.Size = DetectWatermarkConfig(width, height).LogoSize
+	}
+	return info
 }
 
 // DetectWatermarkConfig selects the Gemini watermark parameters based on the
 // original JS rules: if both width and height are greater than 1024, use 96x96
 // with 64px margins; otherwise use 48x48 with 32px margins.
 func DetectWatermarkConfig(width, height int) watermarkConfig {
-	if width > 1024 && height > 1024 {
-		return watermarkConfig{LogoSize: 96, MarginRight: 64, MarginBottom: 64}
-	}
-	return watermarkConfig{LogoSize: 48, MarginRight: 32, MarginBottom: 32}
+	cfg, _ := GeminiProfile().config(width, height)
+	return cfg
 }
 
 // calculateWatermarkRect computes the watermark rectangle in image coordinates.
 func calculateWatermarkRect(bounds image.Rectangle, cfg watermarkConfig) (image.Rectangle, error) {
-	x := bounds.Max.X - cfg.MarginRight - cfg.LogoSize
-	y := bounds.Max.Y - cfg.MarginBottom - cfg.LogoSize
-
-	rect := image.Rect(x, y, x+cfg.LogoSize, y+cfg.LogoSize)
+	origin := cfg.Corner.origin(bounds, cfg.LogoSize, cfg.MarginRight, cfg.MarginBottom)
+	rect := image.Rectangle{Min: origin, Max: origin.Add(image.Pt(cfg.LogoSize, cfg.LogoSize))}
 	if !rect.In(bounds) {
-		return image.Rectangle{}, fmt.Errorf("watermark rectangle %v out of bounds %v", rect, bounds)
+		return image.Rectangle{}, &GeometryError{Rect: rect, Bounds: bounds, Nearest: nearestPlacement(rect, bounds)}
 	}
 	return rect, nil
 }
 
-// cloneToRGBA copies the image into a mutable RGBA buffer.
-func cloneToRGBA(src image.Image) *image.RGBA {
-	bounds := src.Bounds()
-	dst := image.NewRGBA(bounds)
-	draw.Draw(dst, bounds, src, bounds.Min, draw.Src)
-	return dst
+// nearestPlacement shifts rect the minimum distance needed to lie inside
+// bounds. It returns the empty rectangle when rect cannot fit at all.
+func nearestPlacement(rect, bounds image.Rectangle) image.Rectangle {
+	if rect.Dx() > bounds.Dx() || rect.Dy() > bounds.Dy() {
+		return image.Rectangle{}
+	}
+
+	shift := image.Point{}
+	if rect.Min.X < bounds.Min.X {
+		shift.X = bounds.Min.X - rect.Min.X
+	} else if rect.Max.X > bounds.Max.X {
+		shift.X = bounds.Max.X - rect.Max.X
+	}
+	if rect.Min.Y < bounds.Min.Y {
+		shift.Y = bounds.Min.Y - rect.Min.Y
+	} else if rect.Max.Y > bounds.Max.Y {
+		shift.Y = bounds.Max.Y - rect.Max.Y
+	}
+
+	return rect.Add(shift)
+}
+
+// RegisterAlphaMap makes the engine remove watermarks of the given size with
+// alpha, size*size values in [0, 1] in row-major order, instead of the
+// embedded capture, for example one captured from a new logo revision (see
+// LoadAlphaMapFromPNG). A map registered for the 96px base capture also
+// drives the sizes resampled from it, and detection through e uses the
+// registered maps too. RegisterAlphaMap must not be called concurrently with
+// removal.
+func (e *Engine) RegisterAlphaMap(size int, alpha []float32) error {
+	if size <= 0 {
+		return fmt.Errorf("invalid alpha map size %d", size)
+	}
+	if len(alpha) != size*size {
+		return fmt.Errorf("alpha map size mismatch: have %d, want %d", len(alpha), size*size)
+	}
+	for i, a := range alpha {
+		if a < 0 || a > 1 || math.IsNaN(float64(a)) {
+			return fmt.Errorf("alpha map value %v at %d outside [0, 1]", a, i)
+		}
+	}
+
+	e.mu.Lock()
+	if e.custom == nil {
+		e.custom = make(map[int][]float32)
+	}
+	e.custom[size] = append([]float32(nil), alpha...)
+	e.mu.Unlock()
+
+	if size == scaledBaseSize {
+		// Resampled sizes must be derived from the new base capture.
+		e.scaled.mu.Lock()
+		e.scaled.maps = nil
+		e.scaled.mu.Unlock()
+	}
+	return nil
+}
+
+// registeredAlpha returns the alpha map for size when it comes from
+// RegisterAlphaMap, either directly or resampled from a registered base
+// capture.
+func (e *Engine) registeredAlpha(size int) ([]float32, bool) {
+	e.mu.RLock()
+	_, exact := e.custom[size]
+	_, base := e.custom[scaledBaseSize]
+	e.mu.RUnlock()
+	if _, embedded := e.once[size]; !exact && (embedded || !base) {
+		return nil, false
+	}
+	alpha, err := e.getAlphaMap(size)
+	return alpha, err == nil
 }
 
 // getAlphaMap lazily loads and caches the alpha map for the requested size.
 func (e *Engine) getAlphaMap(size int) ([]float32, error) {
+	e.mu.RLock()
+	alpha, ok := e.custom[size]
+	e.mu.RUnlock()
+	if ok {
+		return alpha, nil
+	}
+
 	once, ok := e.once[size]
 	if !ok {
-		return nil, fmt.Errorf("unsupported watermark size %d", size)
+		return e.scaled.get(size, e.getAlphaMap)
 	}
 
 	once.Do(func() {
-		e.alphaMaps[size], e.alphaErrs[size] = decodeAlphaAsset(size)
+		alpha, err := decodeAlphaAsset(size)
+		e.mu.Lock()
+		e.alphaMaps[size], e.alphaErrs[size] = alpha, err
+		e.mu.Unlock()
 	})
 
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
 	if err := e.alphaErrs[size]; err != nil {
 		return nil, err
 	}
@@ -153,14 +434,108 @@ func (e *Engine) getAlphaMap(size int) ([]float32, error) {
 	return nil, fmt.Errorf("alpha map not available for size %d", size)
 }
 
-// applyReverseAlpha performs the reverse alpha blending within the watermark
-// rectangle. It mutates the provided RGBA buffer in place.
-func applyReverseAlpha(img *image.RGBA, alphaMap []float32, rect image.Rectangle) {
-	stride := rect.Dx()
+// blendParams resolves the alpha map, with exclusions applied, and the logo
+// color for removing the watermark described by info under profile p.
+func (e *Engine) blendParams(p Profile, width, height int, info Info) ([]float32, [3]float64, error) {
+	logo := e.logo(p)
+	if cfg, ok := p.config(width, height); ok && cfg.mask != nil {
+		return e.applyExclusion(maskAlpha(cfg.mask, info.Size), info.Position), logo, nil
+	}
 
+	alphaMap, err := e.alphaForRect(info.Size, info.Position)
+	return alphaMap, logo, err
+}
+
+// alphaForRect returns the alpha map for a watermark of the given size placed
+// at rect, with excluded pixels zeroed so the blend leaves them untouched.
+func (e *Engine) alphaForRect(size int, rect image.Rectangle) ([]float32, error) {
+	alphaMap, err := e.getAlphaMap(size)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := rect.Dx() * rect.Dy()
+	if len(alphaMap) != expected {
+		return nil, fmt.Errorf("alpha map size mismatch: have %d, want %d", len(alphaMap), expected)
+	}
+
+	return e.applyExclusion(alphaMap, rect), nil
+}
+
+// applyExclusion returns alphaMap with pixels covered by the exclusion mask
+// zeroed. The input slice is never modified.
+func (e *Engine) applyExclusion(alphaMap []float32, rect image.Rectangle) []float32 {
+	if e.exclude == nil {
+		return alphaMap
+	}
+
+	masked := make([]float32, len(alphaMap))
+	copy(masked, alphaMap)
+
+	mb := e.exclude.Bounds()
 	for row := 0; row < rect.Dy(); row++ {
 		for col := 0; col < rect.Dx(); col++ {
-			alpha := float64(alphaMap[row*stride+col])
+			pt := image.Pt(rect.Min.X+col, rect.Min.Y+row)
+			if !pt.In(mb) {
+				continue
+			}
+			if _, _, _, a := e.exclude.At(pt.X, pt.Y).RGBA(); a != 0 {
+				masked[row*rect.Dx()+col] = 0
+			}
+		}
+	}
+
+	return masked
+}
+
+// reverseAlphaClone copies img and reverse blends the watermark on straight
+// color, see straightClone.
+func (e *Engine) reverseAlphaClone(img image.Image, alphaMap []float32, rect image.Rectangle, logo [3]float64, bands int) *image.RGBA {
+	return straightClone(img, bands, func(rgba *image.RGBA) {
+		applyReverseAlpha(rgba, alphaMap, rect, logo, e.rounding, bands)
+	})
+}
+
+// straightClone copies img and runs blend on the copy's straight
+// (non-premultiplied) color. The watermark was composited onto straight
+// color, so blending premultiplied values would distort translucent pixels.
+// Opaque images, where both representations agree, skip the conversion.
+// Copies run in the given number of bands.
+func straightClone(img image.Image, bands int, blend func(*image.RGBA)) *image.RGBA {
+	if isOpaque(img) {
+		rgba := cloneToRGBAParallel(img, bands)
+		blend(rgba)
+		return rgba
+	}
+
+	// NRGBA shares the RGBA pixel layout, so the blend can run on a view of
+	// the straight-color buffer.
+	nrgba := cloneToNRGBAParallel(img, bands)
+	blend(&image.RGBA{Pix: nrgba.Pix, Stride: nrgba.Stride, Rect: nrgba.Rect})
+	return cloneToRGBAParallel(nrgba, bands)
+}
+
+// applyReverseAlpha performs the reverse alpha blending within the watermark
+// rectangle with the kernel selected at startup (see Capabilities),
+// splitting it into up to bands row slices blended concurrently. It mutates
+// the provided RGBA buffer in place.
+func applyReverseAlpha(img *image.RGBA, alphaMap []float32, rect image.Rectangle, logo [3]float64, mode RoundingMode, bands int) {
+	inBands(rect, bands, func(band image.Rectangle) {
+		reverseAlphaRows(img, alphaMap, rect, band, logo, mode)
+	})
+}
+
+// applyReverseAlphaRows is the generic kernel: it blends the rows of band, a
+// horizontal slice of rect, indexing Pix directly. alphaMap covers all of
+// rect.
+func applyReverseAlphaRows(img *image.RGBA, alphaMap []float32, rect, band image.Rectangle, logo [3]float64, mode RoundingMode) {
+	stride := rect.Dx()
+
+	for y := band.Min.Y; y < band.Max.Y; y++ {
+		alphas := alphaMap[(y-rect.Min.Y)*stride:][:stride]
+		pix := img.Pix[img.PixOffset(rect.Min.X, y):][:4*stride]
+		for col, a := range alphas {
+			alpha := float64(a)
 			if alpha < alphaThreshold {
 				continue
 			}
@@ -169,16 +544,19 @@ func applyReverseAlpha(img *image.RGBA, alphaMap []float32, rect image.Rectangle
 				alpha = maxAlpha
 			}
 
-			oneMinusAlpha := 1.0 - alpha
-			offset := img.PixOffset(rect.Min.X+col, rect.Min.Y+row)
-
-			for c := 0; c < 3; c++ {
-				watermarked := float64(img.Pix[offset+c])
-				original := (watermarked - alpha*logoValue) / oneMinusAlpha
-
-				original = math.Max(0, math.Min(255, original))
-				img.Pix[offset+c] = uint8(math.Round(original))
-			}
+			p := pix[4*col : 4*col+3]
+			p[0] = reverseBlend(p[0], alpha, logo[0], mode)
+			p[1] = reverseBlend(p[1], alpha, logo[1], mode)
+			p[2] = reverseBlend(p[2], alpha, logo[2], mode)
 		}
 	}
 }
+
+// reverseBlend recovers the original channel value from a watermarked one,
+// given the watermark opacity and logo channel value at that pixel.
+func reverseBlend(watermarked uint8, alpha, logo float64, mode RoundingMode) uint8 {
+	original := (float64(watermarked) - alpha*logo) / (1.0 - alpha)
+
+	original = math.Max(0, math.Min(255, original))
+	return uint8(mode.round(original))
+}
@@ -0,0 +1,274 @@
+package watermark
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestExclusionMaskProtectsPixels(t *testing.T) {
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{R: 50, G: 80, B: 20, A: 255})
+	info := WatermarkInfo(320, 240)
+
+	// Protect the left half of the watermark rectangle.
+	protected := image.Rect(info.Position.Min.X, info.Position.Min.Y, info.Position.Min.X+info.Size/2, info.Position.Max.Y)
+	mask := image.NewAlpha(img.Bounds())
+	draw.Draw(mask, protected, image.Opaque, image.Point{}, draw.Src)
+
+	engine := NewEngine()
+	engine.SetExclusionMask(mask)
+
+	cleaned, err := engine.RemoveWatermark(img)
+	if err != nil {
+		t.Fatalf("RemoveWatermark: %v", err)
+	}
+
+	changedOutside := false
+	for y := info.Position.Min.Y; y < info.Position.Max.Y; y++ {
+		for x := info.Position.Min.X; x < info.Position.Max.X; x++ {
+			same := cleaned.RGBAAt(x, y) == img.RGBAAt(x, y)
+			if image.Pt(x, y).In(protected) && !same {
+				t.Fatalf("protected pixel (%d,%d) was modified", x, y)
+			}
+			if !image.Pt(x, y).In(protected) && !same {
+				changedOutside = true
+			}
+		}
+	}
+	if !changedOutside {
+		t.Fatalf("expected unprotected watermark pixels to be cleaned")
+	}
+}
+
+func TestSetDefaultEngine(t *testing.T) {
+	defer SetDefaultEngine(nil)
+
+	custom := NewEngine()
+	custom.SetRoundingMode(RoundTruncate)
+	SetDefaultEngine(custom)
+
+	if Default() != custom {
+		t.Fatalf("Default did not return the installed engine")
+	}
+
+	SetDefaultEngine(nil)
+	if e := Default(); e == nil || e == custom {
+		t.Fatalf("expected a fresh default engine after reset, got %p", e)
+	}
+}
+
+func TestDefaultEngineConcurrentSizes(t *testing.T) {
+	SetDefaultEngine(nil)
+
+	small := watermarkedRGBA(t, 320, 240, color.RGBA{R: 10, G: 20, B: 30, A: 255})
+	large := watermarkedRGBA(t, 1100, 1100, color.RGBA{R: 10, G: 20, B: 30, A: 255})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		img := small
+		if i%2 == 1 {
+			img = large
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := RemoveWatermark(img); err != nil {
+				t.Errorf("RemoveWatermark: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRemoveWatermarkTranslucentSource(t *testing.T) {
+	const width, height = 320, 240
+	bg := color.NRGBA{R: 40, G: 60, B: 90, A: 128}
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
+
+	info := WatermarkInfo(width, height)
+	alphaMap, err := detectAlphaMap(info.Size)
+	if err != nil {
+		t.Fatalf("alpha map: %v", err)
+	}
+	rect := info.Position
+	for row := 0; row < rect.Dy(); row++ {
+		for col := 0; col < rect.Dx(); col++ {
+			alpha := float64(alphaMap[row*rect.Dx()+col])
+			offset := img.PixOffset(rect.Min.X+col, rect.Min.Y+row)
+			for c := 0; c < 3; c++ {
+				v := alpha*logoValue + (1-alpha)*float64(img.Pix[offset+c])
+				img.Pix[offset+c] = uint8(v + 0.5)
+			}
+		}
+	}
+
+	cleaned, err := RemoveWatermark(img)
+	if err != nil {
+		t.Fatalf("RemoveWatermark: %v", err)
+	}
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			got := color.NRGBAModel.Convert(cleaned.At(x, y)).(color.NRGBA)
+			if absDiff(got.R, bg.R) > 3 || absDiff(got.G, bg.G) > 3 || absDiff(got.B, bg.B) > 3 || got.A != bg.A {
+				t.Fatalf("pixel (%d,%d) = %v, want about %v", x, y, got, bg)
+			}
+		}
+	}
+}
+
+func TestRegisterAlphaMap(t *testing.T) {
+	data, err := embeddedAssets.ReadFile("assets/bg_48.png")
+	if err != nil {
+		t.Fatalf("read asset: %v", err)
+	}
+	embedded, size, err := LoadAlphaMapFromPNG(bytes.NewReader(data))
+	if err != nil || size != 48 {
+		t.Fatalf("LoadAlphaMapFromPNG: size %d, %v", size, err)
+	}
+
+	// A revised logo: the capture mirrored top to bottom.
+	revised := make([]float32, len(embedded))
+	for y := 0; y < size; y++ {
+		copy(revised[y*size:(y+1)*size], embedded[(size-1-y)*size:(size-y)*size])
+	}
+	bg := color.RGBA{R: 50, G: 80, B: 20, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
+	info := WatermarkInfo(320, 240)
+	for row := 0; row < size; row++ {
+		for col := 0; col < size; col++ {
+			alpha := float64(revised[row*size+col])
+			offset := img.PixOffset(info.Position.Min.X+col, info.Position.Min.Y+row)
+			for c, v := range []uint8{bg.R, bg.G, bg.B} {
+				img.Pix[offset+c] = uint8(alpha*logoValue + (1-alpha)*float64(v) + 0.5)
+			}
+		}
+	}
+
+	engine := NewEngine()
+	if err := engine.RegisterAlphaMap(size, revised[1:]); err == nil {
+		t.Fatalf("expected an error for a short alpha map")
+	}
+	if err := engine.RegisterAlphaMap(size, revised); err != nil {
+		t.Fatalf("RegisterAlphaMap: %v", err)
+	}
+	// Detection through the engine itself uses its maps, as removal does.
+	r, err := engine.DetectResult(img, GeminiProfile())
+	if err != nil || !r.Present {
+		t.Fatalf("revised logo not detected by its engine: %+v, %v", r, err)
+	}
+	if d, err := NewEngine().DetectResult(img, GeminiProfile()); err != nil || d.Correlation >= r.Correlation {
+		t.Fatalf("engine without the map correlates %.2f, with it %.2f (%v)", d.Correlation, r.Correlation, err)
+	}
+
+	SetDefaultEngine(engine)
+	defer SetDefaultEngine(nil)
+
+	present, _, _, err := DetectWatermark(img)
+	if err != nil || !present {
+		t.Fatalf("revised logo not detected: present %v, %v", present, err)
+	}
+	cleaned, err := engine.RemoveWatermark(img)
+	if err != nil {
+		t.Fatalf("RemoveWatermark: %v", err)
+	}
+	for y := info.Position.Min.Y; y < info.Position.Max.Y; y++ {
+		for x := info.Position.Min.X; x < info.Position.Max.X; x++ {
+			c := cleaned.RGBAAt(x, y)
+			if absDiff(c.R, bg.R) > 1 || absDiff(c.G, bg.G) > 1 || absDiff(c.B, bg.B) > 1 {
+				t.Fatalf("pixel (%d,%d) = %v, want about %v", x, y, c, bg)
+			}
+		}
+	}
+
+	if _, _, err := LoadAlphaMapFromPNG(bytes.NewReader(nil)); err == nil {
+		t.Fatalf("expected a decode error")
+	}
+}
+
+func TestRemoveWatermarkAtPaddedImage(t *testing.T) {
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{R: 50, G: 80, B: 20, A: 255})
+	want, err := RemoveWatermark(img)
+	if err != nil {
+		t.Fatalf("RemoveWatermark: %v", err)
+	}
+
+	// Pad the image so the logo is no longer at the standard placement.
+	pad := image.Pt(30, 20)
+	padded := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	draw.Draw(padded, img.Bounds().Add(pad), img, image.Point{}, draw.Src)
+	rect := WatermarkInfo(320, 240).Position.Add(pad)
+
+	got, err := RemoveWatermarkAt(padded, rect)
+	if err != nil {
+		t.Fatalf("RemoveWatermarkAt: %v", err)
+	}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			if got.RGBAAt(x, y) != want.RGBAAt(x-pad.X, y-pad.Y) {
+				t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, got.RGBAAt(x, y), want.RGBAAt(x-pad.X, y-pad.Y))
+			}
+		}
+	}
+
+	if _, err := RemoveWatermarkAt(padded, image.Rect(0, 0, 48, 40)); err == nil {
+		t.Fatalf("expected an error for a non-square rectangle")
+	}
+	if _, err := RemoveWatermarkAt(padded, image.Rect(380, 0, 428, 48)); !errors.Is(err, ErrOutOfBounds) {
+		t.Fatalf("expected ErrOutOfBounds, got %v", err)
+	}
+}
+
+// reverseAlphaInput returns a random image of size n with a random alpha map
+// over a rectangle inset by 3 pixels, off the image origin.
+func reverseAlphaInput(n int) (*image.RGBA, []float32, image.Rectangle) {
+	rng := rand.New(rand.NewSource(int64(n)))
+	img := image.NewRGBA(image.Rect(-5, 7, n-5, n+7))
+	rng.Read(img.Pix)
+	rect := img.Rect.Inset(3)
+	alpha := make([]float32, rect.Dx()*rect.Dy())
+	for i := range alpha {
+		alpha[i] = rng.Float32()
+	}
+	return img, alpha, rect
+}
+
+func TestApplyReverseAlphaBands(t *testing.T) {
+	img, alpha, rect := reverseAlphaInput(700)
+	want := image.NewRGBA(img.Rect)
+	copy(want.Pix, img.Pix)
+	applyReverseAlpha(want, alpha, rect, [3]float64{255, 200, 120}, RoundHalfUp, 1)
+
+	for _, bands := range []int{2, 3, 8} {
+		got := image.NewRGBA(img.Rect)
+		copy(got.Pix, img.Pix)
+		applyReverseAlpha(got, alpha, rect, [3]float64{255, 200, 120}, RoundHalfUp, bands)
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("%d bands differ from one band", bands)
+		}
+	}
+}
+
+func BenchmarkApplyReverseAlpha(b *testing.B) {
+	for _, n := range []int{96, 1024, 2048} {
+		for _, bands := range []int{1, 4} {
+			b.Run(strconv.Itoa(n)+"/bands="+strconv.Itoa(bands), func(b *testing.B) {
+				img, alpha, rect := reverseAlphaInput(n)
+				b.SetBytes(int64(4 * rect.Dx() * rect.Dy()))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					applyReverseAlpha(img, alpha, rect, whiteLogo, RoundHalfUp, bands)
+				}
+			})
+		}
+	}
+}
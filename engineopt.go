@@ -0,0 +1,123 @@
+package watermark
+
+import (
+	"image"
+	"runtime"
+)
+
+// Option configures an Engine built by NewEngine.
+type Option func(*Engine)
+
+// WithDetectionThresholds sets the detection gates used when a profile sets
+// none of its own: luma is the brightness lift of the watermark rectangle
+// over its surroundings (default 6) and corr the correlation with the logo
+// shape (default 0.30) that detection requires. Values of zero or below keep
+// the default. The gates of the Default engine apply to the package-level
+// helpers such as DetectWatermark and ProcessBytes.
+func WithDetectionThresholds(luma, corr float64) Option {
+	return func(e *Engine) {
+		e.minScore, e.minCorrelation = luma, corr
+	}
+}
+
+// WithLogoValue sets the channel value, 1-255, of the white Gemini logo that
+// the reverse blend removes (default 255), for sources whose logo was
+// composited slightly gray. Values outside the range keep the default.
+// Profiles with another LogoColor are unaffected, and a value other than 255
+// disables the JS-compatible blend.
+func WithLogoValue(v float64) Option {
+	return func(e *Engine) {
+		if v >= 1 && v <= 255 {
+			e.logoValue = v
+		}
+	}
+}
+
+// WithParallelism copies images and reverse blends the watermark region in
+// up to n concurrent horizontal bands during removal, which dominates the
+// time spent on very large images and regions. n
+// below 1 uses runtime.GOMAXPROCS(0). The default is 1: servers and batches
+// already process several images at once.
+func WithParallelism(n int) Option {
+	return func(e *Engine) {
+		if n < 1 {
+			n = runtime.GOMAXPROCS(0)
+		}
+		e.parallelism = n
+	}
+}
+
+// WithMaxConcurrency bounds the pixel work the engine runs at once to n
+// goroutines, shared by every caller: server requests, batch workers and,
+// for the Default engine, the package-level helpers. Each removal needs one
+// slot and waits for it, giving up with ctx.Err() in the Context variants;
+// the extra bands of WithParallelism only use slots that are free, so a busy
+// engine copies images in fewer bands rather than queueing. Detection is not
+// counted. n below 1, the default, sets no bound.
+func WithMaxConcurrency(n int) Option {
+	return func(e *Engine) {
+		e.slots = nil
+		if n > 0 {
+			e.slots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithRoundingMode is the Option form of SetRoundingMode.
+func WithRoundingMode(mode RoundingMode) Option {
+	return func(e *Engine) { e.SetRoundingMode(mode) }
+}
+
+// WithProgress is the Option form of SetProgressFunc.
+func WithProgress(fn func(stage string, pct float64)) Option {
+	return func(e *Engine) { e.SetProgressFunc(fn) }
+}
+
+// WithNoiseMatch is the Option form of SetNoiseMatch.
+func WithNoiseMatch(n *NoiseMatch) Option {
+	return func(e *Engine) { e.SetNoiseMatch(n) }
+}
+
+// WithHaloCleanup is the Option form of SetHaloCleanup.
+func WithHaloCleanup(h *HaloCleanup) Option {
+	return func(e *Engine) { e.SetHaloCleanup(h) }
+}
+
+// WithClipInpaint is the Option form of SetClipInpaint.
+func WithClipInpaint(c *ClipInpaint) Option {
+	return func(e *Engine) { e.SetClipInpaint(c) }
+}
+
+// WithExclusionMask is the Option form of SetExclusionMask.
+func WithExclusionMask(mask image.Image) Option {
+	return func(e *Engine) { e.SetExclusionMask(mask) }
+}
+
+// WithJSCompat is the Option form of SetJSCompat.
+func WithJSCompat(enabled bool) Option {
+	return func(e *Engine) { e.SetJSCompat(enabled) }
+}
+
+// detectParams returns the detection settings of profile p, with e's
+// thresholds filling the gates p leaves unset.
+func (e *Engine) detectParams(p Profile) detectParams {
+	params := p.detectParams()
+	params.engine = e
+	if params.minScore <= 0 {
+		params.minScore = e.minScore
+	}
+	if params.minCorrelation <= 0 {
+		params.minCorrelation = e.minCorrelation
+	}
+	return params
+}
+
+// logo returns the logo color of profile p, with e's logo value in place of
+// the default white.
+func (e *Engine) logo(p Profile) [3]float64 {
+	logo := p.logo()
+	if logo == whiteLogo && e.logoValue > 0 {
+		return [3]float64{e.logoValue, e.logoValue, e.logoValue}
+	}
+	return logo
+}
@@ -0,0 +1,162 @@
+package watermark
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWithDetectionThresholds(t *testing.T) {
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{40, 60, 80, 255})
+
+	present, score, _, err := NewEngine().DetectWatermark(img)
+	if err != nil || !present {
+		t.Fatalf("default gates: present=%v score=%.2f err=%v", present, score, err)
+	}
+
+	strict := NewEngine(WithDetectionThresholds(score+1, 0))
+	if present, _, _, err := strict.DetectWatermark(img); err != nil || present {
+		t.Fatalf("luma gate above the score: present=%v err=%v", present, err)
+	}
+	if present, _, _, err := NewEngine(WithDetectionThresholds(0, 1)).DetectWatermark(img); err != nil || present {
+		t.Fatalf("correlation gate 1: present=%v err=%v", present, err)
+	}
+
+	// Gates set by the profile take precedence.
+	p := GeminiProfile()
+	p.MinScore = 1
+	if present, _, _, err := strict.DetectWatermarkProfile(img, p); err != nil || !present {
+		t.Fatalf("profile gate: present=%v err=%v", present, err)
+	}
+
+	// The package-level helpers follow the Default engine.
+	SetDefaultEngine(strict)
+	defer SetDefaultEngine(nil)
+	if present, _, _, err := DetectWatermark(img); err != nil || present {
+		t.Fatalf("DetectWatermark with strict default: present=%v err=%v", present, err)
+	}
+}
+
+func TestWithLogoValue(t *testing.T) {
+	const gray = 230
+	bg := color.RGBA{40, 60, 80, 255}
+	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = bg.R, bg.G, bg.B, bg.A
+	}
+	cfg := DetectWatermarkConfig(320, 240)
+	rect, err := calculateWatermarkRect(img.Bounds(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alphaMap, err := detectAlphaMap(cfg.LogoSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for row := 0; row < rect.Dy(); row++ {
+		for col := 0; col < rect.Dx(); col++ {
+			alpha := float64(alphaMap[row*rect.Dx()+col])
+			offset := img.PixOffset(rect.Min.X+col, rect.Min.Y+row)
+			for c := 0; c < 3; c++ {
+				img.Pix[offset+c] = uint8(alpha*gray + (1-alpha)*float64(img.Pix[offset+c]) + 0.5)
+			}
+		}
+	}
+
+	maxDiff := func(e *Engine) int {
+		t.Helper()
+		cleaned, err := e.RemoveWatermark(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		worst := 0
+		for y := rect.Min.Y; y < rect.Max.Y; y++ {
+			for x := rect.Min.X; x < rect.Max.X; x++ {
+				c := cleaned.RGBAAt(x, y)
+				for i, v := range [3]uint8{c.R, c.G, c.B} {
+					want := [3]uint8{bg.R, bg.G, bg.B}[i]
+					worst = max(worst, abs(int(v)-int(want)))
+				}
+			}
+		}
+		return worst
+	}
+
+	if d := maxDiff(NewEngine(WithLogoValue(gray))); d > 2 {
+		t.Fatalf("WithLogoValue(%d) leaves a difference of %d", gray, d)
+	}
+	if d := maxDiff(NewEngine()); d <= 2 {
+		t.Fatalf("default logo value should not fit a gray logo (difference %d)", d)
+	}
+}
+
+func TestWithParallelism(t *testing.T) {
+	img := watermarkedRGBA(t, 640, 480, color.RGBA{40, 60, 80, 255})
+	translucent := image.NewNRGBA(img.Bounds())
+	for i := 0; i < len(img.Pix); i += 4 {
+		copy(translucent.Pix[i:i+4], img.Pix[i:i+4])
+		translucent.Pix[i+3] = 200
+	}
+
+	for name, src := range map[string]image.Image{"opaque": img, "translucent": translucent} {
+		want, err := NewEngine().RemoveWatermark(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := NewEngine(WithParallelism(4)).RemoveWatermark(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("%s: parallel removal differs", name)
+		}
+	}
+}
+
+func TestWithMaxConcurrency(t *testing.T) {
+	e := NewEngine(WithMaxConcurrency(3), WithParallelism(4))
+
+	bands, release, err := e.acquire(context.Background())
+	if err != nil || bands != 3 {
+		t.Fatalf("first acquire: bands=%d err=%v, want 3 bands", bands, err)
+	}
+	// With every slot taken, removals wait until ctx gives up.
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{40, 60, 80, 255})
+	info := WatermarkInfo(320, 240)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := e.removeAt(ctx, img, info, GeminiProfile()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("removal with no free slot: err=%v, want deadline exceeded", err)
+	}
+	release()
+
+	// Concurrent removals share the slots and still clean every image.
+	want, err := NewEngine().RemoveWatermark(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := e.RemoveWatermark(img)
+			if err != nil || !bytes.Equal(got.Pix, want.Pix) {
+				t.Errorf("concurrent removal: err=%v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(e.slots); n != 0 {
+		t.Errorf("%d slots still held", n)
+	}
+
+	if e := NewEngine(WithMaxConcurrency(0), WithParallelism(4)); e.slots != nil {
+		t.Error("WithMaxConcurrency(0) set a bound")
+	}
+}
@@ -0,0 +1,70 @@
+package watermark
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxFetchBytes caps downloads made by FetchImage.
+const maxFetchBytes = 256 << 20
+
+// IsURL reports whether input looks like an http(s) URL rather than a path.
+func IsURL(input string) bool {
+	lower := strings.ToLower(input)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// FetchImage downloads the raw bytes of an image from an http(s) URL. It
+// fails with an *OfflineError when offline mode is enabled.
+func FetchImage(ctx context.Context, url string) ([]byte, error) {
+	return FetchImageRetry(ctx, url, RetryPolicy{})
+}
+
+// FetchImageRetry is FetchImage with retries of network errors and 5xx, 408
+// and 429 responses, and per-attempt timeouts, as p configures.
+func FetchImageRetry(ctx context.Context, url string, p RetryPolicy) ([]byte, error) {
+	if err := CheckNetwork("url input"); err != nil {
+		return nil, err
+	}
+	if !IsURL(url) {
+		return nil, fmt.Errorf("unsupported url %q", url)
+	}
+
+	var data []byte
+	err := p.do(ctx, func(ctx context.Context) error {
+		var err error
+		data, err = fetchOnce(ctx, url)
+		return err
+	})
+	return data, err
+}
+
+func fetchOnce(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{"fetch " + url, resp.StatusCode, resp.Status}
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", url, err)
+	}
+	if len(data) > maxFetchBytes {
+		return nil, fmt.Errorf("fetch %s: image larger than %d bytes", url, maxFetchBytes)
+	}
+
+	return data, nil
+}
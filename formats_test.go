@@ -0,0 +1,140 @@
+//go:build !watermark_nov1
+
+package watermark
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRemoveWatermarkBytesFormats locks in byte-level behavior for every
+// registered decoder, with and without a watermark.
+func TestRemoveWatermarkBytesFormats(t *testing.T) {
+	bg := color.RGBA{R: 40, G: 60, B: 90, A: 255}
+	encodePNG := func(img image.Image) ([]byte, error) {
+		var buf bytes.Buffer
+		err := png.Encode(&buf, img)
+		return buf.Bytes(), err
+	}
+	encodeJPEG := func(img image.Image) ([]byte, error) {
+		var buf bytes.Buffer
+		err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95})
+		return buf.Bytes(), err
+	}
+	// The synthetic images use few colors, so an exact palette keeps GIF
+	// lossless.
+	encodeGIF := func(img image.Image) ([]byte, error) {
+		var pal color.Palette
+		seen := make(map[color.Color]bool)
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				if c := img.At(x, y); !seen[c] && len(pal) < 256 {
+					seen[c] = true
+					pal = append(pal, c)
+				}
+			}
+		}
+		p := image.NewPaletted(b, pal)
+		draw.Draw(p, p.Bounds(), img, image.Point{}, draw.Src)
+		var buf bytes.Buffer
+		err := gif.Encode(&buf, p, nil)
+		return buf.Bytes(), err
+	}
+	// The WebP fixtures are lossless 112x112 images with the same background.
+	readWebP := func(name string) func(image.Image) ([]byte, error) {
+		return func(image.Image) ([]byte, error) {
+			return os.ReadFile(filepath.Join("testdata", name))
+		}
+	}
+
+	tests := []struct {
+		name        string
+		encode      func(image.Image) ([]byte, error)
+		watermarked bool
+		// tolerance bounds each channel of each cleaned pixel, or the mean
+		// channel error over the watermark area when lossy is set.
+		tolerance uint8
+		lossy     bool
+	}{
+		{name: "png", encode: encodePNG, watermarked: true, tolerance: 2},
+		{name: "png clean", encode: encodePNG},
+		// Chroma subsampling bleeds around the logo edges and the reverse
+		// blend amplifies it, so only the average error is bounded.
+		{name: "jpeg", encode: encodeJPEG, watermarked: true, tolerance: 6, lossy: true},
+		{name: "jpeg clean", encode: encodeJPEG},
+		{name: "gif", encode: encodeGIF, watermarked: true, tolerance: 2},
+		{name: "gif clean", encode: encodeGIF},
+		{name: "webp", encode: readWebP("watermarked.webp"), watermarked: true, tolerance: 2},
+		{name: "webp clean", encode: readWebP("clean.webp")},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// Ignored by the WebP cases, which read fixtures instead.
+			src := image.NewRGBA(image.Rect(0, 0, 320, 240))
+			draw.Draw(src, src.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
+			if tc.watermarked {
+				src = watermarkedRGBA(t, 320, 240, bg)
+			}
+
+			data, err := tc.encode(src)
+			if err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+
+			out, present, score, info, err := RemoveWatermarkBytes(data)
+			if err != nil {
+				t.Fatalf("RemoveWatermarkBytes: %v", err)
+			}
+			if present != tc.watermarked {
+				t.Fatalf("present = %v (score %.2f), want %v", present, score, tc.watermarked)
+			}
+			if !present {
+				if out != nil {
+					t.Fatalf("expected no output for a clean image")
+				}
+				return
+			}
+
+			cleaned, format, err := DecodeImageBytes(out)
+			if err != nil {
+				t.Fatalf("decode output: %v", err)
+			}
+			if format != "png" {
+				t.Fatalf("output format = %q, want png", format)
+			}
+			// Compare against the source's own background, which lossy
+			// formats shift slightly.
+			decoded, _, err := DecodeImageBytes(data)
+			if err != nil {
+				t.Fatalf("decode input: %v", err)
+			}
+			want := color.RGBAModel.Convert(decoded.At(info.Position.Min.X-4, info.Position.Min.Y-4)).(color.RGBA)
+			var total, samples int
+			for y := info.Position.Min.Y; y < info.Position.Max.Y; y++ {
+				for x := info.Position.Min.X; x < info.Position.Max.X; x++ {
+					got := color.RGBAModel.Convert(cleaned.At(x, y)).(color.RGBA)
+					for _, d := range []uint8{absDiff(got.R, want.R), absDiff(got.G, want.G), absDiff(got.B, want.B)} {
+						if !tc.lossy && d > tc.tolerance {
+							t.Fatalf("pixel (%d,%d) = %v, want about %v", x, y, got, want)
+						}
+						total += int(d)
+						samples++
+					}
+				}
+			}
+			if mean := float64(total) / float64(samples); mean > float64(tc.tolerance) {
+				t.Fatalf("mean channel error %.2f, want at most %d", mean, tc.tolerance)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package watermark
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+)
+
+// ApplyWatermark applies the default engine. See Engine.ApplyWatermark.
+func ApplyWatermark(img image.Image) (*image.RGBA, error) {
+	return Default().ApplyWatermark(img)
+}
+
+// ApplyWatermark is the inverse of RemoveWatermark: it forward blends the
+// Gemini logo into a copy of img at the standard placement, using the same
+// alpha maps removal uses. It serves round-trip tests, generating
+// watermarked samples for tuning detection, and putting a watermark back
+// after editing a cleaned image. The result is returned as a new
+// *image.RGBA.
+func (e *Engine) ApplyWatermark(img image.Image) (*image.RGBA, error) {
+	return e.ApplyWatermarkProfile(img, GeminiProfile())
+}
+
+// ApplyWatermarkProfile forward blends the logo of profile p where p places
+// it. A CornerAuto profile stamps the bottom-right corner. Pixels covered by
+// the exclusion mask are left untouched, as they are by removal.
+func (e *Engine) ApplyWatermarkProfile(img image.Image, p Profile) (*image.RGBA, error) {
+	if img == nil {
+		return nil, fmt.Errorf("nil image provided")
+	}
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid image dimensions %dx%d", width, height)
+	}
+	cfg, ok := p.config(width, height)
+	if !ok {
+		return nil, p.noVariantError(width, height)
+	}
+	if cfg.Corner == CornerAuto {
+		cfg.Corner = CornerBottomRight
+	}
+	rect, err := calculateWatermarkRect(bounds, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	info := Info{Size: cfg.LogoSize, Position: rect, Corner: cfg.Corner}
+	alphaMap, logo, err := e.blendParams(p, width, height, info)
+	if err != nil {
+		return nil, err
+	}
+	bands, release, err := e.acquire(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return straightClone(img, bands, func(rgba *image.RGBA) {
+		inBands(rect, bands, func(band image.Rectangle) {
+			applyForwardAlphaRows(rgba, alphaMap, rect, band, logo)
+		})
+	}), nil
+}
+
+// applyForwardAlphaRows composites the logo over the rows of band, a
+// horizontal slice of rect. Pixels below alphaThreshold are skipped, as
+// removal skips them, so a round trip only touches the same pixels.
+func applyForwardAlphaRows(img *image.RGBA, alphaMap []float32, rect, band image.Rectangle, logo [3]float64) {
+	stride := rect.Dx()
+
+	for y := band.Min.Y; y < band.Max.Y; y++ {
+		alphas := alphaMap[(y-rect.Min.Y)*stride:][:stride]
+		pix := img.Pix[img.PixOffset(rect.Min.X, y):][:4*stride]
+		for col, a := range alphas {
+			alpha := float64(a)
+			if alpha < alphaThreshold {
+				continue
+			}
+
+			p := pix[4*col : 4*col+3]
+			p[0] = forwardBlend(p[0], alpha, logo[0])
+			p[1] = forwardBlend(p[1], alpha, logo[1])
+			p[2] = forwardBlend(p[2], alpha, logo[2])
+		}
+	}
+}
+
+// forwardBlend composites a logo channel value at opacity alpha over an
+// original channel value.
+func forwardBlend(original uint8, alpha, logo float64) uint8 {
+	v := alpha*logo + (1-alpha)*float64(original)
+	return uint8(math.Round(math.Max(0, math.Min(255, v))))
+}
@@ -0,0 +1,87 @@
+package watermark
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestApplyWatermarkMatchesCapture(t *testing.T) {
+	bg := color.RGBA{40, 90, 160, 255}
+	want := watermarkedRGBA(t, 1024, 1024, bg)
+
+	flat := image.NewRGBA(image.Rect(0, 0, 1024, 1024))
+	for i := 0; i < len(flat.Pix); i += 4 {
+		flat.Pix[i], flat.Pix[i+1], flat.Pix[i+2], flat.Pix[i+3] = bg.R, bg.G, bg.B, bg.A
+	}
+	got, err := ApplyWatermark(flat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := maxChannelDiff(got, want); d > 1 {
+		t.Errorf("applied watermark differs from the forward-blended capture by %d", d)
+	}
+	if flat.Pix[flat.PixOffset(1000, 1000)] != bg.R {
+		t.Error("ApplyWatermark modified its input")
+	}
+}
+
+func TestApplyWatermarkRoundTrip(t *testing.T) {
+	for _, size := range []image.Point{{1024, 1024}, {800, 600}} {
+		clean := texturedRGBA(size.X, size.Y, 0, 0, 0)
+		marked, err := NewEngine().ApplyWatermark(clean)
+		if err != nil {
+			t.Fatalf("%v: ApplyWatermark: %v", size, err)
+		}
+		r, err := DetectResult(marked, GeminiProfile())
+		if err != nil || !r.Present {
+			t.Fatalf("%v: applied watermark not detected (%+v, %v)", size, r, err)
+		}
+
+		restored, err := NewEngine().RemoveWatermark(marked)
+		if err != nil {
+			t.Fatalf("%v: RemoveWatermark: %v", size, err)
+		}
+		// Rounding after the forward blend is amplified by the reverse one
+		// where the logo is most opaque.
+		if d := maxChannelDiff(restored, clean); d > 2 {
+			t.Errorf("%v: round trip differs by %d", size, d)
+		}
+	}
+}
+
+func TestApplyWatermarkTranslucent(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 1024, 1024))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = 200, 30, 30, 128
+	}
+	marked, err := ApplyWatermark(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := WatermarkInfo(1024, 1024)
+	c := color.NRGBAModel.Convert(marked.At(info.Position.Max.X-info.Size/2, info.Position.Max.Y-info.Size/2)).(color.NRGBA)
+	if c.A != 128 || c.G <= 30 {
+		t.Errorf("logo center = %+v, want the logo blended over straight color with alpha kept", c)
+	}
+}
+
+func TestApplyWatermarkErrors(t *testing.T) {
+	if _, err := ApplyWatermark(nil); err == nil {
+		t.Error("nil image accepted")
+	}
+	var geomErr *GeometryError
+	if _, err := ApplyWatermark(image.NewRGBA(image.Rect(0, 0, 64, 64))); !errors.As(err, &geomErr) {
+		t.Errorf("tiny image: err = %v, want a GeometryError", err)
+	}
+}
+
+func maxChannelDiff(a, b *image.RGBA) int {
+	worst := 0
+	for i := range a.Pix {
+		d := int(a.Pix[i]) - int(b.Pix[i])
+		worst = max(worst, d, -d)
+	}
+	return worst
+}
@@ -0,0 +1,191 @@
+package watermark
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"slices"
+)
+
+const (
+	// defaultSceneChangeThreshold is the mean absolute luma change, sampled
+	// around a watermark placement, that invalidates a cached detection.
+	defaultSceneChangeThreshold = 4.0
+	// signatureGrid is the number of cells per axis in a corner signature.
+	signatureGrid = 6
+)
+
+// cornerSignature is the coarse luma grid of the area around one placement.
+type cornerSignature [signatureGrid * signatureGrid]float64
+
+// FrameDetector caches watermark detection across consecutive frames of the
+// same stream (video or animation). The full detection only re-runs when the
+// frame size changes or a coarse luma signature of any placement the
+// decision rests on moves by more than SceneChangeThreshold: every corner the
+// profile lets detection try, and the detected position. Steady shots cost
+// one cheap sampling pass per frame instead of a full scoring pass.
+//
+// A FrameDetector is not safe for concurrent use.
+type FrameDetector struct {
+	// SceneChangeThreshold is the mean absolute luma difference (0-255)
+	// between the signatures of one placement that forces a fresh detection.
+	SceneChangeThreshold float64
+	// RefreshInterval forces a fresh detection every N frames when positive.
+	RefreshInterval int
+	// Profile selects the watermark placement to detect.
+	Profile Profile
+
+	valid      bool
+	bounds     image.Rectangle
+	regions    []image.Rectangle
+	signatures []cornerSignature
+	age        int
+
+	present bool
+	score   float64
+	info    Info
+
+	hits, misses int
+}
+
+// NewFrameDetector returns a FrameDetector for the Gemini profile with the
+// default scene-change threshold.
+func NewFrameDetector() *FrameDetector {
+	return &FrameDetector{SceneChangeThreshold: defaultSceneChangeThreshold, Profile: GeminiProfile()}
+}
+
+// Detect reports whether the watermark is present in img, reusing the
+// previous frame's decision when the corner content has not changed.
+func (d *FrameDetector) Detect(img image.Image) (present bool, score float64, info Info, err error) {
+	if img == nil {
+		return false, 0, Info{}, fmt.Errorf("nil image provided")
+	}
+
+	bounds := img.Bounds()
+	cfg, ok := d.Profile.config(bounds.Dx(), bounds.Dy())
+	if !ok {
+		d.valid = false
+		return false, 0, Info{}, nil
+	}
+	if _, err := calculateWatermarkRect(bounds, cfg); err != nil {
+		d.valid = false
+		return false, 0, Info{}, err
+	}
+
+	if d.valid && bounds == d.bounds && !d.expired() && d.unchanged(img) {
+		d.hits++
+		d.age++
+		return d.present, d.score, d.info, nil
+	}
+
+	present, score, info, err = DetectWatermarkProfile(img, d.Profile)
+	if err != nil {
+		d.valid = false
+		return false, 0, Info{}, err
+	}
+
+	d.misses++
+	d.valid = true
+	d.bounds = bounds
+	d.regions = signedRegions(bounds, cfg, present, info)
+	d.signatures = d.signatures[:0]
+	for _, region := range d.regions {
+		d.signatures = append(d.signatures, signRegion(img, region))
+	}
+	d.age = 0
+	d.present, d.score, d.info = present, score, info
+
+	return present, score, info, nil
+}
+
+// Reset discards the cached decision so the next frame is fully evaluated.
+func (d *FrameDetector) Reset() {
+	d.valid = false
+}
+
+// Stats reports how many frames reused the cached decision (hits) and how
+// many required a full detection (misses).
+func (d *FrameDetector) Stats() (hits, misses int) {
+	return d.hits, d.misses
+}
+
+func (d *FrameDetector) expired() bool {
+	return d.RefreshInterval > 0 && d.age+1 >= d.RefreshInterval
+}
+
+// unchanged reports whether every region signed at the last detection
+// still matches its signature in img.
+func (d *FrameDetector) unchanged(img image.Image) bool {
+	for i, region := range d.regions {
+		if signatureDistance(signRegion(img, region), d.signatures[i]) > d.SceneChangeThreshold {
+			return false
+		}
+	}
+	return true
+}
+
+// signedRegions returns the areas a detection over bounds depends on: the
+// placement of cfg at each corner detection tries and, when present, the
+// detected position, which may be an upscaled placement. Each is padded by
+// a band of context around the logo.
+func signedRegions(bounds image.Rectangle, cfg watermarkConfig, present bool, info Info) []image.Rectangle {
+	corners := []Corner{cfg.Corner}
+	if cfg.Corner == CornerAuto {
+		corners = fixedCorners
+	}
+	var rects []image.Rectangle
+	for _, c := range corners {
+		cornerCfg := cfg
+		cornerCfg.Corner = c
+		if rect, err := calculateWatermarkRect(bounds, cornerCfg); err == nil {
+			rects = append(rects, rect)
+		}
+	}
+	if present && !slices.Contains(rects, info.Position) {
+		rects = append(rects, info.Position)
+	}
+
+	band := max(cfg.LogoSize/3, 8)
+	for i, rect := range rects {
+		rects[i] = rect.Inset(-band).Intersect(bounds)
+	}
+	return rects
+}
+
+// signRegion averages luma over a coarse grid covering region, reading
+// every other row to keep the per-frame cost low.
+func signRegion(img image.Image, region image.Rectangle) cornerSignature {
+	var sums cornerSignature
+	var counts [signatureGrid * signatureGrid]int
+
+	w, h := region.Dx(), region.Dy()
+	if w <= 0 || h <= 0 {
+		return sums
+	}
+
+	row := make([]float64, w)
+	for y := region.Min.Y; y < region.Max.Y; y += 2 {
+		cy := (y - region.Min.Y) * signatureGrid / h
+		LumaGamma.lumaRow(img, y, region.Min.X, row)
+		for i, luma := range row {
+			cell := cy*signatureGrid + i*signatureGrid/w
+			sums[cell] += luma
+			counts[cell]++
+		}
+	}
+
+	for i := range sums {
+		if counts[i] > 0 {
+			sums[i] /= float64(counts[i])
+		}
+	}
+	return sums
+}
+
+func signatureDistance(a, b cornerSignature) float64 {
+	var sum float64
+	for i := range a {
+		sum += math.Abs(a[i] - b[i])
+	}
+	return sum / float64(len(a))
+}
@@ -0,0 +1,72 @@
+package watermark
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestFrameDetectorReusesDecision(t *testing.T) {
+	frame := watermarkedRGBA(t, 640, 480, color.RGBA{R: 30, G: 30, B: 30, A: 255})
+
+	d := NewFrameDetector()
+	for i := 0; i < 5; i++ {
+		present, _, _, err := d.Detect(frame)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !present {
+			t.Fatalf("frame %d: expected watermark", i)
+		}
+	}
+
+	if hits, misses := d.Stats(); hits != 4 || misses != 1 {
+		t.Fatalf("expected 4 hits and 1 miss, got %d hits %d misses", hits, misses)
+	}
+
+	// A scene change in the corner must trigger a fresh detection.
+	clean := image.NewRGBA(frame.Bounds())
+	draw.Draw(clean, clean.Bounds(), &image.Uniform{C: color.RGBA{R: 200, G: 180, B: 20, A: 255}}, image.Point{}, draw.Src)
+
+	present, _, _, err := d.Detect(clean)
+	if err != nil {
+		t.Fatalf("scene change: %v", err)
+	}
+	if present {
+		t.Fatalf("expected no watermark after scene change")
+	}
+	if _, misses := d.Stats(); misses != 2 {
+		t.Fatalf("expected scene change to force detection, misses=%d", misses)
+	}
+}
+
+func TestFrameDetectorSignsDetectedCorner(t *testing.T) {
+	bg := image.NewRGBA(image.Rect(0, 0, 640, 480))
+	draw.Draw(bg, bg.Bounds(), &image.Uniform{C: color.RGBA{R: 30, G: 30, B: 30, A: 255}}, image.Point{}, draw.Src)
+	stamp := GeminiProfile()
+	stamp.Corner = CornerTopLeft
+	frame, err := NewEngine().ApplyWatermarkProfile(bg, stamp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewFrameDetector()
+	d.Profile.Corner = CornerAuto
+	for i := 0; i < 2; i++ {
+		present, _, info, err := d.Detect(frame)
+		if err != nil || !present || info.Corner != CornerTopLeft {
+			t.Fatalf("frame %d: present %v at %v, %v", i, present, info.Corner, err)
+		}
+	}
+
+	// The logo leaving the top-left corner must not reuse the decision,
+	// although the bottom-right corner is unchanged.
+	present, _, _, err := d.Detect(bg)
+	if err != nil || present {
+		t.Fatalf("after the logo left: present %v, %v", present, err)
+	}
+	if hits, misses := d.Stats(); hits != 1 || misses != 2 {
+		t.Fatalf("expected 1 hit and 2 misses, got %d hits %d misses", hits, misses)
+	}
+}
@@ -0,0 +1,118 @@
+package watermark
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// ProcessFS calls ProcessFSWith with zero BatchOptions.
+func ProcessFS(fsys fs.FS, glob string, sink OutputSink) ([]BatchResult, error) {
+	return ProcessFSWith(fsys, glob, sink, BatchOptions{})
+}
+
+// ProcessFSWith processes the regular files of fsys whose slash-separated
+// path or base name matches glob (see path.Match; empty matches every file),
+// in lexical order, so embedded assets, zip archives and other virtual
+// filesystems can be batch-processed without touching the OS filesystem.
+// Files are read one at a time and their outputs handed to sink under
+// OutputName, or else the source path with the extension of the output
+// format; the returned results carry no output bytes, which belong to the
+// sink. Per-file read, processing and sink errors are reported in
+// BatchResult.Err; the error return is reserved for an invalid glob or hint
+// pattern and for walk failures.
+func ProcessFSWith(fsys fs.FS, glob string, sink OutputSink, opts BatchOptions) ([]BatchResult, error) {
+	if _, err := path.Match(glob, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", glob, err)
+	}
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	var results []BatchResult
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() || !matchesGlob(glob, name) {
+			return nil
+		}
+
+		var r BatchResult
+		if data, err := fs.ReadFile(fsys, name); err != nil {
+			r = BatchResult{Name: name, Err: err}
+		} else {
+			r = opts.process(BatchItem{Name: name, Data: data})
+		}
+		if r.Err == nil && len(r.Result.Output) > 0 {
+			output := r.Result.Output
+			r.Result.Output = nil
+			if err := sink.Write(sinkName(r), bytes.NewReader(output), r.Result); err != nil {
+				r.Err = fmt.Errorf("write output: %w", err)
+			}
+		}
+		results = append(results, r)
+		return nil
+	})
+	return results, err
+}
+
+// sinkName returns the name under which ProcessFSWith writes the output of r.
+func sinkName(r BatchResult) string {
+	if r.OutputName != "" {
+		return r.OutputName
+	}
+	return strings.TrimSuffix(r.Name, path.Ext(r.Name)) + "." + r.Result.Format
+}
+
+// matchesGlob reports whether glob matches the full path or the base name of
+// name. An empty glob matches everything.
+func matchesGlob(glob, name string) bool {
+	if glob == "" {
+		return true
+	}
+	if ok, _ := path.Match(glob, name); ok {
+		return true
+	}
+	ok, _ := path.Match(glob, path.Base(name))
+	return ok
+}
+
+// HTTPFS returns hfs as an fs.FS, for use with ProcessFS. It is the inverse
+// of http.FS: names are opened as "/" + name, and directories are listed
+// through http.File.Readdir.
+func HTTPFS(hfs http.FileSystem) fs.FS {
+	return httpFS{hfs}
+}
+
+type httpFS struct {
+	hfs http.FileSystem
+}
+
+func (h httpFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	f, err := h.hfs.Open("/" + name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return httpFile{f}, nil
+}
+
+// httpFile adds fs.ReadDirFile to an http.File.
+type httpFile struct {
+	http.File
+}
+
+func (f httpFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	infos, err := f.Readdir(n)
+	entries := make([]fs.DirEntry, len(infos))
+	for i, info := range infos {
+		entries[i] = fs.FileInfoToDirEntry(info)
+	}
+	return entries, err
+}
@@ -0,0 +1,98 @@
+package watermark
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestProcessFS(t *testing.T) {
+	var buf bytes.Buffer
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{R: 20, G: 20, B: 60, A: 255})
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	fsys := fstest.MapFS{
+		"a.png":        {Data: buf.Bytes()},
+		"sub/b.png":    {Data: buf.Bytes()},
+		"sub/bad.png":  {Data: []byte("not an image")},
+		"sub/note.txt": {Data: []byte("skip me")},
+	}
+
+	var seen []string
+	sink := OutputSinkFunc(func(name string, r io.Reader, meta Result) error {
+		seen = append(seen, name)
+		if name == "sub/b.png" {
+			return errors.New("disk full")
+		}
+		return nil
+	})
+	results, err := ProcessFS(fsys, "*.png", sink)
+	if err != nil {
+		t.Fatalf("ProcessFS: %v", err)
+	}
+	want := []string{"a.png", "sub/b.png", "sub/bad.png"}
+	if len(results) != len(want) || len(seen) != 2 || seen[0] != "a.png" || seen[1] != "sub/b.png" {
+		t.Fatalf("got results %v, sink saw %v", results, seen)
+	}
+	for i, r := range results {
+		if r.Name != want[i] {
+			t.Fatalf("result %d is %q, want %q", i, r.Name, want[i])
+		}
+		if r.Result.Output != nil {
+			t.Fatalf("%s: output bytes kept in results", r.Name)
+		}
+	}
+	if r := results[0]; r.Err != nil || !r.Result.Present {
+		t.Fatalf("a.png: %+v", r)
+	}
+	if results[1].Err == nil || results[2].Err == nil {
+		t.Fatalf("expected sink and decode errors, got %v and %v", results[1].Err, results[2].Err)
+	}
+
+	if _, err := ProcessFS(fsys, "[", sink); err == nil {
+		t.Fatalf("expected error for malformed glob")
+	}
+}
+
+func TestProcessFSHTTPFileSystem(t *testing.T) {
+	var buf bytes.Buffer
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{R: 20, G: 20, B: 60, A: 255})
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	src := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(src, "nested"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "nested", "a.png"), buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := fstest.TestFS(HTTPFS(http.Dir(src)), "nested/a.png"); err != nil {
+		t.Fatal(err)
+	}
+
+	out := t.TempDir()
+	results, err := ProcessFSWith(HTTPFS(http.Dir(src)), "", DirSink(out), BatchOptions{Options: Options{Output: OutputJPEG}})
+	if err != nil {
+		t.Fatalf("ProcessFSWith: %v", err)
+	}
+	if len(results) != 1 || results[0].Name != "nested/a.png" || results[0].Err != nil {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "nested", "a.jpeg"))
+	if err != nil {
+		t.Fatalf("sink output: %v", err)
+	}
+	if _, format, err := DecodeImageBytes(data); err != nil || format != "jpeg" {
+		t.Fatalf("sink output decodes as %q: %v", format, err)
+	}
+}
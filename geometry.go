@@ -0,0 +1,37 @@
+package watermark
+
+import (
+	"errors"
+	"fmt"
+	"image"
+)
+
+// ErrOutOfBounds is wrapped by GeometryError so callers can test for the
+// geometry failure with errors.Is.
+var ErrOutOfBounds = errors.New("watermark rectangle out of bounds")
+
+// GeometryError reports that the expected watermark rectangle does not fit
+// inside the image, which happens for images smaller than the logo plus its
+// margins. Nearest is the closest in-bounds placement of the same size, or
+// the empty rectangle when the logo is larger than the image itself, so UIs
+// can explain the situation or offer a manual position.
+type GeometryError struct {
+	Rect    image.Rectangle
+	Bounds  image.Rectangle
+	Nearest image.Rectangle
+}
+
+func (e *GeometryError) Error() string {
+	return fmt.Sprintf("watermark rectangle %v out of bounds %v", e.Rect, e.Bounds)
+}
+
+// Unwrap lets errors.Is(err, ErrOutOfBounds) match.
+func (e *GeometryError) Unwrap() error {
+	return ErrOutOfBounds
+}
+
+// Fits reports whether a watermark of the expected size fits anywhere in
+// the image.
+func (e *GeometryError) Fits() bool {
+	return !e.Nearest.Empty()
+}
@@ -0,0 +1,188 @@
+package watermark
+
+import (
+	"errors"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+func TestGeometryErrorNearestPlacement(t *testing.T) {
+	cases := []struct {
+		name        string
+		width       int
+		height      int
+		wantNearest image.Rectangle
+	}{
+		// 48px logo with 32px margins needs 80px; 60px fits the logo only.
+		{name: "shifted", width: 60, height: 100, wantNearest: image.Rect(0, 20, 48, 68)},
+		{name: "too small", width: 40, height: 40, wantNearest: image.Rectangle{}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			img := image.NewRGBA(image.Rect(0, 0, tc.width, tc.height))
+
+			_, err := NewEngine().RemoveWatermark(img)
+			if !errors.Is(err, ErrOutOfBounds) {
+				t.Fatalf("expected ErrOutOfBounds, got %v", err)
+			}
+
+			var ge *GeometryError
+			if !errors.As(err, &ge) {
+				t.Fatalf("expected *GeometryError, got %T", err)
+			}
+			if ge.Nearest != tc.wantNearest {
+				t.Fatalf("nearest %v, want %v", ge.Nearest, tc.wantNearest)
+			}
+			if ge.Fits() != !tc.wantNearest.Empty() {
+				t.Fatalf("Fits() = %v", ge.Fits())
+			}
+		})
+	}
+}
+
+// boundaryLength returns a length around need: just too small, exact, just
+// large enough plus one, or anything up to twice need.
+func boundaryLength(rng *rand.Rand, need int) int {
+	switch rng.Intn(4) {
+	case 0:
+		return max(1, need-1)
+	case 1:
+		return need
+	case 2:
+		return need + 1
+	default:
+		return 1 + rng.Intn(2*need+1)
+	}
+}
+
+func TestCalculateWatermarkRectProperties(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		cfg := watermarkConfig{
+			LogoSize:     1 + rng.Intn(100),
+			MarginRight:  rng.Intn(80),
+			MarginBottom: rng.Intn(80),
+			Corner:       fixedCorners[rng.Intn(len(fixedCorners))],
+		}
+		w := boundaryLength(rng, cfg.LogoSize+cfg.MarginRight)
+		h := boundaryLength(rng, cfg.LogoSize+cfg.MarginBottom)
+		origin := image.Pt(rng.Intn(1001)-500, rng.Intn(1001)-500)
+		bounds := image.Rectangle{Min: origin, Max: origin.Add(image.Pt(w, h))}
+
+		rect, err := calculateWatermarkRect(bounds, cfg)
+		fits := w >= cfg.LogoSize+cfg.MarginRight && h >= cfg.LogoSize+cfg.MarginBottom
+		if fits != (err == nil) {
+			t.Fatalf("%+v in %v: fits %v, err %v", cfg, bounds, fits, err)
+		}
+
+		if err != nil {
+			var ge *GeometryError
+			if !errors.As(err, &ge) || !errors.Is(err, ErrOutOfBounds) {
+				t.Fatalf("%+v in %v: unexpected error %v", cfg, bounds, err)
+			}
+			logoFits := cfg.LogoSize <= w && cfg.LogoSize <= h
+			if ge.Fits() != logoFits {
+				t.Fatalf("%+v in %v: Fits() = %v, want %v", cfg, bounds, ge.Fits(), logoFits)
+			}
+			if logoFits && (!ge.Nearest.In(bounds) || ge.Nearest.Size() != ge.Rect.Size()) {
+				t.Fatalf("%+v in %v: nearest %v", cfg, bounds, ge.Nearest)
+			}
+			continue
+		}
+
+		if !rect.In(bounds) || rect.Dx() != cfg.LogoSize || rect.Dy() != cfg.LogoSize {
+			t.Fatalf("%+v in %v: rect %v", cfg, bounds, rect)
+		}
+		dx, dy := bounds.Max.X-rect.Max.X, bounds.Max.Y-rect.Max.Y
+		if cfg.Corner == CornerBottomLeft || cfg.Corner == CornerTopLeft {
+			dx = rect.Min.X - bounds.Min.X
+		}
+		if cfg.Corner == CornerTopRight || cfg.Corner == CornerTopLeft {
+			dy = rect.Min.Y - bounds.Min.Y
+		}
+		if dx != cfg.MarginRight || dy != cfg.MarginBottom {
+			t.Fatalf("%+v in %v: rect %v has margins %d,%d", cfg, bounds, rect, dx, dy)
+		}
+	}
+}
+
+func TestApplyReverseAlphaStaysInRect(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for i := 0; i < 500; i++ {
+		cfg := watermarkConfig{
+			LogoSize:     1 + rng.Intn(40),
+			MarginRight:  rng.Intn(20),
+			MarginBottom: rng.Intn(20),
+			Corner:       fixedCorners[rng.Intn(len(fixedCorners))],
+		}
+		w := cfg.LogoSize + cfg.MarginRight + rng.Intn(3)
+		h := cfg.LogoSize + cfg.MarginBottom + rng.Intn(3)
+		origin := image.Pt(rng.Intn(201)-100, rng.Intn(201)-100)
+		img := image.NewRGBA(image.Rectangle{Min: origin, Max: origin.Add(image.Pt(w, h))})
+		rng.Read(img.Pix)
+		before := append([]byte(nil), img.Pix...)
+
+		rect, err := calculateWatermarkRect(img.Bounds(), cfg)
+		if err != nil {
+			t.Fatalf("%+v in %v: %v", cfg, img.Bounds(), err)
+		}
+		alpha := make([]float32, cfg.LogoSize*cfg.LogoSize)
+		for j := range alpha {
+			if rng.Intn(4) > 0 {
+				alpha[j] = rng.Float32()
+			}
+		}
+		applyReverseAlpha(img, alpha, rect, whiteLogo, RoundHalfUp, 1)
+
+		for y := img.Rect.Min.Y; y < img.Rect.Max.Y; y++ {
+			for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+				off := img.PixOffset(x, y)
+				p := image.Pt(x, y)
+				untouched := !p.In(rect)
+				if !untouched {
+					untouched = alpha[(y-rect.Min.Y)*cfg.LogoSize+(x-rect.Min.X)] < alphaThreshold
+				}
+				if img.Pix[off+3] != before[off+3] || untouched && string(img.Pix[off:off+4]) != string(before[off:off+4]) {
+					t.Fatalf("%+v in %v: pixel %v changed outside the blend", cfg, img.Bounds(), p)
+				}
+			}
+		}
+	}
+}
+
+func TestRemoveWatermarkAtFitBoundary(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	// 48px logo with 32px margins up to 1024px, 96px with 64px margins above.
+	for _, need := range []int{80, 1025} {
+		for _, d := range []int{-1, 0, 1} {
+			n := need + d
+			origin := image.Pt(rng.Intn(201)-100, rng.Intn(201)-100)
+			img := image.NewRGBA(image.Rectangle{Min: origin, Max: origin.Add(image.Pt(n, n))})
+			for i := range img.Pix {
+				img.Pix[i] = 128
+			}
+
+			cleaned, err := NewEngine().RemoveWatermark(img)
+			if need == 80 && d < 0 {
+				if !errors.Is(err, ErrOutOfBounds) {
+					t.Fatalf("%v: expected ErrOutOfBounds, got %v", img.Bounds(), err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("%v: %v", img.Bounds(), err)
+			}
+			if cleaned.Bounds() != img.Bounds() {
+				t.Fatalf("cleaned bounds %v, want %v", cleaned.Bounds(), img.Bounds())
+			}
+			rect := WatermarkInfo(n, n).Position.Add(origin)
+			for _, p := range []image.Point{img.Rect.Min, img.Rect.Max.Sub(image.Pt(1, 1)), rect.Min.Sub(image.Pt(1, 1)), rect.Max} {
+				if p.In(img.Rect) && cleaned.RGBAAt(p.X, p.Y) != img.RGBAAt(p.X, p.Y) {
+					t.Fatalf("%v: pixel %v outside %v changed", img.Bounds(), p, rect)
+				}
+			}
+		}
+	}
+}
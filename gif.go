@@ -0,0 +1,160 @@
+package watermark
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+)
+
+// processGIF removes the watermark from every frame of an animated GIF.
+// Detection runs on the composited canvas so partial frames are judged the
+// way viewers see them, while cleaning only rewrites the frame's own pixels,
+// keeping palettes, delays, and disposal methods intact. Cancelling ctx stops
+// processing before the next frame.
+func processGIF(ctx context.Context, g *gif.GIF, opts Options) (Result, error) {
+	engine := Default()
+	detector := NewFrameDetector()
+	detector.Profile = opts.profile()
+	canvas := image.NewRGBA(image.Rect(0, 0, g.Config.Width, g.Config.Height))
+
+	out := &gif.GIF{
+		LoopCount:       g.LoopCount,
+		Config:          g.Config,
+		BackgroundIndex: g.BackgroundIndex,
+	}
+	result := Result{Format: "gif"}
+
+	engine.report(StageBlend, 0)
+	for i, frame := range g.Image {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+
+		var disposal byte
+		if i < len(g.Disposal) {
+			disposal = g.Disposal[i]
+		}
+
+		var previous *image.RGBA
+		if disposal == gif.DisposalPrevious {
+			previous = cloneToRGBA(canvas)
+		}
+		draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+
+		fr := FrameResult{Index: i}
+		cleaned, err := cleanGIFFrame(engine, detector, canvas, frame, &fr)
+		if err != nil {
+			switch opts.FrameErrorPolicy {
+			case FrameSkip:
+				fr.Outcome = FrameSkipped
+			case FrameCopyOriginal:
+				fr.Outcome = FrameCopied
+				cleaned = frame
+			default:
+				return Result{}, fmt.Errorf("frame %d: %w", i, err)
+			}
+			fr.Err = err
+		}
+
+		if fr.Outcome != FrameSkipped {
+			out.Image = append(out.Image, cleaned)
+			if i < len(g.Delay) {
+				out.Delay = append(out.Delay, g.Delay[i])
+			}
+			if i < len(g.Disposal) {
+				out.Disposal = append(out.Disposal, disposal)
+			}
+		}
+
+		result.addFrame(fr)
+		engine.report(StageBlend, float64(i+1)*100/float64(len(g.Image)))
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(canvas, frame.Bounds(), image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			canvas = previous
+		}
+	}
+
+	if !result.Present {
+		return result, nil
+	}
+	if len(out.Image) == 0 {
+		return Result{}, fmt.Errorf("all %d frames were skipped", len(g.Image))
+	}
+
+	engine.report(StageEncode, 0)
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, out); err != nil {
+		return Result{}, fmt.Errorf("encode gif: %w", err)
+	}
+	result.Output = buf.Bytes()
+	engine.report(StageEncode, 100)
+
+	return result, nil
+}
+
+// cleanGIFFrame detects the watermark on the composited canvas, reusing the
+// previous decision while the corner is unchanged, and, when present, reverse
+// blends the frame pixels that overlap the watermark. The cleaned colors are
+// mapped back onto the frame palette.
+func cleanGIFFrame(e *Engine, d *FrameDetector, canvas *image.RGBA, frame *image.Paletted, fr *FrameResult) (*image.Paletted, error) {
+	present, score, info, err := d.Detect(canvas)
+	if err != nil {
+		return nil, err
+	}
+
+	fr.Present, fr.Score, fr.Info = present, score, info
+	if !present {
+		fr.Outcome = FrameUnchanged
+		return frame, nil
+	}
+
+	rect := info.Position
+	alphaMap, logo, err := e.blendParams(d.Profile, canvas.Bounds().Dx(), canvas.Bounds().Dy(), info)
+	if err != nil {
+		return nil, err
+	}
+
+	overlap := rect.Intersect(frame.Bounds())
+
+	cleaned := &image.Paletted{
+		Pix:     append([]uint8(nil), frame.Pix...),
+		Stride:  frame.Stride,
+		Rect:    frame.Rect,
+		Palette: frame.Palette,
+	}
+
+	for y := overlap.Min.Y; y < overlap.Max.Y; y++ {
+		for x := overlap.Min.X; x < overlap.Max.X; x++ {
+			alpha := float64(alphaMap[(y-rect.Min.Y)*rect.Dx()+(x-rect.Min.X)])
+			if alpha < alphaThreshold {
+				continue
+			}
+			if alpha > maxAlpha {
+				alpha = maxAlpha
+			}
+
+			offset := cleaned.PixOffset(x, y)
+			c := color.RGBAModel.Convert(frame.Palette[frame.Pix[offset]]).(color.RGBA)
+			if c.A != 0xff {
+				// Transparent pixels show the previous frame, which has
+				// already been cleaned.
+				continue
+			}
+
+			c.R = reverseBlend(c.R, alpha, logo[0], e.rounding)
+			c.G = reverseBlend(c.G, alpha, logo[1], e.rounding)
+			c.B = reverseBlend(c.B, alpha, logo[2], e.rounding)
+			cleaned.Pix[offset] = uint8(frame.Palette.Index(c))
+		}
+	}
+
+	fr.Outcome = FrameCleaned
+	return cleaned, nil
+}
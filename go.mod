@@ -1,5 +1,22 @@
 module github.com/gcslaoli/gemini-watermark-remover-go
 
-go 1.22
+go 1.22.0
 
-require golang.org/x/image v0.19.0
+require (
+	fyne.io/systray v1.11.0
+	github.com/expr-lang/expr v1.17.8
+	github.com/gen2brain/webp v0.5.2
+	golang.org/x/image v0.19.0
+	golang.org/x/sys v0.29.0
+	google.golang.org/grpc v1.71.0
+	google.golang.org/protobuf v1.36.6
+)
+
+require (
+	github.com/ebitengine/purego v0.8.1 // indirect
+	github.com/godbus/dbus/v5 v5.1.0 // indirect
+	github.com/tetratelabs/wazero v1.9.0 // indirect
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
+)
@@ -0,0 +1,128 @@
+package watermark
+
+import (
+	"image"
+	"math"
+)
+
+// HaloCleanup configures the post-processor that suppresses the halo JPEG
+// compression leaves around a removed logo. Reverse blending assumes the
+// logo was blended losslessly; on a recompressed image it divides the
+// compression error, ringing strongest along the logo's edges, by 1 - alpha,
+// so the cleaned area keeps a faint outline of the logo. The post-processor
+// smooths each cleaned pixel toward the average of its neighbours, weighted
+// by how little reverse blending amplified their error, and feathers the
+// effect by the logo's opacity: pixels under its faint rim barely change.
+type HaloCleanup struct {
+	// Strength, from 0 to 1, is how far the most amplified pixels move to
+	// their smoothed value. Zero means DefaultHaloStrength.
+	Strength float64
+	// Radius is the half-width of the smoothing window in pixels. Zero
+	// means DefaultHaloRadius.
+	Radius int
+	// AllInputs runs the cleanup on every image. By default it only runs on
+	// images that decode to *image.YCbCr, as JPEG and lossy WebP do, since
+	// lossless inputs have no compression error to amplify.
+	AllInputs bool
+}
+
+const (
+	// DefaultHaloStrength is the HaloCleanup strength used when Strength is
+	// zero.
+	DefaultHaloStrength = 0.8
+	// DefaultHaloRadius is the HaloCleanup radius used when Radius is zero.
+	DefaultHaloRadius = 2
+	// haloFullAlpha is the logo opacity from which pixels get the full
+	// strength; below it the strength ramps down linearly.
+	haloFullAlpha = 0.25
+)
+
+// SetHaloCleanup enables the halo cleanup post-processor, or disables it
+// when h is nil. SetHaloCleanup must not be called concurrently with
+// removal.
+func (e *Engine) SetHaloCleanup(h *HaloCleanup) {
+	if h == nil {
+		e.halo = nil
+		return
+	}
+	copied := *h
+	e.halo = &copied
+}
+
+// applies reports whether the cleanup runs on img; h may be nil.
+func (h *HaloCleanup) applies(img image.Image) bool {
+	if h == nil {
+		return false
+	}
+	_, lossy := img.(*image.YCbCr)
+	return lossy || h.AllInputs
+}
+
+// suppressHalo smooths the reverse blended pixels of img inside rect, where
+// alphaMap gives the logo opacity.
+func (h *HaloCleanup) suppressHalo(img *image.RGBA, alphaMap []float32, rect image.Rectangle) {
+	strength := h.Strength
+	if strength <= 0 {
+		strength = DefaultHaloStrength
+	}
+	strength = min(strength, 1)
+	radius := h.Radius
+	if radius <= 0 {
+		radius = DefaultHaloRadius
+	}
+
+	// Read from a copy of the area the windows reach, with the confidence
+	// of every pixel: the inverse square of its error amplification.
+	area := rect.Inset(-radius).Intersect(img.Bounds())
+	src := image.NewRGBA(area)
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		copy(src.Pix[src.PixOffset(area.Min.X, y):][:4*area.Dx()], img.Pix[img.PixOffset(area.Min.X, y):])
+	}
+	alphaAt := func(x, y int) float64 {
+		if !image.Pt(x, y).In(rect) {
+			return 0
+		}
+		return math.Min(float64(alphaMap[(y-rect.Min.Y)*rect.Dx()+x-rect.Min.X]), maxAlpha)
+	}
+	confidence := make([]float64, area.Dx()*area.Dy())
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			keep := 1 - alphaAt(x, y)
+			confidence[(y-area.Min.Y)*area.Dx()+x-area.Min.X] = keep * keep
+		}
+	}
+
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			alpha := alphaAt(x, y)
+			if alpha < alphaThreshold {
+				continue
+			}
+			t := strength * math.Min(1, alpha/haloFullAlpha)
+
+			var sum [3]float64
+			var weight float64
+			window := image.Rect(x-radius, y-radius, x+radius+1, y+radius+1).Intersect(area)
+			for wy := window.Min.Y; wy < window.Max.Y; wy++ {
+				for wx := window.Min.X; wx < window.Max.X; wx++ {
+					w := confidence[(wy-area.Min.Y)*area.Dx()+wx-area.Min.X]
+					p := src.Pix[src.PixOffset(wx, wy):]
+					sum[0] += w * float64(p[0])
+					sum[1] += w * float64(p[1])
+					sum[2] += w * float64(p[2])
+					weight += w
+				}
+			}
+			if weight <= 0 {
+				continue
+			}
+
+			p := img.Pix[img.PixOffset(x, y):][:3]
+			for c := range p {
+				v := float64(p[c])
+				v += t * (sum[c]/weight - v)
+				p[c] = uint8(math.Round(math.Max(0, math.Min(255, v))))
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package watermark
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+
+	"github.com/gcslaoli/gemini-watermark-remover-go/imagecmp"
+)
+
+func TestHaloCleanup(t *testing.T) {
+	clean := texturedRGBA(1024, 1024, 0, 0, 0)
+	marked := stampWatermark(t, texturedRGBA(1024, 1024, 0, 0, 0))
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, marked, &jpeg.Options{Quality: 75}); err != nil {
+		t.Fatal(err)
+	}
+	compressed, err := jpeg.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	region := image.Rect(944, 944, 992, 992)
+	psnr := func(e *Engine, img image.Image) float64 {
+		t.Helper()
+		out, err := e.RemoveWatermark(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := ComparePSNR(clean.SubImage(region), out.SubImage(region))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	plain := psnr(NewEngine(), compressed)
+	cleaned := psnr(NewEngine(WithHaloCleanup(&HaloCleanup{})), compressed)
+	if cleaned < plain+1 {
+		t.Errorf("watermark region PSNR %.2f dB with halo cleanup, %.2f dB without", cleaned, plain)
+	}
+
+	// Lossless inputs are left alone unless AllInputs is set.
+	want, err := NewEngine().RemoveWatermark(marked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewEngine(WithHaloCleanup(&HaloCleanup{})).RemoveWatermark(marked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := imagecmp.Diff(want, got, imagecmp.Options{}); err != nil {
+		t.Errorf("lossless input changed: %v", err)
+	}
+	all, err := NewEngine(WithHaloCleanup(&HaloCleanup{AllInputs: true})).RemoveWatermark(marked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imagecmp.Equal(want, all, imagecmp.Options{}) {
+		t.Error("AllInputs did not run the cleanup")
+	}
+	if err := imagecmp.Diff(want.SubImage(image.Rect(0, 0, 900, 900)), all.SubImage(image.Rect(0, 0, 900, 900)), imagecmp.Options{}); err != nil {
+		t.Errorf("cleanup reached outside the watermark: %v", err)
+	}
+}
@@ -0,0 +1,4 @@
+// Package imagecmp compares decoded images for golden tests, with tolerances
+// for the small differences encoders and decoders introduce: a per-channel
+// delta, and optionally ignoring alpha.
+package imagecmp
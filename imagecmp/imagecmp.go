@@ -0,0 +1,83 @@
+package imagecmp
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+)
+
+// Options sets how far two images may differ and still compare equal. The
+// zero value requires identical pixels.
+type Options struct {
+	// MaxDelta is the largest difference allowed in any 8-bit channel of a
+	// pixel, compared without alpha premultiplication.
+	MaxDelta uint8
+	// IgnoreAlpha compares only the color channels.
+	IgnoreAlpha bool
+}
+
+// Equal reports whether a and b have the same bounds and every pixel is
+// within the tolerances of o.
+func Equal(a, b image.Image, o Options) bool {
+	return Diff(a, b, o) == nil
+}
+
+// Diff returns nil when a and b compare equal under o, and otherwise an
+// error describing the bounds mismatch or the first differing pixel, the
+// number of differing pixels and the largest channel delta.
+func Diff(a, b image.Image, o Options) error {
+	bounds := a.Bounds()
+	if !bounds.Eq(b.Bounds()) {
+		return fmt.Errorf("image bounds differ: %v vs %v", bounds, b.Bounds())
+	}
+
+	an, bn := ToNRGBA(a), ToNRGBA(b)
+	channels := 4
+	if o.IgnoreAlpha {
+		channels = 3
+	}
+
+	var first image.Point
+	count, worst := 0, 0
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			// Sub-images keep the stride of their parent, so each image
+			// needs its own offset.
+			ai, bi := an.PixOffset(x, y), bn.PixOffset(x, y)
+			moved := false
+			for c := 0; c < channels; c++ {
+				d := int(an.Pix[ai+c]) - int(bn.Pix[bi+c])
+				if d < 0 {
+					d = -d
+				}
+				worst = max(worst, d)
+				if d > int(o.MaxDelta) {
+					moved = true
+				}
+			}
+			if moved {
+				if count == 0 {
+					first = image.Pt(x, y)
+				}
+				count++
+			}
+		}
+	}
+	if count == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d pixels differ by more than %d (max delta %d), first at %v: %v vs %v",
+		count, o.MaxDelta, worst, first, an.NRGBAAt(first.X, first.Y), bn.NRGBAAt(first.X, first.Y))
+}
+
+// ToNRGBA returns img as *image.NRGBA with the same bounds. An *image.NRGBA
+// is returned as is; anything else is copied.
+func ToNRGBA(img image.Image) *image.NRGBA {
+	if n, ok := img.(*image.NRGBA); ok {
+		return n
+	}
+	bounds := img.Bounds()
+	out := image.NewNRGBA(bounds)
+	draw.Draw(out, bounds, img, bounds.Min, draw.Src)
+	return out
+}
@@ -0,0 +1,81 @@
+package imagecmp
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := image.NewNRGBA(image.Rect(0, 0, 4, 3))
+	for i := range a.Pix {
+		a.Pix[i] = 100
+	}
+	b := image.NewNRGBA(a.Bounds())
+	copy(b.Pix, a.Pix)
+	b.SetNRGBA(2, 1, color.NRGBA{R: 103, G: 100, B: 100, A: 40})
+
+	if Equal(a, b, Options{}) {
+		t.Fatalf("exact comparison ignored a changed pixel")
+	}
+	if Equal(a, b, Options{MaxDelta: 3}) {
+		t.Fatalf("alpha difference ignored without IgnoreAlpha")
+	}
+	if !Equal(a, b, Options{MaxDelta: 3, IgnoreAlpha: true}) {
+		t.Fatalf("difference within tolerance reported")
+	}
+
+	err := Diff(a, b, Options{MaxDelta: 2, IgnoreAlpha: true})
+	if err == nil || !strings.Contains(err.Error(), "1 pixels") || !strings.Contains(err.Error(), "(2,1)") {
+		t.Fatalf("unexpected diff %v", err)
+	}
+	if err := Diff(a, image.NewNRGBA(image.Rect(0, 0, 3, 4)), Options{}); err == nil {
+		t.Fatalf("expected a bounds mismatch")
+	}
+}
+
+func TestEqualAcrossColorModels(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 2, 2))
+	rgba := image.NewRGBA(gray.Bounds())
+	for i := range gray.Pix {
+		gray.Pix[i] = uint8(60 * i)
+	}
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			v := gray.GrayAt(x, y).Y
+			rgba.SetRGBA(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	if err := Diff(gray, rgba, Options{}); err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+}
+
+func TestDiffSubImage(t *testing.T) {
+	parent := image.NewNRGBA(image.Rect(0, 0, 8, 8))
+	for i := range parent.Pix {
+		parent.Pix[i] = uint8(i)
+	}
+	region := image.Rect(2, 3, 6, 7)
+	sub := parent.SubImage(region)
+
+	// A copy with its own, narrower stride.
+	copied := image.NewNRGBA(region)
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			copied.SetNRGBA(x, y, parent.NRGBAAt(x, y))
+		}
+	}
+	if err := Diff(sub, copied, Options{}); err != nil {
+		t.Fatalf("sub-image vs copy: %v", err)
+	}
+	if err := Diff(copied, sub, Options{}); err != nil {
+		t.Fatalf("copy vs sub-image: %v", err)
+	}
+
+	copied.SetNRGBA(5, 6, color.NRGBA{})
+	if err := Diff(sub, copied, Options{}); err == nil || !strings.Contains(err.Error(), "(5,6)") {
+		t.Fatalf("unexpected diff %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package watermark
+
+import (
+	"image"
+	"math"
+)
+
+// ClipInpaint configures the inpainting fallback for clipped pixels. Where
+// the watermarked image was edited or compressed until a pixel under the
+// logo hit 0 or 255, the channel's information is gone: reverse blending
+// computes an original outside 0-255, clamped and off by as much as the
+// clipping, or, for white saturated under the white logo, exactly 255. The
+// fallback takes the watermark pixels with a channel more than a few levels
+// outside 0-255 or saturated under a visible part of the logo, the ones
+// WarnClipped counts, and refills them from the cleaned pixels around them:
+// first peeling the clipped area from its border inward, each pixel taking
+// the mean of its known neighbours, then smoothing the fill by diffusion,
+// each pixel repeatedly replaced by the mean of its four neighbours.
+type ClipInpaint struct {
+	// Iterations is the number of diffusion passes after the initial fill.
+	// Zero means DefaultInpaintIterations.
+	Iterations int
+}
+
+// DefaultInpaintIterations is the number of ClipInpaint diffusion passes
+// used when Iterations is zero.
+const DefaultInpaintIterations = 50
+
+// SetClipInpaint enables the inpainting fallback for clipped pixels, or
+// disables it when c is nil. SetClipInpaint must not be called concurrently
+// with removal.
+func (e *Engine) SetClipInpaint(c *ClipInpaint) {
+	if c == nil {
+		e.inpaint = nil
+		return
+	}
+	copied := *c
+	e.inpaint = &copied
+}
+
+// inpaintClipped refills the pixels of img inside rect whose watermarked
+// values in src clipped, see clippedMask. c may be nil.
+func (c *ClipInpaint) inpaintClipped(img *image.RGBA, src image.Image, alphaMap []float32, rect image.Rectangle, logo [3]float64) {
+	if c == nil {
+		return
+	}
+	clipped := clippedMask(src, alphaMap, rect, logo)
+	missing := 0
+	for _, m := range clipped {
+		if m {
+			missing++
+		}
+	}
+	if missing == 0 {
+		return
+	}
+	iterations := c.Iterations
+	if iterations <= 0 {
+		iterations = DefaultInpaintIterations
+	}
+
+	// Work on the rectangle and a one-pixel border of unclipped pixels
+	// around it, in floating point.
+	area := rect.Inset(-1).Intersect(img.Bounds())
+	w, h := area.Dx(), area.Dy()
+	vals := make([][3]float64, w*h)
+	unknown := make([]bool, w*h)
+	for y := 0; y < h; y++ {
+		p := img.Pix[img.PixOffset(area.Min.X, area.Min.Y+y):][:4*w]
+		for x := 0; x < w; x++ {
+			vals[y*w+x] = [3]float64{float64(p[4*x]), float64(p[4*x+1]), float64(p[4*x+2])}
+			if pt := image.Pt(area.Min.X+x, area.Min.Y+y); pt.In(rect) {
+				unknown[y*w+x] = clipped[(pt.Y-rect.Min.Y)*rect.Dx()+pt.X-rect.Min.X]
+			}
+		}
+	}
+	fill := make([]bool, w*h)
+	copy(fill, unknown)
+
+	// Peel: every pass fills the unknown pixels next to a known one.
+	type filled struct {
+		i int
+		v [3]float64
+	}
+	var ring []filled
+	for missing > 0 {
+		ring = ring[:0]
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if !unknown[y*w+x] {
+					continue
+				}
+				var sum [3]float64
+				n := 0
+				for ny := max(y-1, 0); ny <= min(y+1, h-1); ny++ {
+					for nx := max(x-1, 0); nx <= min(x+1, w-1); nx++ {
+						if j := ny*w + nx; !unknown[j] {
+							for ch := range sum {
+								sum[ch] += vals[j][ch]
+							}
+							n++
+						}
+					}
+				}
+				if n > 0 {
+					ring = append(ring, filled{y*w + x, [3]float64{sum[0] / float64(n), sum[1] / float64(n), sum[2] / float64(n)}})
+				}
+			}
+		}
+		if len(ring) == 0 {
+			// Nothing unclipped to fill from.
+			return
+		}
+		for _, f := range ring {
+			vals[f.i], unknown[f.i] = f.v, false
+		}
+		missing -= len(ring)
+	}
+
+	// Diffuse in place (Gauss-Seidel), so the fill follows the gradients
+	// along its border instead of the peeling order.
+	for it := 0; it < iterations; it++ {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				if !fill[y*w+x] {
+					continue
+				}
+				var sum [3]float64
+				n := 0
+				for _, d := range [4]image.Point{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+					nx, ny := x+d.X, y+d.Y
+					if nx < 0 || ny < 0 || nx >= w || ny >= h {
+						continue
+					}
+					for ch := range sum {
+						sum[ch] += vals[ny*w+nx][ch]
+					}
+					n++
+				}
+				for ch := range sum {
+					vals[y*w+x][ch] = sum[ch] / float64(n)
+				}
+			}
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		p := img.Pix[img.PixOffset(area.Min.X, area.Min.Y+y):][:4*w]
+		for x := 0; x < w; x++ {
+			if !fill[y*w+x] {
+				continue
+			}
+			for ch, v := range vals[y*w+x] {
+				p[4*x+ch] = uint8(math.Round(math.Max(0, math.Min(255, v))))
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package watermark
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestClipInpaint(t *testing.T) {
+	// Crush a patch under the logo to black, or blow it out to white, as a
+	// levels edit would.
+	for _, level := range []uint8{0, 255} {
+		t.Run(fmt.Sprint(level), func(t *testing.T) { testClipInpaint(t, level) })
+	}
+}
+
+func testClipInpaint(t *testing.T, level uint8) {
+	clean := texturedRGBA(1024, 1024, 0, 0, 0)
+	marked, err := NewEngine().ApplyWatermark(texturedRGBA(1024, 1024, 0, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	crushed := image.Rect(962, 962, 974, 974)
+	for y := crushed.Min.Y; y < crushed.Max.Y; y++ {
+		for x := crushed.Min.X; x < crushed.Max.X; x++ {
+			p := marked.Pix[marked.PixOffset(x, y):]
+			p[0], p[1], p[2] = level, level, level
+		}
+	}
+
+	region := image.Rect(944, 944, 992, 992)
+	plain, err := NewEngine().RemoveWatermarkAt(marked, region)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inpainted, err := NewEngine(WithClipInpaint(&ClipInpaint{})).RemoveWatermarkAt(marked, region)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := ComparePSNR(clean.SubImage(crushed), plain.SubImage(crushed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := ComparePSNR(clean.SubImage(crushed), inpainted.SubImage(crushed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after < before+10 {
+		t.Errorf("crushed patch PSNR %.2f dB inpainted, %.2f dB without", after, before)
+	}
+
+	// Pixels that did not clip keep the plain result.
+	alphaMap, err := detectAlphaMap(region.Dx())
+	if err != nil {
+		t.Fatal(err)
+	}
+	mask := clippedMask(marked, alphaMap, region, whiteLogo)
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		for x := region.Min.X; x < region.Max.X; x++ {
+			if !mask[(y-region.Min.Y)*region.Dx()+x-region.Min.X] && inpainted.RGBAAt(x, y) != plain.RGBAAt(x, y) {
+				t.Fatalf("unclipped pixel (%d,%d) changed: %v vs %v", x, y, inpainted.RGBAAt(x, y), plain.RGBAAt(x, y))
+			}
+		}
+	}
+
+	e := NewEngine(WithClipInpaint(&ClipInpaint{}))
+	info := Info{Size: region.Dx(), Position: region, Corner: CornerBottomRight}
+	var clipped *Warning
+	for _, w := range e.RemovalWarnings(marked, GeminiProfile(), info) {
+		if w.Code == WarnClipped {
+			clipped = &w
+		}
+	}
+	if clipped == nil || !strings.HasSuffix(clipped.Message, ", inpainted") {
+		t.Errorf("clipping warning %v", clipped)
+	}
+}
@@ -0,0 +1,189 @@
+package watermark
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"image"
+)
+
+// Report describes a decoded image alongside its detection outcome, for
+// triaging images that did not match the expected watermark geometry.
+type Report struct {
+	Width      int
+	Height     int
+	Format     string
+	ColorModel string
+	// Orientation is the EXIF orientation tag (1-8), or 0 when the image
+	// carries none. Non-zero values other than 1 mean viewers display the
+	// image rotated or mirrored relative to its stored pixels, while
+	// detection always works on the stored pixels.
+	Orientation int
+
+	Present bool
+	Score   float64
+	// Correlation and Confidence are those of DetectionResult.
+	Correlation float64
+	Confidence  Confidence
+	Info        Info
+	// DetectErr holds the detection failure, such as a *GeometryError for
+	// images too small for the watermark. The report is still filled in.
+	DetectErr error
+}
+
+// InspectBytes decodes data and reports its dimensions, format, color model
+// and EXIF orientation along with the watermark detection result. Only decode
+// failures are returned as errors; detection failures land in DetectErr.
+func InspectBytes(data []byte) (Report, error) {
+	return InspectBytesProfile(data, GeminiProfile())
+}
+
+// InspectBytesProfile runs InspectBytes with detection following profile p.
+func InspectBytesProfile(data []byte, p Profile) (_ Report, err error) {
+	defer recoverPanic("InspectBytes", data, &err)
+	if len(data) == 0 {
+		return Report{}, fmt.Errorf("empty image data")
+	}
+
+	release, err := reserveDecode(context.Background(), data)
+	if err != nil {
+		return Report{}, err
+	}
+	defer release()
+
+	img, format, err := decodeContext(context.Background(), data)
+	if err != nil {
+		return Report{}, err
+	}
+
+	bounds := img.Bounds()
+	r := Report{
+		Width:       bounds.Dx(),
+		Height:      bounds.Dy(),
+		Format:      format,
+		ColorModel:  colorModelName(img),
+		Orientation: exifOrientation(data),
+	}
+	d, err := DetectResult(img, p)
+	r.Present, r.Score, r.Correlation, r.Confidence, r.Info, r.DetectErr = d.Present, d.Score, d.Correlation, d.Confidence, d.Info, err
+
+	return r, nil
+}
+
+// colorModelName returns a short description of the decoded pixel layout.
+func colorModelName(img image.Image) string {
+	switch m := img.(type) {
+	case *image.YCbCr:
+		return "YCbCr " + subsampleName(m.SubsampleRatio)
+	case *image.NYCbCrA:
+		return "NYCbCrA " + subsampleName(m.SubsampleRatio)
+	case *image.Paletted:
+		return fmt.Sprintf("Paletted (%d colors)", len(m.Palette))
+	case *image.RGBA:
+		return "RGBA"
+	case *image.RGBA64:
+		return "RGBA64"
+	case *image.NRGBA:
+		return "NRGBA"
+	case *image.NRGBA64:
+		return "NRGBA64"
+	case *image.Gray:
+		return "Gray"
+	case *image.Gray16:
+		return "Gray16"
+	case *image.CMYK:
+		return "CMYK"
+	default:
+		return fmt.Sprintf("%T", img)
+	}
+}
+
+func subsampleName(r image.YCbCrSubsampleRatio) string {
+	switch r {
+	case image.YCbCrSubsampleRatio444:
+		return "4:4:4"
+	case image.YCbCrSubsampleRatio422:
+		return "4:2:2"
+	case image.YCbCrSubsampleRatio420:
+		return "4:2:0"
+	case image.YCbCrSubsampleRatio440:
+		return "4:4:0"
+	case image.YCbCrSubsampleRatio411:
+		return "4:1:1"
+	case image.YCbCrSubsampleRatio410:
+		return "4:1:0"
+	default:
+		return "unknown"
+	}
+}
+
+// exifOrientation extracts the orientation tag from the EXIF data of a JPEG,
+// PNG or WebP file. It returns 0 for other formats or when the tag is absent.
+func exifOrientation(data []byte) int {
+	return tiffOrientation(ReadMetadata(data).EXIF)
+}
+
+// jpegSegments calls fn with the marker and payload of every metadata segment
+// before the first scan, stopping early when fn returns false. Data that is
+// not a JPEG yields no segments.
+func jpegSegments(data []byte, fn func(marker byte, segment []byte) bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return
+	}
+
+	for pos := 2; pos+4 <= len(data); {
+		if data[pos] != 0xFF {
+			return
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 {
+			// Start of scan or end of image: metadata segments are over.
+			return
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if length < 2 || pos+2+length > len(data) {
+			return
+		}
+		if !fn(marker, data[pos+4:pos+2+length]) {
+			return
+		}
+		pos += 2 + length
+	}
+}
+
+// tiffOrientation reads tag 0x0112 from IFD0 of a TIFF header.
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			v := int(order.Uint16(tiff[entry+8:]))
+			if v < 1 || v > 8 {
+				return 0
+			}
+			return v
+		}
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+package watermark
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+func TestInspectBytesReportsOrientation(t *testing.T) {
+	var buf bytes.Buffer
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{R: 40, G: 60, B: 90, A: 255})
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	data := withExifOrientation(buf.Bytes(), 6)
+
+	r, err := InspectBytes(data)
+	if err != nil {
+		t.Fatalf("InspectBytes: %v", err)
+	}
+	if r.Width != 320 || r.Height != 240 || r.Format != "jpeg" {
+		t.Fatalf("unexpected geometry %dx%d %s", r.Width, r.Height, r.Format)
+	}
+	if r.ColorModel != "YCbCr 4:2:0" || r.Orientation != 6 {
+		t.Fatalf("unexpected color model %q orientation %d", r.ColorModel, r.Orientation)
+	}
+	if !r.Present || r.DetectErr != nil {
+		t.Fatalf("expected detection, got present=%v err=%v", r.Present, r.DetectErr)
+	}
+}
+
+func TestInspectBytesKeepsGeometryError(t *testing.T) {
+	data, err := EncodePNGToBytes(image.NewGray(image.Rect(0, 0, 40, 40)))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	r, err := InspectBytes(data)
+	if err != nil {
+		t.Fatalf("InspectBytes: %v", err)
+	}
+	if r.ColorModel != "Gray" || r.Orientation != 0 {
+		t.Fatalf("unexpected report %+v", r)
+	}
+	if !errors.Is(r.DetectErr, ErrOutOfBounds) {
+		t.Fatalf("expected geometry error, got %v", r.DetectErr)
+	}
+}
+
+// withExifOrientation inserts a minimal big-endian Exif APP1 segment carrying
+// the orientation tag right after the JPEG SOI marker.
+func withExifOrientation(jpg []byte, orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, segment...)
+	out = append(out, payload...)
+	return append(out, jpg[2:]...)
+}
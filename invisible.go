@@ -0,0 +1,251 @@
+package watermark
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/cmplx"
+	"sort"
+)
+
+// InvisibleReport is the result of DetectInvisibleWatermark.
+type InvisibleReport struct {
+	// Suspected reports that the spectrum carries the kind of isolated,
+	// image-independent peaks that invisible watermarks leave.
+	Suspected bool
+	// PeakRatio is the power of the strongest such peak relative to the
+	// median power at the same spatial frequency; photographs typically
+	// stay below 10.
+	PeakRatio float64
+	// Frequency is the location of that peak in cycles per spectrumTile
+	// pixels, horizontal then vertical.
+	Frequency image.Point
+	// Tiles is the number of tiles averaged into the spectrum.
+	Tiles int
+}
+
+const (
+	// spectrumTile is the side of the tiles whose power spectra are
+	// averaged; a power of two for the FFT.
+	spectrumTile = 64
+	// maxSpectrumTiles bounds the analysis time on large images. Tiles are
+	// spread evenly over the image.
+	maxSpectrumTiles = 256
+	// minSpectrumTiles is the fewest tiles that average out the noise of
+	// single-tile spectra.
+	minSpectrumTiles = 16
+	// minSpectrumRadius skips the lowest frequencies, which hold the image
+	// content.
+	minSpectrumRadius = 4
+	// axisBand is the distance from the axes within which bins are skipped:
+	// the straight horizontal and vertical edges of content and tile borders
+	// concentrate there.
+	axisBand = 3
+	// invisiblePeakThreshold is the PeakRatio above which Suspected is set.
+	invisiblePeakThreshold = 16
+)
+
+// DetectInvisibleWatermark looks for residual invisible watermarks, such as
+// SynthID, that survive removal of the visible logo. It averages the power
+// spectra of luma tiles over the image and reports mid and high frequencies
+// that stand far above the other frequencies of the same radius: content
+// spreads its energy over all orientations, while embedded patterns repeat
+// across tiles and pile up at fixed frequencies. Peaks of the 8 x 8 JPEG block
+// grid are ignored.
+//
+// This is a diagnostic heuristic, not a decoder: proprietary schemes are not
+// designed to be found this way, so a negative report does not prove the
+// image is clean, and strongly periodic content (fabric, grids) can raise a
+// false positive.
+func DetectInvisibleWatermark(img image.Image) (InvisibleReport, error) {
+	if img == nil {
+		return InvisibleReport{}, fmt.Errorf("nil image provided")
+	}
+	bounds := img.Bounds()
+	cols, rows := bounds.Dx()/spectrumTile, bounds.Dy()/spectrumTile
+	if cols*rows < minSpectrumTiles {
+		return InvisibleReport{}, fmt.Errorf("image %dx%d is too small for spectrum analysis (%d of %d %dpx tiles)",
+			bounds.Dx(), bounds.Dy(), cols*rows, minSpectrumTiles, spectrumTile)
+	}
+
+	// Pick tiles on an evenly strided grid when there are too many.
+	stride := 1
+	for (cols+stride-1)/stride*((rows+stride-1)/stride) > maxSpectrumTiles {
+		stride++
+	}
+
+	power := make([]float64, spectrumTile*spectrumTile)
+	window := hannWindow(spectrumTile)
+	tile := make([]complex128, spectrumTile*spectrumTile)
+	tilePower := make([]float64, len(tile))
+	report := InvisibleReport{}
+	for ty := 0; ty < rows; ty += stride {
+		for tx := 0; tx < cols; tx += stride {
+			origin := bounds.Min.Add(image.Pt(tx*spectrumTile, ty*spectrumTile))
+			lumaTile(img, origin, window, tile)
+			fft2D(tile, spectrumTile)
+			// Normalize each tile so a few high-contrast tiles cannot
+			// dominate; a watermark is present in all of them.
+			var total float64
+			for i, c := range tile {
+				re, im := real(c), imag(c)
+				p := re*re + im*im
+				tilePower[i] = p
+				total += p
+			}
+			if total == 0 {
+				continue
+			}
+			for i, p := range tilePower {
+				power[i] += p / total
+			}
+			report.Tiles++
+		}
+	}
+
+	report.PeakRatio, report.Frequency = spectrumPeak(power, spectrumTile)
+	report.Suspected = report.PeakRatio > invisiblePeakThreshold
+	return report, nil
+}
+
+// lumaTile fills tile with the windowed, mean-removed luma of the
+// spectrumTile square at origin.
+func lumaTile(img image.Image, origin image.Point, window []float64, tile []complex128) {
+	n := len(window)
+	var mean float64
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			r, g, b, _ := img.At(origin.X+x, origin.Y+y).RGBA()
+			l := LumaGamma.luma(r, g, b)
+			tile[y*n+x] = complex(l, 0)
+			mean += l
+		}
+	}
+	mean /= float64(n * n)
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			tile[y*n+x] = complex((real(tile[y*n+x])-mean)*window[x]*window[y], 0)
+		}
+	}
+}
+
+// spectrumPeak returns the largest ratio of a bin's power to the median
+// power of its radius, skipping low frequencies, the bins near the axes and
+// the JPEG block harmonics. Only
+// the upper half plane is scanned, the spectrum of real input being
+// symmetric.
+func spectrumPeak(power []float64, n int) (float64, image.Point) {
+	freq := func(i int) int {
+		if i > n/2 {
+			return i - n
+		}
+		return i
+	}
+	maxRadius := n / 2
+	rings := make([][]float64, maxRadius+1)
+	for v := 0; v < n; v++ {
+		for u := 0; u < n; u++ {
+			fu, fv := freq(u), freq(v)
+			r := int(math.Round(math.Hypot(float64(fu), float64(fv))))
+			if r < minSpectrumRadius || r > maxRadius {
+				continue
+			}
+			rings[r] = append(rings[r], power[v*n+u])
+		}
+	}
+	medians := make([]float64, len(rings))
+	for r, ring := range rings {
+		if len(ring) == 0 {
+			continue
+		}
+		sort.Float64s(ring)
+		medians[r] = ring[len(ring)/2]
+	}
+
+	block := n / 8
+	var best float64
+	var at image.Point
+	for v := 0; v <= n/2; v++ {
+		for u := 0; u < n; u++ {
+			fu, fv := freq(u), freq(v)
+			if abs(fu) <= axisBand || abs(fv) <= axisBand || (fu%block == 0 && fv%block == 0) {
+				continue
+			}
+			r := int(math.Round(math.Hypot(float64(fu), float64(fv))))
+			if r < minSpectrumRadius || r > maxRadius || medians[r] <= 0 {
+				continue
+			}
+			if ratio := power[v*n+u] / medians[r]; ratio > best {
+				best, at = ratio, image.Pt(fu, fv)
+			}
+		}
+	}
+	return best, at
+}
+
+func hannWindow(n int) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+	}
+	return w
+}
+
+// fft2D transforms the n x n row-major data in place; n must be a power of
+// two.
+func fft2D(data []complex128, n int) {
+	col := make([]complex128, n)
+	for y := 0; y < n; y++ {
+		fft(data[y*n : (y+1)*n])
+	}
+	for x := 0; x < n; x++ {
+		for y := 0; y < n; y++ {
+			col[y] = data[y*n+x]
+		}
+		fft(col)
+		for y := 0; y < n; y++ {
+			data[y*n+x] = col[y]
+		}
+	}
+}
+
+// fft is an iterative radix-2 Cooley-Tukey transform of a, in place.
+func fft(a []complex128) {
+	n := len(a)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j |= bit
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := a[start+k], a[start+k+size/2]*w
+				a[start+k], a[start+k+size/2] = even+odd, even-odd
+				w *= step
+			}
+		}
+	}
+}
+
+// InvisibleWarnings reports WarnInvisibleWatermark when o.CheckInvisible is
+// set and DetectInvisibleWatermark suspects the cleaned image still carries
+// an invisible watermark.
+func (o Options) InvisibleWarnings(cleaned image.Image) []Warning {
+	if !o.CheckInvisible {
+		return nil
+	}
+	report, err := DetectInvisibleWatermark(cleaned)
+	if err != nil || !report.Suspected {
+		return nil
+	}
+	return []Warning{{WarnInvisibleWatermark, fmt.Sprintf("spectral peak %.1fx the median at frequency %v suggests an invisible watermark remains",
+		report.PeakRatio, report.Frequency)}}
+}
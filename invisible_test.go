@@ -0,0 +1,95 @@
+package watermark
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/cmplx"
+	"math/rand"
+	"testing"
+)
+
+// texturedRGBA returns a noisy gradient image with an optional faint
+// periodic pattern of amplitude amp at (fu, fv) cycles per spectrumTile.
+func texturedRGBA(w, h int, amp float64, fu, fv int) *image.RGBA {
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := 100 + 50*math.Sin(float64(x)/40) + 30*math.Cos(float64(y)/55) + rng.NormFloat64()*4
+			v += amp * math.Sin(2*math.Pi*float64(x*fu+y*fv)/spectrumTile)
+			c := uint8(math.Max(0, math.Min(255, math.Round(v))))
+			img.SetRGBA(x, y, color.RGBA{c, c, c, 255})
+		}
+	}
+	return img
+}
+
+func TestDetectInvisibleWatermark(t *testing.T) {
+	clean, err := DetectInvisibleWatermark(texturedRGBA(512, 384, 0, 0, 0))
+	if err != nil {
+		t.Fatalf("clean: %v", err)
+	}
+	if clean.Suspected || clean.Tiles != 48 {
+		t.Fatalf("clean image flagged: %+v", clean)
+	}
+
+	marked, err := DetectInvisibleWatermark(texturedRGBA(512, 384, 1.5, 11, 5))
+	if err != nil {
+		t.Fatalf("marked: %v", err)
+	}
+	if !marked.Suspected || marked.Frequency != image.Pt(11, 5) {
+		t.Fatalf("faint pattern missed: %+v", marked)
+	}
+
+	if _, err := DetectInvisibleWatermark(texturedRGBA(112, 112, 0, 0, 0)); err == nil {
+		t.Fatalf("expected error for an image smaller than %d tiles", minSpectrumTiles)
+	}
+}
+
+func TestFFTMatchesDFT(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	a := make([]complex128, 16)
+	for i := range a {
+		a[i] = complex(rng.Float64(), rng.Float64())
+	}
+	want := make([]complex128, len(a))
+	for k := range want {
+		for n, v := range a {
+			want[k] += v * cmplx.Exp(complex(0, -2*math.Pi*float64(k*n)/float64(len(a))))
+		}
+	}
+	fft(a)
+	for k := range a {
+		if cmplx.Abs(a[k]-want[k]) > 1e-9 {
+			t.Fatalf("bin %d = %v, want %v", k, a[k], want[k])
+		}
+	}
+}
+
+func TestProcessBytesCheckInvisible(t *testing.T) {
+	data, err := EncodePNGToBytes(stampWatermark(t, texturedRGBA(512, 384, 2, 11, 5)))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	unchecked, err := ProcessBytes(data, Options{})
+	if err != nil || !unchecked.Present {
+		t.Fatalf("ProcessBytes: present %v, %v", unchecked.Present, err)
+	}
+	if unchecked.Info.InvisibleWatermark {
+		t.Fatalf("invisible watermark reported without CheckInvisible")
+	}
+
+	checked, err := ProcessBytes(data, Options{CheckInvisible: true})
+	if err != nil {
+		t.Fatalf("ProcessBytes: %v", err)
+	}
+	if !checked.Info.InvisibleWatermark {
+		t.Fatalf("invisible watermark not reported")
+	}
+	last := checked.Warnings[len(checked.Warnings)-1]
+	if last.Code != WarnInvisibleWatermark {
+		t.Fatalf("unexpected warnings %v", checked.Warnings)
+	}
+}
@@ -0,0 +1,103 @@
+package watermark
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// LuminanceMode selects the brightness measure used by detection.
+type LuminanceMode int
+
+const (
+	// LumaGamma applies the Rec. 709 weights directly to gamma-encoded sRGB
+	// values. It is cheap and matches the original detector.
+	LumaGamma LuminanceMode = iota
+	// LumaLinear linearizes sRGB before weighting, giving true relative
+	// luminance. The watermark is blended in a way whose brightness lift is
+	// more uniform in linear light, so scores vary less between bright and
+	// dark corners.
+	LumaLinear
+)
+
+// String returns the mode name.
+func (m LuminanceMode) String() string {
+	switch m {
+	case LumaGamma:
+		return "gamma"
+	case LumaLinear:
+		return "linear"
+	default:
+		return "unknown"
+	}
+}
+
+// srgbToLinear maps 8-bit sRGB values to linear light scaled to [0, 255].
+var srgbToLinear = func() (lut [256]float64) {
+	for i := range lut {
+		v := float64(i) / 255
+		if v <= 0.04045 {
+			v /= 12.92
+		} else {
+			v = math.Pow((v+0.055)/1.055, 2.4)
+		}
+		lut[i] = v * 255
+	}
+	return lut
+}()
+
+// luma converts 16-bit color channels, as returned by color.Color.RGBA, to a
+// brightness in [0, 255].
+func (m LuminanceMode) luma(r, g, b uint32) float64 {
+	if m == LumaLinear {
+		return 0.2126*srgbToLinear[r>>8] + 0.7152*srgbToLinear[g>>8] + 0.0722*srgbToLinear[b>>8]
+	}
+	return 0.2126*float64(r)/257.0 + 0.7152*float64(g)/257.0 + 0.0722*float64(b)/257.0
+}
+
+// lumaRow writes the luma of the len(dst) pixels of row y starting at x0
+// into dst. RGBA, NRGBA and YCbCr images are read from their pixel buffers
+// directly; the values match those of At, which the other types and rows
+// reaching outside the bounds go through.
+func (m LuminanceMode) lumaRow(img image.Image, y, x0 int, dst []float64) {
+	if !(image.Rectangle{Min: image.Pt(x0, y), Max: image.Pt(x0+len(dst), y+1)}).In(img.Bounds()) {
+		m.lumaRowAt(img, y, x0, dst)
+		return
+	}
+	switch img := img.(type) {
+	case *image.RGBA:
+		pix := img.Pix[img.PixOffset(x0, y):][:4*len(dst)]
+		for i := range dst {
+			p := pix[4*i : 4*i+3]
+			r, g, b := uint32(p[0]), uint32(p[1]), uint32(p[2])
+			dst[i] = m.luma(r|r<<8, g|g<<8, b|b<<8)
+		}
+	case *image.NRGBA:
+		pix := img.Pix[img.PixOffset(x0, y):][:4*len(dst)]
+		for i := range dst {
+			p := pix[4*i : 4*i+4]
+			// As color.NRGBA.RGBA: widen, then premultiply.
+			r, g, b, a := uint32(p[0]), uint32(p[1]), uint32(p[2]), uint32(p[3])
+			r |= r << 8
+			g |= g << 8
+			b |= b << 8
+			a |= a << 8
+			dst[i] = m.luma(r*a/0xffff, g*a/0xffff, b*a/0xffff)
+		}
+	case *image.YCbCr:
+		for i := range dst {
+			yi, ci := img.YOffset(x0+i, y), img.COffset(x0+i, y)
+			r, g, b, _ := color.YCbCr{Y: img.Y[yi], Cb: img.Cb[ci], Cr: img.Cr[ci]}.RGBA()
+			dst[i] = m.luma(r, g, b)
+		}
+	default:
+		m.lumaRowAt(img, y, x0, dst)
+	}
+}
+
+func (m LuminanceMode) lumaRowAt(img image.Image, y, x0 int, dst []float64) {
+	for i := range dst {
+		r, g, b, _ := img.At(x0+i, y).RGBA()
+		dst[i] = m.luma(r, g, b)
+	}
+}
@@ -0,0 +1,94 @@
+package watermark
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+func TestDetectLinearLuminance(t *testing.T) {
+	p := GeminiProfile()
+	p.Luminance = LumaLinear
+
+	for _, bg := range []uint8{20, 90, 180} {
+		img := watermarkedRGBA(t, 320, 240, color.RGBA{R: bg, G: bg, B: bg, A: 255})
+
+		present, linear, _, err := DetectWatermarkProfile(img, p)
+		if err != nil {
+			t.Fatalf("bg %d: detect: %v", bg, err)
+		}
+		_, gamma, _, _ := DetectWatermark(img)
+		if !present {
+			t.Fatalf("bg %d: linear detection missed (score %.2f, gamma score %.2f)", bg, linear, gamma)
+		}
+		if linear == gamma {
+			t.Fatalf("bg %d: linear mode did not change the score", bg)
+		}
+	}
+}
+
+func TestLuminanceModeEndpoints(t *testing.T) {
+	for _, m := range []LuminanceMode{LumaGamma, LumaLinear} {
+		if got := m.luma(0, 0, 0); got != 0 {
+			t.Fatalf("%v: black luma %v", m, got)
+		}
+		if got := m.luma(0xffff, 0xffff, 0xffff); got < 254.99 || got > 255.01 {
+			t.Fatalf("%v: white luma %v", m, got)
+		}
+	}
+}
+
+// lumaSources returns a random image of size w x h in each type lumaRow has
+// a fast path for, offset from the origin, plus a wrapper hiding the type.
+func lumaSources(w, h int) map[string]image.Image {
+	rng := rand.New(rand.NewSource(int64(w * h)))
+	r := image.Rect(3, -2, 3+w, h-2)
+	nrgba := image.NewNRGBA(r)
+	rng.Read(nrgba.Pix)
+	rgba := image.NewRGBA(r)
+	draw.Draw(rgba, r, nrgba, r.Min, draw.Src)
+	sources := map[string]image.Image{"rgba": rgba, "nrgba": nrgba}
+	for _, ratio := range []image.YCbCrSubsampleRatio{image.YCbCrSubsampleRatio444, image.YCbCrSubsampleRatio420, image.YCbCrSubsampleRatio411} {
+		ycc := image.NewYCbCr(r, ratio)
+		rng.Read(ycc.Y)
+		rng.Read(ycc.Cb)
+		rng.Read(ycc.Cr)
+		sources["ycbcr "+ratio.String()] = ycc
+	}
+	sources["generic rgba"] = struct{ image.Image }{rgba}
+	sources["generic ycbcr"] = struct{ image.Image }{sources["ycbcr "+image.YCbCrSubsampleRatio420.String()]}
+	return sources
+}
+
+func TestLumaRowMatchesAt(t *testing.T) {
+	for name, img := range lumaSources(37, 9) {
+		b := img.Bounds()
+		for _, m := range []LuminanceMode{LumaGamma, LumaLinear} {
+			// The last row starts left of the bounds and goes through At.
+			for _, x0 := range []int{b.Min.X, b.Min.X + 5, b.Min.X - 2} {
+				for y := b.Min.Y; y < b.Max.Y; y++ {
+					got := make([]float64, 30)
+					m.lumaRow(img, y, x0, got)
+					for i, l := range got {
+						r, g, bl, _ := img.At(x0+i, y).RGBA()
+						if want := m.luma(r, g, bl); l != want {
+							t.Fatalf("%s %v: luma at (%d, %d) = %v, want %v", name, m, x0+i, y, l, want)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkMeanLuma(b *testing.B) {
+	for name, img := range lumaSources(1024, 1024) {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				meanLuma(img, img.Bounds(), image.Rectangle{}, LumaGamma)
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package watermark
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// RemoveWithMask applies the default engine's RemoveWithMask.
+func RemoveWithMask(img, mask image.Image, logoColor color.Color) (*image.RGBA, error) {
+	return Default().RemoveWithMask(img, mask, logoColor)
+}
+
+// RemoveWithMaskContext applies the default engine's RemoveWithMaskContext.
+func RemoveWithMaskContext(ctx context.Context, img, mask image.Image, logoColor color.Color) (*image.RGBA, error) {
+	return Default().RemoveWithMaskContext(ctx, img, mask, logoColor)
+}
+
+// RemoveWithMask reverse blends an arbitrary watermark described by a
+// caller-provided mask. The mask uses the same convention as the embedded
+// Gemini captures: the logo rendered over black, so each pixel's brightest
+// channel is the watermark opacity. The mask bounds give its location in
+// image coordinates, and logoColor is the watermark's solid color.
+//
+// The engine's exclusion mask is honored. The result is returned as a new
+// *image.RGBA.
+func (e *Engine) RemoveWithMask(img, mask image.Image, logoColor color.Color) (*image.RGBA, error) {
+	return e.RemoveWithMaskContext(context.Background(), img, mask, logoColor)
+}
+
+// RemoveWithMaskContext is RemoveWithMask with cancellation, checked like
+// RemoveWatermarkContext: before copying and between the cleanup stages.
+func (e *Engine) RemoveWithMaskContext(ctx context.Context, img, mask image.Image, logoColor color.Color) (*image.RGBA, error) {
+	if img == nil {
+		return nil, fmt.Errorf("nil image provided")
+	}
+	if mask == nil {
+		return nil, fmt.Errorf("nil mask provided")
+	}
+	if logoColor == nil {
+		return nil, fmt.Errorf("nil logo color provided")
+	}
+
+	rect := mask.Bounds()
+	if rect.Empty() {
+		return nil, fmt.Errorf("empty mask bounds %v", rect)
+	}
+	if !rect.In(img.Bounds()) {
+		return nil, fmt.Errorf("mask rectangle %v out of bounds %v", rect, img.Bounds())
+	}
+
+	alphaMap := e.applyExclusion(calculateAlphaMap(mask), rect)
+
+	c := color.NRGBAModel.Convert(logoColor).(color.NRGBA)
+	logo := [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+
+	bands, release, err := e.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	rgba := e.reverseAlphaClone(img, alphaMap, rect, logo, bands)
+	if err := e.postProcess(ctx, rgba, img, alphaMap, rect, logo); err != nil {
+		return nil, err
+	}
+	return rgba, nil
+}
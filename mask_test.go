@@ -0,0 +1,74 @@
+package watermark
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestRemoveWithMaskCustomLogo(t *testing.T) {
+	const w, h = 64, 48
+	logo := color.RGBA{R: 200, G: 30, B: 30, A: 255}
+	rect := image.Rect(20, 10, 36, 26)
+
+	// Gradient mask rendered over black, positioned at rect.
+	mask := image.NewGray(rect)
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			mask.SetGray(x, y, color.Gray{Y: uint8((x - rect.Min.X) * 12)})
+		}
+	}
+
+	original := image.NewRGBA(image.Rect(0, 0, w, h))
+	for i := range original.Pix {
+		original.Pix[i] = uint8(i*7) | 0x0f
+		if i%4 == 3 {
+			original.Pix[i] = 0xff
+		}
+	}
+
+	marked := cloneToRGBA(original)
+	lv := [3]float64{float64(logo.R), float64(logo.G), float64(logo.B)}
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			alpha := float64(mask.GrayAt(x, y).Y) / 255
+			off := marked.PixOffset(x, y)
+			for c := 0; c < 3; c++ {
+				marked.Pix[off+c] = uint8(alpha*lv[c] + (1-alpha)*float64(marked.Pix[off+c]) + 0.5)
+			}
+		}
+	}
+
+	cleaned, err := RemoveWithMask(marked, mask, logo)
+	if err != nil {
+		t.Fatalf("RemoveWithMask: %v", err)
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			got, want := cleaned.RGBAAt(x, y), original.RGBAAt(x, y)
+			if absDiff(got.R, want.R) > 2 || absDiff(got.G, want.G) > 2 || absDiff(got.B, want.B) > 2 {
+				t.Fatalf("pixel (%d,%d): got %v want %v", x, y, got, want)
+			}
+		}
+	}
+
+	if _, err := RemoveWithMask(marked, image.NewGray(image.Rect(60, 40, 80, 60)), logo); err == nil {
+		t.Fatalf("expected error for mask outside image bounds")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := RemoveWithMaskContext(ctx, marked, mask, logo); !errors.Is(err, context.Canceled) {
+		t.Fatalf("cancelled RemoveWithMaskContext: %v", err)
+	}
+}
+
+func absDiff(a, b uint8) uint8 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
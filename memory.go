@@ -0,0 +1,124 @@
+package watermark
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrMemoryBudget is returned when decoding an image would exceed the
+// process-wide memory budget.
+var ErrMemoryBudget = errors.New("memory budget exceeded")
+
+// bytesPerPixel approximates the memory held per pixel while an image is
+// processed: the decoded image plus the RGBA working copy.
+const bytesPerPixel = 8
+
+// MemoryBudget bounds the decoded image memory held by concurrent byte-level
+// calls (ProcessBytes, DetectResultBytes and the v1 byte helpers). Each call
+// reserves an estimate derived from the image header before decoding and
+// releases it when done.
+type MemoryBudget struct {
+	// Queue makes callers wait for memory to be released instead of failing
+	// with ErrMemoryBudget. Requests larger than the whole budget always fail.
+	Queue bool
+
+	mu      sync.Mutex
+	limit   int64
+	used    int64
+	release chan struct{}
+}
+
+// NewMemoryBudget returns a budget of limit bytes.
+func NewMemoryBudget(limit int64) *MemoryBudget {
+	return &MemoryBudget{limit: limit, release: make(chan struct{})}
+}
+
+// Acquire reserves n bytes. When the budget is exhausted it fails with
+// ErrMemoryBudget, or waits for a release if Queue is set.
+func (b *MemoryBudget) Acquire(ctx context.Context, n int64) error {
+	if n > b.limit {
+		return fmt.Errorf("%w: need %d bytes, budget is %d", ErrMemoryBudget, n, b.limit)
+	}
+
+	for {
+		b.mu.Lock()
+		if b.used+n <= b.limit {
+			b.used += n
+			b.mu.Unlock()
+			return nil
+		}
+		if !b.Queue {
+			used := b.used
+			b.mu.Unlock()
+			return fmt.Errorf("%w: need %d bytes, %d of %d in use", ErrMemoryBudget, n, used, b.limit)
+		}
+		wait := b.release
+		b.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release returns n bytes to the budget and wakes queued callers.
+func (b *MemoryBudget) Release(n int64) {
+	b.mu.Lock()
+	b.used -= n
+	if b.used < 0 {
+		b.used = 0
+	}
+	close(b.release)
+	b.release = make(chan struct{})
+	b.mu.Unlock()
+}
+
+// InUse reports the bytes currently reserved.
+func (b *MemoryBudget) InUse() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+var memoryBudget struct {
+	mu     sync.RWMutex
+	budget *MemoryBudget
+}
+
+// SetMemoryBudget installs a process-wide memory budget for the byte-level
+// helpers. A nil budget removes the limit.
+func SetMemoryBudget(b *MemoryBudget) {
+	memoryBudget.mu.Lock()
+	memoryBudget.budget = b
+	memoryBudget.mu.Unlock()
+}
+
+// reserveDecode reserves budget for decoding data, using the image header to
+// estimate the pixel count. The returned release func must be called once
+// processing is done.
+func reserveDecode(ctx context.Context, data []byte) (release func(), err error) {
+	memoryBudget.mu.RLock()
+	b := memoryBudget.budget
+	memoryBudget.mu.RUnlock()
+
+	if b == nil {
+		return func() {}, nil
+	}
+
+	cfg, _, err := decodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decode image header: %w", err)
+	}
+
+	n := int64(cfg.Width) * int64(cfg.Height) * bytesPerPixel
+
+	if err := b.Acquire(ctx, n); err != nil {
+		return nil, err
+	}
+	return func() { b.Release(n) }, nil
+}
@@ -0,0 +1,38 @@
+package watermark
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryBudgetQueue(t *testing.T) {
+	b := NewMemoryBudget(100)
+	ctx := context.Background()
+
+	if err := b.Acquire(ctx, 80); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if err := b.Acquire(ctx, 40); !errors.Is(err, ErrMemoryBudget) {
+		t.Fatalf("expected rejection without queueing, got %v", err)
+	}
+
+	b.Queue = true
+	acquired := make(chan error, 1)
+	go func() { acquired <- b.Acquire(ctx, 40) }()
+
+	select {
+	case err := <-acquired:
+		t.Fatalf("acquire should wait for release, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	b.Release(80)
+	if err := <-acquired; err != nil {
+		t.Fatalf("queued acquire: %v", err)
+	}
+	if got := b.InUse(); got != 40 {
+		t.Fatalf("expected 40 bytes in use, got %d", got)
+	}
+}
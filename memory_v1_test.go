@@ -0,0 +1,29 @@
+//go:build !watermark_nov1
+
+package watermark
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMemoryBudgetRejectsLargeImages(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("cmd", "gwatermark", "image.png"))
+	if err != nil {
+		t.Fatalf("read input image: %v", err)
+	}
+
+	SetMemoryBudget(NewMemoryBudget(1 << 10))
+	defer SetMemoryBudget(nil)
+
+	if _, _, _, _, err := RemoveWatermarkBytes(data); !errors.Is(err, ErrMemoryBudget) {
+		t.Fatalf("expected ErrMemoryBudget, got %v", err)
+	}
+
+	SetMemoryBudget(NewMemoryBudget(1 << 30))
+	if _, _, _, _, err := RemoveWatermarkBytes(data); err != nil {
+		t.Fatalf("RemoveWatermarkBytes within budget: %v", err)
+	}
+}
@@ -0,0 +1,243 @@
+package watermark
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"io"
+)
+
+const (
+	exifHeader   = "Exif\x00\x00"
+	xmpJPEGTag   = "http://ns.adobe.com/xap/1.0/\x00"
+	xmpPNGKey    = "XML:com.adobe.xmp"
+	maxJPEGSeg   = 0xFFFF - 2
+	webpEXIFFlag = 0x08
+	webpXMPFlag  = 0x04
+)
+
+// Metadata holds the EXIF and XMP packets of an image file. ICC profiles are
+// handled by TagColorSpace.
+type Metadata struct {
+	// EXIF is the TIFF-structured EXIF data, starting with the byte order
+	// mark ("II" or "MM").
+	EXIF []byte
+	// XMP is the XMP packet.
+	XMP []byte
+}
+
+// ReadMetadata extracts the EXIF and XMP packets of a PNG (eXIf, iTXt), JPEG
+// (APP1) or WebP (EXIF, XMP) file. Missing packets are left nil.
+func ReadMetadata(data []byte) Metadata {
+	var m Metadata
+	jpegSegments(data, func(marker byte, segment []byte) bool {
+		if marker != 0xE1 {
+			return true
+		}
+		switch {
+		case m.EXIF == nil && bytes.HasPrefix(segment, []byte(exifHeader)):
+			m.EXIF = segment[len(exifHeader):]
+		case m.XMP == nil && bytes.HasPrefix(segment, []byte(xmpJPEGTag)):
+			m.XMP = segment[len(xmpJPEGTag):]
+		}
+		return true
+	})
+	pngChunks(data, func(typ string, body []byte) bool {
+		switch {
+		case typ == "eXIf" && m.EXIF == nil:
+			m.EXIF = body
+		case typ == "iTXt" && m.XMP == nil:
+			m.XMP = pngXMP(body)
+		}
+		return true
+	})
+	webpChunks(data, func(fourcc string, body []byte) bool {
+		switch {
+		case fourcc == "EXIF" && m.EXIF == nil:
+			m.EXIF = body
+		case fourcc == "XMP " && m.XMP == nil:
+			m.XMP = body
+		}
+		return true
+	})
+	// Some writers keep the JPEG APP1 prefix in PNG and WebP chunks.
+	m.EXIF = bytes.TrimPrefix(m.EXIF, []byte(exifHeader))
+	return m
+}
+
+// PreserveMetadata returns the PNG, JPEG or WebP out with the color space
+// (see TagColorSpace), EXIF and XMP of source, so orientation, camera data
+// and editing metadata survive re-encoding. The EXIF thumbnail is dropped,
+// since it still shows the watermark. Packets out already carries are kept;
+// other formats are returned unchanged.
+func PreserveMetadata(out, source []byte) []byte {
+	m := ReadMetadata(source)
+	m.EXIF = dropEXIFThumbnail(m.EXIF)
+	return WriteMetadata(TagColorSpace(out, source), m)
+}
+
+// WriteMetadata returns the PNG, JPEG or WebP out with the packets of m
+// added. Packets out already carries, and EXIF too large for a JPEG APP1
+// segment, are skipped; other formats are returned unchanged.
+func WriteMetadata(out []byte, m Metadata) []byte {
+	have := ReadMetadata(out)
+	if have.EXIF != nil {
+		m.EXIF = nil
+	}
+	if have.XMP != nil {
+		m.XMP = nil
+	}
+	if m.EXIF == nil && m.XMP == nil {
+		return out
+	}
+
+	switch {
+	case len(out) >= 2 && out[0] == 0xFF && out[1] == 0xD8:
+		return writeJPEGMetadata(out, m)
+	case bytes.HasPrefix(out, pngSignature) && len(out) >= 33:
+		return writePNGMetadata(out, m)
+	}
+	if _, _, ok := webpSize(out); ok {
+		var flags byte
+		var tail [][]byte
+		if m.EXIF != nil {
+			flags |= webpEXIFFlag
+			tail = append(tail, webpChunk("EXIF", m.EXIF))
+		}
+		if m.XMP != nil {
+			flags |= webpXMPFlag
+			tail = append(tail, webpChunk("XMP ", m.XMP))
+		}
+		return extendWebP(out, flags, nil, tail)
+	}
+	return out
+}
+
+// writeJPEGMetadata inserts APP1 segments right after SOI, where the Exif
+// specification requires them.
+func writeJPEGMetadata(out []byte, m Metadata) []byte {
+	var segs []byte
+	add := func(prefix string, payload []byte) {
+		if payload == nil || len(prefix)+len(payload) > maxJPEGSeg {
+			return
+		}
+		segs = append(segs, 0xFF, 0xE1)
+		segs = binary.BigEndian.AppendUint16(segs, uint16(2+len(prefix)+len(payload)))
+		segs = append(segs, prefix...)
+		segs = append(segs, payload...)
+	}
+	add(exifHeader, m.EXIF)
+	add(xmpJPEGTag, m.XMP)
+	if segs == nil {
+		return out
+	}
+
+	tagged := make([]byte, 0, len(out)+len(segs))
+	tagged = append(tagged, out[:2]...)
+	tagged = append(tagged, segs...)
+	return append(tagged, out[2:]...)
+}
+
+// writePNGMetadata inserts eXIf and an uncompressed XMP iTXt chunk after the
+// IHDR chunk; eXIf must precede the image data.
+func writePNGMetadata(out []byte, m Metadata) []byte {
+	var chunks []byte
+	if m.EXIF != nil {
+		chunks = append(chunks, pngChunk("eXIf", m.EXIF)...)
+	}
+	if m.XMP != nil {
+		// Keyword, NUL, compression flag and method, empty language tag and
+		// translated keyword, then the text.
+		body := append([]byte(xmpPNGKey+"\x00\x00\x00\x00\x00"), m.XMP...)
+		chunks = append(chunks, pngChunk("iTXt", body)...)
+	}
+
+	const ihdrEnd = 8 + 25
+	tagged := make([]byte, 0, len(out)+len(chunks))
+	tagged = append(tagged, out[:ihdrEnd]...)
+	tagged = append(tagged, chunks...)
+	return append(tagged, out[ihdrEnd:]...)
+}
+
+// pngXMP returns the text of an iTXt chunk holding XMP, or nil for other
+// iTXt chunks.
+func pngXMP(body []byte) []byte {
+	if !bytes.HasPrefix(body, []byte(xmpPNGKey+"\x00")) {
+		return nil
+	}
+	rest := body[len(xmpPNGKey)+1:]
+	if len(rest) < 2 {
+		return nil
+	}
+	compressed := rest[0] == 1
+	rest = rest[2:]
+	// Skip the language tag and the translated keyword.
+	for i := 0; i < 2; i++ {
+		n := bytes.IndexByte(rest, 0)
+		if n < 0 {
+			return nil
+		}
+		rest = rest[n+1:]
+	}
+	if !compressed {
+		return rest
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(rest))
+	if err != nil {
+		return nil
+	}
+	text, err := io.ReadAll(zr)
+	if err != nil {
+		return nil
+	}
+	return text
+}
+
+// pngChunks calls fn with the type and data of every chunk of a PNG file,
+// stopping early when fn returns false.
+func pngChunks(data []byte, fn func(typ string, body []byte) bool) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return
+	}
+	for pos := len(pngSignature); pos+12 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return
+		}
+		if !fn(string(data[pos+4:pos+8]), data[pos+8:end-4]) {
+			return
+		}
+		pos = end
+	}
+}
+
+// dropEXIFThumbnail returns a copy of tiff whose IFD0 no longer links to
+// IFD1, the thumbnail directory. The thumbnail bytes stay but are
+// unreferenced. Unparseable data is returned unchanged.
+func dropEXIFThumbnail(tiff []byte) []byte {
+	if len(tiff) < 8 {
+		return tiff
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return tiff
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return tiff
+	}
+	next := ifd + 2 + 12*int(order.Uint16(tiff[ifd:]))
+	if next+4 > len(tiff) || order.Uint32(tiff[next:]) == 0 {
+		return tiff
+	}
+	out := append([]byte(nil), tiff...)
+	order.PutUint32(out[next:], 0)
+	return out
+}
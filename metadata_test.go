@@ -0,0 +1,104 @@
+package watermark
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"image/color"
+	"testing"
+)
+
+const testXMP = `<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF/></x:xmpmeta>`
+
+// exifWithThumbnail returns big-endian EXIF data whose IFD0 carries the
+// orientation tag and links to an empty IFD1, as camera thumbnails do.
+func exifWithThumbnail(orientation uint16) []byte {
+	var tiff bytes.Buffer
+	tiff.WriteString("MM")
+	binary.Write(&tiff, binary.BigEndian, uint16(42))
+	binary.Write(&tiff, binary.BigEndian, uint32(8))
+	binary.Write(&tiff, binary.BigEndian, uint16(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{0x0112, 3})
+	binary.Write(&tiff, binary.BigEndian, uint32(1))
+	binary.Write(&tiff, binary.BigEndian, []uint16{orientation, 0})
+	binary.Write(&tiff, binary.BigEndian, uint32(26))
+	binary.Write(&tiff, binary.BigEndian, uint16(0))
+	binary.Write(&tiff, binary.BigEndian, uint32(0))
+	return tiff.Bytes()
+}
+
+func TestProcessBytesPreservesMetadata(t *testing.T) {
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{R: 40, G: 60, B: 90, A: 255})
+	jpg, err := EncodeJPEGToBytes(img, 95)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	source := WriteMetadata(jpg, Metadata{EXIF: exifWithThumbnail(6), XMP: []byte(testXMP)})
+
+	for _, output := range []OutputFormat{OutputPNG, OutputJPEG, OutputWebP} {
+		result, err := ProcessBytes(source, Options{Output: output})
+		if err != nil {
+			t.Fatalf("%v: ProcessBytes: %v", output, err)
+		}
+		if !result.Present {
+			t.Fatalf("%v: watermark not detected", output)
+		}
+		if _, _, err := DecodeImageBytes(result.Output); err != nil {
+			t.Fatalf("%v: output does not decode: %v", output, err)
+		}
+
+		m := ReadMetadata(result.Output)
+		if string(m.XMP) != testXMP {
+			t.Fatalf("%v: XMP = %q", output, m.XMP)
+		}
+		if got := exifOrientation(result.Output); got != 6 {
+			t.Fatalf("%v: orientation = %d, want 6", output, got)
+		}
+		if next := binary.BigEndian.Uint32(m.EXIF[22:]); next != 0 {
+			t.Fatalf("%v: thumbnail IFD still linked at %d", output, next)
+		}
+
+		stripped, err := ProcessBytes(source, Options{Output: output, StripMetadata: true})
+		if err != nil {
+			t.Fatalf("%v: ProcessBytes: %v", output, err)
+		}
+		if m := ReadMetadata(stripped.Output); m.EXIF != nil || m.XMP != nil {
+			t.Fatalf("%v: metadata kept despite StripMetadata", output)
+		}
+	}
+}
+
+func TestWriteMetadataKeepsExistingPackets(t *testing.T) {
+	png, err := EncodePNGToBytes(gradientNRGBA(8, 8, false))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	tagged := WriteMetadata(png, Metadata{XMP: []byte(testXMP)})
+	again := WriteMetadata(tagged, Metadata{EXIF: exifWithThumbnail(3), XMP: []byte("other")})
+
+	m := ReadMetadata(again)
+	if string(m.XMP) != testXMP || tiffOrientation(m.EXIF) != 3 {
+		t.Fatalf("unexpected metadata XMP=%q orientation=%d", m.XMP, tiffOrientation(m.EXIF))
+	}
+	if _, _, err := DecodeImageBytes(again); err != nil {
+		t.Fatalf("tagged PNG does not decode: %v", err)
+	}
+}
+
+func TestReadMetadataCompressedPNGXMP(t *testing.T) {
+	png, err := EncodePNGToBytes(gradientNRGBA(8, 8, false))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write([]byte(testXMP))
+	zw.Close()
+	body := append([]byte(xmpPNGKey+"\x00\x01\x00en\x00\x00"), z.Bytes()...)
+
+	const ihdrEnd = 8 + 25
+	data := append(append(append([]byte{}, png[:ihdrEnd]...), pngChunk("iTXt", body)...), png[ihdrEnd:]...)
+	if got := ReadMetadata(data).XMP; string(got) != testXMP {
+		t.Fatalf("XMP = %q", got)
+	}
+}
@@ -0,0 +1,90 @@
+package watermark
+
+import (
+	"fmt"
+	"image"
+)
+
+// DefaultScales is the pyramid DetectMultiScale uses when no scales are
+// given. It covers common downscales and upscales applied after generation.
+var DefaultScales = []float64{0.5, 0.75, 1, 4.0 / 3.0, 1.5, 2}
+
+// ScaleMatch is the best placement found by DetectMultiScale.
+type ScaleMatch struct {
+	// Scale is the resize factor relative to the original Gemini output.
+	Scale       float64
+	Present     bool
+	Score       float64
+	Correlation float64
+	Info        Info
+}
+
+// DetectMultiScale runs detection for the Gemini watermark scaled by each
+// factor in scales (DefaultScales when empty) and returns the best match.
+// Detected placements win over undetected ones; ties are broken by shape
+// correlation. Scales whose placement does not fit the image are skipped; an
+// error is returned only when no scale could be evaluated.
+func DetectMultiScale(img image.Image, scales []float64) (ScaleMatch, error) {
+	if img == nil {
+		return ScaleMatch{}, fmt.Errorf("nil image provided")
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width <= 0 || height <= 0 {
+		return ScaleMatch{}, fmt.Errorf("invalid image dimensions %dx%d", width, height)
+	}
+
+	if len(scales) == 0 {
+		scales = DefaultScales
+	}
+
+	// The variant is chosen from the dimensions the image had before it was
+	// resized, so a 2x upscale of a 1024px image still uses the 48px logo.
+	profile := GeminiProfile()
+
+	var (
+		best     ScaleMatch
+		found    bool
+		firstErr error
+	)
+	for _, scale := range scales {
+		if scale <= 0 {
+			return ScaleMatch{}, fmt.Errorf("invalid scale %v", scale)
+		}
+
+		cfg, ok := profile.config(int(float64(width)/scale), int(float64(height)/scale))
+		if !ok {
+			continue
+		}
+
+		d, err := detectWithConfig(img, cfg.scaled(scale), detectParams{})
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		candidate := ScaleMatch{Scale: scale, Present: d.present, Score: d.score, Correlation: d.corr, Info: d.info}
+		if !found || betterMatch(candidate, best) {
+			best, found = candidate, true
+		}
+	}
+
+	if !found {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("no scale could be evaluated for %dx%d", width, height)
+		}
+		return ScaleMatch{}, firstErr
+	}
+
+	return best, nil
+}
+
+func betterMatch(a, b ScaleMatch) bool {
+	if a.Present != b.Present {
+		return a.Present
+	}
+	return a.Correlation > b.Correlation
+}
@@ -0,0 +1,54 @@
+package watermark
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func TestNoiseMatchIsSeeded(t *testing.T) {
+	const width, height = 320, 240
+	info := WatermarkInfo(width, height)
+
+	// Grainy background with a flat patch under the watermark, so the
+	// cleaned area ends up smoother than its surroundings.
+	img := watermarkedRGBA(t, width, height, color.RGBA{R: 100, G: 100, B: 100, A: 255})
+	rng := rand.New(rand.NewSource(1))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			if image.Pt(x, y).In(info.Position) {
+				continue
+			}
+			v := uint8(100 + rng.Intn(21) - 10)
+			img.SetRGBA(x, y, color.RGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+
+	clean := func(seed int64) *image.RGBA {
+		e := NewEngine()
+		e.SetNoiseMatch(&NoiseMatch{Seed: seed})
+		out, err := e.RemoveWatermark(img)
+		if err != nil {
+			t.Fatalf("RemoveWatermark: %v", err)
+		}
+		return out
+	}
+
+	a, b, c := clean(7), clean(7), clean(8)
+	if !bytes.Equal(a.Pix, b.Pix) {
+		t.Fatalf("same seed produced different outputs")
+	}
+	if bytes.Equal(a.Pix, c.Pix) {
+		t.Fatalf("different seeds produced identical outputs")
+	}
+
+	plain, err := RemoveWatermark(img)
+	if err != nil {
+		t.Fatalf("RemoveWatermark: %v", err)
+	}
+	if err := VerifyRegionOnly(plain, a, info.Position); err != nil {
+		t.Fatalf("noise leaked outside the watermark: %v", err)
+	}
+}
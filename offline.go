@@ -0,0 +1,49 @@
+package watermark
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrOffline is wrapped by every error returned because offline mode blocked
+// a network operation.
+var ErrOffline = errors.New("network access disabled by offline mode")
+
+// OfflineError reports which feature attempted network access while offline
+// mode was enabled.
+type OfflineError struct {
+	Feature string
+}
+
+func (e *OfflineError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Feature, ErrOffline)
+}
+
+// Unwrap lets errors.Is(err, ErrOffline) match.
+func (e *OfflineError) Unwrap() error {
+	return ErrOffline
+}
+
+var offline atomic.Bool
+
+// SetOffline enables or disables offline mode. While enabled, every feature
+// that would open a network connection fails with an *OfflineError before
+// dialing, so air-gapped deployments can rely on no egress.
+func SetOffline(enabled bool) {
+	offline.Store(enabled)
+}
+
+// Offline reports whether offline mode is enabled.
+func Offline() bool {
+	return offline.Load()
+}
+
+// CheckNetwork returns an *OfflineError for feature when offline mode is
+// enabled. Every network-touching code path calls it before dialing.
+func CheckNetwork(feature string) error {
+	if offline.Load() {
+		return &OfflineError{Feature: feature}
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package watermark
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestOfflineBlocksFetch(t *testing.T) {
+	SetOffline(true)
+	defer SetOffline(false)
+
+	_, err := FetchImage(context.Background(), "https://example.com/image.png")
+	if !errors.Is(err, ErrOffline) {
+		t.Fatalf("expected ErrOffline, got %v", err)
+	}
+
+	var oe *OfflineError
+	if !errors.As(err, &oe) || oe.Feature != "url input" {
+		t.Fatalf("expected OfflineError for url input, got %#v", err)
+	}
+}
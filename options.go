@@ -0,0 +1,252 @@
+package watermark
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+)
+
+// FrameErrorPolicy controls how multi-frame inputs (animated GIFs) react when
+// a single frame fails to process.
+type FrameErrorPolicy int
+
+const (
+	// FrameAbort stops processing and returns the frame error. It is the
+	// default so multi-frame inputs behave like single images.
+	FrameAbort FrameErrorPolicy = iota
+	// FrameSkip drops the failing frame from the output animation.
+	FrameSkip
+	// FrameCopyOriginal keeps the failing frame untouched in the output.
+	FrameCopyOriginal
+)
+
+// String returns the policy name used in logs and CLI flags.
+func (p FrameErrorPolicy) String() string {
+	switch p {
+	case FrameAbort:
+		return "abort"
+	case FrameSkip:
+		return "skip"
+	case FrameCopyOriginal:
+		return "copy"
+	default:
+		return "unknown"
+	}
+}
+
+// OutputFormat selects the encoding of cleaned single images. Animated GIFs
+// are always re-encoded as GIF.
+type OutputFormat int
+
+const (
+	// OutputPNG encodes lossless PNG. It is the default.
+	OutputPNG OutputFormat = iota
+	// OutputJPEG encodes JPEG at Options.JPEGQuality.
+	OutputJPEG
+	// OutputSource keeps the input format when it can be encoded (JPEG,
+	// PNG and WebP) and falls back to PNG otherwise. WebP inputs keep their
+	// lossless or lossy mode.
+	OutputSource
+	// OutputWebP encodes WebP, lossless unless Options.WebPLossy is set.
+	OutputWebP
+)
+
+// String returns the format name used in CLI flags.
+func (f OutputFormat) String() string {
+	switch f {
+	case OutputPNG:
+		return "png"
+	case OutputJPEG:
+		return "jpeg"
+	case OutputSource:
+		return "source"
+	case OutputWebP:
+		return "webp"
+	default:
+		return "unknown"
+	}
+}
+
+// Resolve returns the encoding ("png", "jpeg" or "webp") used for an input of
+// the given decoded format.
+func (f OutputFormat) Resolve(inputFormat string) string {
+	switch f {
+	case OutputJPEG:
+		return "jpeg"
+	case OutputWebP:
+		return "webp"
+	case OutputSource:
+		if inputFormat == "jpeg" || inputFormat == "webp" {
+			return inputFormat
+		}
+	}
+	return "png"
+}
+
+// ParseOutputFormat parses "png", "jpeg" (or "jpg"), "webp" and "source".
+func ParseOutputFormat(s string) (OutputFormat, bool) {
+	switch s {
+	case "png":
+		return OutputPNG, true
+	case "jpeg", "jpg":
+		return OutputJPEG, true
+	case "webp":
+		return OutputWebP, true
+	case "source":
+		return OutputSource, true
+	default:
+		return 0, false
+	}
+}
+
+// Options configures ProcessBytes. The zero value matches the behavior of
+// RemoveWatermarkBytes.
+type Options struct {
+	// FrameErrorPolicy decides what happens to frames that fail to process.
+	FrameErrorPolicy FrameErrorPolicy
+	// Profile selects the watermark placement. Nil means GeminiProfile.
+	Profile *Profile
+	// PassThrough returns the original bytes in Result.Output when no
+	// watermark is detected, so pipelines can write the output
+	// unconditionally. The input slice is returned as is, without copying;
+	// Result.Format then reports the input format.
+	PassThrough bool
+	// MaxGrowth, when positive, makes ProcessBytes fail with ErrSizeGrowth
+	// if the cleaned output is more than MaxGrowth times the input size,
+	// catching accidental JPEG to PNG bloat. Zero disables the check.
+	MaxGrowth float64
+	// Output selects the encoding of cleaned single images.
+	Output OutputFormat
+	// JPEGQuality is the JPEG quality (1-100) for JPEG output. Zero means
+	// DefaultJPEGQuality.
+	JPEGQuality int
+	// WebPLossy makes OutputWebP encode lossy WebP at WebPQuality (1-100,
+	// zero means DefaultWebPQuality). OutputSource ignores it and keeps the
+	// mode of WebP inputs.
+	WebPLossy   bool
+	WebPQuality int
+	// StripMetadata drops the source's EXIF and XMP from cleaned outputs,
+	// which otherwise keep them (see PreserveMetadata). The color space is
+	// tagged either way.
+	StripMetadata bool
+	// CheckInvisible runs DetectInvisibleWatermark on cleaned single images,
+	// setting Info.InvisibleWatermark and a WarnInvisibleWatermark warning
+	// when residual invisible-watermark artifacts are suspected. It costs a
+	// spectrum analysis per image.
+	CheckInvisible bool
+	// Resize scales cleaned single images before encoding; the zero value
+	// keeps their size. Pass-through outputs are not resized.
+	Resize Resize
+	// Thumbnail, when positive, also encodes the cleaned single image
+	// shrunk to at most Thumbnail pixels on its longer side into
+	// Result.Thumbnail, in the format and with the metadata of the output.
+	// Resize does not apply to it.
+	Thumbnail int
+	// Correction also encodes the CorrectionLayer from the decoded input to
+	// the cleaned single image, before Resize and lossy encoding, as a
+	// 16-bit PNG into Result.Correction, for reconstructing either image
+	// from the other or reviewing what changed.
+	Correction bool
+	// Salvage processes truncated or damaged PNG and JPEG inputs as far as
+	// they decode, as long as the rows holding the watermark are intact, and
+	// adds a WarnSalvaged warning. Rows that are lost come out gray. Inputs
+	// that cannot be salvaged fail with a *CorruptImageError instead of the
+	// decoder's error.
+	Salvage bool
+}
+
+// Encode encodes a cleaned image, resized per Resize, in the format Output
+// selects for an input of inputFormat, carrying the color space and metadata
+// of the source bytes. It returns the encoding used ("png", "jpeg" or
+// "webp").
+func (o Options) Encode(img image.Image, source []byte, inputFormat string) ([]byte, string, error) {
+	format := o.Output.Resolve(inputFormat)
+	output, err := o.encode(context.Background(), img, source, format)
+	if err != nil {
+		return nil, "", err
+	}
+	return o.tag(output, source), format, nil
+}
+
+// tag copies the color space, and unless StripMetadata is set the EXIF and
+// XMP, of source into the encoded output.
+func (o Options) tag(output, source []byte) []byte {
+	if o.StripMetadata {
+		return TagColorSpace(output, source)
+	}
+	return PreserveMetadata(output, source)
+}
+
+// encode encodes img, resized per o.Resize, as format. WebP is lossy when
+// WebPLossy is set for OutputWebP, and otherwise when the source is lossy
+// WebP. The PNG and JPEG encoders stop at their next write once ctx is done.
+func (o Options) encode(ctx context.Context, img image.Image, source []byte, format string) ([]byte, error) {
+	img = o.Resize.Apply(img)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	w := ctxWriter{ctx, &buf}
+	var err error
+	switch format {
+	case "jpeg":
+		err = EncodeJPEG(w, img, o.JPEGQuality)
+	case "webp":
+		lossy := webpLossy(source)
+		if o.Output == OutputWebP {
+			lossy = o.WebPLossy
+		}
+		var wo *WebPOptions
+		if lossy {
+			wo = &WebPOptions{Lossy: true, Quality: o.WebPQuality}
+		}
+		err = EncodeWebP(w, img, wo)
+	default:
+		err = EncodePNG(w, img)
+	}
+	if err != nil {
+		return nil, ctxErr(ctx, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// thumbnail encodes img per o.Thumbnail as format, or returns nil when no
+// thumbnail is requested.
+func (o Options) thumbnail(ctx context.Context, img image.Image, source []byte, format string) ([]byte, error) {
+	if o.Thumbnail <= 0 {
+		return nil, nil
+	}
+	t := o
+	t.Resize = Resize{MaxDim: o.Thumbnail}
+	thumb, err := t.encode(ctx, img, source, format)
+	if err != nil {
+		return nil, fmt.Errorf("thumbnail: %w", err)
+	}
+	return o.tag(thumb, source), nil
+}
+
+// correction encodes the CorrectionLayer from img to cleaned as PNG, or
+// returns nil when none is requested.
+func (o Options) correction(ctx context.Context, img, cleaned image.Image) ([]byte, error) {
+	if !o.Correction {
+		return nil, nil
+	}
+	layer, err := CorrectionLayer(img, cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("correction layer: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := EncodePNG(ctxWriter{ctx, &buf}, layer); err != nil {
+		return nil, fmt.Errorf("correction layer: %w", ctxErr(ctx, err))
+	}
+	return buf.Bytes(), nil
+}
+
+func (o Options) profile() Profile {
+	if o.Profile != nil {
+		return *o.Profile
+	}
+	return GeminiProfile()
+}
@@ -0,0 +1,65 @@
+package watermark
+
+import (
+	"bytes"
+	"fmt"
+	"runtime/debug"
+)
+
+// PanicError is returned instead of crashing the caller when processing an
+// input panics, typically a decoder tripping over an edge case of a
+// malformed image. The entry points that take encoded images (ProcessBytes,
+// DetectResultBytes, InspectBytes, PreviewBytes, Decode, DecodeImageBytes,
+// DecodeBase64Image, RemoveWatermarkTiled and the v1 byte helpers, with
+// their Context and Profile variants) recover such panics, so servers can
+// reject the upload and keep running.
+type PanicError struct {
+	// Op is the entry point that recovered the panic, e.g. "ProcessBytes".
+	Op string
+	// Value is the value passed to panic.
+	Value any
+	// Size is the length of the input in bytes, 0 for readers, and Format
+	// the format its header claims ("" when unknown), for reproducing the
+	// failure.
+	Size   int
+	Format string
+	// Stack is the stack of the panicking goroutine.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	format := e.Format
+	if format == "" {
+		format = "unknown"
+	}
+	return fmt.Sprintf("%s: internal error on %d-byte %s input: %v", e.Op, e.Size, format, e.Value)
+}
+
+// Unwrap returns Value when it is an error, such as a runtime.Error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// recoverPanic stores a panic of the entry point op, which processed input,
+// in *err as a *PanicError. It must be deferred directly by op.
+func recoverPanic(op string, input []byte, err *error) {
+	if v := recover(); v != nil {
+		*err = &PanicError{Op: op, Value: v, Size: len(input), Format: sniffFormat(input), Stack: debug.Stack()}
+	}
+}
+
+// sniffFormat returns the image format input's magic bytes claim, or "".
+func sniffFormat(input []byte) string {
+	switch {
+	case bytes.HasPrefix(input, pngSignature):
+		return "png"
+	case bytes.HasPrefix(input, []byte{0xFF, 0xD8}):
+		return "jpeg"
+	case bytes.HasPrefix(input, []byte("GIF8")):
+		return "gif"
+	case len(input) >= 12 && string(input[:4]) == "RIFF" && string(input[8:12]) == "WEBP":
+		return "webp"
+	}
+	return ""
+}
@@ -0,0 +1,90 @@
+package watermark
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"io"
+	"testing"
+)
+
+// panicMagic starts inputs of a test-only image format whose decoder panics,
+// standing in for a decoder edge case.
+const panicMagic = "PANIC!"
+
+func init() {
+	image.RegisterFormat("panic", panicMagic, func(io.Reader) (image.Image, error) {
+		var pix []byte
+		return nil, errors.New(string(pix[3:])) // index out of range
+	}, func(io.Reader) (image.Config, error) {
+		return image.Config{Width: 1, Height: 1}, nil
+	})
+}
+
+func TestPanicRecovered(t *testing.T) {
+	data := []byte(panicMagic + "payload")
+	calls := map[string]func() error{
+		"ProcessBytes": func() error {
+			_, err := ProcessBytes(data, Options{})
+			return err
+		},
+		"DetectResultBytes": func() error {
+			_, err := DetectResultBytes(data, GeminiProfile())
+			return err
+		},
+		"InspectBytes": func() error {
+			_, err := InspectBytes(data)
+			return err
+		},
+		"PreviewBytes": func() error {
+			_, err := PreviewBytes(data, GeminiProfile(), 0)
+			return err
+		},
+		"DecodeImageBytes": func() error {
+			_, _, err := DecodeImageBytes(data)
+			return err
+		},
+		"DecodeBase64Image": func() error {
+			_, _, err := DecodeBase64Image(base64.StdEncoding.EncodeToString(data))
+			return err
+		},
+		"Decode": func() error {
+			_, _, err := Decode(bytes.NewReader(data))
+			return err
+		},
+	}
+	for op, call := range calls {
+		var panicErr *PanicError
+		if err := call(); !errors.As(err, &panicErr) {
+			t.Errorf("%s: got %v, want a *PanicError", op, err)
+			continue
+		}
+		if panicErr.Op != op || len(panicErr.Stack) == 0 {
+			t.Errorf("%s: PanicError{Op: %q, %d-byte stack}", op, panicErr.Op, len(panicErr.Stack))
+		}
+		if op != "Decode" && panicErr.Size != len(data) {
+			t.Errorf("%s: Size = %d, want %d", op, panicErr.Size, len(data))
+		}
+		var runtimeErr interface{ RuntimeError() }
+		if !errors.As(panicErr, &runtimeErr) {
+			t.Errorf("%s: %v does not unwrap to the runtime error", op, panicErr)
+		}
+	}
+}
+
+func TestSniffFormat(t *testing.T) {
+	tests := map[string]string{
+		"\x89PNG\r\n\x1a\n....":        "png",
+		"\xff\xd8\xff\xe0":             "jpeg",
+		"GIF89a":                       "gif",
+		"RIFF\x00\x00\x00\x00WEBPVP8L": "webp",
+		"RIFF":                         "",
+		panicMagic:                     "",
+	}
+	for in, want := range tests {
+		if got := sniffFormat([]byte(in)); got != want {
+			t.Errorf("sniffFormat(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+// Package plugin runs third-party detectors, removers and post-processors
+// as subprocesses, so they can be added to gwatermark without recompiling
+// it and written in any language.
+//
+// A plugin is an executable that speaks JSON-RPC 2.0 on its stdin and
+// stdout, one JSON object per line. Its stderr is passed through to the
+// host's. The host first sends
+//
+//	{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":1}}
+//
+// and the plugin answers with its name and the methods it implements:
+//
+//	{"jsonrpc":"2.0","id":1,"result":{"name":"my-detector","protocolVersion":1,"capabilities":["detect"]}}
+//
+// Images travel as base64 PNG, with rectangles in pixels from the image's
+// top-left corner. The methods are:
+//
+//	detect       {"image"} → {"present", "score", "rect"}
+//	remove       {"image", "rect"} → {"image"}, the cleaned image
+//	postprocess  {"image"} → {"image"}, applied to cleaned images
+//
+// Failures are reported as JSON-RPC errors. The host closes the plugin's
+// stdin when done; the plugin should then exit. Go plugins can use Serve,
+// which implements the plugin side of the protocol.
+package plugin
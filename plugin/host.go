@@ -0,0 +1,231 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// closeTimeout is how long Close waits for a plugin to exit after its
+// stdin is closed before killing it.
+const closeTimeout = 5 * time.Second
+
+// Plugin is a running plugin process. Calls are sent one at a time; a
+// Plugin is safe for concurrent use.
+type Plugin struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	enc   *json.Encoder
+	dec   *json.Decoder
+	info  InitializeResult
+
+	mu     sync.Mutex
+	nextID int64
+	// broken is set once the stream can no longer be trusted: the plugin
+	// died, answered out of order, or a call was abandoned midway.
+	broken error
+}
+
+// Detection is the outcome of a plugin's detect.
+type Detection struct {
+	Present bool
+	Score   float64
+	// Rect is the watermark rectangle in the image's coordinates, empty
+	// when not Present.
+	Rect image.Rectangle
+}
+
+// Start runs the plugin executable at path with args and initializes it.
+// ctx bounds the initialization only; Close stops the plugin.
+func Start(ctx context.Context, path string, args ...string) (*Plugin, error) {
+	cmd := exec.Command(path, args...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start plugin %s: %w", path, err)
+	}
+
+	// Errors name the plugin by its file until it announces a name.
+	p := &Plugin{cmd: cmd, stdin: stdin, enc: json.NewEncoder(stdin), dec: json.NewDecoder(stdout)}
+	p.info.Name = filepath.Base(path)
+	var info InitializeResult
+	err = p.call(ctx, MethodInitialize, InitializeParams{ProtocolVersion: ProtocolVersion}, &info)
+	if err == nil && info.ProtocolVersion != ProtocolVersion {
+		err = fmt.Errorf("plugin %s speaks protocol version %d, want %d", p.Name(), info.ProtocolVersion, ProtocolVersion)
+	}
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	if info.Name == "" {
+		info.Name = p.info.Name
+	}
+	p.info = info
+	return p, nil
+}
+
+// Name returns the name the plugin announced.
+func (p *Plugin) Name() string { return p.info.Name }
+
+// Has reports whether the plugin implements method, one of MethodDetect,
+// MethodRemove and MethodPostProcess.
+func (p *Plugin) Has(method string) bool {
+	return slices.Contains(p.info.Capabilities, method)
+}
+
+// Detect asks the plugin whether img carries a watermark and where.
+func (p *Plugin) Detect(ctx context.Context, img image.Image) (Detection, error) {
+	params, err := imageParams(img)
+	if err != nil {
+		return Detection{}, err
+	}
+	var r DetectResult
+	if err := p.call(ctx, MethodDetect, params, &r); err != nil {
+		return Detection{}, err
+	}
+	d := Detection{Present: r.Present, Score: r.Score}
+	if r.Present {
+		if r.Rect == nil {
+			return Detection{}, fmt.Errorf("plugin %s: detect reported a watermark without a rect", p.Name())
+		}
+		d.Rect = r.Rect.rectangle(img.Bounds())
+	}
+	return d, nil
+}
+
+// Remove asks the plugin to clean the watermark at rect from img.
+func (p *Plugin) Remove(ctx context.Context, img image.Image, rect image.Rectangle) (image.Image, error) {
+	params, err := imageParams(img)
+	if err != nil {
+		return nil, err
+	}
+	params.Rect = rectOf(rect, img.Bounds())
+	return p.callImage(ctx, MethodRemove, params, img.Bounds())
+}
+
+// PostProcess passes a cleaned image through the plugin.
+func (p *Plugin) PostProcess(ctx context.Context, img image.Image) (image.Image, error) {
+	params, err := imageParams(img)
+	if err != nil {
+		return nil, err
+	}
+	return p.callImage(ctx, MethodPostProcess, params, img.Bounds())
+}
+
+// Close closes the plugin's stdin and waits for it to exit, killing it
+// after a grace period.
+func (p *Plugin) Close() error {
+	p.stdin.Close()
+	done := make(chan error, 1)
+	go func() { done <- p.cmd.Wait() }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(closeTimeout):
+		p.cmd.Process.Kill()
+		return <-done
+	}
+}
+
+func imageParams(img image.Image) (ImageParams, error) {
+	encoded, err := EncodeImage(img)
+	if err != nil {
+		return ImageParams{}, err
+	}
+	return ImageParams{Image: encoded}, nil
+}
+
+// callImage calls a method returning an ImageResult, which must have the
+// size of bounds, and places the image at bounds.
+func (p *Plugin) callImage(ctx context.Context, method string, params ImageParams, bounds image.Rectangle) (image.Image, error) {
+	var r ImageResult
+	if err := p.call(ctx, method, params, &r); err != nil {
+		return nil, err
+	}
+	img, err := DecodeImage(r.Image)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %s: %w", p.Name(), method, err)
+	}
+	if img.Bounds().Size() != bounds.Size() {
+		return nil, fmt.Errorf("plugin %s: %s returned a %v image for a %v one", p.Name(), method, img.Bounds().Size(), bounds.Size())
+	}
+	if img.Bounds() == bounds {
+		return img, nil
+	}
+	placed := image.NewRGBA(bounds)
+	draw.Draw(placed, bounds, img, img.Bounds().Min, draw.Src)
+	return placed, nil
+}
+
+// call sends one request and decodes the result into result. Once ctx is
+// done the plugin is killed, since its reply can no longer be matched.
+func (p *Plugin) call(ctx context.Context, method string, params, result any) error {
+	if method != MethodInitialize && !p.Has(method) {
+		return fmt.Errorf("plugin %s does not implement %s", p.Name(), method)
+	}
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.broken != nil {
+		return p.broken
+	}
+	p.nextID++
+	req := Request{JSONRPC: "2.0", ID: p.nextID, Method: method, Params: raw}
+
+	done := make(chan error, 1)
+	var resp Response
+	go func() {
+		if err := p.enc.Encode(req); err != nil {
+			done <- err
+			return
+		}
+		done <- p.dec.Decode(&resp)
+	}()
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		p.cmd.Process.Kill()
+		<-done
+		p.broken = fmt.Errorf("plugin %s: killed after an abandoned %s call", p.Name(), method)
+		return ctx.Err()
+	}
+
+	if err == nil && resp.ID != req.ID {
+		err = fmt.Errorf("reply id %d, want %d", resp.ID, req.ID)
+	}
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("exited")
+		}
+		p.broken = fmt.Errorf("plugin %s: %s: %w", p.Name(), method, err)
+		return p.broken
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("plugin %s: %s: %w", p.Name(), method, resp.Error)
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		return fmt.Errorf("plugin %s: %s: invalid result: %w", p.Name(), method, err)
+	}
+	return nil
+}
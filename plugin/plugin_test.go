@@ -0,0 +1,151 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// helperEnv makes the test binary run testHandler as a plugin.
+const helperEnv = "GWATERMARK_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(helperEnv) != "" {
+		if err := Serve(os.Stdin, os.Stdout, testHandler(os.Getenv(helperEnv))); err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// testHandler detects a watermark in the bottom-right 4x4 pixels when they
+// are white, removes it by painting them black and post-processes by
+// inverting the top-left pixel. Mode "slow" hangs in detect.
+func testHandler(mode string) Handler {
+	corner := func(b image.Rectangle) image.Rectangle {
+		return image.Rectangle{Min: b.Max.Sub(image.Pt(4, 4)), Max: b.Max}
+	}
+	return Handler{
+		Name: "test",
+		Detect: func(img image.Image) (Detection, error) {
+			if mode == "slow" {
+				time.Sleep(time.Minute)
+			}
+			r := corner(img.Bounds())
+			if img.At(r.Min.X, r.Min.Y) != (color.RGBA{255, 255, 255, 255}) {
+				return Detection{Score: 1}, nil
+			}
+			return Detection{Present: true, Score: 9, Rect: r}, nil
+		},
+		Remove: func(img image.Image, rect image.Rectangle) (image.Image, error) {
+			if rect != corner(img.Bounds()) {
+				return nil, errors.New("unexpected rect " + rect.String())
+			}
+			out := toRGBA(img)
+			for y := rect.Min.Y; y < rect.Max.Y; y++ {
+				for x := rect.Min.X; x < rect.Max.X; x++ {
+					out.Set(x, y, color.Black)
+				}
+			}
+			return out, nil
+		},
+	}
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	out := image.NewRGBA(img.Bounds())
+	for y := out.Rect.Min.Y; y < out.Rect.Max.Y; y++ {
+		for x := out.Rect.Min.X; x < out.Rect.Max.X; x++ {
+			out.Set(x, y, img.At(x, y))
+		}
+	}
+	return out
+}
+
+func startTest(t *testing.T, mode string) *Plugin {
+	t.Helper()
+	t.Setenv(helperEnv, mode)
+	p, err := Start(context.Background(), os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestPlugin(t *testing.T) {
+	p := startTest(t, "normal")
+	if p.Name() != "test" || !p.Has(MethodDetect) || !p.Has(MethodRemove) || p.Has(MethodPostProcess) {
+		t.Fatalf("plugin %q announced %v", p.Name(), p.info.Capabilities)
+	}
+
+	// Off the origin, to check coordinates are translated both ways.
+	img := image.NewRGBA(image.Rect(10, 20, 30, 36))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	d, err := p.Detect(context.Background(), img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := image.Rect(26, 32, 30, 36)
+	if !d.Present || d.Score != 9 || d.Rect != want {
+		t.Fatalf("Detect = %+v, want present at %v", d, want)
+	}
+
+	cleaned, err := p.Remove(context.Background(), img, d.Rect)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cleaned.Bounds() != img.Bounds() {
+		t.Fatalf("cleaned bounds %v, want %v", cleaned.Bounds(), img.Bounds())
+	}
+	if _, _, _, a := cleaned.At(29, 35).RGBA(); cleaned.At(29, 35) == img.At(29, 35) || a == 0 {
+		t.Errorf("watermark pixel not cleaned: %v", cleaned.At(29, 35))
+	}
+	if cleaned.At(10, 20) != img.At(10, 20) {
+		t.Errorf("pixel outside the rect changed")
+	}
+
+	d, err = p.Detect(context.Background(), cleaned)
+	if err != nil || d.Present {
+		t.Fatalf("Detect after removal = %+v, %v", d, err)
+	}
+
+	if _, err := p.Remove(context.Background(), img, image.Rect(10, 20, 12, 22)); err == nil || !strings.Contains(err.Error(), "unexpected rect") {
+		t.Errorf("plugin error not reported: %v", err)
+	}
+	if _, err := p.PostProcess(context.Background(), img); err == nil {
+		t.Error("PostProcess on a plugin without it succeeded")
+	}
+	// The plugin is still usable after failed calls.
+	if _, err := p.Detect(context.Background(), img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPluginCancel(t *testing.T) {
+	p := startTest(t, "slow")
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := p.Detect(ctx, image.NewRGBA(image.Rect(0, 0, 8, 8))); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Detect = %v, want deadline exceeded", err)
+	}
+	if _, err := p.Detect(context.Background(), image.NewRGBA(image.Rect(0, 0, 8, 8))); err == nil {
+		t.Fatal("killed plugin still answered")
+	}
+}
+
+func TestStartNotAPlugin(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := Start(ctx, "true"); err == nil {
+		t.Fatal("started a program that does not speak the protocol")
+	}
+}
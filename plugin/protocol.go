@@ -0,0 +1,129 @@
+package plugin
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+)
+
+// ProtocolVersion is the version of the protocol implemented here.
+const ProtocolVersion = 1
+
+// Method names, which double as the capabilities a plugin announces.
+const (
+	MethodInitialize  = "initialize"
+	MethodDetect      = "detect"
+	MethodRemove      = "remove"
+	MethodPostProcess = "postprocess"
+)
+
+// Error codes of JSON-RPC 2.0, and the one plugins report failures with.
+const (
+	CodeParseError     = -32700
+	CodeMethodNotFound = -32601
+	CodeInvalidParams  = -32602
+	CodeFailed         = -32000
+)
+
+// Request is a call from the host.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// Response answers a Request with either Result or Error.
+type Response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      int64           `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Error is a JSON-RPC error.
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// InitializeParams are the parameters of initialize.
+type InitializeParams struct {
+	ProtocolVersion int `json:"protocolVersion"`
+}
+
+// InitializeResult describes a plugin.
+type InitializeResult struct {
+	Name            string   `json:"name"`
+	ProtocolVersion int      `json:"protocolVersion"`
+	Capabilities    []string `json:"capabilities"`
+}
+
+// Rect is a rectangle in pixels from the image's top-left corner.
+type Rect struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// ImageParams are the parameters of detect and postprocess, and of remove
+// together with Rect, which is nil for the other methods.
+type ImageParams struct {
+	// Image is the image as base64 PNG.
+	Image string `json:"image"`
+	Rect  *Rect  `json:"rect,omitempty"`
+}
+
+// DetectResult is the result of detect. Rect is required when Present.
+type DetectResult struct {
+	Present bool    `json:"present"`
+	Score   float64 `json:"score"`
+	Rect    *Rect   `json:"rect,omitempty"`
+}
+
+// ImageResult is the result of remove and postprocess.
+type ImageResult struct {
+	Image string `json:"image"`
+}
+
+// rectOf converts r, relative to the top-left corner of bounds.
+func rectOf(r image.Rectangle, bounds image.Rectangle) *Rect {
+	r = r.Sub(bounds.Min)
+	return &Rect{X: r.Min.X, Y: r.Min.Y, Width: r.Dx(), Height: r.Dy()}
+}
+
+// rectangle converts r back into the coordinates of bounds.
+func (r Rect) rectangle(bounds image.Rectangle) image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height).Add(bounds.Min)
+}
+
+// EncodeImage encodes img as base64 PNG for the wire.
+func EncodeImage(img image.Image) (string, error) {
+	var buf bytes.Buffer
+	enc := png.Encoder{CompressionLevel: png.BestSpeed}
+	if err := enc.Encode(&buf, img); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// DecodeImage decodes a base64 PNG from the wire.
+func DecodeImage(s string) (image.Image, error) {
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("image is not base64: %w", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("image is not a PNG: %w", err)
+	}
+	return img, nil
+}
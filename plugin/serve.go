@@ -0,0 +1,117 @@
+package plugin
+
+import (
+	"encoding/json"
+	"errors"
+	"image"
+	"io"
+	"slices"
+)
+
+// Handler implements a plugin for Serve. The methods left nil are not
+// announced.
+type Handler struct {
+	// Name is announced to the host.
+	Name        string
+	Detect      func(img image.Image) (Detection, error)
+	Remove      func(img image.Image, rect image.Rectangle) (image.Image, error)
+	PostProcess func(img image.Image) (image.Image, error)
+}
+
+// Serve implements the plugin side of the protocol on r and w, normally
+// os.Stdin and os.Stdout, until r reaches EOF. Errors returned by h are
+// sent to the host; Serve itself fails only when the stream breaks.
+func Serve(r io.Reader, w io.Writer, h Handler) error {
+	dec := json.NewDecoder(r)
+	enc := json.NewEncoder(w)
+	for {
+		var req Request
+		if err := dec.Decode(&req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			// The stream cannot be resynchronized after malformed JSON.
+			enc.Encode(Response{JSONRPC: "2.0", Error: &Error{Code: CodeParseError, Message: err.Error()}})
+			return err
+		}
+		resp := Response{JSONRPC: "2.0", ID: req.ID}
+		result, rpcErr := h.handle(req)
+		if rpcErr == nil {
+			raw, err := json.Marshal(result)
+			if err != nil {
+				rpcErr = &Error{Code: CodeFailed, Message: err.Error()}
+			}
+			resp.Result = raw
+		}
+		if rpcErr != nil {
+			resp.Result, resp.Error = nil, rpcErr
+		}
+		if err := enc.Encode(resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (h Handler) capabilities() []string {
+	caps := []string{}
+	if h.Detect != nil {
+		caps = append(caps, MethodDetect)
+	}
+	if h.Remove != nil {
+		caps = append(caps, MethodRemove)
+	}
+	if h.PostProcess != nil {
+		caps = append(caps, MethodPostProcess)
+	}
+	return caps
+}
+
+func (h Handler) handle(req Request) (any, *Error) {
+	if req.Method == MethodInitialize {
+		return InitializeResult{Name: h.Name, ProtocolVersion: ProtocolVersion, Capabilities: h.capabilities()}, nil
+	}
+	if !slices.Contains(h.capabilities(), req.Method) {
+		return nil, &Error{Code: CodeMethodNotFound, Message: "method not implemented: " + req.Method}
+	}
+
+	var params ImageParams
+	if err := json.Unmarshal(req.Params, &params); err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+	img, err := DecodeImage(params.Image)
+	if err != nil {
+		return nil, &Error{Code: CodeInvalidParams, Message: err.Error()}
+	}
+
+	switch req.Method {
+	case MethodDetect:
+		d, err := h.Detect(img)
+		if err != nil {
+			return nil, &Error{Code: CodeFailed, Message: err.Error()}
+		}
+		r := DetectResult{Present: d.Present, Score: d.Score}
+		if d.Present {
+			r.Rect = rectOf(d.Rect, img.Bounds())
+		}
+		return r, nil
+	case MethodRemove:
+		if params.Rect == nil {
+			return nil, &Error{Code: CodeInvalidParams, Message: "remove needs a rect"}
+		}
+		return imageResult(h.Remove(img, params.Rect.rectangle(img.Bounds())))
+	case MethodPostProcess:
+		return imageResult(h.PostProcess(img))
+	}
+	panic("unreachable")
+}
+
+func imageResult(img image.Image, err error) (any, *Error) {
+	if err == nil {
+		var encoded string
+		encoded, err = EncodeImage(img)
+		if err == nil {
+			return ImageResult{Image: encoded}, nil
+		}
+	}
+	return nil, &Error{Code: CodeFailed, Message: err.Error()}
+}
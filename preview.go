@@ -0,0 +1,133 @@
+package watermark
+
+import (
+	"context"
+	"fmt"
+	"image"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// DefaultPreviewSize bounds the longer side of a corner preview when
+// PreviewBytes is given no size.
+const DefaultPreviewSize = 128
+
+// Preview is a quick, low-resolution look at the cleaned watermark corner,
+// for user interfaces that show feedback before the full image is processed.
+type Preview struct {
+	Width   int
+	Height  int
+	Format  string
+	Present bool
+	Score   float64
+	// Correlation and Confidence are those of DetectionResult.
+	Correlation float64
+	Confidence  Confidence
+	Info        Info
+	// Region is the part of the image the preview shows: the watermark
+	// rectangle with a margin of half the logo size, clipped to the image.
+	Region image.Rectangle
+	// Image holds Region cleaned and scaled down to fit the requested size
+	// (never up), as PNG. It is nil when no watermark was detected.
+	Image []byte
+}
+
+// PreviewBytes decodes data, detects the watermark placed according to p and
+// cleans only the corner around it, scaled so its longer side is at most size
+// pixels (DefaultPreviewSize when size is not positive). Neither the full
+// image nor a full-resolution output is encoded, so previews stay fast on
+// large inputs.
+func PreviewBytes(data []byte, p Profile, size int) (_ Preview, err error) {
+	defer recoverPanic("PreviewBytes", data, &err)
+	if len(data) == 0 {
+		return Preview{}, fmt.Errorf("empty image data")
+	}
+	if size <= 0 {
+		size = DefaultPreviewSize
+	}
+
+	release, err := reserveDecode(context.Background(), data)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer release()
+
+	img, format, err := decodeContext(context.Background(), data)
+	if err != nil {
+		return Preview{}, err
+	}
+
+	bounds := img.Bounds()
+	pv := Preview{Width: bounds.Dx(), Height: bounds.Dy(), Format: format}
+	d, err := DetectResult(img, p)
+	pv.Present, pv.Score, pv.Correlation, pv.Confidence, pv.Info = d.Present, d.Score, d.Correlation, d.Confidence, d.Info
+	if err != nil || !pv.Present {
+		return pv, err
+	}
+
+	margin := pv.Info.Size / 2
+	pv.Region = pv.Info.Position.Inset(-margin).Intersect(bounds)
+	cleaned, err := Default().removeWithin(context.Background(), img, pv.Region, pv.Info, p)
+	if err != nil {
+		return pv, err
+	}
+
+	pv.Image, err = EncodePNGToBytes(shrinkToFit(cleaned, size))
+	if err != nil {
+		return pv, err
+	}
+	return pv, nil
+}
+
+// PreviewRemoval applies the default engine to the watermark rectangle of
+// img only. See Engine.PreviewRemoval.
+func PreviewRemoval(img image.Image) (cornerBefore, cornerAfter image.Image, info Info, err error) {
+	return Default().PreviewRemoval(img)
+}
+
+// PreviewRemoval removes the Gemini watermark like RemoveWatermark but
+// processes and returns only the watermark rectangle (info.Position), so
+// interfaces can show an instant before and after without cloning the full
+// image. cornerBefore shares img's pixels when img supports SubImage; both
+// corners keep img's coordinates.
+func (e *Engine) PreviewRemoval(img image.Image) (cornerBefore, cornerAfter image.Image, info Info, err error) {
+	p := GeminiProfile()
+	info, err = e.placement(img, p)
+	if err != nil {
+		return nil, nil, Info{}, err
+	}
+	after, err := e.removeWithin(context.Background(), img, info.Position, info, p)
+	if err != nil {
+		return nil, nil, Info{}, err
+	}
+	return subImage(img, info.Position), after, info, nil
+}
+
+// shrinkToFit scales img down so its longer side is at most size pixels,
+// keeping the aspect ratio. Smaller images are returned unchanged.
+func shrinkToFit(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= size && h <= size {
+		return img
+	}
+	if w >= h {
+		w, h = size, max(1, h*size/w)
+	} else {
+		w, h = max(1, w*size/h), size
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	xdraw.BiLinear.Scale(dst, dst.Bounds(), img, b, xdraw.Src, nil)
+	return dst
+}
+
+// subImage returns the region r of img, sharing its pixels when img supports
+// it.
+func subImage(img image.Image, r image.Rectangle) image.Image {
+	if s, ok := img.(interface {
+		SubImage(image.Rectangle) image.Image
+	}); ok {
+		return s.SubImage(r)
+	}
+	return cloneToRGBA(img).SubImage(r)
+}
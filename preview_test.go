@@ -0,0 +1,53 @@
+package watermark
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreviewBytesClean(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "clean.webp"))
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+	pv, err := PreviewBytes(data, GeminiProfile(), 0)
+	if err != nil {
+		t.Fatalf("PreviewBytes: %v", err)
+	}
+	if pv.Present || pv.Image != nil || pv.Format != "webp" {
+		t.Fatalf("preview = %+v", pv)
+	}
+}
+
+func TestPreviewRemovalMatchesFullRemoval(t *testing.T) {
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{R: 40, G: 60, B: 90, A: 255})
+	full, err := RemoveWatermark(img)
+	if err != nil {
+		t.Fatalf("RemoveWatermark: %v", err)
+	}
+
+	before, after, info, err := PreviewRemoval(img)
+	if err != nil {
+		t.Fatalf("PreviewRemoval: %v", err)
+	}
+	if before.Bounds() != info.Position || after.Bounds() != info.Position {
+		t.Fatalf("corners %v and %v, watermark %v", before.Bounds(), after.Bounds(), info.Position)
+	}
+	for y := info.Position.Min.Y; y < info.Position.Max.Y; y++ {
+		for x := info.Position.Min.X; x < info.Position.Max.X; x++ {
+			if before.At(x, y) != img.At(x, y) {
+				t.Fatalf("before differs from input at (%d,%d)", x, y)
+			}
+			if after.At(x, y) != full.At(x, y) {
+				t.Fatalf("after differs from full removal at (%d,%d): %v vs %v", x, y, after.At(x, y), full.At(x, y))
+			}
+		}
+	}
+
+	if _, _, _, err := PreviewRemoval(image.NewRGBA(image.Rect(0, 0, 10, 10))); err == nil {
+		t.Fatalf("expected an error for an image too small for the watermark")
+	}
+}
@@ -0,0 +1,65 @@
+//go:build !watermark_nov1
+
+package watermark
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPreviewBytes(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("cmd", "gwatermark", "image.png"))
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+	full, present, _, _, err := RemoveWatermarkBytes(data)
+	if err != nil || !present {
+		t.Fatalf("RemoveWatermarkBytes: present %v, %v", present, err)
+	}
+	cleaned, err := png.Decode(bytes.NewReader(full))
+	if err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+
+	// Unscaled, the preview is exactly the cleaned corner.
+	pv, err := PreviewBytes(data, GeminiProfile(), 1<<20)
+	if err != nil || !pv.Present {
+		t.Fatalf("PreviewBytes: %+v, %v", pv, err)
+	}
+	if !pv.Info.Position.In(pv.Region) || !pv.Region.In(image.Rect(0, 0, pv.Width, pv.Height)) {
+		t.Fatalf("region %v, watermark %v, image %dx%d", pv.Region, pv.Info.Position, pv.Width, pv.Height)
+	}
+	corner, err := png.Decode(bytes.NewReader(pv.Image))
+	if err != nil {
+		t.Fatalf("decode preview: %v", err)
+	}
+	if corner.Bounds().Size() != pv.Region.Size() {
+		t.Fatalf("preview size %v, region %v", corner.Bounds().Size(), pv.Region.Size())
+	}
+	for y := 0; y < pv.Region.Dy(); y++ {
+		for x := 0; x < pv.Region.Dx(); x++ {
+			got := color.RGBAModel.Convert(corner.At(x, y))
+			want := color.RGBAModel.Convert(cleaned.At(pv.Region.Min.X+x, pv.Region.Min.Y+y))
+			if got != want {
+				t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	pv, err = PreviewBytes(data, GeminiProfile(), 32)
+	if err != nil {
+		t.Fatalf("PreviewBytes: %v", err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(pv.Image))
+	if err != nil {
+		t.Fatalf("decode preview: %v", err)
+	}
+	if max(cfg.Width, cfg.Height) != 32 {
+		t.Fatalf("preview %dx%d, want longer side 32", cfg.Width, cfg.Height)
+	}
+}
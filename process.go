@@ -0,0 +1,155 @@
+package watermark
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+)
+
+// ProcessBytes removes the watermark from raw image bytes and reports the
+// outcome as a Result. Single images are encoded per opts.Output, PNG by
+// default as in RemoveWatermarkBytes; 16-bit PNGs stay 16-bit as PNG output.
+// Animated GIFs and WebPs are processed frame by frame and re-encoded in
+// their own format, with failing frames handled per opts.FrameErrorPolicy.
+func ProcessBytes(input []byte, opts Options) (Result, error) {
+	return ProcessBytesContext(context.Background(), input, opts)
+}
+
+// ProcessBytesContext is ProcessBytes with cancellation, for servers that
+// tie the work to a request or bound it with a deadline. Waiting for the
+// memory budget, decoding, removal and PNG or JPEG encoding stop once ctx is
+// done, as does animated input between frames, and ctx.Err() is returned.
+func ProcessBytesContext(ctx context.Context, input []byte, opts Options) (_ Result, err error) {
+	defer recoverPanic("ProcessBytes", input, &err)
+	if len(input) == 0 {
+		return Result{}, fmt.Errorf("empty image data")
+	}
+
+	release, err := reserveDecode(ctx, input)
+	if err != nil {
+		return Result{}, err
+	}
+	defer release()
+
+	var (
+		img      image.Image
+		format   string
+		salvaged *CorruptImageError
+	)
+	switch {
+	case bytes.HasPrefix(input, []byte("GIF8")):
+		engine := Default()
+		engine.report(StageDecode, 0)
+		g, err := gif.DecodeAll(ctxReader{ctx, engine.decodeReader(input)})
+		if err := ctxErr(ctx, err); err != nil {
+			return Result{}, fmt.Errorf("decode gif: %w", err)
+		}
+		engine.report(StageDecode, 100)
+		if len(g.Image) > 1 {
+			result, err := processGIF(ctx, g, opts)
+			if err != nil {
+				return Result{}, err
+			}
+			return finishResult(result, input, "gif", opts)
+		}
+		// A single frame is what image.Decode returns for a GIF.
+		img, format = g.Image[0], "gif"
+
+	case webpAnimated(input):
+		result, err := processWebPAnimation(ctx, input, opts)
+		if err != nil {
+			return Result{}, err
+		}
+		return finishResult(result, input, "webp", opts)
+
+	default:
+		img, format, err = decodeContext(ctx, input)
+		if err != nil && opts.Salvage && ctx.Err() == nil {
+			img, salvaged, err = salvageInput(input, err, opts.profile())
+			if err == nil {
+				format = salvaged.Format
+			}
+		}
+		if err != nil {
+			return Result{}, err
+		}
+	}
+
+	result, err := removeAndEncode(ctx, img, input, opts.profile(), opts.Output.Resolve(format), opts)
+	if err != nil {
+		return Result{}, err
+	}
+	if salvaged != nil {
+		result.Warnings = append(result.Warnings, Warning{WarnSalvaged, salvaged.Error()})
+	}
+	return finishResult(result, input, format, opts)
+}
+
+// SalvageImageBytes decodes data like DecodeImageBytes, but with
+// Options.Salvage semantics: a truncated or damaged PNG or JPEG decodes as
+// far as it can, and the returned *CorruptImageError describes the damage.
+// Inputs whose rows holding the watermark of profile p are lost fail with a
+// *CorruptImageError.
+func SalvageImageBytes(data []byte, p Profile) (image.Image, string, *CorruptImageError, error) {
+	img, format, err := DecodeImageBytes(data)
+	if err == nil {
+		return img, format, nil, nil
+	}
+	img, cerr, err := salvageInput(data, err, p)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return img, cerr.Format, cerr, nil
+}
+
+// salvageInput salvages an input that failed to decode with decodeErr. The
+// watermark placement of p must lie within the intact rows; CornerAuto
+// profiles are checked at the bottom-right corner.
+func salvageInput(input []byte, decodeErr error, p Profile) (image.Image, *CorruptImageError, error) {
+	img, cerr := salvage(input, decodeErr)
+	if cerr == nil {
+		return nil, nil, decodeErr
+	}
+	if img == nil {
+		return nil, nil, cerr
+	}
+	if info, ok := p.Placement(img.Bounds().Dx(), cerr.Height); ok && info.Position.Max.Y > cerr.Rows {
+		cerr.Reason += "; watermark region not decoded"
+		return nil, nil, cerr
+	}
+	return img, cerr, nil
+}
+
+// ErrSizeGrowth is returned when the re-encoded output exceeds
+// Options.MaxGrowth times the input size.
+var ErrSizeGrowth = errors.New("output size growth exceeds limit")
+
+// finishResult applies pass-through, records the input size and enforces
+// opts.MaxGrowth. The result is returned even when the growth check fails so
+// callers can report the sizes.
+func finishResult(result Result, input []byte, format string, opts Options) (Result, error) {
+	result = passThrough(result, input, format, opts)
+	result.InputSize = len(input)
+
+	if opts.MaxGrowth > 0 && result.Present {
+		if growth := result.Growth(); growth > opts.MaxGrowth {
+			return result, fmt.Errorf("%w: output is %.2fx the input (%d -> %d bytes, limit %.2fx)",
+				ErrSizeGrowth, growth, result.InputSize, len(result.Output), opts.MaxGrowth)
+		}
+	}
+	return result, nil
+}
+
+// passThrough fills Output with the unmodified input when no watermark was
+// found and opts.PassThrough is set. The input slice is shared, not copied.
+func passThrough(result Result, input []byte, format string, opts Options) Result {
+	if result.Present || !opts.PassThrough {
+		return result
+	}
+	result.Output = input
+	result.Format = format
+	return result
+}
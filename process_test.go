@@ -0,0 +1,291 @@
+package watermark
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestProcessBytesAnimatedGIF(t *testing.T) {
+	data := encodeTestGIF(t, 320, 240, 3)
+
+	result, err := ProcessBytes(data, Options{})
+	if err != nil {
+		t.Fatalf("ProcessBytes: %v", err)
+	}
+	if !result.Present || result.Format != "gif" {
+		t.Fatalf("expected cleaned gif, got present=%v format=%q", result.Present, result.Format)
+	}
+	if len(result.Frames) != 3 {
+		t.Fatalf("expected 3 frame results, got %d", len(result.Frames))
+	}
+	for _, fr := range result.Frames {
+		if fr.Outcome != FrameCleaned {
+			t.Fatalf("frame %d: outcome %v (score %.2f)", fr.Index, fr.Outcome, fr.Score)
+		}
+	}
+
+	out, err := gif.DecodeAll(bytes.NewReader(result.Output))
+	if err != nil {
+		t.Fatalf("decode output: %v", err)
+	}
+	if len(out.Image) != 3 {
+		t.Fatalf("expected 3 output frames, got %d", len(out.Image))
+	}
+
+	present, score, _, err := DetectWatermark(out.Image[0])
+	if err != nil {
+		t.Fatalf("detect cleaned frame: %v", err)
+	}
+	if present {
+		t.Fatalf("watermark still detected in cleaned frame (score %.2f)", score)
+	}
+}
+
+func TestProcessBytesFrameErrorPolicy(t *testing.T) {
+	// Frames too small for the watermark rectangle fail individually.
+	data := encodeTestGIF(t, 40, 40, 2)
+
+	if _, err := ProcessBytes(data, Options{FrameErrorPolicy: FrameAbort}); err == nil {
+		t.Fatalf("expected abort policy to return an error")
+	}
+
+	if _, err := ProcessBytes(data, Options{FrameErrorPolicy: FrameSkip}); err != nil {
+		t.Fatalf("skip policy: %v", err)
+	}
+
+	result, err := ProcessBytes(data, Options{FrameErrorPolicy: FrameCopyOriginal})
+	if err != nil {
+		t.Fatalf("copy policy: %v", err)
+	}
+	for _, fr := range result.Frames {
+		if fr.Outcome != FrameCopied || fr.Err == nil {
+			t.Fatalf("frame %d: outcome %v err %v", fr.Index, fr.Outcome, fr.Err)
+		}
+	}
+}
+
+// watermarkedRGBA returns a flat-colored image with the Gemini watermark
+// forward-blended at its expected position.
+func watermarkedRGBA(t testing.TB, width, height int, bg color.RGBA) *image.RGBA {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
+	return stampWatermark(t, img)
+}
+
+// stampWatermark blends the Gemini logo into img at its default placement.
+func stampWatermark(t testing.TB, img *image.RGBA) *image.RGBA {
+	t.Helper()
+
+	cfg := DetectWatermarkConfig(img.Bounds().Dx(), img.Bounds().Dy())
+	rect, err := calculateWatermarkRect(img.Bounds(), cfg)
+	if err != nil {
+		return img
+	}
+
+	alphaMap, err := detectAlphaMap(cfg.LogoSize)
+	if err != nil {
+		t.Fatalf("alpha map: %v", err)
+	}
+
+	for row := 0; row < rect.Dy(); row++ {
+		for col := 0; col < rect.Dx(); col++ {
+			alpha := float64(alphaMap[row*rect.Dx()+col])
+			offset := img.PixOffset(rect.Min.X+col, rect.Min.Y+row)
+			for c := 0; c < 3; c++ {
+				v := alpha*logoValue + (1-alpha)*float64(img.Pix[offset+c])
+				img.Pix[offset+c] = uint8(v + 0.5)
+			}
+		}
+	}
+
+	return img
+}
+
+func encodeTestGIF(t *testing.T, width, height, frames int) []byte {
+	t.Helper()
+
+	src := watermarkedRGBA(t, width, height, color.RGBA{R: 40, G: 60, B: 90, A: 255})
+
+	g := &gif.GIF{}
+	for i := 0; i < frames; i++ {
+		frame := image.NewPaletted(src.Bounds(), palette.Plan9)
+		draw.Draw(frame, frame.Bounds(), src, image.Point{}, draw.Src)
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 10)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatalf("encode gif: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessBytesPassThrough(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, image.NewPaletted(image.Rect(0, 0, 320, 240), palette.Plan9), nil); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	input := buf.Bytes()
+
+	result, err := ProcessBytes(input, Options{})
+	if err != nil {
+		t.Fatalf("ProcessBytes: %v", err)
+	}
+	if result.Present || result.Output != nil {
+		t.Fatalf("expected no output without pass-through, got %d bytes", len(result.Output))
+	}
+
+	result, err = ProcessBytes(input, Options{PassThrough: true})
+	if err != nil {
+		t.Fatalf("ProcessBytes pass-through: %v", err)
+	}
+	if result.Format != "gif" || len(result.Output) != len(input) || &result.Output[0] != &input[0] {
+		t.Fatalf("expected the original bytes back, got format %q len %d", result.Format, len(result.Output))
+	}
+}
+
+func TestProcessBytesMaxGrowth(t *testing.T) {
+	var buf bytes.Buffer
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{R: 40, G: 60, B: 90, A: 255})
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	input := buf.Bytes()
+
+	result, err := ProcessBytes(input, Options{})
+	if err != nil {
+		t.Fatalf("ProcessBytes: %v", err)
+	}
+	if result.InputSize != len(input) {
+		t.Fatalf("InputSize = %d, want %d", result.InputSize, len(input))
+	}
+	want := float64(len(result.Output)) / float64(len(input))
+	if !result.Present || result.Growth() != want {
+		t.Fatalf("Growth = %.3f, want %.3f (present=%v)", result.Growth(), want, result.Present)
+	}
+
+	result, err = ProcessBytes(input, Options{MaxGrowth: want / 2})
+	if !errors.Is(err, ErrSizeGrowth) {
+		t.Fatalf("expected ErrSizeGrowth, got %v", err)
+	}
+	if result.InputSize != len(input) || len(result.Output) == 0 {
+		t.Fatalf("expected sizes to be reported alongside the error")
+	}
+}
+
+func TestProcessBytesOutputFormat(t *testing.T) {
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{R: 40, G: 60, B: 90, A: 255})
+	var jpg bytes.Buffer
+	if err := jpeg.Encode(&jpg, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	png, err := EncodePNGToBytes(img)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	lossy, err := EncodeWebPToBytes(img, &WebPOptions{Lossy: true})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		input  []byte
+		opts   Options
+		format string
+		lossy  bool
+	}{
+		{name: "default", input: jpg.Bytes(), format: "png"},
+		{name: "jpeg", input: png, opts: Options{Output: OutputJPEG}, format: "jpeg"},
+		{name: "source jpeg", input: jpg.Bytes(), opts: Options{Output: OutputSource, JPEGQuality: 80}, format: "jpeg"},
+		{name: "source png", input: png, opts: Options{Output: OutputSource}, format: "png"},
+		{name: "webp", input: jpg.Bytes(), opts: Options{Output: OutputWebP}, format: "webp"},
+		{name: "webp lossy", input: png, opts: Options{Output: OutputWebP, WebPLossy: true, WebPQuality: 80}, format: "webp", lossy: true},
+		{name: "source webp", input: lossy, opts: Options{Output: OutputSource}, format: "webp", lossy: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result, err := ProcessBytes(tc.input, tc.opts)
+			if err != nil {
+				t.Fatalf("ProcessBytes: %v", err)
+			}
+			if result.Format != tc.format {
+				t.Fatalf("Format = %q, want %q", result.Format, tc.format)
+			}
+			if _, format, err := DecodeImageBytes(result.Output); err != nil || format != tc.format {
+				t.Fatalf("output decodes as %q, %v", format, err)
+			}
+			if tc.format == "webp" && webpLossy(result.Output) != tc.lossy {
+				t.Fatalf("lossy WebP = %v, want %v", !tc.lossy, tc.lossy)
+			}
+		})
+	}
+
+	// JPEG output keeps a JPEG input close to its size instead of
+	// ballooning into a PNG.
+	result, err := ProcessBytes(jpg.Bytes(), Options{Output: OutputSource, JPEGQuality: 90})
+	if err != nil {
+		t.Fatalf("ProcessBytes: %v", err)
+	}
+	if g := result.Growth(); g > 1.5 {
+		t.Fatalf("JPEG output growth %.2f", g)
+	}
+}
+
+func TestProcessBytesReportsDetection(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("cmd", "gwatermark", "image.png"))
+	if err != nil {
+		t.Fatalf("read input: %v", err)
+	}
+	want, err := DetectResultBytes(input, GeminiProfile())
+	if err != nil {
+		t.Fatalf("DetectResultBytes: %v", err)
+	}
+
+	result, err := ProcessBytes(input, Options{})
+	if err != nil {
+		t.Fatalf("ProcessBytes: %v", err)
+	}
+	if !result.Present || result.Score != want.Score || result.Correlation != want.Correlation || result.Confidence != want.Confidence {
+		t.Fatalf("result = present %v, score %.2f, correlation %.3f, confidence %v; want %+v",
+			result.Present, result.Score, result.Correlation, result.Confidence, want)
+	}
+}
+
+func TestProcessBytesDecodesSingleFrameGIFOnce(t *testing.T) {
+	var buf bytes.Buffer
+	if err := gif.Encode(&buf, watermarkedRGBA(t, 320, 240, color.RGBA{40, 60, 80, 255}), nil); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	decodes := 0
+	SetDefaultEngine(NewEngine(WithProgress(func(stage string, pct float64) {
+		if stage == StageDecode && pct == 0 {
+			decodes++
+		}
+	})))
+	defer SetDefaultEngine(nil)
+
+	result, err := ProcessBytes(buf.Bytes(), Options{})
+	if err != nil {
+		t.Fatalf("ProcessBytes: %v", err)
+	}
+	if decodes != 1 {
+		t.Fatalf("decoded %d times, want once", decodes)
+	}
+	if len(result.Frames) != 0 {
+		t.Fatalf("single-frame GIF reported %d frames", len(result.Frames))
+	}
+}
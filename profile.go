@@ -0,0 +1,139 @@
+package watermark
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// Variant is one watermark size and placement rule within a Profile.
+type Variant struct {
+	// MinWidth and MinHeight are the inclusive minimum image dimensions for
+	// the variant to apply.
+	MinWidth  int
+	MinHeight int
+
+	LogoSize int
+	// MarginRight and MarginBottom are fixed margins in pixels, as Gemini
+	// uses.
+	MarginRight  int
+	MarginBottom int
+
+	// MarginRightPercent and MarginBottomPercent, when positive, replace the
+	// fixed margins with a percentage of the image width and height
+	// respectively, for exports that scale margins with the image.
+	MarginRightPercent  float64
+	MarginBottomPercent float64
+	// MarginRounding converts percentage margins to whole pixels. The zero
+	// value rounds halves up.
+	MarginRounding RoundingMode
+
+	// Mask is a custom logo capture rendered over black, as for
+	// RemoveWithMask. It is resampled to LogoSize when the sizes differ. Nil
+	// uses the embedded Gemini capture.
+	Mask image.Image
+}
+
+// margins resolves the variant's margins in pixels for an image of the given
+// size.
+func (v Variant) margins(width, height int) (right, bottom int) {
+	right, bottom = v.MarginRight, v.MarginBottom
+	if v.MarginRightPercent > 0 {
+		right = int(v.MarginRounding.round(float64(width) * v.MarginRightPercent / 100))
+	}
+	if v.MarginBottomPercent > 0 {
+		bottom = int(v.MarginRounding.round(float64(height) * v.MarginBottomPercent / 100))
+	}
+	return right, bottom
+}
+
+// Profile describes how a generator places its visible watermark. Variants
+// are evaluated in order and the first one whose minimum dimensions fit the
+// image is used. A profile without variants never detects a watermark, which
+// makes it suitable for passing through images from other generators.
+type Profile struct {
+	Name     string
+	Variants []Variant
+	// Corner anchors the watermark. Variant margins are measured from the
+	// edges meeting at this corner, so MarginRight and MarginBottom are the
+	// horizontal and vertical margins for corners other than the default
+	// bottom-right.
+	Corner Corner
+	// LogoColor is the color of the watermark logo. Nil means white.
+	LogoColor color.Color
+	// Luminance selects how detection measures brightness. The zero value
+	// keeps the historical gamma-encoded Rec. 709 luma.
+	Luminance LuminanceMode
+
+	// MinScore is the brightness lift, in luma levels, that a placement must
+	// exceed to count as a watermark. Zero uses the Gemini default of 6.
+	MinScore float64
+	// MinCorrelation is the required correlation between the brightness
+	// lift and the logo shape. Zero uses the Gemini default of 0.30.
+	MinCorrelation float64
+}
+
+// GeminiProfile returns the profile for Gemini's visible watermark: 96x96
+// with 64px margins when both dimensions exceed 1024, otherwise 48x48 with
+// 32px margins.
+func GeminiProfile() Profile {
+	return Profile{
+		Name: "gemini",
+		Variants: []Variant{
+			{MinWidth: 1025, MinHeight: 1025, LogoSize: 96, MarginRight: 64, MarginBottom: 64},
+			{LogoSize: 48, MarginRight: 32, MarginBottom: 32},
+		},
+	}
+}
+
+// PassthroughProfile returns a profile that never detects a watermark. Use it
+// for images from generators that do not add one.
+func PassthroughProfile() Profile {
+	return Profile{Name: "none"}
+}
+
+// config selects the watermark parameters for an image of the given size. It
+// reports false when no variant applies.
+func (p Profile) config(width, height int) (watermarkConfig, bool) {
+	for _, v := range p.Variants {
+		if width >= v.MinWidth && height >= v.MinHeight {
+			right, bottom := v.margins(width, height)
+			return watermarkConfig{LogoSize: v.LogoSize, MarginRight: right, MarginBottom: bottom, Corner: p.Corner, mask: v.Mask}, true
+		}
+	}
+	return watermarkConfig{}, false
+}
+
+// Placement returns the native watermark size and rectangle that p assigns to
+// an image of the given size, reporting false when no variant applies or the
+// rectangle does not fit. CornerAuto yields the bottom-right placement; the
+// actual corner is only known after detection.
+func (p Profile) Placement(width, height int) (Info, bool) {
+	cfg, ok := p.config(width, height)
+	if !ok {
+		return Info{}, false
+	}
+	rect, err := calculateWatermarkRect(image.Rect(0, 0, width, height), cfg)
+	if err != nil {
+		return Info{}, false
+	}
+	return Info{Size: cfg.LogoSize, Position: rect, Corner: cfg.Corner}, true
+}
+
+// logo returns the per-channel logo color used by the reverse blend.
+func (p Profile) logo() [3]float64 {
+	if p.LogoColor == nil {
+		return whiteLogo
+	}
+	c := color.NRGBAModel.Convert(p.LogoColor).(color.NRGBA)
+	return [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+}
+
+func (p Profile) detectParams() detectParams {
+	return detectParams{luminance: p.Luminance, minScore: p.MinScore, minCorrelation: p.MinCorrelation}
+}
+
+// noVariantError reports that a profile has no rule for the image size.
+func (p Profile) noVariantError(width, height int) error {
+	return fmt.Errorf("profile %q has no watermark variant for %dx%d", p.Name, width, height)
+}
@@ -0,0 +1,56 @@
+package watermark
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestProfileDetectionGates(t *testing.T) {
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{R: 40, G: 60, B: 90, A: 255})
+
+	present, score, _, err := DetectWatermarkProfile(img, GeminiProfile())
+	if err != nil || !present {
+		t.Fatalf("default gates: present=%v err=%v", present, err)
+	}
+
+	strict := GeminiProfile()
+	strict.MinScore = score + 1
+	if present, _, _, _ := DetectWatermarkProfile(img, strict); present {
+		t.Fatalf("expected MinScore %.2f to reject score %.2f", strict.MinScore, score)
+	}
+
+	strict = GeminiProfile()
+	strict.MinCorrelation = 1
+	if present, _, _, _ := DetectWatermarkProfile(img, strict); present {
+		t.Fatalf("expected MinCorrelation 1 to reject the placement")
+	}
+}
+
+func TestPercentMargins(t *testing.T) {
+	p := Profile{Name: "percent", Variants: []Variant{{
+		LogoSize:            48,
+		MarginRightPercent:  2.5,
+		MarginBottomPercent: 2.5,
+		MarginRounding:      RoundTruncate,
+	}}}
+
+	info, ok := p.Placement(330, 250)
+	if !ok {
+		t.Fatalf("expected a placement")
+	}
+	// 2.5% of 330 is 8.25 and of 250 is 6.25, truncated to 8 and 6.
+	if want := image.Rect(330-8-48, 250-6-48, 330-8, 250-6); info.Position != want {
+		t.Fatalf("placement %v, want %v", info.Position, want)
+	}
+
+	// 10% of 325 is 32.5, which each rounding mode resolves differently.
+	p.Variants[0].MarginRightPercent = 10
+	for mode, want := range map[RoundingMode]int{RoundHalfUp: 33, RoundTruncate: 32, RoundHalfEven: 32} {
+		p.Variants[0].MarginRounding = mode
+		info, _ := p.Placement(325, 250)
+		if got := 325 - info.Position.Max.X; got != want {
+			t.Fatalf("%v: right margin %d, want %d", mode, got, want)
+		}
+	}
+}
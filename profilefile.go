@@ -0,0 +1,210 @@
+package watermark
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ProfileError lists every problem found while validating a profile file.
+type ProfileError struct {
+	// Source names the file the profile was loaded from.
+	Source   string
+	Problems []string
+}
+
+func (e *ProfileError) Error() string {
+	return fmt.Sprintf("invalid profile %s: %s", e.Source, strings.Join(e.Problems, "; "))
+}
+
+// profileFile is the JSON schema of a profile:
+//
+//	{
+//	  "name": "meta",
+//	  "corner": "br",               // br, bl, tr, tl or auto
+//	  "logoColor": "#ffffff",
+//	  "luminance": "gamma",         // gamma or linear
+//	  "minScore": 6,
+//	  "minCorrelation": 0.3,
+//	  "variants": [{
+//	    "minWidth": 1025, "minHeight": 1025,
+//	    "logoSize": 96,
+//	    "marginRight": 64, "marginBottom": 64,
+//	    "marginRightPercent": 0, "marginBottomPercent": 0,
+//	    "marginRounding": "half-up", // half-up, truncate or half-even
+//	    "asset": "logo_96.png"       // relative to the profile file
+//	  }]
+//	}
+//
+// Every field except name and variants is optional.
+type profileFile struct {
+	Name           string        `json:"name"`
+	Corner         string        `json:"corner"`
+	LogoColor      string        `json:"logoColor"`
+	Luminance      string        `json:"luminance"`
+	MinScore       float64       `json:"minScore"`
+	MinCorrelation float64       `json:"minCorrelation"`
+	Variants       []variantFile `json:"variants"`
+}
+
+type variantFile struct {
+	MinWidth            int     `json:"minWidth"`
+	MinHeight           int     `json:"minHeight"`
+	LogoSize            int     `json:"logoSize"`
+	MarginRight         int     `json:"marginRight"`
+	MarginBottom        int     `json:"marginBottom"`
+	MarginRightPercent  float64 `json:"marginRightPercent"`
+	MarginBottomPercent float64 `json:"marginBottomPercent"`
+	MarginRounding      string  `json:"marginRounding"`
+	Asset               string  `json:"asset"`
+}
+
+// LoadProfileFile reads a JSON profile from disk. Asset paths resolve
+// relative to the file's directory.
+func LoadProfileFile(name string) (Profile, error) {
+	return LoadProfile(os.DirFS(filepath.Dir(name)), filepath.Base(name))
+}
+
+// LoadProfile reads and validates the JSON profile name from fsys. Asset
+// paths resolve relative to the profile's directory within fsys. Schema
+// violations are reported together as a *ProfileError.
+func LoadProfile(fsys fs.FS, name string) (Profile, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return Profile{}, fmt.Errorf("read profile: %w", err)
+	}
+
+	var pf profileFile
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&pf); err != nil {
+		return Profile{}, &ProfileError{Source: name, Problems: []string{err.Error()}}
+	}
+
+	var problems []string
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	p := Profile{Name: pf.Name, MinScore: pf.MinScore, MinCorrelation: pf.MinCorrelation}
+	if pf.Name == "" {
+		fail("name is required")
+	}
+	if pf.Corner != "" {
+		if c, ok := ParseCorner(pf.Corner); ok {
+			p.Corner = c
+		} else {
+			fail("corner %q is not one of br, bl, tr, tl, auto", pf.Corner)
+		}
+	}
+	if pf.LogoColor != "" {
+		if c, ok := parseHexColor(pf.LogoColor); ok {
+			p.LogoColor = c
+		} else {
+			fail("logoColor %q is not a #rrggbb color", pf.LogoColor)
+		}
+	}
+	switch pf.Luminance {
+	case "", "gamma":
+	case "linear":
+		p.Luminance = LumaLinear
+	default:
+		fail("luminance %q is not gamma or linear", pf.Luminance)
+	}
+	if pf.MinScore < 0 {
+		fail("minScore must not be negative")
+	}
+	if pf.MinCorrelation < 0 || pf.MinCorrelation > 1 {
+		fail("minCorrelation must be between 0 and 1")
+	}
+
+	for i, vf := range pf.Variants {
+		v, vp := vf.variant(fsys, path.Dir(name))
+		for _, problem := range vp {
+			fail("variants[%d]: %s", i, problem)
+		}
+		p.Variants = append(p.Variants, v)
+	}
+
+	if len(problems) > 0 {
+		return Profile{}, &ProfileError{Source: name, Problems: problems}
+	}
+	return p, nil
+}
+
+// variant converts and validates one variant, loading its asset from dir.
+func (vf variantFile) variant(fsys fs.FS, dir string) (Variant, []string) {
+	var problems []string
+	fail := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	v := Variant{
+		MinWidth:            vf.MinWidth,
+		MinHeight:           vf.MinHeight,
+		LogoSize:            vf.LogoSize,
+		MarginRight:         vf.MarginRight,
+		MarginBottom:        vf.MarginBottom,
+		MarginRightPercent:  vf.MarginRightPercent,
+		MarginBottomPercent: vf.MarginBottomPercent,
+	}
+
+	if vf.LogoSize <= 0 {
+		fail("logoSize must be positive")
+	}
+	if vf.MinWidth < 0 || vf.MinHeight < 0 || vf.MarginRight < 0 || vf.MarginBottom < 0 {
+		fail("sizes and margins must not be negative")
+	}
+	if vf.MarginRightPercent < 0 || vf.MarginRightPercent >= 100 || vf.MarginBottomPercent < 0 || vf.MarginBottomPercent >= 100 {
+		fail("margin percentages must be in [0, 100)")
+	}
+	if vf.MarginRounding != "" {
+		if mode, ok := ParseRoundingMode(vf.MarginRounding); ok {
+			v.MarginRounding = mode
+		} else {
+			fail("marginRounding %q is not half-up, truncate or half-even", vf.MarginRounding)
+		}
+	}
+
+	if vf.Asset != "" {
+		mask, err := loadAsset(fsys, path.Join(dir, vf.Asset))
+		if err != nil {
+			fail("asset %q: %v", vf.Asset, err)
+		} else if b := mask.Bounds(); b.Dx() != b.Dy() {
+			fail("asset %q is %dx%d, want a square capture", vf.Asset, b.Dx(), b.Dy())
+		} else {
+			v.Mask = mask
+		}
+	}
+
+	return v, problems
+}
+
+func loadAsset(fsys fs.FS, name string) (image.Image, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := DecodeImageBytes(data)
+	return img, err
+}
+
+// parseHexColor parses #rrggbb.
+func parseHexColor(s string) (color.Color, bool) {
+	if len(s) != 7 || s[0] != '#' {
+		return nil, false
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return nil, false
+	}
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, true
+}
@@ -0,0 +1,90 @@
+package watermark
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadProfileColoredLogo(t *testing.T) {
+	asset, err := os.ReadFile(filepath.Join("assets", "bg_48.png"))
+	if err != nil {
+		t.Fatalf("read asset: %v", err)
+	}
+	fsys := fstest.MapFS{
+		"profiles/red.json": {Data: []byte(`{
+			"name": "red",
+			"logoColor": "#ff2000",
+			"variants": [{"logoSize": 48, "marginRight": 32, "marginBottom": 32, "asset": "red_48.png"}]
+		}`)},
+		"profiles/red_48.png": {Data: asset},
+	}
+
+	p, err := LoadProfile(fsys, "profiles/red.json")
+	if err != nil {
+		t.Fatalf("LoadProfile: %v", err)
+	}
+	if p.Name != "red" || len(p.Variants) != 1 || p.Variants[0].Mask == nil {
+		t.Fatalf("unexpected profile %+v", p)
+	}
+
+	// Forward blend the logo in red rather than white.
+	bg := color.RGBA{R: 40, G: 60, B: 90, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 320, 240))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: bg}, image.Point{}, draw.Src)
+	info, _ := p.Placement(320, 240)
+	alphaMap := maskAlpha(p.Variants[0].Mask, 48)
+	logo := [3]float64{255, 32, 0}
+	for row := 0; row < 48; row++ {
+		for col := 0; col < 48; col++ {
+			alpha := float64(alphaMap[row*48+col])
+			offset := img.PixOffset(info.Position.Min.X+col, info.Position.Min.Y+row)
+			for c := 0; c < 3; c++ {
+				img.Pix[offset+c] = uint8(alpha*logo[c] + (1-alpha)*float64(img.Pix[offset+c]) + 0.5)
+			}
+		}
+	}
+
+	cleaned, err := NewEngine().RemoveWatermarkProfile(img, p)
+	if err != nil {
+		t.Fatalf("RemoveWatermarkProfile: %v", err)
+	}
+	for y := info.Position.Min.Y; y < info.Position.Max.Y; y++ {
+		for x := info.Position.Min.X; x < info.Position.Max.X; x++ {
+			got := cleaned.RGBAAt(x, y)
+			if absDiff(got.R, bg.R) > 3 || absDiff(got.G, bg.G) > 3 || absDiff(got.B, bg.B) > 3 {
+				t.Fatalf("pixel (%d,%d) = %v, want about %v", x, y, got, bg)
+			}
+		}
+	}
+}
+
+func TestLoadProfileValidation(t *testing.T) {
+	fsys := fstest.MapFS{
+		"bad.json": {Data: []byte(`{
+			"corner": "middle",
+			"minCorrelation": 2,
+			"variants": [{"logoSize": 0, "asset": "missing.png"}]
+		}`)},
+		"unknown.json": {Data: []byte(`{"name": "x", "logoSzie": 48}`)},
+	}
+
+	_, err := LoadProfile(fsys, "bad.json")
+	var perr *ProfileError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expected ProfileError, got %v", err)
+	}
+	// name, corner, minCorrelation, logoSize and asset.
+	if len(perr.Problems) != 5 {
+		t.Fatalf("expected 5 problems, got %q", perr.Problems)
+	}
+
+	if _, err := LoadProfile(fsys, "unknown.json"); !errors.As(err, &perr) {
+		t.Fatalf("expected unknown field to be rejected, got %v", err)
+	}
+}
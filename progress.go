@@ -0,0 +1,63 @@
+package watermark
+
+import (
+	"bytes"
+	"io"
+)
+
+// The stages reported to the function installed with SetProgressFunc.
+const (
+	StageDecode = "decode"
+	StageDetect = "detect"
+	StageBlend  = "blend"
+	StageEncode = "encode"
+)
+
+// SetProgressFunc installs fn to follow the byte-level calls running on the
+// engine (ProcessBytes, DetectResultBytes and the v1 byte helpers use the
+// Default engine). fn receives the stage and how much of it is done, from 0
+// to 100: each stage reports 0 when it starts and 100 when it ends, decoding
+// also reports the share of the input read, and animations report blending
+// frame by frame. Stages a call does not reach, such as blending when no
+// watermark is found, are not reported. Calls may run concurrently, so fn
+// must be safe for concurrent use; it should also return quickly. A nil fn
+// stops reporting. SetProgressFunc must not be called concurrently with
+// processing.
+func (e *Engine) SetProgressFunc(fn func(stage string, pct float64)) {
+	e.progress = fn
+}
+
+// report passes progress to the engine's progress function, if any.
+func (e *Engine) report(stage string, pct float64) {
+	if e.progress != nil {
+		e.progress(stage, pct)
+	}
+}
+
+// decodeReader returns a reader of data that reports the share read as
+// StageDecode progress of e, in whole percent.
+func (e *Engine) decodeReader(data []byte) io.Reader {
+	r := bytes.NewReader(data)
+	if e.progress == nil {
+		return r
+	}
+	return &progressReader{r: r, size: len(data), report: e.report}
+}
+
+type progressReader struct {
+	r      *bytes.Reader
+	size   int
+	read   int
+	last   int
+	report func(stage string, pct float64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += n
+	if pct := p.read * 100 / p.size; pct > p.last && pct < 100 {
+		p.last = pct
+		p.report(StageDecode, float64(pct))
+	}
+	return n, err
+}
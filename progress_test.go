@@ -0,0 +1,45 @@
+package watermark
+
+import (
+	"image/color"
+	"sync"
+	"testing"
+)
+
+func TestSetProgressFunc(t *testing.T) {
+	data, err := EncodePNGToBytes(watermarkedRGBA(t, 320, 240, color.RGBA{40, 60, 80, 255}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var stages []string
+	last := map[string]float64{}
+	e := NewEngine(WithProgress(func(stage string, pct float64) {
+		mu.Lock()
+		defer mu.Unlock()
+		if pct < last[stage] {
+			t.Errorf("%s went back from %v to %v", stage, last[stage], pct)
+		}
+		if len(stages) == 0 || stages[len(stages)-1] != stage {
+			stages = append(stages, stage)
+		}
+		last[stage] = pct
+	}))
+	SetDefaultEngine(e)
+	defer SetDefaultEngine(nil)
+
+	if _, err := ProcessBytes(data, Options{}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{StageDecode, StageDetect, StageBlend, StageEncode}
+	if len(stages) != len(want) {
+		t.Fatalf("stages %v, want %v", stages, want)
+	}
+	for i := range want {
+		if stages[i] != want[i] || last[want[i]] != 100 {
+			t.Errorf("stages %v ending at %v, want %v each reaching 100", stages, last, want)
+			break
+		}
+	}
+}
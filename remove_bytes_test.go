@@ -1,12 +1,15 @@
+//go:build !watermark_nov1
+
 package watermark
 
 import (
 	"bytes"
 	"image"
-	"image/draw"
 	"os"
 	"path/filepath"
 	"testing"
+
+	"github.com/gcslaoli/gemini-watermark-remover-go/imagecmp"
 )
 
 // Ensure byte-slice removal path matches the known cleaned image output.
@@ -30,11 +33,7 @@ func TestRemoveWatermarkBytesMatchesExpectedImage(t *testing.T) {
 		t.Fatalf("RemoveWatermarkBytes returned empty output")
 	}
 
-	outDir := filepath.Join("temp")
-	if err := os.MkdirAll(outDir, 0o755); err != nil {
-		t.Fatalf("create temp dir: %v", err)
-	}
-	outPath := filepath.Join(outDir, "image_cleaned.png")
+	outPath := filepath.Join(t.TempDir(), "image_cleaned.png")
 	if err := os.WriteFile(outPath, outputBytes, 0o644); err != nil {
 		t.Fatalf("write output image: %v", err)
 	}
@@ -57,25 +56,7 @@ func TestRemoveWatermarkBytesMatchesExpectedImage(t *testing.T) {
 		t.Fatalf("decode expected: %v", err)
 	}
 
-	if !imagesEqual(expectedImg, gotImg) {
-		t.Fatalf("output image pixels differ from expected cleaned image")
-	}
-}
-
-func imagesEqual(a, b image.Image) bool {
-	if !a.Bounds().Eq(b.Bounds()) {
-		return false
+	if err := imagecmp.Diff(expectedImg, gotImg, imagecmp.Options{}); err != nil {
+		t.Fatalf("output image differs from expected cleaned image: %v", err)
 	}
-
-	ab := imageToNRGBA(a)
-	bb := imageToNRGBA(b)
-
-	return bytes.Equal(ab.Pix, bb.Pix)
-}
-
-func imageToNRGBA(img image.Image) *image.NRGBA {
-	bounds := img.Bounds()
-	out := image.NewNRGBA(bounds)
-	draw.Draw(out, bounds, img, bounds.Min, draw.Src)
-	return out
 }
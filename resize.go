@@ -0,0 +1,77 @@
+package watermark
+
+import (
+	"image"
+	"math"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// Resize scales cleaned outputs before they are encoded, saving callers that
+// downscale anyway a second decode and encode. The zero value keeps the
+// size. Animated outputs are not resized.
+type Resize struct {
+	// Width and Height fit the image inside a Width x Height box, scaling
+	// up or down and keeping the aspect ratio. Either may be zero to leave
+	// that side unconstrained.
+	Width, Height int
+	// MaxDim shrinks the image, after any Width/Height fit, until its
+	// longer side is at most MaxDim pixels. Smaller images are kept.
+	MaxDim int
+}
+
+// IsZero reports whether r leaves images unchanged.
+func (r Resize) IsZero() bool {
+	return r.Width <= 0 && r.Height <= 0 && r.MaxDim <= 0
+}
+
+// Size returns the dimensions r gives a width x height image. Both are at
+// least 1.
+func (r Resize) Size(width, height int) (int, int) {
+	scale := 1.0
+	switch {
+	case r.Width > 0 && r.Height > 0:
+		scale = math.Min(float64(r.Width)/float64(width), float64(r.Height)/float64(height))
+	case r.Width > 0:
+		scale = float64(r.Width) / float64(width)
+	case r.Height > 0:
+		scale = float64(r.Height) / float64(height)
+	}
+	if longer := float64(max(width, height)) * scale; r.MaxDim > 0 && longer > float64(r.MaxDim) {
+		scale *= float64(r.MaxDim) / longer
+	}
+	if scale == 1 {
+		return width, height
+	}
+	return max(1, int(math.Round(float64(width)*scale))), max(1, int(math.Round(float64(height)*scale)))
+}
+
+// Apply returns img scaled per r with a Lanczos-3 filter, or img itself when
+// its size does not change. The result has its origin at (0, 0) and is an
+// *image.RGBA64 for 16-bit images, an *image.RGBA otherwise.
+func (r Resize) Apply(img image.Image) image.Image {
+	if r.IsZero() {
+		return img
+	}
+	b := img.Bounds()
+	w, h := r.Size(b.Dx(), b.Dy())
+	if w == b.Dx() && h == b.Dy() {
+		return img
+	}
+	var dst xdraw.Image = image.NewRGBA(image.Rect(0, 0, w, h))
+	if is16Bit(img) {
+		dst = image.NewRGBA64(dst.Bounds())
+	}
+	lanczos3.Scale(dst, dst.Bounds(), img, b, xdraw.Src, nil)
+	return dst
+}
+
+// lanczos3 is the three-lobe Lanczos kernel, sharper than Catmull-Rom when
+// downscaling and with little ringing.
+var lanczos3 = &xdraw.Kernel{Support: 3, At: func(t float64) float64 {
+	if t == 0 {
+		return 1
+	}
+	x := math.Pi * t
+	return 3 * math.Sin(x) * math.Sin(x/3) / (x * x)
+}}
@@ -0,0 +1,102 @@
+package watermark
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestResizeSize(t *testing.T) {
+	for _, tc := range []struct {
+		r            Resize
+		w, h         int
+		wantW, wantH int
+	}{
+		{Resize{}, 2048, 1536, 2048, 1536},
+		{Resize{Width: 1024, Height: 1024}, 2048, 1536, 1024, 768},
+		{Resize{Width: 1024, Height: 1024}, 512, 256, 1024, 512},
+		{Resize{Height: 100}, 300, 200, 150, 100},
+		{Resize{MaxDim: 1000}, 1536, 2048, 750, 1000},
+		{Resize{MaxDim: 4000}, 1536, 2048, 1536, 2048},
+		{Resize{Width: 4096, MaxDim: 1024}, 2048, 1024, 1024, 512},
+		{Resize{MaxDim: 10}, 4000, 1, 10, 1},
+	} {
+		w, h := tc.r.Size(tc.w, tc.h)
+		if w != tc.wantW || h != tc.wantH {
+			t.Errorf("%+v.Size(%d, %d) = %dx%d, want %dx%d", tc.r, tc.w, tc.h, w, h, tc.wantW, tc.wantH)
+		}
+	}
+}
+
+func TestResizeApply(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(10, 10, 410, 310))
+	fill := color.NRGBA{200, 120, 40, 255}
+	for i := 0; i < len(src.Pix); i += 4 {
+		src.Pix[i], src.Pix[i+1], src.Pix[i+2], src.Pix[i+3] = fill.R, fill.G, fill.B, fill.A
+	}
+
+	if got := (Resize{MaxDim: 400}).Apply(src); got != image.Image(src) {
+		t.Fatalf("unchanged size should return the input")
+	}
+
+	out := Resize{MaxDim: 100}.Apply(src)
+	if out.Bounds() != image.Rect(0, 0, 100, 75) {
+		t.Fatalf("bounds %v, want 100x75 at the origin", out.Bounds())
+	}
+	// A flat image stays flat: the kernel weights sum to one.
+	for y := 0; y < 75; y++ {
+		for x := 0; x < 100; x++ {
+			if c := color.NRGBAModel.Convert(out.At(x, y)).(color.NRGBA); c != fill {
+				t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, c, fill)
+			}
+		}
+	}
+}
+
+func TestProcessBytesResize(t *testing.T) {
+	data, err := EncodePNGToBytes(watermarkedRGBA(t, 320, 240, color.RGBA{40, 60, 80, 255}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ProcessBytes(data, Options{Resize: Resize{Width: 160}})
+	if err != nil {
+		t.Fatalf("ProcessBytes: %v", err)
+	}
+	if !result.Present {
+		t.Fatalf("expected the watermark to be found")
+	}
+	img, _, err := DecodeImageBytes(result.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != image.Rect(0, 0, 160, 120) {
+		t.Fatalf("output bounds %v, want 160x120", img.Bounds())
+	}
+}
+
+func TestProcessBytesThumbnail(t *testing.T) {
+	data, err := EncodePNGToBytes(watermarkedRGBA(t, 320, 240, color.RGBA{40, 60, 80, 255}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ProcessBytes(data, Options{Output: OutputJPEG, Resize: Resize{Width: 160}, Thumbnail: 64})
+	if err != nil {
+		t.Fatalf("ProcessBytes: %v", err)
+	}
+	thumb, format, err := DecodeImageBytes(result.Thumbnail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != "jpeg" || thumb.Bounds() != image.Rect(0, 0, 64, 48) {
+		t.Fatalf("thumbnail %s %v, want jpeg 64x48", format, thumb.Bounds())
+	}
+	if img, _, err := DecodeImageBytes(result.Output); err != nil || img.Bounds().Dx() != 160 {
+		t.Fatalf("output: %v", err)
+	}
+
+	if result, err := ProcessBytes(data, Options{}); err != nil || result.Thumbnail != nil {
+		t.Fatalf("thumbnail without Options.Thumbnail: %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package watermark
+
+// FrameOutcome describes what happened to a single frame of a multi-frame
+// input.
+type FrameOutcome int
+
+const (
+	// FrameUnchanged means no watermark was detected in the frame.
+	FrameUnchanged FrameOutcome = iota
+	// FrameCleaned means the watermark was detected and removed.
+	FrameCleaned
+	// FrameSkipped means the frame failed and was dropped from the output.
+	FrameSkipped
+	// FrameCopied means the frame failed and was copied through untouched.
+	FrameCopied
+)
+
+// String returns a short label for the outcome.
+func (o FrameOutcome) String() string {
+	switch o {
+	case FrameUnchanged:
+		return "unchanged"
+	case FrameCleaned:
+		return "cleaned"
+	case FrameSkipped:
+		return "skipped"
+	case FrameCopied:
+		return "copied"
+	default:
+		return "unknown"
+	}
+}
+
+// FrameResult reports the detection and processing outcome of one frame.
+type FrameResult struct {
+	Index   int
+	Present bool
+	Score   float64
+	Info    Info
+	Outcome FrameOutcome
+	// Err holds the frame error when Outcome is FrameSkipped or FrameCopied.
+	Err error
+}
+
+// Result describes the outcome of ProcessBytes.
+type Result struct {
+	// Output holds the encoded cleaned image. It is nil when no watermark
+	// was detected, unless Options.PassThrough returned the input instead.
+	Output []byte
+	// Thumbnail holds the thumbnail requested by Options.Thumbnail, in the
+	// encoding of Output. It is nil for animations and pass-through outputs.
+	Thumbnail []byte
+	// Correction holds the PNG correction layer requested by
+	// Options.Correction. It is nil for animations and pass-through outputs.
+	Correction []byte
+	// Format is the encoding of Output ("png", "jpeg", "webp" or "gif", or the input
+	// format for passed-through images).
+	Format  string
+	Present bool
+	Score   float64
+	// Correlation and Confidence are the detection measurements behind
+	// Present, see DetectionResult. They are set for single images only;
+	// animation frames are detected with a FrameDetector, which reports
+	// neither.
+	Correlation float64
+	Confidence  Confidence
+	Info        Info
+	// InputSize is the byte length of the input passed to ProcessBytes.
+	InputSize int
+	// Frames is populated for multi-frame inputs only.
+	Frames []FrameResult
+	// Warnings lists conditions of a cleaned single image that did not stop
+	// processing but may have affected the result.
+	Warnings []Warning
+}
+
+// addFrame appends fr to r.Frames and folds it into the overall outcome: the
+// result is present if any frame is, with the first present frame's Info and
+// the highest score.
+func (r *Result) addFrame(fr FrameResult) {
+	if fr.Present && !r.Present {
+		r.Info = fr.Info
+	}
+	r.Present = r.Present || fr.Present
+	if fr.Score > r.Score {
+		r.Score = fr.Score
+	}
+	r.Frames = append(r.Frames, fr)
+}
+
+// Growth reports the output size as a ratio of the input size, so 2.5 means
+// the output is two and a half times larger. It is 0 when there is no output.
+func (r Result) Growth() float64 {
+	if r.InputSize == 0 || len(r.Output) == 0 {
+		return 0
+	}
+	return float64(len(r.Output)) / float64(r.InputSize)
+}
@@ -0,0 +1,82 @@
+package watermark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy configures retries and timeouts of network operations: URL
+// inputs (FetchImageRetry) and the S3Sink and HTTPSink outputs. The zero value
+// makes a single attempt without a timeout.
+type RetryPolicy struct {
+	// Attempts is the total number of tries; values below 1 mean 1.
+	Attempts int
+	// Backoff is the delay before the first retry, doubled for every
+	// further retry.
+	Backoff time.Duration
+	// MaxBackoff caps the delay between retries; zero means no cap.
+	MaxBackoff time.Duration
+	// Timeout bounds each attempt, including reading the response; zero
+	// means no timeout.
+	Timeout time.Duration
+}
+
+// do runs fn until it succeeds, fails with an error that retrying cannot fix,
+// or the attempts are used up, waiting between attempts per the policy. The
+// last error is returned.
+func (p RetryPolicy) do(ctx context.Context, fn func(ctx context.Context) error) error {
+	delay := p.Backoff
+	for attempt := 1; ; attempt++ {
+		err := p.attempt(ctx, fn)
+		if err == nil || attempt >= p.Attempts || !retryable(err) || ctx.Err() != nil {
+			return err
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+		if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+			delay = p.MaxBackoff
+		}
+	}
+}
+
+func (p RetryPolicy) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if p.Timeout <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// statusError reports an unsuccessful HTTP response.
+type statusError struct {
+	what   string
+	code   int
+	status string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.what, e.status)
+}
+
+// retryable reports whether err is transient: a network error or timeout, or
+// a response status asking to try again later.
+func retryable(err error) bool {
+	var status *statusError
+	if errors.As(err, &status) {
+		return status.code >= 500 || status.code == http.StatusTooManyRequests || status.code == http.StatusRequestTimeout
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
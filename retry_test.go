@@ -0,0 +1,71 @@
+package watermark
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer answers the first failures requests with status, or hangs
+// them when status is 0, then succeeds with "ok".
+func flakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			if status == 0 {
+				select {
+				case <-r.Context().Done():
+				case <-time.After(time.Second):
+				}
+				return
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestFetchImageRetry(t *testing.T) {
+	policy := RetryPolicy{Attempts: 3, Backoff: time.Millisecond}
+
+	srv, calls := flakyServer(t, 2, http.StatusServiceUnavailable)
+	data, err := FetchImageRetry(context.Background(), srv.URL, policy)
+	if err != nil || string(data) != "ok" || calls.Load() != 3 {
+		t.Fatalf("got %q, %v after %d calls", data, err, calls.Load())
+	}
+
+	srv, calls = flakyServer(t, 1, http.StatusNotFound)
+	if _, err := FetchImageRetry(context.Background(), srv.URL, policy); err == nil || calls.Load() != 1 {
+		t.Fatalf("404 retried or ignored: %v after %d calls", err, calls.Load())
+	}
+
+	srv, calls = flakyServer(t, 5, http.StatusBadGateway)
+	if _, err := FetchImageRetry(context.Background(), srv.URL, policy); err == nil || !strings.Contains(err.Error(), "502") || calls.Load() != 3 {
+		t.Fatalf("expected the last 502 after 3 calls, got %v after %d", err, calls.Load())
+	}
+}
+
+func TestFetchImageRetryTimeout(t *testing.T) {
+	srv, calls := flakyServer(t, 1, 0)
+	policy := RetryPolicy{Attempts: 2, Timeout: 50 * time.Millisecond}
+	data, err := FetchImageRetry(context.Background(), srv.URL, policy)
+	if err != nil || string(data) != "ok" || calls.Load() != 2 {
+		t.Fatalf("got %q, %v after %d calls", data, err, calls.Load())
+	}
+}
+
+func TestHTTPSinkRetry(t *testing.T) {
+	srv, calls := flakyServer(t, 1, http.StatusTooManyRequests)
+	h := &HTTPSink{URL: srv.URL, Retry: RetryPolicy{Attempts: 2}}
+	if err := h.Write("a.png", strings.NewReader("data"), Result{Format: "png"}); err != nil || calls.Load() != 2 {
+		t.Fatalf("Write: %v after %d calls", err, calls.Load())
+	}
+}
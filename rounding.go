@@ -0,0 +1,61 @@
+package watermark
+
+import "math"
+
+// RoundingMode selects how the reverse blend quantizes recovered channel
+// values back to 8 bits.
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds to the nearest value, with halves rounded up. It is
+	// the default and matches the reference JavaScript extension.
+	RoundHalfUp RoundingMode = iota
+	// RoundTruncate drops the fractional part.
+	RoundTruncate
+	// RoundHalfEven rounds to the nearest value, with halves rounded to the
+	// even neighbor (banker's rounding).
+	RoundHalfEven
+)
+
+// String returns the mode name used in CLI flags.
+func (m RoundingMode) String() string {
+	switch m {
+	case RoundHalfUp:
+		return "half-up"
+	case RoundTruncate:
+		return "truncate"
+	case RoundHalfEven:
+		return "half-even"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseRoundingMode parses "half-up", "truncate", or "half-even".
+func ParseRoundingMode(s string) (RoundingMode, bool) {
+	for _, m := range []RoundingMode{RoundHalfUp, RoundTruncate, RoundHalfEven} {
+		if m.String() == s {
+			return m, true
+		}
+	}
+	return RoundHalfUp, false
+}
+
+// round quantizes a non-negative value according to the mode.
+func (m RoundingMode) round(v float64) float64 {
+	switch m {
+	case RoundTruncate:
+		return math.Trunc(v)
+	case RoundHalfEven:
+		return math.RoundToEven(v)
+	default:
+		return math.Floor(v + 0.5)
+	}
+}
+
+// SetRoundingMode selects the quantization of the reverse blend. It has no
+// effect in JS-compatible mode, which always rounds like the extension.
+// SetRoundingMode must not be called concurrently with removal.
+func (e *Engine) SetRoundingMode(mode RoundingMode) {
+	e.rounding = mode
+}
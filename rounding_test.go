@@ -0,0 +1,32 @@
+package watermark
+
+import "testing"
+
+func TestRoundingModes(t *testing.T) {
+	cases := []struct {
+		mode RoundingMode
+		in   float64
+		want float64
+	}{
+		{RoundHalfUp, 2.5, 3},
+		{RoundHalfUp, 3.5, 4},
+		{RoundHalfUp, 2.49, 2},
+		{RoundTruncate, 2.99, 2},
+		{RoundHalfEven, 2.5, 2},
+		{RoundHalfEven, 3.5, 4},
+		{RoundHalfEven, 2.51, 3},
+	}
+
+	for _, tc := range cases {
+		if got := tc.mode.round(tc.in); got != tc.want {
+			t.Errorf("%v.round(%v) = %v, want %v", tc.mode, tc.in, got, tc.want)
+		}
+	}
+
+	if m, ok := ParseRoundingMode("half-even"); !ok || m != RoundHalfEven {
+		t.Errorf("ParseRoundingMode(half-even) = %v, %v", m, ok)
+	}
+	if _, ok := ParseRoundingMode("nearest"); ok {
+		t.Errorf("expected unknown mode to fail parsing")
+	}
+}
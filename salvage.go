@@ -0,0 +1,480 @@
+package watermark
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+)
+
+// ErrCorruptImage matches, with errors.Is, every *CorruptImageError.
+var ErrCorruptImage = errors.New("corrupt image")
+
+// CorruptImageError describes a truncated or damaged PNG or JPEG that
+// ProcessBytes could not use, returned when Options.Salvage is set.
+type CorruptImageError struct {
+	// Format is "png" or "jpeg".
+	Format string
+	// Offset is the byte where the damage starts: the chunk or segment that
+	// is cut off or fails its checksum, or the end of the data. Size is the
+	// length of the input.
+	Offset, Size int
+	// Reason says what is wrong at Offset.
+	Reason string
+	// Rows is how many rows from the top decoded from real data, out of
+	// Height. Height is zero when the header itself is damaged.
+	Rows, Height int
+	// Err is the error decoding the whole input failed with.
+	Err error
+}
+
+func (e *CorruptImageError) Error() string {
+	msg := fmt.Sprintf("corrupt %s at byte %d of %d: %s", e.Format, e.Offset, e.Size, e.Reason)
+	if e.Height > 0 {
+		msg += fmt.Sprintf(" (%d of %d rows intact)", e.Rows, e.Height)
+	}
+	return msg
+}
+
+func (e *CorruptImageError) Is(target error) bool {
+	return target == ErrCorruptImage
+}
+
+func (e *CorruptImageError) Unwrap() error {
+	return e.Err
+}
+
+// salvageFill is the gray that replaces rows a salvaged image lacks.
+const salvageFill = 128
+
+// salvage decodes as much of a PNG or JPEG that failed to decode with
+// decodeErr as it can. It returns the image, with the rows below the intact
+// ones blank, gray for the image types the decoders usually return, and a
+// *CorruptImageError that locates the damage and counts the intact rows.
+// When nothing usable is left the image is nil. Both are nil for other
+// formats and for damage salvage does not recognize.
+func salvage(data []byte, decodeErr error) (image.Image, *CorruptImageError) {
+	switch {
+	case bytes.HasPrefix(data, pngSignature):
+		return salvagePNG(data, decodeErr)
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8}):
+		return salvageJPEG(data, decodeErr)
+	default:
+		return nil, nil
+	}
+}
+
+// salvagePNG collects the IDAT data up to the first chunk that fails its
+// checksum, or into the chunk that is cut off, inflates what it can and
+// keeps the complete rows. Interlaced images are only salvaged when all
+// their pixel data is there, since every pass spans the whole image.
+func salvagePNG(data []byte, decodeErr error) (image.Image, *CorruptImageError) {
+	cerr := &CorruptImageError{Format: "png", Size: len(data), Err: decodeErr}
+	var ihdr, plte, trns []byte
+	var idat bytes.Buffer
+	idatOffset := -1
+	off := len(pngSignature)
+chunks:
+	for {
+		if off+8 > len(data) {
+			cerr.Offset, cerr.Reason = off, "data ends before IEND chunk"
+			break
+		}
+		length := int(binary.BigEndian.Uint32(data[off:]))
+		typ := string(data[off+4 : off+8])
+		end := off + 12 + length
+		if length > len(data) || end > len(data) {
+			cerr.Offset, cerr.Reason = off, typ+" chunk truncated"
+			if typ == "IDAT" {
+				// Whatever of the chunk arrived is still good data.
+				idat.Write(data[min(off+8, len(data)):min(off+8+length, len(data))])
+			}
+			break
+		}
+		body := data[off+8 : off+8+length]
+		if crc32.ChecksumIEEE(data[off+4:off+8+length]) != binary.BigEndian.Uint32(data[off+8+length:]) {
+			cerr.Offset, cerr.Reason = off, typ+" chunk checksum mismatch"
+			break
+		}
+		switch typ {
+		case "IHDR":
+			ihdr = body
+		case "PLTE":
+			plte = body
+		case "tRNS":
+			trns = body
+		case "IDAT":
+			if idatOffset < 0 {
+				idatOffset = off
+			}
+			idat.Write(body)
+		case "IEND":
+			break chunks
+		}
+		off = end
+	}
+	if len(ihdr) != 13 {
+		if cerr.Reason == "" {
+			cerr.Reason = "missing IHDR chunk"
+		}
+		return nil, cerr
+	}
+
+	width := int(binary.BigEndian.Uint32(ihdr[0:]))
+	height := int(binary.BigEndian.Uint32(ihdr[4:]))
+	depth, colorType, interlaced := int(ihdr[8]), ihdr[9], ihdr[12] != 0
+	channels := map[byte]int{0: 1, 2: 3, 3: 1, 4: 2, 6: 4}[colorType]
+	cerr.Height = height
+	if width <= 0 || height <= 0 || channels == 0 || depth == 0 || int64(width)*int64(height) > maxSalvagePixels {
+		cerr.Offset, cerr.Reason = len(pngSignature), "invalid IHDR chunk"
+		return nil, cerr
+	}
+	stride := 1 + (width*channels*depth+7)/8
+
+	var raw []byte
+	zr, err := zlib.NewReader(&idat)
+	if err == nil {
+		raw, err = io.ReadAll(io.LimitReader(zr, int64(stride)*int64(height)+1))
+	}
+	rows := len(raw) / stride
+	if err != nil && cerr.Reason == "" {
+		// The chunks are sound but the compressed data is not, so the
+		// damage cannot be placed more precisely than the first IDAT.
+		cerr.Offset, cerr.Reason = max(idatOffset, 0), "IDAT data does not inflate"
+	}
+	for row := 0; row < rows; row++ {
+		if raw[row*stride] > 4 {
+			rows = row
+			break
+		}
+	}
+	rows = min(rows, height)
+	if cerr.Reason == "" {
+		// The decoder failed on something salvage does not check; report
+		// the original error.
+		return nil, nil
+	}
+	if interlaced && rows < height {
+		rows = 0
+	}
+	cerr.Rows = rows
+	if rows == 0 {
+		return nil, cerr
+	}
+
+	// Rebuild a well-formed PNG from the intact rows, padded with rows of
+	// zero bytes, and let image/png turn it into an image of the usual type.
+	raw = append(raw[:rows*stride], make([]byte, (height-rows)*stride)...)
+	var rebuilt bytes.Buffer
+	rebuilt.Write(pngSignature)
+	writePNGChunk(&rebuilt, "IHDR", ihdr)
+	if plte != nil {
+		writePNGChunk(&rebuilt, "PLTE", plte)
+	}
+	if trns != nil {
+		writePNGChunk(&rebuilt, "tRNS", trns)
+	}
+	var compressed bytes.Buffer
+	zw, _ := zlib.NewWriterLevel(&compressed, zlib.BestSpeed)
+	zw.Write(raw)
+	zw.Close()
+	writePNGChunk(&rebuilt, "IDAT", compressed.Bytes())
+	writePNGChunk(&rebuilt, "IEND", nil)
+	img, err := png.Decode(&rebuilt)
+	if err != nil {
+		cerr.Rows = 0
+		return nil, cerr
+	}
+	return fillRows(img, rows), cerr
+}
+
+func writePNGChunk(buf *bytes.Buffer, typ string, body []byte) {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(body)))
+	buf.Write(n[:])
+	crc := crc32.NewIEEE()
+	io.WriteString(crc, typ)
+	crc.Write(body)
+	buf.WriteString(typ)
+	buf.Write(body)
+	binary.BigEndian.PutUint32(n[:], crc.Sum32())
+	buf.Write(n[:])
+}
+
+// maxSalvagePixels bounds the images salvage rebuilds, since a damaged
+// header could otherwise claim any size.
+const maxSalvagePixels = 1 << 28
+
+// jpegScan is what salvageJPEG learns from the marker segments of a JPEG.
+type jpegScan struct {
+	width, height int
+	hmax, vmax    int
+	blocks        int // 8x8 blocks per MCU, summed over components
+	restart       int // MCUs per restart interval, zero without DRI
+	nextRST       int // the RST marker expected next in the last scan
+	truncatedAt   int // where the data ends inside a scan, or -1
+	damageOffset  int
+	damage        string
+	sawFrame      bool
+}
+
+// salvageJPEG completes a JPEG cut off inside its entropy-coded data with
+// filler and an EOI marker and decodes it. Filling with two different
+// patterns tells the real data from the filler: rows that decode the same
+// both ways are intact. Damage anywhere else is only reported.
+func salvageJPEG(data []byte, decodeErr error) (image.Image, *CorruptImageError) {
+	cerr := &CorruptImageError{Format: "jpeg", Size: len(data), Err: decodeErr}
+	scan := parseJPEG(data)
+	cerr.Height = scan.height
+	if scan.truncatedAt < 0 {
+		if scan.damage == "" {
+			return nil, nil
+		}
+		cerr.Offset, cerr.Reason = scan.damageOffset, scan.damage
+		return nil, cerr
+	}
+	cerr.Offset, cerr.Reason = scan.truncatedAt, "data ends inside scan"
+	if !scan.sawFrame || scan.width <= 0 || scan.height <= 0 || int64(scan.width)*int64(scan.height) > maxSalvagePixels {
+		cerr.Height = 0
+		return nil, cerr
+	}
+
+	body := data
+	if body[len(body)-1] == 0xff {
+		// Drop a marker or stuffed byte that was cut in half.
+		body = body[:len(body)-1]
+	}
+	var decoded []image.Image
+	for _, pattern := range []byte{0x00, 0x55, 0x33, 0x0f} {
+		img, err := jpeg.Decode(bytes.NewReader(scan.complete(body, pattern)))
+		if err != nil {
+			continue
+		}
+		if decoded = append(decoded, img); len(decoded) == 2 {
+			break
+		}
+	}
+	if len(decoded) < 2 {
+		return nil, cerr
+	}
+	rows := sameRows(decoded[0], decoded[1])
+	cerr.Rows = rows
+	if rows == 0 {
+		return nil, cerr
+	}
+	return fillRows(decoded[0], rows), cerr
+}
+
+// parseJPEG walks the marker segments of data, skipping entropy-coded
+// data, until EOI, the end of the data or a segment it cannot parse.
+func parseJPEG(data []byte) jpegScan {
+	scan := jpegScan{truncatedAt: -1}
+	off := 2
+	for {
+		for off < len(data) && data[off] == 0xff && off+1 < len(data) && data[off+1] == 0xff {
+			off++ // fill bytes
+		}
+		if off+2 > len(data) {
+			scan.damageOffset, scan.damage = off, "data ends before EOI marker"
+			return scan
+		}
+		if data[off] != 0xff {
+			scan.damageOffset, scan.damage = off, "marker expected"
+			return scan
+		}
+		marker := data[off+1]
+		if marker == 0xd9 {
+			return scan
+		}
+		if marker == 0x01 || marker == 0xd8 || marker >= 0xd0 && marker <= 0xd7 {
+			off += 2
+			continue
+		}
+		if off+4 > len(data) {
+			scan.damageOffset, scan.damage = off, "segment truncated"
+			return scan
+		}
+		length := int(binary.BigEndian.Uint16(data[off+2:]))
+		end := off + 2 + length
+		if length < 2 || end > len(data) {
+			scan.damageOffset, scan.damage = off, "segment truncated"
+			return scan
+		}
+		seg := data[off+4 : end]
+		switch {
+		case marker >= 0xc0 && marker <= 0xcf && marker != 0xc4 && marker != 0xc8 && marker != 0xcc:
+			if !scan.frame(seg) {
+				scan.damageOffset, scan.damage = off, "invalid frame header"
+				return scan
+			}
+		case marker == 0xdd && len(seg) >= 2:
+			scan.restart = int(binary.BigEndian.Uint16(seg))
+		}
+		off = end
+		if marker != 0xda {
+			continue
+		}
+
+		// Skip the entropy-coded data, noting restart markers.
+		scan.nextRST = 0
+		for {
+			if off+1 >= len(data) {
+				scan.truncatedAt = len(data)
+				return scan
+			}
+			if data[off] != 0xff {
+				off++
+				continue
+			}
+			next := data[off+1]
+			if next == 0x00 {
+				off += 2
+			} else if next >= 0xd0 && next <= 0xd7 {
+				scan.nextRST = int(next-0xd0+1) % 8
+				off += 2
+			} else if next == 0xff {
+				off++
+			} else {
+				break
+			}
+		}
+	}
+}
+
+// frame reads the size and sampling factors from a SOFn segment.
+func (s *jpegScan) frame(seg []byte) bool {
+	if len(seg) < 6 {
+		return false
+	}
+	s.height = int(binary.BigEndian.Uint16(seg[1:]))
+	s.width = int(binary.BigEndian.Uint16(seg[3:]))
+	n := int(seg[5])
+	if n == 0 || len(seg) < 6+3*n {
+		return false
+	}
+	s.hmax, s.vmax, s.blocks = 1, 1, 0
+	for i := 0; i < n; i++ {
+		h, v := int(seg[7+3*i]>>4), int(seg[7+3*i]&0x0f)
+		if h == 0 || v == 0 {
+			return false
+		}
+		s.hmax, s.vmax = max(s.hmax, h), max(s.vmax, v)
+		s.blocks += h * v
+	}
+	s.sawFrame = true
+	return true
+}
+
+// complete appends filler of pattern to body, enough for every MCU of the
+// image, with the restart markers the decoder expects, and an EOI marker.
+// The decoder skips filler left over at the end of an interval or scan.
+func (s *jpegScan) complete(body []byte, pattern byte) []byte {
+	// 128 bytes per block leaves room for a coefficient per byte even with
+	// long Huffman codes.
+	const perBlock = 128
+	mcus := ((s.width + 8*s.hmax - 1) / (8 * s.hmax)) * ((s.height + 8*s.vmax - 1) / (8 * s.vmax))
+	interval := mcus
+	if s.restart > 0 {
+		interval = s.restart
+	}
+	fill := bytes.Repeat([]byte{pattern}, interval*s.blocks*perBlock)
+
+	out := append([]byte{}, body...)
+	rst := s.nextRST
+	for left := mcus; left > 0; left -= interval {
+		out = append(out, fill...)
+		if s.restart > 0 {
+			out = append(out, 0xff, byte(0xd0+rst))
+			rst = (rst + 1) % 8
+		}
+	}
+	return append(out, 0xff, 0xd9)
+}
+
+// sameRows returns how many rows from the top a and b agree on.
+func sameRows(a, b image.Image) int {
+	bounds := a.Bounds()
+	if b.Bounds() != bounds {
+		return 0
+	}
+	if ya, ok := a.(*image.YCbCr); ok {
+		if yb, ok := b.(*image.YCbCr); ok && ya.SubsampleRatio == yb.SubsampleRatio {
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				ca, cb := ya.COffset(bounds.Min.X, y), yb.COffset(bounds.Min.X, y)
+				cw := ya.COffset(bounds.Max.X-1, y) - ca + 1
+				if !bytes.Equal(ya.Y[ya.YOffset(bounds.Min.X, y):][:bounds.Dx()], yb.Y[yb.YOffset(bounds.Min.X, y):][:bounds.Dx()]) ||
+					!bytes.Equal(ya.Cb[ca:][:cw], yb.Cb[cb:][:cw]) ||
+					!bytes.Equal(ya.Cr[ca:][:cw], yb.Cr[cb:][:cw]) {
+					return y - bounds.Min.Y
+				}
+			}
+			return bounds.Dy()
+		}
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if a.At(x, y) != b.At(x, y) {
+				return y - bounds.Min.Y
+			}
+		}
+	}
+	return bounds.Dy()
+}
+
+// fillRows paints the rows of img from rows down gray, in place for the
+// types the decoders return. Other types are left as decoded.
+func fillRows(img image.Image, rows int) image.Image {
+	bounds := img.Bounds()
+	if rows >= bounds.Dy() {
+		return img
+	}
+	top := bounds.Min.Y + rows
+	switch m := img.(type) {
+	case *image.YCbCr:
+		for y := top; y < bounds.Max.Y; y++ {
+			clear128(m.Y[m.YOffset(bounds.Min.X, y):][:bounds.Dx()])
+			c := m.COffset(bounds.Min.X, y)
+			cw := m.COffset(bounds.Max.X-1, y) - c + 1
+			clear128(m.Cb[c:][:cw])
+			clear128(m.Cr[c:][:cw])
+		}
+	case *image.Gray:
+		for y := top; y < bounds.Max.Y; y++ {
+			clear128(m.Pix[m.PixOffset(bounds.Min.X, y):][:bounds.Dx()])
+		}
+	case *image.NRGBA:
+		fillOpaque(m.Pix[m.PixOffset(bounds.Min.X, top):], 4, 1)
+	case *image.RGBA:
+		fillOpaque(m.Pix[m.PixOffset(bounds.Min.X, top):], 4, 1)
+	case *image.NRGBA64:
+		fillOpaque(m.Pix[m.PixOffset(bounds.Min.X, top):], 4, 2)
+	case *image.RGBA64:
+		fillOpaque(m.Pix[m.PixOffset(bounds.Min.X, top):], 4, 2)
+	}
+	return img
+}
+
+func clear128(b []byte) {
+	for i := range b {
+		b[i] = salvageFill
+	}
+}
+
+// fillOpaque sets pix, pixels of channels channels of size bytes each with
+// alpha last, to opaque gray.
+func fillOpaque(pix []byte, channels, size int) {
+	for i := 0; i+channels*size <= len(pix); i += channels * size {
+		for c := 0; c < channels*size; c++ {
+			pix[i+c] = salvageFill
+		}
+		for c := (channels - 1) * size; c < channels*size; c++ {
+			pix[i+c] = 0xff
+		}
+	}
+}
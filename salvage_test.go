@@ -0,0 +1,98 @@
+package watermark
+
+import (
+	"bytes"
+	"errors"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func salvageFixtures(t *testing.T) (pngData, jpegData []byte) {
+	t.Helper()
+	img := stampWatermark(t, texturedRGBA(1024, 1024, 0, 0, 0))
+	var p, j bytes.Buffer
+	if err := png.Encode(&p, img); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&j, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatal(err)
+	}
+	return p.Bytes(), j.Bytes()
+}
+
+func TestSalvageTruncated(t *testing.T) {
+	pngData, jpegData := salvageFixtures(t)
+	tests := []struct {
+		name    string
+		input   []byte
+		salvage bool
+	}{
+		{"png-tail", pngData[:len(pngData)-20], true},
+		{"jpeg-tail", jpegData[:len(jpegData)*995/1000], true},
+		{"png-half", pngData[:len(pngData)/2], false},
+		{"jpeg-half", jpegData[:len(jpegData)/2], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ProcessBytes(tt.input, Options{}); err == nil || errors.Is(err, ErrCorruptImage) {
+				t.Fatalf("without Salvage: err = %v, want the decoder's error", err)
+			}
+
+			result, err := ProcessBytes(tt.input, Options{Salvage: true})
+			if !tt.salvage {
+				var cerr *CorruptImageError
+				if !errors.As(err, &cerr) || !errors.Is(err, ErrCorruptImage) {
+					t.Fatalf("err = %v, want *CorruptImageError", err)
+				}
+				if cerr.Offset <= 0 || cerr.Offset > len(tt.input) || cerr.Size != len(tt.input) {
+					t.Errorf("offset %d, size %d for %d bytes", cerr.Offset, cerr.Size, len(tt.input))
+				}
+				if cerr.Rows <= 0 || cerr.Rows >= cerr.Height || !strings.Contains(cerr.Reason, "watermark region not decoded") {
+					t.Errorf("error %v", cerr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProcessBytes: %v", err)
+			}
+			if !result.Present || result.Output == nil {
+				t.Fatalf("watermark not removed from salvaged image (score %.3f)", result.Score)
+			}
+			var warned bool
+			for _, w := range result.Warnings {
+				warned = warned || w.Code == WarnSalvaged
+			}
+			if !warned {
+				t.Errorf("warnings %v lack %v", result.Warnings, WarnSalvaged)
+			}
+		})
+	}
+}
+
+func TestSalvagePNGRows(t *testing.T) {
+	pngData, _ := salvageFixtures(t)
+	img, cerr := salvage(pngData[:len(pngData)/2], errors.New("unexpected EOF"))
+	if img == nil || cerr == nil {
+		t.Fatalf("salvage failed: %v", cerr)
+	}
+	if cerr.Format != "png" || cerr.Height != 1024 || cerr.Rows == 0 || cerr.Rows >= 1024 {
+		t.Fatalf("error %v", cerr)
+	}
+	// Rows past the intact ones are gray.
+	if r, g, b, _ := img.At(10, 1023).RGBA(); r>>8 != salvageFill || g>>8 != salvageFill || b>>8 != salvageFill {
+		t.Errorf("lost row is %d,%d,%d, want gray", r>>8, g>>8, b>>8)
+	}
+}
+
+func TestSalvageOtherErrors(t *testing.T) {
+	if _, err := ProcessBytes([]byte("not an image"), Options{Salvage: true}); err == nil || errors.Is(err, ErrCorruptImage) {
+		t.Errorf("err = %v, want the decoder's error", err)
+	}
+	_, err := ProcessBytes([]byte("\x89PNG\r\n\x1a\n\x00\x00"), Options{Salvage: true})
+	var cerr *CorruptImageError
+	if !errors.As(err, &cerr) || cerr.Height != 0 {
+		t.Errorf("err = %v, want *CorruptImageError without a header", err)
+	}
+}
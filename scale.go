@@ -0,0 +1,103 @@
+package watermark
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+const (
+	// scaledBaseSize is the embedded capture that scaled alpha maps are
+	// resampled from; it is the largest one, so upscales lose the least.
+	scaledBaseSize = 96
+	// minScaledSize and maxScaledSize bound the derived watermark sizes.
+	minScaledSize = 16
+	maxScaledSize = 4 * scaledBaseSize
+)
+
+// upscaleFactors lists the post-generation upscales probed when the standard
+// watermark is not found: 96→128 and 96→192 for the large variant.
+var upscaleFactors = []float64{4.0 / 3.0, 2.0}
+
+// scaled returns the configuration for an image upscaled by factor after the
+// watermark was applied.
+func (c watermarkConfig) scaled(factor float64) watermarkConfig {
+	return watermarkConfig{
+		LogoSize:     int(math.Round(float64(c.LogoSize) * factor)),
+		MarginRight:  int(math.Round(float64(c.MarginRight) * factor)),
+		MarginBottom: int(math.Round(float64(c.MarginBottom) * factor)),
+		Corner:       c.Corner,
+		mask:         c.mask,
+	}
+}
+
+// scaledAlphaCache derives and caches alpha maps for sizes without an
+// embedded capture.
+type scaledAlphaCache struct {
+	mu   sync.Mutex
+	maps map[int][]float32
+}
+
+// get returns the alpha map for size, resampling the base capture obtained
+// from load on first use.
+func (c *scaledAlphaCache) get(size int, load func(int) ([]float32, error)) ([]float32, error) {
+	if size < minScaledSize || size > maxScaledSize {
+		return nil, fmt.Errorf("unsupported watermark size %d", size)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if alpha, ok := c.maps[size]; ok {
+		return alpha, nil
+	}
+
+	base, err := load(scaledBaseSize)
+	if err != nil {
+		return nil, err
+	}
+
+	alpha := scaleAlphaMap(base, scaledBaseSize, size)
+	if c.maps == nil {
+		c.maps = make(map[int][]float32)
+	}
+	c.maps[size] = alpha
+	return alpha, nil
+}
+
+// scaleAlphaMap resamples a square alpha map from one edge length to another
+// with bilinear interpolation, sampling at pixel centers.
+func scaleAlphaMap(src []float32, from, to int) []float32 {
+	dst := make([]float32, to*to)
+	ratio := float64(from) / float64(to)
+
+	for y := 0; y < to; y++ {
+		sy := (float64(y)+0.5)*ratio - 0.5
+		y0, fy := splitCoord(sy, from)
+
+		for x := 0; x < to; x++ {
+			sx := (float64(x)+0.5)*ratio - 0.5
+			x0, fx := splitCoord(sx, from)
+
+			x1, y1 := min(x0+1, from-1), min(y0+1, from-1)
+			top := float64(src[y0*from+x0])*(1-fx) + float64(src[y0*from+x1])*fx
+			bottom := float64(src[y1*from+x0])*(1-fx) + float64(src[y1*from+x1])*fx
+			dst[y*to+x] = float32(top*(1-fy) + bottom*fy)
+		}
+	}
+
+	return dst
+}
+
+// splitCoord clamps a source coordinate into [0, n-1] and splits it into the
+// integer sample index and the interpolation weight.
+func splitCoord(v float64, n int) (int, float64) {
+	if v <= 0 {
+		return 0, 0
+	}
+	if v >= float64(n-1) {
+		return n - 1, 0
+	}
+	i := int(v)
+	return i, v - float64(i)
+}
@@ -0,0 +1,86 @@
+package watermark
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+func TestDetectUpscaledWatermark(t *testing.T) {
+	src := watermarkedRGBA(t, 1100, 1100, color.RGBA{R: 35, G: 45, B: 70, A: 255})
+
+	upscaled := image.NewRGBA(image.Rect(0, 0, 2200, 2200))
+	xdraw.BiLinear.Scale(upscaled, upscaled.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+
+	present, score, info, err := DetectWatermark(upscaled)
+	if err != nil {
+		t.Fatalf("DetectWatermark: %v", err)
+	}
+	if !present || info.Size != 192 {
+		t.Fatalf("expected 192px watermark, got present=%v size=%d (score %.2f)", present, info.Size, score)
+	}
+
+	cleaned, err := NewEngine().removeAt(context.Background(), upscaled, info, GeminiProfile())
+	if err != nil {
+		t.Fatalf("removeAt: %v", err)
+	}
+	if present, score, _, _ := DetectWatermark(cleaned); present {
+		t.Fatalf("watermark still detected after scaled removal (score %.2f)", score)
+	}
+}
+
+func TestRemoveWatermarkDepthInfoUpscaled(t *testing.T) {
+	src := watermarkedRGBA(t, 1100, 1100, color.RGBA{R: 35, G: 45, B: 70, A: 255})
+	upscaled := image.NewRGBA(image.Rect(0, 0, 2200, 2200))
+	xdraw.BiLinear.Scale(upscaled, upscaled.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+
+	present, _, info, err := DetectWatermarkProfile(upscaled, GeminiProfile())
+	if err != nil || !present || info.Size != 192 {
+		t.Fatalf("detect: present=%v info=%+v err=%v", present, info, err)
+	}
+
+	// The standard placement misses the upscaled logo; the detected one
+	// removes it.
+	standard, err := NewEngine().RemoveWatermarkDepth(upscaled, GeminiProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if present, _, _, _ := DetectWatermarkProfile(standard, GeminiProfile()); !present {
+		t.Fatal("standard placement removed the upscaled watermark; the test no longer tells the paths apart")
+	}
+	cleaned, err := NewEngine().RemoveWatermarkDepthInfo(upscaled, info, GeminiProfile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if present, score, _, _ := DetectWatermarkProfile(cleaned, GeminiProfile()); present {
+		t.Fatalf("watermark still detected after removal at %v (score %.2f)", info.Position, score)
+	}
+
+	if _, err := NewEngine().RemoveWatermarkDepthInfo(upscaled, Info{Size: 192, Position: image.Rect(2100, 2100, 2292, 2292)}, GeminiProfile()); err == nil {
+		t.Error("placement outside the image accepted")
+	}
+	if _, err := NewEngine().RemoveWatermarkDepthInfo(upscaled, Info{Size: 96, Position: info.Position}, GeminiProfile()); err == nil {
+		t.Error("placement of the wrong size accepted")
+	}
+}
+
+func TestDetectMultiScaleFindsResizedWatermark(t *testing.T) {
+	src := watermarkedRGBA(t, 800, 800, color.RGBA{R: 60, G: 30, B: 30, A: 255})
+
+	resized := image.NewRGBA(image.Rect(0, 0, 1200, 1200))
+	xdraw.BiLinear.Scale(resized, resized.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+
+	match, err := DetectMultiScale(resized, nil)
+	if err != nil {
+		t.Fatalf("DetectMultiScale: %v", err)
+	}
+	// A 1.5x upscale of the 48px variant and a 0.75x downscale of the 96px
+	// variant land on the same placement, so only the placement is checked.
+	want := image.Rect(1080, 1080, 1152, 1152)
+	if !match.Present || match.Info.Size != 72 || match.Info.Position != want {
+		t.Fatalf("unexpected match %+v", match)
+	}
+}
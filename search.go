@@ -0,0 +1,229 @@
+package watermark
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"sort"
+)
+
+// SearchOptions configures DetectSearch.
+type SearchOptions struct {
+	// WholeImage searches the entire image instead of its bottom-right
+	// quadrant, for logos moved far from the corner by cropping or padding.
+	WholeImage bool
+	// Scales lists the logo resize factors tried, relative to the 48 and
+	// 96px Gemini captures; empty means DefaultScales.
+	Scales []float64
+}
+
+// SearchMatch is the best window found by DetectSearch.
+type SearchMatch struct {
+	Present bool
+	Score   float64
+	// Correlation is the shape correlation of the window with the logo, from
+	// -1 to 1, and serves as the confidence of the match.
+	Correlation float64
+	Info        Info
+}
+
+const (
+	// searchRefine is the number of coarse candidates per size refined at
+	// single-pixel steps.
+	searchRefine = 3
+	// searchCorrelationThreshold replaces the correlation gate of the fixed
+	// placement: among thousands of windows, textured content reaches
+	// moderate correlations by chance, while real logos score above 0.9.
+	searchCorrelationThreshold = 0.75
+)
+
+// DetectSearch slides the Gemini logo over the bottom-right quadrant of img
+// (or all of it with opts.WholeImage) at several sizes and returns the
+// best-correlated window, so watermarks are found in cropped, resized or
+// letterboxed images where the standard placement misses. Present applies
+// the luma gate of DetectWatermark and a stricter correlation gate to that
+// window. The search runs coarse to fine
+// and costs far more than DetectWatermark.
+func DetectSearch(img image.Image, opts SearchOptions) (SearchMatch, error) {
+	if img == nil {
+		return SearchMatch{}, fmt.Errorf("nil image provided")
+	}
+	bounds := img.Bounds()
+	if bounds.Dx() <= 0 || bounds.Dy() <= 0 {
+		return SearchMatch{}, fmt.Errorf("invalid image dimensions %dx%d", bounds.Dx(), bounds.Dy())
+	}
+
+	area := bounds
+	if !opts.WholeImage {
+		area.Min = bounds.Min.Add(bounds.Max).Div(2)
+	}
+	sizes := searchSizes(opts.Scales, min(area.Dx(), area.Dy()))
+	if len(sizes) == 0 {
+		return SearchMatch{}, fmt.Errorf("search area %v is too small for the watermark", area)
+	}
+
+	params := detectParams{minScore: Default().minScore, minCorrelation: searchCorrelationThreshold}
+	lum := newLumaTable(img, area, params.luminance)
+
+	var (
+		best  SearchMatch
+		found bool
+	)
+	for _, size := range sizes {
+		alpha, err := watermarkConfig{LogoSize: size}.detectAlpha(nil)
+		if err != nil {
+			return SearchMatch{}, err
+		}
+		w := newSearchWindow(alpha, size)
+
+		// Score a coarse grid, then refine around its best cells.
+		step := max(1, size/8)
+		var coarse []searchHit
+		for y := 0; y+size <= lum.h; y += step {
+			for x := 0; x+size <= lum.w; x += step {
+				coarse = append(coarse, searchHit{x, y, w.corr(lum, x, y)})
+			}
+		}
+		sort.Slice(coarse, func(i, j int) bool { return coarse[i].corr > coarse[j].corr })
+
+		for _, c := range coarse[:min(searchRefine, len(coarse))] {
+			hit := c
+			for y := max(0, c.y-step+1); y < min(lum.h-size+1, c.y+step); y++ {
+				for x := max(0, c.x-step+1); x < min(lum.w-size+1, c.x+step); x++ {
+					if corr := w.corr(lum, x, y); corr > hit.corr {
+						hit = searchHit{x, y, corr}
+					}
+				}
+			}
+			if found && hit.corr <= best.Correlation {
+				continue
+			}
+
+			rect := image.Rect(hit.x, hit.y, hit.x+size, hit.y+size).Add(area.Min)
+			score, corr, err := scoreRect(img, rect, alpha, params.luminance)
+			if err != nil {
+				continue
+			}
+			best = SearchMatch{
+				Present:     params.accepts(score, corr),
+				Score:       score,
+				Correlation: corr,
+				Info:        Info{Size: size, Position: rect, Corner: NearestCorner(rect, bounds)},
+			}
+			found = true
+		}
+	}
+	if !found {
+		return SearchMatch{}, fmt.Errorf("no window of %v could be scored", area)
+	}
+	return best, nil
+}
+
+// searchSizes returns the distinct logo sizes for scales applied to both
+// Gemini captures that fit in limit pixels.
+func searchSizes(scales []float64, limit int) []int {
+	if len(scales) == 0 {
+		scales = DefaultScales
+	}
+	seen := make(map[int]bool)
+	var sizes []int
+	for _, base := range []int{48, 96} {
+		for _, scale := range scales {
+			size := int(math.Round(float64(base) * scale))
+			if size < minScaledSize || size > maxScaledSize || size > limit || seen[size] {
+				continue
+			}
+			seen[size] = true
+			sizes = append(sizes, size)
+		}
+	}
+	sort.Ints(sizes)
+	return sizes
+}
+
+type searchHit struct {
+	x, y int
+	corr float64
+}
+
+// lumaTable holds the luma of a search area with summed-area tables of the
+// luma and its square, so window means and variances cost O(1).
+type lumaTable struct {
+	w, h    int
+	luma    []float64
+	sum, sq []float64 // (w+1) x (h+1)
+}
+
+func newLumaTable(img image.Image, area image.Rectangle, mode LuminanceMode) *lumaTable {
+	t := &lumaTable{w: area.Dx(), h: area.Dy()}
+	t.luma = make([]float64, t.w*t.h)
+	t.sum = make([]float64, (t.w+1)*(t.h+1))
+	t.sq = make([]float64, (t.w+1)*(t.h+1))
+	stride := t.w + 1
+	for y := 0; y < t.h; y++ {
+		mode.lumaRow(img, area.Min.Y+y, area.Min.X, t.luma[y*t.w:(y+1)*t.w])
+		var row, rowSq float64
+		for x := 0; x < t.w; x++ {
+			l := t.luma[y*t.w+x]
+			row += l
+			rowSq += l * l
+			t.sum[(y+1)*stride+x+1] = t.sum[y*stride+x+1] + row
+			t.sq[(y+1)*stride+x+1] = t.sq[y*stride+x+1] + rowSq
+		}
+	}
+	return t
+}
+
+// window returns the sum and sum of squares of the size x size window at
+// (x, y).
+func (t *lumaTable) window(x, y, size int) (sum, sq float64) {
+	stride := t.w + 1
+	at := func(s []float64) float64 {
+		return s[(y+size)*stride+x+size] - s[y*stride+x+size] - s[(y+size)*stride+x] + s[y*stride+x]
+	}
+	return at(t.sum), at(t.sq)
+}
+
+// searchWindow is a logo alpha map centered on its mean, for Pearson
+// correlation against luma windows.
+type searchWindow struct {
+	size     int
+	centered []float64
+	norm     float64
+}
+
+func newSearchWindow(alpha []float32, size int) searchWindow {
+	var mean float64
+	for _, a := range alpha {
+		mean += float64(a)
+	}
+	mean /= float64(len(alpha))
+
+	w := searchWindow{size: size, centered: make([]float64, len(alpha))}
+	for i, a := range alpha {
+		w.centered[i] = float64(a) - mean
+		w.norm += w.centered[i] * w.centered[i]
+	}
+	w.norm = math.Sqrt(w.norm)
+	return w
+}
+
+// corr returns the correlation of the logo with the luma window at (x, y).
+func (w searchWindow) corr(t *lumaTable, x, y int) float64 {
+	n := float64(w.size * w.size)
+	sum, sq := t.window(x, y, w.size)
+	variance := sq - sum*sum/n
+	if variance <= 1e-9 || w.norm == 0 {
+		return 0
+	}
+
+	var dot float64
+	for row := 0; row < w.size; row++ {
+		luma := t.luma[(y+row)*t.w+x : (y+row)*t.w+x+w.size]
+		alpha := w.centered[row*w.size : (row+1)*w.size]
+		for i, a := range alpha {
+			dot += a * luma[i]
+		}
+	}
+	return dot / (math.Sqrt(variance) * w.norm)
+}
@@ -0,0 +1,85 @@
+package watermark
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"path/filepath"
+	"testing"
+
+	xdraw "golang.org/x/image/draw"
+)
+
+// placeOnCanvas copies img onto a flat canvas of the given size at offset,
+// like padding or letterboxing after generation.
+func placeOnCanvas(img image.Image, width, height int, offset image.Point) *image.RGBA {
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{C: color.RGBA{R: 10, G: 10, B: 10, A: 255}}, image.Point{}, draw.Src)
+	draw.Draw(canvas, image.Rectangle{Max: img.Bounds().Size()}.Add(offset), img, img.Bounds().Min, draw.Src)
+	return canvas
+}
+
+func TestDetectSearch(t *testing.T) {
+	src := watermarkedRGBA(t, 320, 240, color.RGBA{R: 40, G: 60, B: 90, A: 255})
+	want := WatermarkInfo(320, 240).Position
+
+	t.Run("padded", func(t *testing.T) {
+		offset := image.Pt(250, 230)
+		m, err := DetectSearch(placeOnCanvas(src, 600, 500, offset), SearchOptions{})
+		if err != nil || !m.Present {
+			t.Fatalf("DetectSearch: %+v, %v", m, err)
+		}
+		if m.Info.Position != want.Add(offset) || m.Info.Corner != CornerBottomRight {
+			t.Fatalf("found %v (%v), want %v", m.Info.Position, m.Info.Corner, want.Add(offset))
+		}
+	})
+
+	t.Run("resized", func(t *testing.T) {
+		big := image.NewRGBA(image.Rect(0, 0, 480, 360))
+		xdraw.CatmullRom.Scale(big, big.Bounds(), src, src.Bounds(), xdraw.Src, nil)
+		m, err := DetectSearch(big, SearchOptions{})
+		if err != nil || !m.Present || m.Info.Size != 72 {
+			t.Fatalf("DetectSearch: %+v, %v", m, err)
+		}
+		wantScaled := image.Rect(want.Min.X*3/2, want.Min.Y*3/2, want.Max.X*3/2, want.Max.Y*3/2)
+		if d := m.Info.Position.Min.Sub(wantScaled.Min); abs(d.X) > 1 || abs(d.Y) > 1 {
+			t.Fatalf("found %v, want about %v", m.Info.Position, wantScaled)
+		}
+	})
+
+	t.Run("whole image", func(t *testing.T) {
+		// The logo ends up near the top-left after cropping the bottom and
+		// right away and padding the other sides.
+		crop := src.SubImage(image.Rect(200, 140, 320, 240))
+		canvas := placeOnCanvas(crop, 500, 400, image.Pt(20, 30))
+		rect := want.Sub(image.Pt(200, 140)).Add(image.Pt(20, 30))
+
+		if m, err := DetectSearch(canvas, SearchOptions{}); err == nil && m.Present {
+			t.Fatalf("quadrant search found %v outside the quadrant", m.Info.Position)
+		}
+		m, err := DetectSearch(canvas, SearchOptions{WholeImage: true})
+		if err != nil || !m.Present || m.Info.Position != rect || m.Info.Corner != CornerTopLeft {
+			t.Fatalf("DetectSearch: %+v, %v, want %v", m, err, rect)
+		}
+	})
+
+	t.Run("no watermark", func(t *testing.T) {
+		plain := placeOnCanvas(image.NewRGBA(image.Rect(0, 0, 0, 0)), 320, 240, image.Point{})
+		m, err := DetectSearch(plain, SearchOptions{})
+		if err != nil || m.Present {
+			t.Fatalf("DetectSearch on a flat image: %+v, %v", m, err)
+		}
+	})
+}
+
+func TestDetectSearchRejectsTexture(t *testing.T) {
+	img, err := readSample(filepath.Join("cmd", "gwatermark", "nowater.jpg"))
+	if err != nil {
+		t.Fatalf("read sample: %v", err)
+	}
+	for _, whole := range []bool{false, true} {
+		if m, err := DetectSearch(img, SearchOptions{WholeImage: whole}); err != nil || m.Present {
+			t.Fatalf("whole image %v: %+v, %v", whole, m, err)
+		}
+	}
+}
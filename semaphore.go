@@ -0,0 +1,38 @@
+package watermark
+
+import "context"
+
+// acquire waits for one of the engine's concurrency slots, giving up with
+// ctx.Err() once ctx is done, and then takes up to parallelism-1 more if
+// they are free right away. It returns the number of bands the removal may
+// copy in and the function that gives every slot back. Engines without
+// WithMaxConcurrency never wait.
+func (e *Engine) acquire(ctx context.Context) (bands int, release func(), err error) {
+	if e.slots == nil {
+		return e.parallelism, func() {}, nil
+	}
+	select {
+	case e.slots <- struct{}{}:
+	case <-ctx.Done():
+		return 0, nil, ctx.Err()
+	}
+	bands = 1
+	for bands < e.parallelism && e.tryAcquire() {
+		bands++
+	}
+	return bands, func() {
+		for i := 0; i < bands; i++ {
+			<-e.slots
+		}
+	}, nil
+}
+
+// tryAcquire takes a concurrency slot if one is free.
+func (e *Engine) tryAcquire() bool {
+	select {
+	case e.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
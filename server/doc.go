@@ -0,0 +1,6 @@
+// Package server hosts the watermark remover as a long-running service.
+//
+// Work is admitted through a Scheduler that keeps interactive and batch
+// requests in separate worker pools with their own queue limits, so bulk
+// jobs cannot starve latency-sensitive callers.
+package server
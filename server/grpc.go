@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	"github.com/gcslaoli/gemini-watermark-remover-go/server/grpcpb"
+)
+
+// grpcChunkSize is the size of the data chunks Remove streams the cleaned
+// image in, well below gRPC's default 4 MiB message limit.
+const grpcChunkSize = 1 << 20
+
+// GRPCConfig configures RegisterGRPC.
+type GRPCConfig struct {
+	// Options is passed to ProcessBytes by Remove; its Profile also drives
+	// Detect.
+	Options watermark.Options
+	// Scheduler, when set, runs each call in the pool named by the
+	// "x-priority" metadata ("interactive" or "batch", default
+	// interactive), as Limiter does for HTTP. Calls beyond the queue limit
+	// fail with ResourceExhausted. Nil runs every call immediately.
+	Scheduler *Scheduler
+	// MaxImageBytes limits the size of one uploaded image. Zero means
+	// DefaultMaxBodyBytes.
+	MaxImageBytes int64
+	// ImageTimeout bounds the wait for one image (see watermark.Watchdog);
+	// calls that exceed it fail with DeadlineExceeded, while work that does
+	// not check its context keeps its Scheduler worker until it stops. Zero
+	// means no limit.
+	ImageTimeout time.Duration
+}
+
+// RegisterGRPC registers the gwatermark.v1.Watermark service of
+// grpcpb/watermark.proto on s:
+//
+//	Detect  reads a stream of image chunks and returns the detection
+//	Remove  reads a stream of image chunks and streams back a header with
+//	        the detection, then the cleaned image in data chunks
+//
+// Undecodable images fail with InvalidArgument, oversized ones with
+// ResourceExhausted, and inputs that crash a decoder with Internal.
+func RegisterGRPC(s grpc.ServiceRegistrar, cfg GRPCConfig) {
+	if cfg.MaxImageBytes <= 0 {
+		cfg.MaxImageBytes = DefaultMaxBodyBytes
+	}
+	grpcpb.RegisterWatermarkServer(s, &grpcServer{cfg: cfg})
+}
+
+type grpcServer struct {
+	grpcpb.UnimplementedWatermarkServer
+	cfg GRPCConfig
+}
+
+func (s *grpcServer) Detect(stream grpc.ClientStreamingServer[grpcpb.ImageChunk, grpcpb.Detection]) error {
+	data, err := receiveImage(stream, s.cfg.MaxImageBytes)
+	if err != nil {
+		return err
+	}
+	var resp Response
+	err = s.run(stream.Context(), func(context.Context) (err error) {
+		resp, err = detect(data, s.cfg.Options)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return stream.SendAndClose(detectionPB(resp))
+}
+
+func (s *grpcServer) Remove(stream grpc.BidiStreamingServer[grpcpb.ImageChunk, grpcpb.RemoveResponse]) error {
+	data, err := receiveImage(stream, s.cfg.MaxImageBytes)
+	if err != nil {
+		return err
+	}
+	ctx := stream.Context()
+	var resp Response
+	var invisible bool
+	err = s.run(ctx, func(ctx context.Context) (err error) {
+		resp, err = remove(ctx, data, s.cfg.Options)
+		invisible = resp.Info.InvisibleWatermark
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	header := &grpcpb.RemoveHeader{
+		Detection:          detectionPB(resp),
+		ImageFormat:        resp.ImageFormat,
+		InvisibleWatermark: invisible,
+	}
+	for _, w := range resp.Warnings {
+		header.Warnings = append(header.Warnings, &grpcpb.Warning{Code: w.Code.String(), Message: w.Message})
+	}
+	if err := stream.Send(&grpcpb.RemoveResponse{Payload: &grpcpb.RemoveResponse_Header{Header: header}}); err != nil {
+		return err
+	}
+	for out := resp.Image; len(out) > 0; {
+		n := min(len(out), grpcChunkSize)
+		if err := stream.Send(&grpcpb.RemoveResponse{Payload: &grpcpb.RemoveResponse_Data{Data: out[:n]}}); err != nil {
+			return err
+		}
+		out = out[n:]
+	}
+	return nil
+}
+
+// run runs fn under the watchdog in the scheduler pool the call's metadata
+// asks for and converts its error to a gRPC status. Work abandoned after a
+// timeout keeps its worker until it stops.
+func (s *grpcServer) run(ctx context.Context, fn func(context.Context) error) error {
+	var err error
+	work := func() <-chan struct{} {
+		var hold <-chan struct{}
+		hold, err = watermark.WatchdogDone(ctx, s.cfg.ImageTimeout, fn)
+		return hold
+	}
+	if s.cfg.Scheduler == nil {
+		work()
+		return grpcStatus(err)
+	}
+
+	prio := Interactive
+	if v := metadata.ValueFromIncomingContext(ctx, "x-priority"); len(v) > 0 {
+		p, perr := ParsePriority(v[0])
+		if perr != nil {
+			return status.Error(codes.InvalidArgument, perr.Error())
+		}
+		prio = p
+	}
+	switch serr := s.cfg.Scheduler.DoHeld(ctx, prio, work); {
+	case serr == nil:
+		return grpcStatus(err)
+	case errors.Is(serr, ErrQueueFull):
+		return status.Error(codes.ResourceExhausted, "server saturated, retry later")
+	case errors.Is(serr, ErrClosed):
+		return status.Error(codes.Unavailable, "server shutting down")
+	default:
+		return grpcStatus(serr)
+	}
+}
+
+// grpcStatus is failureStatus for gRPC.
+func grpcStatus(err error) error {
+	var panicErr *watermark.PanicError
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &panicErr):
+		return status.Error(codes.Internal, err.Error())
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return status.FromContextError(err).Err()
+	default:
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+}
+
+// chunkReceiver is the receiving side of the Detect and Remove streams.
+type chunkReceiver interface {
+	Recv() (*grpcpb.ImageChunk, error)
+}
+
+// receiveImage reads image chunks from stream until the client closes it.
+func receiveImage(stream chunkReceiver, limit int64) ([]byte, error) {
+	var data []byte
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)+len(chunk.GetData())) > limit {
+			return nil, status.Error(codes.ResourceExhausted, fmt.Sprintf("image exceeds %d bytes", limit))
+		}
+		data = append(data, chunk.GetData()...)
+	}
+	if len(data) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "empty image")
+	}
+	return data, nil
+}
+
+func detectionPB(resp Response) *grpcpb.Detection {
+	pos := resp.Info.Position
+	return &grpcpb.Detection{
+		Width:       int32(resp.Width),
+		Height:      int32(resp.Height),
+		Format:      resp.Format,
+		Present:     resp.Present,
+		Score:       resp.Score,
+		Correlation: resp.Correlation,
+		Confidence:  resp.Confidence.String(),
+		Size:        int32(resp.Info.Size),
+		Position:    &grpcpb.Rect{MinX: int32(pos.Min.X), MinY: int32(pos.Min.Y), MaxX: int32(pos.Max.X), MaxY: int32(pos.Max.Y)},
+		Corner:      resp.Info.Corner.String(),
+	}
+}
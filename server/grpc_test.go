@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"io"
+	"net"
+	"os"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/gcslaoli/gemini-watermark-remover-go/server/grpcpb"
+)
+
+// grpcClient serves cfg over an in-memory listener and returns a client.
+func grpcClient(t *testing.T, cfg GRPCConfig) grpcpb.WatermarkClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	RegisterGRPC(srv, cfg)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return lis.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return grpcpb.NewWatermarkClient(conn)
+}
+
+// sendChunks streams data in small chunks and closes the sending side.
+func sendChunks(t *testing.T, stream interface {
+	Send(*grpcpb.ImageChunk) error
+	CloseSend() error
+}, data []byte) {
+	t.Helper()
+	for len(data) > 0 {
+		n := min(len(data), 4096)
+		if err := stream.Send(&grpcpb.ImageChunk{Data: data[:n]}); err != nil {
+			t.Fatal(err)
+		}
+		data = data[n:]
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func grpcDetect(t *testing.T, client grpcpb.WatermarkClient, data []byte) (*grpcpb.Detection, error) {
+	t.Helper()
+	stream, err := client.Detect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendChunks(t, stream, data)
+	return stream.CloseAndRecv()
+}
+
+func grpcRemove(t *testing.T, client grpcpb.WatermarkClient, data []byte) (*grpcpb.RemoveHeader, []byte, error) {
+	t.Helper()
+	stream, err := client.Remove(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sendChunks(t, stream, data)
+	var header *grpcpb.RemoveHeader
+	var out []byte
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return header, out, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if h := msg.GetHeader(); h != nil {
+			header = h
+		}
+		out = append(out, msg.GetData()...)
+	}
+}
+
+func TestGRPC(t *testing.T) {
+	marked, err := os.ReadFile("../testdata/watermarked.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clean, err := os.ReadFile("../testdata/clean.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := grpcClient(t, GRPCConfig{})
+
+	det, err := grpcDetect(t, client, marked)
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if !det.Present || det.Format != "webp" || det.Width == 0 || det.Size == 0 || det.Confidence == "" {
+		t.Errorf("Detect = %v, want a present webp watermark", det)
+	}
+
+	header, out, err := grpcRemove(t, client, marked)
+	if err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if header == nil || !header.Detection.Present || header.ImageFormat != "png" {
+		t.Fatalf("Remove header = %v, want a cleaned png", header)
+	}
+	img, _, err := image.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("decode streamed output: %v", err)
+	}
+	if got := img.Bounds().Dx(); got != int(det.Width) {
+		t.Errorf("output width = %d, want %d", got, det.Width)
+	}
+
+	header, out, err = grpcRemove(t, client, clean)
+	if err != nil {
+		t.Fatalf("Remove clean: %v", err)
+	}
+	if header == nil || header.Detection.Present || len(out) != 0 {
+		t.Errorf("Remove on a clean image sent present=%v and %d bytes", header.GetDetection().GetPresent(), len(out))
+	}
+}
+
+func TestGRPCErrors(t *testing.T) {
+	client := grpcClient(t, GRPCConfig{MaxImageBytes: 64})
+
+	tests := []struct {
+		name string
+		data []byte
+		want codes.Code
+	}{
+		{"empty", nil, codes.InvalidArgument},
+		{"garbage", []byte("not an image"), codes.InvalidArgument},
+		{"too large", make([]byte, 65), codes.ResourceExhausted},
+		{"decoder panic", []byte(panicMagic + "payload"), codes.Internal},
+	}
+	for _, tt := range tests {
+		if _, err := grpcDetect(t, client, tt.data); status.Code(err) != tt.want {
+			t.Errorf("Detect %s: %v, want %v", tt.name, err, tt.want)
+		}
+		if _, _, err := grpcRemove(t, client, tt.data); status.Code(err) != tt.want {
+			t.Errorf("Remove %s: %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
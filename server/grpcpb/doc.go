@@ -0,0 +1,8 @@
+// Package grpcpb holds the protobuf messages and gRPC service of
+// gwatermark serve-grpc, generated from watermark.proto.
+//
+// Regenerate them with buf, protoc-gen-go and protoc-gen-go-grpc on PATH,
+// from the repository root:
+//
+//	buf generate --template server/grpcpb/buf.gen.yaml server/grpcpb
+package grpcpb
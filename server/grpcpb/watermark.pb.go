@@ -0,0 +1,569 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: watermark.proto
+
+package grpcpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ImageChunk is one piece of the encoded input image (PNG, JPEG, WebP or
+// GIF), in order.
+type ImageChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Data          []byte                 `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ImageChunk) Reset() {
+	*x = ImageChunk{}
+	mi := &file_watermark_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ImageChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImageChunk) ProtoMessage() {}
+
+func (x *ImageChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_watermark_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImageChunk.ProtoReflect.Descriptor instead.
+func (*ImageChunk) Descriptor() ([]byte, []int) {
+	return file_watermark_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ImageChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type Rect struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MinX          int32                  `protobuf:"varint,1,opt,name=min_x,json=minX,proto3" json:"min_x,omitempty"`
+	MinY          int32                  `protobuf:"varint,2,opt,name=min_y,json=minY,proto3" json:"min_y,omitempty"`
+	MaxX          int32                  `protobuf:"varint,3,opt,name=max_x,json=maxX,proto3" json:"max_x,omitempty"`
+	MaxY          int32                  `protobuf:"varint,4,opt,name=max_y,json=maxY,proto3" json:"max_y,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Rect) Reset() {
+	*x = Rect{}
+	mi := &file_watermark_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Rect) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Rect) ProtoMessage() {}
+
+func (x *Rect) ProtoReflect() protoreflect.Message {
+	mi := &file_watermark_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Rect.ProtoReflect.Descriptor instead.
+func (*Rect) Descriptor() ([]byte, []int) {
+	return file_watermark_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Rect) GetMinX() int32 {
+	if x != nil {
+		return x.MinX
+	}
+	return 0
+}
+
+func (x *Rect) GetMinY() int32 {
+	if x != nil {
+		return x.MinY
+	}
+	return 0
+}
+
+func (x *Rect) GetMaxX() int32 {
+	if x != nil {
+		return x.MaxX
+	}
+	return 0
+}
+
+func (x *Rect) GetMaxY() int32 {
+	if x != nil {
+		return x.MaxY
+	}
+	return 0
+}
+
+type Detection struct {
+	state  protoimpl.MessageState `protogen:"open.v1"`
+	Width  int32                  `protobuf:"varint,1,opt,name=width,proto3" json:"width,omitempty"`
+	Height int32                  `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	// format is the decoded input format, e.g. "png".
+	Format      string  `protobuf:"bytes,3,opt,name=format,proto3" json:"format,omitempty"`
+	Present     bool    `protobuf:"varint,4,opt,name=present,proto3" json:"present,omitempty"`
+	Score       float64 `protobuf:"fixed64,5,opt,name=score,proto3" json:"score,omitempty"`
+	Correlation float64 `protobuf:"fixed64,6,opt,name=correlation,proto3" json:"correlation,omitempty"`
+	// confidence is "low", "medium" or "high".
+	Confidence string `protobuf:"bytes,7,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	// size is the side of the logo in pixels and position its rectangle.
+	Size     int32 `protobuf:"varint,8,opt,name=size,proto3" json:"size,omitempty"`
+	Position *Rect `protobuf:"bytes,9,opt,name=position,proto3" json:"position,omitempty"`
+	// corner is the corner the watermark is anchored to, e.g. "br".
+	Corner        string `protobuf:"bytes,10,opt,name=corner,proto3" json:"corner,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Detection) Reset() {
+	*x = Detection{}
+	mi := &file_watermark_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Detection) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Detection) ProtoMessage() {}
+
+func (x *Detection) ProtoReflect() protoreflect.Message {
+	mi := &file_watermark_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Detection.ProtoReflect.Descriptor instead.
+func (*Detection) Descriptor() ([]byte, []int) {
+	return file_watermark_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Detection) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *Detection) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *Detection) GetFormat() string {
+	if x != nil {
+		return x.Format
+	}
+	return ""
+}
+
+func (x *Detection) GetPresent() bool {
+	if x != nil {
+		return x.Present
+	}
+	return false
+}
+
+func (x *Detection) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+func (x *Detection) GetCorrelation() float64 {
+	if x != nil {
+		return x.Correlation
+	}
+	return 0
+}
+
+func (x *Detection) GetConfidence() string {
+	if x != nil {
+		return x.Confidence
+	}
+	return ""
+}
+
+func (x *Detection) GetSize() int32 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Detection) GetPosition() *Rect {
+	if x != nil {
+		return x.Position
+	}
+	return nil
+}
+
+func (x *Detection) GetCorner() string {
+	if x != nil {
+		return x.Corner
+	}
+	return ""
+}
+
+type Warning struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Code          string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Warning) Reset() {
+	*x = Warning{}
+	mi := &file_watermark_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Warning) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Warning) ProtoMessage() {}
+
+func (x *Warning) ProtoReflect() protoreflect.Message {
+	mi := &file_watermark_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Warning.ProtoReflect.Descriptor instead.
+func (*Warning) Descriptor() ([]byte, []int) {
+	return file_watermark_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Warning) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *Warning) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+type RemoveHeader struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Detection *Detection             `protobuf:"bytes,1,opt,name=detection,proto3" json:"detection,omitempty"`
+	// image_format is the format of the cleaned image, e.g. "png".
+	ImageFormat string     `protobuf:"bytes,2,opt,name=image_format,json=imageFormat,proto3" json:"image_format,omitempty"`
+	Warnings    []*Warning `protobuf:"bytes,3,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	// invisible_watermark reports that an invisible watermark likely
+	// remains, when the server checks for one.
+	InvisibleWatermark bool `protobuf:"varint,4,opt,name=invisible_watermark,json=invisibleWatermark,proto3" json:"invisible_watermark,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *RemoveHeader) Reset() {
+	*x = RemoveHeader{}
+	mi := &file_watermark_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveHeader) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveHeader) ProtoMessage() {}
+
+func (x *RemoveHeader) ProtoReflect() protoreflect.Message {
+	mi := &file_watermark_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveHeader.ProtoReflect.Descriptor instead.
+func (*RemoveHeader) Descriptor() ([]byte, []int) {
+	return file_watermark_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *RemoveHeader) GetDetection() *Detection {
+	if x != nil {
+		return x.Detection
+	}
+	return nil
+}
+
+func (x *RemoveHeader) GetImageFormat() string {
+	if x != nil {
+		return x.ImageFormat
+	}
+	return ""
+}
+
+func (x *RemoveHeader) GetWarnings() []*Warning {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *RemoveHeader) GetInvisibleWatermark() bool {
+	if x != nil {
+		return x.InvisibleWatermark
+	}
+	return false
+}
+
+type RemoveResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*RemoveResponse_Header
+	//	*RemoveResponse_Data
+	Payload       isRemoveResponse_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RemoveResponse) Reset() {
+	*x = RemoveResponse{}
+	mi := &file_watermark_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RemoveResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RemoveResponse) ProtoMessage() {}
+
+func (x *RemoveResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_watermark_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RemoveResponse.ProtoReflect.Descriptor instead.
+func (*RemoveResponse) Descriptor() ([]byte, []int) {
+	return file_watermark_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *RemoveResponse) GetPayload() isRemoveResponse_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *RemoveResponse) GetHeader() *RemoveHeader {
+	if x != nil {
+		if x, ok := x.Payload.(*RemoveResponse_Header); ok {
+			return x.Header
+		}
+	}
+	return nil
+}
+
+func (x *RemoveResponse) GetData() []byte {
+	if x != nil {
+		if x, ok := x.Payload.(*RemoveResponse_Data); ok {
+			return x.Data
+		}
+	}
+	return nil
+}
+
+type isRemoveResponse_Payload interface {
+	isRemoveResponse_Payload()
+}
+
+type RemoveResponse_Header struct {
+	Header *RemoveHeader `protobuf:"bytes,1,opt,name=header,proto3,oneof"`
+}
+
+type RemoveResponse_Data struct {
+	Data []byte `protobuf:"bytes,2,opt,name=data,proto3,oneof"`
+}
+
+func (*RemoveResponse_Header) isRemoveResponse_Payload() {}
+
+func (*RemoveResponse_Data) isRemoveResponse_Payload() {}
+
+var File_watermark_proto protoreflect.FileDescriptor
+
+const file_watermark_proto_rawDesc = "" +
+	"\n" +
+	"\x0fwatermark.proto\x12\rgwatermark.v1\" \n" +
+	"\n" +
+	"ImageChunk\x12\x12\n" +
+	"\x04data\x18\x01 \x01(\fR\x04data\"Z\n" +
+	"\x04Rect\x12\x13\n" +
+	"\x05min_x\x18\x01 \x01(\x05R\x04minX\x12\x13\n" +
+	"\x05min_y\x18\x02 \x01(\x05R\x04minY\x12\x13\n" +
+	"\x05max_x\x18\x03 \x01(\x05R\x04maxX\x12\x13\n" +
+	"\x05max_y\x18\x04 \x01(\x05R\x04maxY\"\xa0\x02\n" +
+	"\tDetection\x12\x14\n" +
+	"\x05width\x18\x01 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x02 \x01(\x05R\x06height\x12\x16\n" +
+	"\x06format\x18\x03 \x01(\tR\x06format\x12\x18\n" +
+	"\apresent\x18\x04 \x01(\bR\apresent\x12\x14\n" +
+	"\x05score\x18\x05 \x01(\x01R\x05score\x12 \n" +
+	"\vcorrelation\x18\x06 \x01(\x01R\vcorrelation\x12\x1e\n" +
+	"\n" +
+	"confidence\x18\a \x01(\tR\n" +
+	"confidence\x12\x12\n" +
+	"\x04size\x18\b \x01(\x05R\x04size\x12/\n" +
+	"\bposition\x18\t \x01(\v2\x13.gwatermark.v1.RectR\bposition\x12\x16\n" +
+	"\x06corner\x18\n" +
+	" \x01(\tR\x06corner\"7\n" +
+	"\aWarning\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\"\xce\x01\n" +
+	"\fRemoveHeader\x126\n" +
+	"\tdetection\x18\x01 \x01(\v2\x18.gwatermark.v1.DetectionR\tdetection\x12!\n" +
+	"\fimage_format\x18\x02 \x01(\tR\vimageFormat\x122\n" +
+	"\bwarnings\x18\x03 \x03(\v2\x16.gwatermark.v1.WarningR\bwarnings\x12/\n" +
+	"\x13invisible_watermark\x18\x04 \x01(\bR\x12invisibleWatermark\"h\n" +
+	"\x0eRemoveResponse\x125\n" +
+	"\x06header\x18\x01 \x01(\v2\x1b.gwatermark.v1.RemoveHeaderH\x00R\x06header\x12\x14\n" +
+	"\x04data\x18\x02 \x01(\fH\x00R\x04dataB\t\n" +
+	"\apayload2\x94\x01\n" +
+	"\tWatermark\x12?\n" +
+	"\x06Detect\x12\x19.gwatermark.v1.ImageChunk\x1a\x18.gwatermark.v1.Detection(\x01\x12F\n" +
+	"\x06Remove\x12\x19.gwatermark.v1.ImageChunk\x1a\x1d.gwatermark.v1.RemoveResponse(\x010\x01B?Z=github.com/gcslaoli/gemini-watermark-remover-go/server/grpcpbb\x06proto3"
+
+var (
+	file_watermark_proto_rawDescOnce sync.Once
+	file_watermark_proto_rawDescData []byte
+)
+
+func file_watermark_proto_rawDescGZIP() []byte {
+	file_watermark_proto_rawDescOnce.Do(func() {
+		file_watermark_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_watermark_proto_rawDesc), len(file_watermark_proto_rawDesc)))
+	})
+	return file_watermark_proto_rawDescData
+}
+
+var file_watermark_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_watermark_proto_goTypes = []any{
+	(*ImageChunk)(nil),     // 0: gwatermark.v1.ImageChunk
+	(*Rect)(nil),           // 1: gwatermark.v1.Rect
+	(*Detection)(nil),      // 2: gwatermark.v1.Detection
+	(*Warning)(nil),        // 3: gwatermark.v1.Warning
+	(*RemoveHeader)(nil),   // 4: gwatermark.v1.RemoveHeader
+	(*RemoveResponse)(nil), // 5: gwatermark.v1.RemoveResponse
+}
+var file_watermark_proto_depIdxs = []int32{
+	1, // 0: gwatermark.v1.Detection.position:type_name -> gwatermark.v1.Rect
+	2, // 1: gwatermark.v1.RemoveHeader.detection:type_name -> gwatermark.v1.Detection
+	3, // 2: gwatermark.v1.RemoveHeader.warnings:type_name -> gwatermark.v1.Warning
+	4, // 3: gwatermark.v1.RemoveResponse.header:type_name -> gwatermark.v1.RemoveHeader
+	0, // 4: gwatermark.v1.Watermark.Detect:input_type -> gwatermark.v1.ImageChunk
+	0, // 5: gwatermark.v1.Watermark.Remove:input_type -> gwatermark.v1.ImageChunk
+	2, // 6: gwatermark.v1.Watermark.Detect:output_type -> gwatermark.v1.Detection
+	5, // 7: gwatermark.v1.Watermark.Remove:output_type -> gwatermark.v1.RemoveResponse
+	6, // [6:8] is the sub-list for method output_type
+	4, // [4:6] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_watermark_proto_init() }
+func file_watermark_proto_init() {
+	if File_watermark_proto != nil {
+		return
+	}
+	file_watermark_proto_msgTypes[5].OneofWrappers = []any{
+		(*RemoveResponse_Header)(nil),
+		(*RemoveResponse_Data)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_watermark_proto_rawDesc), len(file_watermark_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   6,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_watermark_proto_goTypes,
+		DependencyIndexes: file_watermark_proto_depIdxs,
+		MessageInfos:      file_watermark_proto_msgTypes,
+	}.Build()
+	File_watermark_proto = out.File
+	file_watermark_proto_goTypes = nil
+	file_watermark_proto_depIdxs = nil
+}
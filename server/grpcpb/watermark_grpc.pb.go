@@ -0,0 +1,166 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: watermark.proto
+
+package grpcpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Watermark_Detect_FullMethodName = "/gwatermark.v1.Watermark/Detect"
+	Watermark_Remove_FullMethodName = "/gwatermark.v1.Watermark/Remove"
+)
+
+// WatermarkClient is the client API for Watermark service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Watermark is the gRPC API of gwatermark serve-grpc. Images travel as
+// streams of byte chunks, so clients need not hold a whole upload in one
+// message and outputs larger than the default 4 MiB message limit still get
+// through.
+type WatermarkClient interface {
+	// Detect reports the image dimensions and whether the watermark is
+	// present, without removing it.
+	Detect(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImageChunk, Detection], error)
+	// Remove cleans the image. The first response carries the detection and
+	// the output format; the cleaned image follows in data chunks. No data
+	// is sent when no watermark was found.
+	Remove(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ImageChunk, RemoveResponse], error)
+}
+
+type watermarkClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewWatermarkClient(cc grpc.ClientConnInterface) WatermarkClient {
+	return &watermarkClient{cc}
+}
+
+func (c *watermarkClient) Detect(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ImageChunk, Detection], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Watermark_ServiceDesc.Streams[0], Watermark_Detect_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImageChunk, Detection]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Watermark_DetectClient = grpc.ClientStreamingClient[ImageChunk, Detection]
+
+func (c *watermarkClient) Remove(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[ImageChunk, RemoveResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Watermark_ServiceDesc.Streams[1], Watermark_Remove_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ImageChunk, RemoveResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Watermark_RemoveClient = grpc.BidiStreamingClient[ImageChunk, RemoveResponse]
+
+// WatermarkServer is the server API for Watermark service.
+// All implementations must embed UnimplementedWatermarkServer
+// for forward compatibility.
+//
+// Watermark is the gRPC API of gwatermark serve-grpc. Images travel as
+// streams of byte chunks, so clients need not hold a whole upload in one
+// message and outputs larger than the default 4 MiB message limit still get
+// through.
+type WatermarkServer interface {
+	// Detect reports the image dimensions and whether the watermark is
+	// present, without removing it.
+	Detect(grpc.ClientStreamingServer[ImageChunk, Detection]) error
+	// Remove cleans the image. The first response carries the detection and
+	// the output format; the cleaned image follows in data chunks. No data
+	// is sent when no watermark was found.
+	Remove(grpc.BidiStreamingServer[ImageChunk, RemoveResponse]) error
+	mustEmbedUnimplementedWatermarkServer()
+}
+
+// UnimplementedWatermarkServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedWatermarkServer struct{}
+
+func (UnimplementedWatermarkServer) Detect(grpc.ClientStreamingServer[ImageChunk, Detection]) error {
+	return status.Errorf(codes.Unimplemented, "method Detect not implemented")
+}
+func (UnimplementedWatermarkServer) Remove(grpc.BidiStreamingServer[ImageChunk, RemoveResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method Remove not implemented")
+}
+func (UnimplementedWatermarkServer) mustEmbedUnimplementedWatermarkServer() {}
+func (UnimplementedWatermarkServer) testEmbeddedByValue()                   {}
+
+// UnsafeWatermarkServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to WatermarkServer will
+// result in compilation errors.
+type UnsafeWatermarkServer interface {
+	mustEmbedUnimplementedWatermarkServer()
+}
+
+func RegisterWatermarkServer(s grpc.ServiceRegistrar, srv WatermarkServer) {
+	// If the following call pancis, it indicates UnimplementedWatermarkServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Watermark_ServiceDesc, srv)
+}
+
+func _Watermark_Detect_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(WatermarkServer).Detect(&grpc.GenericServerStream[ImageChunk, Detection]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Watermark_DetectServer = grpc.ClientStreamingServer[ImageChunk, Detection]
+
+func _Watermark_Remove_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(WatermarkServer).Remove(&grpc.GenericServerStream[ImageChunk, RemoveResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Watermark_RemoveServer = grpc.BidiStreamingServer[ImageChunk, RemoveResponse]
+
+// Watermark_ServiceDesc is the grpc.ServiceDesc for Watermark service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Watermark_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "gwatermark.v1.Watermark",
+	HandlerType: (*WatermarkServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Detect",
+			Handler:       _Watermark_Detect_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Remove",
+			Handler:       _Watermark_Remove_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "watermark.proto",
+}
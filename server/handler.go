@@ -0,0 +1,319 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// DefaultMaxBodyBytes caps request bodies when HandlerConfig.MaxBodyBytes is
+// not set.
+const DefaultMaxBodyBytes = 32 << 20
+
+// HandlerConfig configures NewHandler.
+type HandlerConfig struct {
+	// Options is passed to ProcessBytes by /remove. Its Profile also drives
+	// /detect; nil means the Gemini profile.
+	Options watermark.Options
+	// MaxBodyBytes limits the request body. Zero means DefaultMaxBodyBytes.
+	MaxBodyBytes int64
+	// ImageTimeout bounds the wait for one image (see watermark.Watchdog);
+	// requests that exceed it fail with 422 instead of hanging. Work that
+	// does not check its context, like a decoder stuck on a malformed
+	// image, keeps running after the reply until it finishes on its own;
+	// behind a Limiter it holds its worker until then, so stuck images
+	// still count against the pool. Zero means no limit.
+	ImageTimeout time.Duration
+}
+
+// Response is the JSON body of /detect and /remove.
+type Response struct {
+	Width   int     `json:"width"`
+	Height  int     `json:"height"`
+	Format  string  `json:"format"`
+	Present bool    `json:"present"`
+	Score   float64 `json:"score"`
+	// Correlation and Confidence come from detection, see
+	// watermark.DetectionResult. /remove reports them for single images
+	// only, as watermark.Result does.
+	Correlation float64              `json:"correlation"`
+	Confidence  watermark.Confidence `json:"confidence"`
+	Info        watermark.Info       `json:"info"`
+	// Image holds the cleaned output of /remove (PNG, or GIF for
+	// animations), base64-encoded by encoding/json. It is omitted when no
+	// watermark was found.
+	Image       []byte `json:"image,omitempty"`
+	ImageFormat string `json:"imageFormat,omitempty"`
+	// Warnings lists the non-fatal conditions /remove ran into, such as
+	// clipped pixels or metadata the output format could not carry.
+	Warnings []watermark.Warning `json:"warnings,omitempty"`
+}
+
+// PreviewResponse is the JSON body of /preview, and of the preview results
+// and notifications of the RPC protocol.
+type PreviewResponse struct {
+	Width       int                  `json:"width"`
+	Height      int                  `json:"height"`
+	Format      string               `json:"format"`
+	Present     bool                 `json:"present"`
+	Score       float64              `json:"score"`
+	Correlation float64              `json:"correlation"`
+	Confidence  watermark.Confidence `json:"confidence"`
+	Info        watermark.Info       `json:"info"`
+	// Region is the part of the image the preview shows, and Image the
+	// cleaned, scaled-down region as base64 PNG. Both are omitted when no
+	// watermark was found.
+	Region image.Rectangle `json:"region"`
+	Image  []byte          `json:"image,omitempty"`
+}
+
+// errorResponse is the JSON body of failed requests.
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// NewHandler returns the REST API:
+//
+//	POST /detect   reports dimensions and the detection result
+//	POST /preview  returns a low-resolution cleaned watermark corner
+//	POST /remove   also returns the cleaned image
+//
+// /preview takes the longest side of the preview in the "size" query
+// parameter (default watermark.DefaultPreviewSize); it is much faster than
+// /remove on large images, so interfaces can show it while the full image is
+// processed. The image is sent as multipart/form-data in the "image" field, as JSON
+// {"image": "<base64 or data URL>"}, or as the raw request body. Wrap the
+// handler with a Limiter to bound concurrent work.
+func NewHandler(cfg HandlerConfig) http.Handler {
+	if cfg.MaxBodyBytes <= 0 {
+		cfg.MaxBodyBytes = DefaultMaxBodyBytes
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /detect", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := readImage(w, r, cfg.MaxBodyBytes)
+		if !ok {
+			return
+		}
+		var resp Response
+		err := watchdog(r.Context(), cfg.ImageTimeout, func(context.Context) (err error) {
+			resp, err = detect(data, cfg.Options)
+			return err
+		})
+		if err != nil {
+			writeError(w, failureStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+	mux.HandleFunc("POST /preview", func(w http.ResponseWriter, r *http.Request) {
+		size := 0
+		if v := r.URL.Query().Get("size"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("invalid size %q", v))
+				return
+			}
+			size = n
+		}
+		data, ok := readImage(w, r, cfg.MaxBodyBytes)
+		if !ok {
+			return
+		}
+		var resp PreviewResponse
+		err := watchdog(r.Context(), cfg.ImageTimeout, func(context.Context) (err error) {
+			resp, err = preview(data, cfg.Options, size)
+			return err
+		})
+		if err != nil {
+			writeError(w, failureStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+	mux.HandleFunc("POST /remove", func(w http.ResponseWriter, r *http.Request) {
+		data, ok := readImage(w, r, cfg.MaxBodyBytes)
+		if !ok {
+			return
+		}
+		var resp Response
+		err := watchdog(r.Context(), cfg.ImageTimeout, func(ctx context.Context) (err error) {
+			resp, err = remove(ctx, data, cfg.Options)
+			return err
+		})
+		if err != nil {
+			writeError(w, failureStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+	return mux
+}
+
+func detect(data []byte, opts watermark.Options) (Response, error) {
+	report, err := watermark.InspectBytesProfile(data, profile(opts))
+	if err != nil {
+		return Response{}, err
+	}
+	if report.DetectErr != nil {
+		return Response{}, report.DetectErr
+	}
+	return Response{
+		Width:       report.Width,
+		Height:      report.Height,
+		Format:      report.Format,
+		Present:     report.Present,
+		Score:       report.Score,
+		Correlation: report.Correlation,
+		Confidence:  report.Confidence,
+		Info:        report.Info,
+	}, nil
+}
+
+// remove runs ProcessBytes once and reports its detection and output. The
+// input's size and format come from its header, which is read without
+// decoding the pixels.
+func remove(ctx context.Context, data []byte, opts watermark.Options) (Response, error) {
+	result, err := watermark.ProcessBytesContext(ctx, data, opts)
+	if err != nil {
+		return Response{}, err
+	}
+	resp := Response{
+		Present:     result.Present,
+		Score:       result.Score,
+		Correlation: result.Correlation,
+		Confidence:  result.Confidence,
+		Info:        result.Info,
+		Warnings:    result.Warnings,
+	}
+	if cfg, format, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		resp.Width, resp.Height, resp.Format = cfg.Width, cfg.Height, format
+	}
+	// Options.PassThrough outputs are the input itself, which the
+	// responses leave out.
+	if result.Present {
+		resp.Image, resp.ImageFormat = result.Output, result.Format
+	}
+	return resp, nil
+}
+
+func preview(data []byte, opts watermark.Options, size int) (PreviewResponse, error) {
+	pv, err := watermark.PreviewBytes(data, profile(opts), size)
+	if err != nil {
+		return PreviewResponse{}, err
+	}
+	return PreviewResponse{
+		Width:       pv.Width,
+		Height:      pv.Height,
+		Format:      pv.Format,
+		Present:     pv.Present,
+		Score:       pv.Score,
+		Correlation: pv.Correlation,
+		Confidence:  pv.Confidence,
+		Info:        pv.Info,
+		Region:      pv.Region,
+		Image:       pv.Image,
+	}, nil
+}
+
+func profile(opts watermark.Options) watermark.Profile {
+	if opts.Profile != nil {
+		return *opts.Profile
+	}
+	return watermark.GeminiProfile()
+}
+
+// failureStatus is the status of a request whose processing failed: 500
+// when the library recovered from an internal panic, 422 otherwise, since the
+// image could not be processed.
+func failureStatus(err error) int {
+	var panicErr *watermark.PanicError
+	if errors.As(err, &panicErr) {
+		return http.StatusInternalServerError
+	}
+	return http.StatusUnprocessableEntity
+}
+
+// readImage extracts the image bytes from r, writing an error response and
+// returning false when that fails.
+func readImage(w http.ResponseWriter, r *http.Request, limit int64) ([]byte, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	data, err := imageBytes(r, limit)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", tooLarge.Limit))
+		return nil, false
+	case err != nil:
+		writeError(w, http.StatusBadRequest, err)
+		return nil, false
+	case len(data) == 0:
+		writeError(w, http.StatusBadRequest, errors.New("empty image"))
+		return nil, false
+	}
+	return data, true
+}
+
+// imageBytes reads the image of r, of at most limit bytes. Multipart forms
+// are streamed, so the image field is read into memory and never spilled to
+// a temporary file, and other fields are skipped.
+func imageBytes(r *http.Request, limit int64) ([]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		mr, err := r.MultipartReader()
+		if err != nil {
+			return nil, fmt.Errorf("read form: %w", err)
+		}
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				return nil, fmt.Errorf("read form field image: %w", http.ErrMissingFile)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("read form: %w", err)
+			}
+			if part.FormName() != "image" {
+				continue
+			}
+			data, err := io.ReadAll(io.LimitReader(part, limit+1))
+			if err == nil && int64(len(data)) > limit {
+				err = &http.MaxBytesError{Limit: limit}
+			}
+			return data, err
+		}
+
+	case "application/json":
+		var body struct {
+			Image string `json:"image"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, fmt.Errorf("decode json: %w", err)
+		}
+		return watermark.DecodeBase64Bytes(body.Image)
+
+	default:
+		return io.ReadAll(r.Body)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// panicMagic and spinMagic start inputs of test-only image formats whose
+// decoders panic and hang until the test holding them ends.
+const (
+	panicMagic = "PANIC!"
+	spinMagic  = "SPIN!!"
+)
+
+var spin struct {
+	sync.Mutex
+	release chan struct{}
+}
+
+func init() {
+	image.RegisterFormat("panic", panicMagic, func(io.Reader) (image.Image, error) {
+		panic("decoder edge case")
+	}, func(io.Reader) (image.Config, error) {
+		return image.Config{Width: 1, Height: 1}, nil
+	})
+	image.RegisterFormat("spin", spinMagic, func(io.Reader) (image.Image, error) {
+		spin.Lock()
+		release := spin.release
+		spin.Unlock()
+		if release != nil {
+			<-release
+		}
+		return nil, errors.New("spin decoder released")
+	}, func(io.Reader) (image.Config, error) {
+		return image.Config{Width: 1, Height: 1}, nil
+	})
+}
+
+// holdSpin makes spin decoders hang until t ends, so abandoned decodes
+// don't outlive the test.
+func holdSpin(t *testing.T) {
+	release := make(chan struct{})
+	spin.Lock()
+	spin.release = release
+	spin.Unlock()
+	t.Cleanup(func() { close(release) })
+}
+
+func TestHandler(t *testing.T) {
+	marked, err := os.ReadFile("../testdata/watermarked.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clean, err := os.ReadFile("../testdata/clean.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	multipartBody := func(data []byte) (string, *bytes.Buffer) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		fw, err := mw.CreateFormFile("image", "in.webp")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write(data)
+		mw.Close()
+		return mw.FormDataContentType(), &buf
+	}
+	jsonBody := func(data []byte) (string, *bytes.Buffer) {
+		body, _ := json.Marshal(map[string]string{"image": "data:image/webp;base64," + base64.StdEncoding.EncodeToString(data)})
+		return "application/json", bytes.NewBuffer(body)
+	}
+	rawBody := func(data []byte) (string, *bytes.Buffer) {
+		return "image/webp", bytes.NewBuffer(data)
+	}
+
+	h := NewHandler(HandlerConfig{})
+	for _, tc := range []struct {
+		name    string
+		path    string
+		body    func([]byte) (string, *bytes.Buffer)
+		data    []byte
+		present bool
+	}{
+		{name: "detect multipart", path: "/detect", body: multipartBody, data: marked, present: true},
+		{name: "detect json", path: "/detect", body: jsonBody, data: clean},
+		{name: "remove raw", path: "/remove", body: rawBody, data: marked, present: true},
+		{name: "remove json", path: "/remove", body: jsonBody, data: marked, present: true},
+		{name: "remove clean", path: "/remove", body: multipartBody, data: clean},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			contentType, body := tc.body(tc.data)
+			req := httptest.NewRequest("POST", tc.path, body)
+			req.Header.Set("Content-Type", contentType)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body)
+			}
+			var resp Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Width != 112 || resp.Format != "webp" || resp.Present != tc.present {
+				t.Fatalf("response = %+v", resp)
+			}
+			// A present verdict passes the shape-match gate.
+			if tc.present && resp.Correlation < 0.3 {
+				t.Fatalf("correlation = %.2f, want the detection measurement", resp.Correlation)
+			}
+
+			wantImage := tc.path == "/remove" && tc.present
+			if (len(resp.Image) > 0) != wantImage {
+				t.Fatalf("image present = %v, want %v", len(resp.Image) > 0, wantImage)
+			}
+			if wantImage {
+				if resp.ImageFormat != "png" {
+					t.Fatalf("image format = %q", resp.ImageFormat)
+				}
+				if _, err := png.Decode(bytes.NewReader(resp.Image)); err != nil {
+					t.Fatalf("decode cleaned image: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestHandlerErrors(t *testing.T) {
+	h := NewHandler(HandlerConfig{MaxBodyBytes: 16})
+	for _, tc := range []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{name: "too large", method: "POST", body: strings.Repeat("x", 17), status: http.StatusRequestEntityTooLarge},
+		{name: "empty", method: "POST", status: http.StatusBadRequest},
+		{name: "not an image", method: "POST", body: "hello", status: http.StatusUnprocessableEntity},
+		{name: "decoder panic", method: "POST", body: panicMagic, status: http.StatusInternalServerError},
+		{name: "wrong method", method: "GET", status: http.StatusMethodNotAllowed},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(tc.method, "/detect", strings.NewReader(tc.body)))
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tc.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestHandlerMultipart(t *testing.T) {
+	marked, err := os.ReadFile("../testdata/watermarked.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	form := func(fields map[string][]byte) *http.Request {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		for _, name := range []string{"note", "image"} {
+			if data, ok := fields[name]; ok {
+				fw, err := mw.CreateFormFile(name, name+".bin")
+				if err != nil {
+					t.Fatal(err)
+				}
+				fw.Write(data)
+			}
+		}
+		mw.Close()
+		r := httptest.NewRequest("POST", "/detect", &buf)
+		r.Header.Set("Content-Type", mw.FormDataContentType())
+		return r
+	}
+
+	h := NewHandler(HandlerConfig{})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, form(map[string][]byte{"note": []byte("skipped"), "image": marked}))
+	var resp Response
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); rec.Code != http.StatusOK || err != nil || !resp.Present {
+		t.Errorf("image after another field: status %d, %+v, %v", rec.Code, resp, err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, form(map[string][]byte{"note": marked}))
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "image") {
+		t.Errorf("no image field: status %d (%s)", rec.Code, rec.Body)
+	}
+
+	h = NewHandler(HandlerConfig{MaxBodyBytes: int64(len(marked)) / 2})
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, form(map[string][]byte{"image": marked}))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("image over the limit: status %d (%s)", rec.Code, rec.Body)
+	}
+}
+
+func TestHandlerImageTimeout(t *testing.T) {
+	holdSpin(t)
+	h := NewHandler(HandlerConfig{ImageTimeout: 50 * time.Millisecond})
+	for _, path := range []string{"/detect", "/preview", "/remove"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", path, strings.NewReader(spinMagic)))
+		if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "did not finish within 50ms") {
+			t.Errorf("%s: status %d (%s), want 422 with a timeout", path, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestHandlerPreview(t *testing.T) {
+	marked, err := os.ReadFile("../testdata/watermarked.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(HandlerConfig{})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/preview?size=24", bytes.NewReader(marked)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body)
+	}
+	var resp PreviewResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if !resp.Present || !resp.Info.Position.In(resp.Region) {
+		t.Fatalf("response = %+v", resp)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(resp.Image))
+	if err != nil || max(cfg.Width, cfg.Height) != 24 {
+		t.Fatalf("preview %dx%d, %v", cfg.Width, cfg.Height, err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/preview?size=0", bytes.NewReader(marked)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("size=0: status %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// DefaultRetryAfter is the Retry-After hint sent with 429 responses when a
+// Limiter does not set its own.
+const DefaultRetryAfter = 2 * time.Second
+
+// Limiter applies backpressure to HTTP handlers. Each request runs in the
+// Scheduler pool for its priority, so the pool's Workers bound the requests
+// in flight and QueueLimit bounds how many may wait. Requests beyond that are
+// rejected with 429 Too Many Requests and a Retry-After header instead of
+// being accepted without limit. A request whose image outlived
+// HandlerConfig.ImageTimeout is answered at once but keeps its worker until
+// the abandoned work stops.
+type Limiter struct {
+	Scheduler  *Scheduler
+	RetryAfter time.Duration
+}
+
+// Wrap returns a handler that admits requests to next through the scheduler.
+func (l Limiter) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prio, err := PriorityFromRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		slot := new(heldSlot)
+		r = r.WithContext(context.WithValue(r.Context(), heldSlotKey{}, slot))
+		err = l.Scheduler.DoHeld(r.Context(), prio, func() <-chan struct{} {
+			next.ServeHTTP(w, r)
+			return slot.hold
+		})
+
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrQueueFull):
+			w.Header().Set("Retry-After", retryAfterSeconds(l.RetryAfter))
+			http.Error(w, "server saturated, retry later", http.StatusTooManyRequests)
+		case errors.Is(err, ErrClosed):
+			http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		default:
+			// The client went away while queued; nobody reads the reply.
+		}
+	})
+}
+
+// heldSlot carries work a handler abandoned back to Limiter.Wrap, which
+// keeps the request's worker until it stops.
+type heldSlot struct {
+	hold <-chan struct{}
+}
+
+type heldSlotKey struct{}
+
+// watchdog runs fn under watermark.WatchdogDone and, behind a Limiter,
+// holds the request's worker until fn returns, even after a timeout.
+func watchdog(ctx context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	hold, err := watermark.WatchdogDone(ctx, timeout, fn)
+	if slot, ok := ctx.Value(heldSlotKey{}).(*heldSlot); ok {
+		slot.hold = hold
+	}
+	return err
+}
+
+func retryAfterSeconds(d time.Duration) string {
+	if d <= 0 {
+		d = DefaultRetryAfter
+	}
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
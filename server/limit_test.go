@@ -0,0 +1,76 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiterRejectsWhenSaturated(t *testing.T) {
+	s := NewScheduler(SchedulerConfig{
+		Interactive: PoolConfig{Workers: 1, QueueLimit: 0},
+		Batch:       PoolConfig{Workers: 1, QueueLimit: 0},
+	})
+	defer s.Close()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	go s.Do(context.Background(), Interactive, func() { close(started); <-release })
+	<-started
+	defer close(release)
+
+	h := Limiter{Scheduler: s, RetryAfter: 1500 * time.Millisecond}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/remove", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Fatalf("expected Retry-After 2, got %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/remove?priority=batch", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("batch request should use its own pool, got %d", rec.Code)
+	}
+}
+
+func TestLimiterHoldsAbandonedWork(t *testing.T) {
+	s := NewScheduler(SchedulerConfig{Interactive: PoolConfig{Workers: 1}})
+	defer s.Close()
+
+	release := make(chan struct{})
+	h := Limiter{Scheduler: s}.Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err := watchdog(r.Context(), 20*time.Millisecond, func(context.Context) error {
+			<-release
+			return nil
+		})
+		if err != nil {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	serve := func() int {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/remove", nil))
+		return rec.Code
+	}
+
+	if code := serve(); code != http.StatusUnprocessableEntity {
+		t.Fatalf("stuck request: status %d, want 422", code)
+	}
+	// The abandoned work still runs, so its worker stays busy.
+	if code := serve(); code != http.StatusTooManyRequests {
+		t.Fatalf("request during abandoned work: status %d, want 429", code)
+	}
+
+	close(release)
+	waitFor(t, func() bool { return serve() == http.StatusNoContent })
+}
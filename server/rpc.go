@@ -0,0 +1,368 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// JSON-RPC 2.0 error codes, plus the LSP code for requests that were valid
+// but failed.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcServerBusy     = -32000
+	rpcRequestFailed  = -32803
+)
+
+// RPCConfig configures ServeRPC.
+type RPCConfig struct {
+	// Options is passed to ProcessBytes by remove; its Profile also drives
+	// detect and preview.
+	Options watermark.Options
+	// Scheduler, when set, runs detect and preview in the Interactive pool
+	// and remove in the Batch pool, so previews stay responsive while full
+	// images are cleaned. Nil runs every request immediately.
+	Scheduler *Scheduler
+	// MaxMessageBytes limits the content of one message. Zero means
+	// DefaultMaxBodyBytes.
+	MaxMessageBytes int64
+	// ImageTimeout bounds the wait for one image (see watermark.Watchdog);
+	// requests that exceed it fail with -32803, while work that does not
+	// check its context keeps its Scheduler worker until it stops. Zero
+	// means no limit.
+	ImageTimeout time.Duration
+}
+
+// rpcParams are the parameters of every method. The image is given either
+// inline as base64 (optionally a data URL) or as a local file path.
+type rpcParams struct {
+	Image string `json:"image"`
+	Path  string `json:"path"`
+	// Size bounds the longer side of previews; zero means
+	// watermark.DefaultPreviewSize.
+	Size int `json:"size"`
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// previewNotification is sent as $/preview while remove runs.
+type previewNotification struct {
+	ID json.RawMessage `json:"id"`
+	PreviewResponse
+}
+
+// ServeRPC speaks JSON-RPC 2.0 on r and w with the framing of the Language
+// Server Protocol (a Content-Length header, a blank line, then the JSON
+// body), for editor plugins and GUI frontends that drive a long-running
+// process over stdio. The methods are:
+//
+//	initialize  returns {"serverInfo": {"name": "gwatermark"}}
+//	detect      returns a Response without the image
+//	preview     returns a PreviewResponse
+//	remove      returns a Response with the cleaned image
+//	shutdown    stops accepting requests
+//	exit        (notification) ends ServeRPC
+//
+// detect, preview and remove take {"image": "<base64 or data URL>"} or
+// {"path": "<file>"}; preview and remove also take "size" for the preview. Before the remove
+// result, a $/preview notification carrying the request id and a
+// PreviewResponse is sent as soon as the corner is cleaned, so interfaces
+// can show it while the full image is processed.
+//
+// Requests run concurrently and responses may arrive out of order. ServeRPC
+// returns after exit or at the end of r, once in-flight requests have been
+// answered.
+func ServeRPC(r io.Reader, w io.Writer, cfg RPCConfig) error {
+	if cfg.MaxMessageBytes <= 0 {
+		cfg.MaxMessageBytes = DefaultMaxBodyBytes
+	}
+	s := &rpcServer{cfg: cfg, w: w}
+	defer s.wg.Wait()
+
+	br := bufio.NewReader(r)
+	for {
+		body, err := readRPCMessage(br, cfg.MaxMessageBytes)
+		var tooLarge *messageTooLargeError
+		switch {
+		case errors.Is(err, io.EOF):
+			return nil
+		case errors.As(err, &tooLarge):
+			// The message was skipped, so the stream is still in sync.
+			s.replyError(nil, rpcInvalidRequest, err.Error())
+			continue
+		case err != nil:
+			return err
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.replyError(nil, rpcParseError, err.Error())
+			continue
+		}
+		if req.JSONRPC != "2.0" || req.Method == "" {
+			s.replyError(req.ID, rpcInvalidRequest, "not a JSON-RPC 2.0 request")
+			continue
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		if len(req.ID) == 0 {
+			// Other notifications, such as $/cancelRequest, need no reply.
+			continue
+		}
+		if s.isShutdown() {
+			s.replyError(req.ID, rpcInvalidRequest, "server is shutting down")
+			continue
+		}
+		switch req.Method {
+		case "initialize":
+			s.reply(req.ID, map[string]any{"serverInfo": map[string]string{"name": "gwatermark"}})
+			continue
+		case "shutdown":
+			s.mu.Lock()
+			s.shutdown = true
+			s.mu.Unlock()
+			s.reply(req.ID, nil)
+			continue
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(req)
+		}()
+	}
+}
+
+type rpcServer struct {
+	cfg RPCConfig
+	wg  sync.WaitGroup
+
+	// mu serializes writes to w and guards shutdown.
+	mu       sync.Mutex
+	w        io.Writer
+	shutdown bool
+}
+
+func (s *rpcServer) isShutdown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.shutdown
+}
+
+func (s *rpcServer) handle(req rpcRequest) {
+	prio := Interactive
+	switch req.Method {
+	case "detect", "preview":
+	case "remove":
+		prio = Batch
+	default:
+		s.replyError(req.ID, rpcMethodNotFound, fmt.Sprintf("unknown method %q", req.Method))
+		return
+	}
+
+	var params rpcParams
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			s.replyError(req.ID, rpcInvalidParams, err.Error())
+			return
+		}
+	}
+	data, err := params.imageBytes(s.cfg.MaxMessageBytes)
+	if err != nil {
+		s.replyError(req.ID, rpcInvalidParams, err.Error())
+		return
+	}
+
+	// run replies at once and returns the channel of the work it ran, which
+	// stays open after a timeout until abandoned work stops.
+	run := func() <-chan struct{} {
+		var result any
+		hold, err := watermark.WatchdogDone(context.Background(), s.cfg.ImageTimeout, func(ctx context.Context) (err error) {
+			result, err = s.call(ctx, req, data, params.Size)
+			return err
+		})
+		var panicErr *watermark.PanicError
+		if errors.As(err, &panicErr) {
+			s.replyError(req.ID, rpcInternalError, err.Error())
+			return hold
+		}
+		if err != nil {
+			s.replyError(req.ID, rpcRequestFailed, err.Error())
+			return hold
+		}
+		s.reply(req.ID, result)
+		return hold
+	}
+	if s.cfg.Scheduler == nil {
+		run()
+		return
+	}
+	if err := s.cfg.Scheduler.DoHeld(context.Background(), prio, run); err != nil {
+		s.replyError(req.ID, rpcServerBusy, err.Error())
+	}
+}
+
+// call runs one method. Once ctx is done it sends no more notifications.
+func (s *rpcServer) call(ctx context.Context, req rpcRequest, data []byte, size int) (any, error) {
+	opts := s.cfg.Options
+	switch req.Method {
+	case "detect":
+		return detect(data, opts)
+	case "preview":
+		return preview(data, opts, size)
+	}
+
+	pv, err := preview(data, opts, size)
+	if err != nil {
+		return nil, err
+	}
+	resp := Response{Width: pv.Width, Height: pv.Height, Format: pv.Format, Present: pv.Present, Score: pv.Score,
+		Correlation: pv.Correlation, Confidence: pv.Confidence, Info: pv.Info}
+	if !pv.Present {
+		return resp, nil
+	}
+	if ctx.Err() == nil {
+		s.notify("$/preview", previewNotification{ID: req.ID, PreviewResponse: pv})
+	}
+
+	result, err := watermark.ProcessBytesContext(ctx, data, opts)
+	if err != nil {
+		return nil, err
+	}
+	resp.Present, resp.Score, resp.Info = result.Present, result.Score, result.Info
+	resp.Image, resp.ImageFormat = result.Output, result.Format
+	resp.Warnings = result.Warnings
+	return resp, nil
+}
+
+// imageBytes returns the inline image or reads the file at Path.
+func (p rpcParams) imageBytes(limit int64) ([]byte, error) {
+	switch {
+	case p.Image != "" && p.Path != "":
+		return nil, errors.New("params: set either image or path, not both")
+	case p.Image != "":
+		return watermark.DecodeBase64Bytes(p.Image)
+	case p.Path != "":
+		f, err := os.Open(p.Path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, limit+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > limit {
+			return nil, fmt.Errorf("%s exceeds %d bytes", p.Path, limit)
+		}
+		return data, nil
+	default:
+		return nil, errors.New("params: image or path is required")
+	}
+}
+
+func (s *rpcServer) reply(id json.RawMessage, result any) {
+	s.write(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Result  any             `json:"result"`
+	}{"2.0", rpcID(id), result})
+}
+
+func (s *rpcServer) replyError(id json.RawMessage, code int, message string) {
+	s.write(struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Error   rpcError        `json:"error"`
+	}{"2.0", rpcID(id), rpcError{Code: code, Message: message}})
+}
+
+func (s *rpcServer) notify(method string, params any) {
+	s.write(struct {
+		JSONRPC string `json:"jsonrpc"`
+		Method  string `json:"method"`
+		Params  any    `json:"params"`
+	}{"2.0", method, params})
+}
+
+// rpcID returns id, or null for requests whose id could not be read.
+func rpcID(id json.RawMessage) json.RawMessage {
+	if len(id) == 0 {
+		return json.RawMessage("null")
+	}
+	return id
+}
+
+func (s *rpcServer) write(msg any) {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n", len(body))
+	s.w.Write(body)
+}
+
+type messageTooLargeError struct {
+	size, limit int64
+}
+
+func (e *messageTooLargeError) Error() string {
+	return fmt.Sprintf("message of %d bytes exceeds %d", e.size, e.limit)
+}
+
+// readRPCMessage reads the headers and content of one message. It returns
+// io.EOF only at a message boundary, and a *messageTooLargeError after
+// skipping content above limit.
+func readRPCMessage(r *bufio.Reader, limit int64) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		if errors.Is(err, io.EOF) && len(header) == 0 {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("read message header: %w", err)
+	}
+	v := strings.TrimSpace(header.Get("Content-Length"))
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", v)
+	}
+	if n > limit {
+		if _, err := io.CopyN(io.Discard, r, n); err != nil {
+			return nil, fmt.Errorf("read message: %w", err)
+		}
+		return nil, &messageTooLargeError{size: n, limit: limit}
+	}
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("read message: %w", err)
+	}
+	return body, nil
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// rpcFrame frames a JSON-RPC message with a Content-Length header.
+func rpcFrame(msg string) string {
+	return fmt.Sprintf("Content-Length: %d\r\n\r\n%s", len(msg), msg)
+}
+
+// rpcReply is any message written by ServeRPC.
+type rpcReply struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+func runRPC(t *testing.T, cfg RPCConfig, messages ...string) []rpcReply {
+	t.Helper()
+	var in, out bytes.Buffer
+	for _, m := range messages {
+		in.WriteString(rpcFrame(m))
+	}
+	if err := ServeRPC(&in, &out, cfg); err != nil {
+		t.Fatalf("ServeRPC: %v", err)
+	}
+
+	var replies []rpcReply
+	br := bufio.NewReader(&out)
+	for {
+		body, err := readRPCMessage(br, 1<<30)
+		if err == io.EOF {
+			return replies
+		}
+		if err != nil {
+			t.Fatalf("read reply: %v", err)
+		}
+		var r rpcReply
+		if err := json.Unmarshal(body, &r); err != nil {
+			t.Fatalf("decode reply %s: %v", body, err)
+		}
+		replies = append(replies, r)
+	}
+}
+
+func TestServeRPC(t *testing.T) {
+	marked, err := os.ReadFile("../testdata/watermarked.webp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	image := base64.StdEncoding.EncodeToString(marked)
+	path, _ := json.Marshal(filepath.Join("..", "testdata", "clean.webp"))
+
+	// The requests are dispatched concurrently, so leave room to queue them
+	// all behind the single worker.
+	sched := NewScheduler(SchedulerConfig{Interactive: PoolConfig{Workers: 1, QueueLimit: 8}})
+	defer sched.Close()
+	replies := runRPC(t, RPCConfig{Scheduler: sched},
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"preview","params":{"image":"`+image+`","size":32}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"remove","params":{"image":"`+image+`"}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"detect","params":{"path":`+string(path)+`}}`,
+		`{"jsonrpc":"2.0","method":"$/cancelRequest","params":{"id":2}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"nope"}`,
+	)
+
+	byID := make(map[string]rpcReply)
+	var notified *PreviewResponse
+	for i, r := range replies {
+		if r.Method == "$/preview" {
+			var n previewNotification
+			if err := json.Unmarshal(r.Params, &n); err != nil || string(n.ID) != "3" {
+				t.Fatalf("preview notification %s: %v", r.Params, err)
+			}
+			if _, ok := byID["3"]; ok {
+				t.Fatalf("preview notification after the remove result")
+			}
+			notified = &n.PreviewResponse
+			continue
+		}
+		if r.Error != nil && string(r.ID) != "5" {
+			t.Fatalf("reply %d: %+v", i, r.Error)
+		}
+		byID[string(r.ID)] = r
+	}
+	if len(byID) != 5 {
+		t.Fatalf("got replies for %d requests, want 5", len(byID))
+	}
+	if e := byID["5"].Error; e == nil || e.Code != rpcMethodNotFound {
+		t.Fatalf("unknown method error = %+v", e)
+	}
+
+	var pv PreviewResponse
+	if err := json.Unmarshal(byID["2"].Result, &pv); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := png.DecodeConfig(bytes.NewReader(pv.Image))
+	if err != nil || !pv.Present || max(cfg.Width, cfg.Height) != 32 {
+		t.Fatalf("preview %+v: %dx%d, %v", pv.Info, cfg.Width, cfg.Height, err)
+	}
+	if notified == nil || !notified.Present || len(notified.Image) == 0 {
+		t.Fatalf("no preview notification before the remove result")
+	}
+
+	var removed Response
+	if err := json.Unmarshal(byID["3"].Result, &removed); err != nil {
+		t.Fatal(err)
+	}
+	if !removed.Present || removed.ImageFormat != "png" || len(removed.Image) == 0 {
+		t.Fatalf("remove result = %+v", removed.Info)
+	}
+	var detected Response
+	if err := json.Unmarshal(byID["4"].Result, &detected); err != nil {
+		t.Fatal(err)
+	}
+	if detected.Present || detected.Format != "webp" {
+		t.Fatalf("detect result = %+v", detected)
+	}
+}
+
+func TestServeRPCErrors(t *testing.T) {
+	replies := runRPC(t, RPCConfig{MaxMessageBytes: 200},
+		`{"jsonrpc":"2.0","id":1,"method":"detect","params":{"image":"aGVsbG8="}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"detect","params":{}}`,
+		`not json`,
+		`{"jsonrpc":"2.0","id":3,"method":"detect","params":{"image":"`+strings.Repeat("A", 200)+`"}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","id":5,"method":"detect","params":{"image":"aGVsbG8="}}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+		`{"jsonrpc":"2.0","id":6,"method":"detect","params":{"image":"aGVsbG8="}}`,
+	)
+
+	want := []struct {
+		id   string
+		code int
+	}{
+		{"1", rpcRequestFailed},
+		{"2", rpcInvalidParams},
+		{"null", rpcParseError},
+		{"null", rpcInvalidRequest},
+		{"4", 0},
+		{"5", rpcInvalidRequest},
+	}
+	if len(replies) != len(want) {
+		t.Fatalf("got %d replies, want %d", len(replies), len(want))
+	}
+	// Replies to concurrent requests may be reordered.
+	for _, w := range want {
+		found := false
+		for _, r := range replies {
+			code := 0
+			if r.Error != nil {
+				code = r.Error.Code
+			}
+			if string(r.ID) == w.id && code == w.code {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("no reply with id %s and code %d in %+v", w.id, w.code, replies)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrQueueFull is returned when a pool's queue limit is reached.
+	ErrQueueFull = errors.New("server: queue full")
+	// ErrClosed is returned for work submitted after Close.
+	ErrClosed = errors.New("server: scheduler closed")
+)
+
+// Priority selects the worker pool a job runs in.
+type Priority int
+
+const (
+	// Interactive is for latency-sensitive requests such as UI previews.
+	Interactive Priority = iota
+	// Batch is for background bulk work.
+	Batch
+)
+
+// String returns the priority name used in headers and query strings.
+func (p Priority) String() string {
+	switch p {
+	case Interactive:
+		return "interactive"
+	case Batch:
+		return "batch"
+	default:
+		return "unknown"
+	}
+}
+
+// ParsePriority parses "interactive" or "batch" (case-insensitive).
+func ParsePriority(s string) (Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "interactive":
+		return Interactive, nil
+	case "batch":
+		return Batch, nil
+	default:
+		return 0, fmt.Errorf("unknown priority %q", s)
+	}
+}
+
+// PriorityFromRequest reads the priority from the "priority" query parameter
+// or the X-Priority header, defaulting to Interactive.
+func PriorityFromRequest(r *http.Request) (Priority, error) {
+	v := r.URL.Query().Get("priority")
+	if v == "" {
+		v = r.Header.Get("X-Priority")
+	}
+	if v == "" {
+		return Interactive, nil
+	}
+	return ParsePriority(v)
+}
+
+// PoolConfig sizes one worker pool.
+type PoolConfig struct {
+	// Workers is the number of jobs run concurrently. Values below 1 are
+	// treated as 1.
+	Workers int
+	// QueueLimit is the number of jobs that may wait for a worker before new
+	// submissions are rejected with ErrQueueFull.
+	QueueLimit int
+}
+
+// SchedulerConfig sizes the interactive and batch pools.
+type SchedulerConfig struct {
+	Interactive PoolConfig
+	Batch       PoolConfig
+}
+
+// Scheduler runs jobs in per-priority worker pools.
+type Scheduler struct {
+	mu     sync.Mutex
+	closed bool
+	pools  [2]*pool
+	wg     sync.WaitGroup
+	// quit is closed by Close so that workers stop waiting on held work,
+	// which may never finish.
+	quit chan struct{}
+}
+
+type pool struct {
+	queue chan *job
+	// capacity is Workers+QueueLimit; pending counts queued and running
+	// jobs. Admission checks and increments happen under Scheduler.mu.
+	capacity int
+	pending  atomic.Int32
+}
+
+// Job states; a queued job is claimed by exactly one of its worker (running)
+// or its submitter (canceled).
+const (
+	jobQueued int32 = iota
+	jobRunning
+	jobCanceled
+)
+
+type job struct {
+	fn    func() <-chan struct{}
+	done  chan struct{}
+	state atomic.Int32
+}
+
+// NewScheduler starts the worker pools described by cfg.
+func NewScheduler(cfg SchedulerConfig) *Scheduler {
+	s := &Scheduler{quit: make(chan struct{})}
+	for i, pc := range []PoolConfig{cfg.Interactive, cfg.Batch} {
+		workers := pc.Workers
+		if workers < 1 {
+			workers = 1
+		}
+		queueLimit := pc.QueueLimit
+		if queueLimit < 0 {
+			queueLimit = 0
+		}
+
+		p := &pool{queue: make(chan *job, workers+queueLimit), capacity: workers + queueLimit}
+		s.pools[i] = p
+
+		for w := 0; w < workers; w++ {
+			s.wg.Add(1)
+			go s.work(p)
+		}
+	}
+	return s
+}
+
+func (s *Scheduler) work(p *pool) {
+	defer s.wg.Done()
+	for j := range p.queue {
+		var hold <-chan struct{}
+		if j.state.CompareAndSwap(jobQueued, jobRunning) {
+			hold = j.fn()
+		}
+		if hold == nil {
+			p.pending.Add(-1)
+			close(j.done)
+			continue
+		}
+		close(j.done)
+		select {
+		case <-hold:
+		case <-s.quit:
+		}
+		p.pending.Add(-1)
+	}
+}
+
+// Do runs fn in the pool for priority p and waits for it to finish. It
+// returns ErrQueueFull without running fn when every worker is busy and the
+// queue is at its limit. If ctx is done while fn is still queued, fn is
+// dropped and Do returns ctx.Err(). Once fn has started Do always waits for
+// it, so fn may safely use state owned by the caller, such as an
+// http.ResponseWriter.
+func (s *Scheduler) Do(ctx context.Context, p Priority, fn func()) error {
+	return s.DoHeld(ctx, p, func() <-chan struct{} {
+		fn()
+		return nil
+	})
+}
+
+// DoHeld is Do for work that can outlive fn, such as an image abandoned by
+// watermark.WatchdogDone: DoHeld returns once fn does, but the worker stays
+// busy until the channel fn returns is closed, so the abandoned work keeps
+// counting against the pool. A nil channel frees the worker at once.
+func (s *Scheduler) DoHeld(ctx context.Context, p Priority, fn func() <-chan struct{}) error {
+	if p != Interactive && p != Batch {
+		return fmt.Errorf("unknown priority %d", p)
+	}
+
+	j := &job{fn: fn, done: make(chan struct{})}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return ErrClosed
+	}
+	pl := s.pools[p]
+	if int(pl.pending.Load()) >= pl.capacity {
+		s.mu.Unlock()
+		return ErrQueueFull
+	}
+	pl.pending.Add(1)
+	// The queue is sized to capacity, so this send never blocks.
+	pl.queue <- j
+	s.mu.Unlock()
+
+	select {
+	case <-j.done:
+		return nil
+	case <-ctx.Done():
+		if j.state.CompareAndSwap(jobQueued, jobCanceled) {
+			return ctx.Err()
+		}
+		<-j.done
+		return nil
+	}
+}
+
+// QueueLen reports how many jobs are waiting in the pool for priority p.
+func (s *Scheduler) QueueLen(p Priority) int {
+	if p != Interactive && p != Batch {
+		return 0
+	}
+	return len(s.pools[p].queue)
+}
+
+// Close stops accepting work, lets queued jobs finish, and waits for the
+// workers to exit. It does not wait for work held by DoHeld, which may be
+// stuck for good.
+func (s *Scheduler) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.quit)
+	for _, p := range s.pools {
+		close(p.queue)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSchedulerIsolatesPools(t *testing.T) {
+	s := NewScheduler(SchedulerConfig{
+		Interactive: PoolConfig{Workers: 1, QueueLimit: 1},
+		Batch:       PoolConfig{Workers: 1, QueueLimit: 1},
+	})
+	defer s.Close()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	ctx := context.Background()
+
+	// Occupy the batch worker and fill its queue.
+	go s.Do(ctx, Batch, func() { close(started); <-release })
+	<-started
+	go s.Do(ctx, Batch, func() {})
+	waitFor(t, func() bool { return s.QueueLen(Batch) == 1 })
+
+	if err := s.Do(ctx, Batch, func() {}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected ErrQueueFull for saturated batch pool, got %v", err)
+	}
+
+	ran := false
+	if err := s.Do(ctx, Interactive, func() { ran = true }); err != nil || !ran {
+		t.Fatalf("interactive job blocked by batch work: ran=%v err=%v", ran, err)
+	}
+
+	close(release)
+}
+
+func TestPriorityFromRequest(t *testing.T) {
+	r := httptest.NewRequest("POST", "/remove?priority=batch", nil)
+	if p, err := PriorityFromRequest(r); err != nil || p != Batch {
+		t.Fatalf("query priority: got %v, %v", p, err)
+	}
+
+	r = httptest.NewRequest("POST", "/remove", nil)
+	r.Header.Set("X-Priority", "Interactive")
+	if p, err := PriorityFromRequest(r); err != nil || p != Interactive {
+		t.Fatalf("header priority: got %v, %v", p, err)
+	}
+
+	r = httptest.NewRequest("POST", "/remove?priority=urgent", nil)
+	if _, err := PriorityFromRequest(r); err == nil {
+		t.Fatalf("expected error for unknown priority")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("condition not met before deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerCloseSkipsHeldWork(t *testing.T) {
+	s := NewScheduler(SchedulerConfig{})
+	stuck := make(chan struct{})
+	if err := s.DoHeld(context.Background(), Interactive, func() <-chan struct{} { return stuck }); err != nil {
+		t.Fatalf("DoHeld: %v", err)
+	}
+
+	closed := make(chan struct{})
+	go func() { s.Close(); close(closed) }()
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Close waited for held work that never stops")
+	}
+}
@@ -0,0 +1,275 @@
+package watermark
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OutputSink is the destination of batch outputs, such as those of
+// ProcessFS.
+type OutputSink interface {
+	// Write stores the cleaned image read from r under the slash-separated
+	// name. meta describes it; its Output is nil.
+	Write(name string, r io.Reader, meta Result) error
+}
+
+// OutputSinkFunc adapts a function to OutputSink.
+type OutputSinkFunc func(name string, r io.Reader, meta Result) error
+
+// Write calls f(name, r, meta).
+func (f OutputSinkFunc) Write(name string, r io.Reader, meta Result) error {
+	return f(name, r, meta)
+}
+
+// contentType returns the media type of an output format.
+func contentType(format string) string {
+	return "image/" + format
+}
+
+// DirSink writes outputs as files below a directory of the OS filesystem,
+// creating subdirectories as needed.
+type DirSink string
+
+// Write creates or replaces the file name below the directory.
+func (d DirSink) Write(name string, r io.Reader, _ Result) error {
+	target := filepath.Join(string(d), filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ZipSink writes outputs as entries of a zip archive. Images are already
+// compressed, so entries are stored rather than deflated. Close must be
+// called to finish the archive.
+type ZipSink struct {
+	zw *zip.Writer
+}
+
+// NewZipSink returns a ZipSink writing the archive to w.
+func NewZipSink(w io.Writer) *ZipSink {
+	return &ZipSink{zw: zip.NewWriter(w)}
+}
+
+// Write adds an entry called name.
+func (z *ZipSink) Write(name string, r io.Reader, _ Result) error {
+	w, err := z.zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
+
+// Close writes the zip central directory. It does not close the underlying
+// writer.
+func (z *ZipSink) Close() error {
+	return z.zw.Close()
+}
+
+// S3Sink uploads outputs to an S3-compatible object store with path-style
+// PUT requests signed with AWS Signature Version 4. It fails with an
+// *OfflineError when offline mode is enabled.
+type S3Sink struct {
+	// Endpoint is the base URL of the service, for example
+	// "https://s3.us-east-1.amazonaws.com" or a MinIO address.
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to every object key.
+	Prefix string
+
+	AccessKeyID     string
+	SecretAccessKey string
+	// SessionToken is set for temporary credentials only.
+	SessionToken string
+
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// Retry configures retries and per-upload timeouts.
+	Retry RetryPolicy
+}
+
+// Write uploads the output as the object Prefix+name.
+func (s *S3Sink) Write(name string, r io.Reader, meta Result) error {
+	if err := CheckNetwork("s3 output"); err != nil {
+		return err
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	key := s.Prefix + name
+	u, err := url.Parse(s.Endpoint)
+	if err != nil {
+		return fmt.Errorf("s3 output %s: %w", key, err)
+	}
+	// Send the path exactly as it is signed.
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.Bucket + "/" + key
+	u.RawPath = awsURIEncode(u.Path)
+	sum := sha256.Sum256(body)
+
+	return s.Retry.do(context.Background(), func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("s3 output %s: %w", key, err)
+		}
+		req.Header.Set("Content-Type", contentType(meta.Format))
+		if s.SessionToken != "" {
+			req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+		}
+		// Each attempt is signed anew, as signatures expire.
+		s.sign(req, hex.EncodeToString(sum[:]), time.Now())
+		return send(s.Client, req, "s3 output "+key)
+	})
+}
+
+// sign adds the x-amz-content-sha256, x-amz-date and Authorization headers
+// of AWS Signature Version 4 to req, signing the host and every header
+// already set.
+func (s *S3Sink) sign(req *http.Request, payloadHash string, now time.Time) {
+	now = now.UTC()
+	stamp := now.Format("20060102T150405Z")
+	day := stamp[:8]
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	req.Header.Set("X-Amz-Date", stamp)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		awsURIEncode(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	canonicalSum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + hex.EncodeToString(canonicalSum[:])
+
+	key := []byte("AWS4" + s.SecretAccessKey)
+	for _, part := range []string{day, s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsURIEncode percent-encodes every byte of p except unreserved characters
+// and slashes, as Signature Version 4 requires for S3 object paths.
+func awsURIEncode(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// HTTPSink POSTs every output to a callback URL. The body is the image; the
+// X-Output-Name, X-Watermark-Present and X-Watermark-Score headers describe
+// it. It fails with an *OfflineError when offline mode is enabled.
+type HTTPSink struct {
+	URL string
+	// Header is added to every request, for example for authorization.
+	Header http.Header
+	// Client sends the requests; nil means http.DefaultClient.
+	Client *http.Client
+	// Retry configures retries and per-request timeouts.
+	Retry RetryPolicy
+}
+
+// Write posts the output and fails unless the callback answers 2xx.
+func (h *HTTPSink) Write(name string, r io.Reader, meta Result) error {
+	if err := CheckNetwork("http output"); err != nil {
+		return err
+	}
+	// Buffer the body so retries can resend it.
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return h.Retry.do(context.Background(), func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+		if err != nil {
+			return fmt.Errorf("http output %s: %w", name, err)
+		}
+		for k, v := range h.Header {
+			req.Header[k] = v
+		}
+		req.Header.Set("Content-Type", contentType(meta.Format))
+		req.Header.Set("X-Output-Name", name)
+		req.Header.Set("X-Watermark-Present", strconv.FormatBool(meta.Present))
+		req.Header.Set("X-Watermark-Score", strconv.FormatFloat(meta.Score, 'f', 4, 64))
+		return send(h.Client, req, "http output "+name)
+	})
+}
+
+// send performs req and fails unless the response status is 2xx.
+func send(client *http.Client, req *http.Request, what string) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", what, err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode/100 != 2 {
+		return &statusError{what, resp.StatusCode, resp.Status}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package watermark
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The library must work purely in memory: none of the byte-level helpers may
+// create files in the temp directory.
+func TestLibraryWritesNoTempFiles(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("cmd", "gwatermark", "image.png"))
+	if err != nil {
+		t.Fatalf("read input image: %v", err)
+	}
+
+	checkNoTempFiles(t, func() {
+		if _, err := ProcessBytes(data, Options{}); err != nil {
+			t.Fatalf("ProcessBytes: %v", err)
+		}
+		if _, err := DetectResultBytes(data, GeminiProfile()); err != nil {
+			t.Fatalf("DetectResultBytes: %v", err)
+		}
+		if _, err := ProcessBytes(encodeTestGIF(t, 320, 240, 2), Options{}); err != nil {
+			t.Fatalf("ProcessBytes: %v", err)
+		}
+	})
+}
+
+// checkNoTempFiles runs fn with TMPDIR pointing at an empty directory and
+// fails if fn leaves anything in it.
+func checkNoTempFiles(t *testing.T, fn func()) {
+	t.Helper()
+
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	fn()
+
+	entries, err := os.ReadDir(tmp)
+	if err != nil {
+		t.Fatalf("read temp dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("library created %d temp entries, first %q", len(entries), entries[0].Name())
+	}
+}
@@ -0,0 +1,29 @@
+//go:build !watermark_nov1
+
+package watermark
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestV1HelpersWriteNoTempFiles(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("cmd", "gwatermark", "image.png"))
+	if err != nil {
+		t.Fatalf("read input image: %v", err)
+	}
+
+	checkNoTempFiles(t, func() {
+		if _, _, _, _, err := RemoveWatermarkBytes(data); err != nil {
+			t.Fatalf("RemoveWatermarkBytes: %v", err)
+		}
+		if _, _, _, err := DetectWatermarkBytes(data); err != nil {
+			t.Fatalf("DetectWatermarkBytes: %v", err)
+		}
+		if _, _, _, _, err := RemoveWatermarkBase64(base64.StdEncoding.EncodeToString(data)); err != nil {
+			t.Fatalf("RemoveWatermarkBase64: %v", err)
+		}
+	})
+}
@@ -0,0 +1,6 @@
+// Package testutil generates Gemini-watermarked test images, so tests need
+// no binary samples. A Fixture describes the image: its size, a flat,
+// gradient or textured background, Gaussian noise, whether the logo is
+// forward blended with the embedded alpha maps, and PNG or JPEG encoding.
+// Matrix lists a spread of fixtures for table tests.
+package testutil
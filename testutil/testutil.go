@@ -0,0 +1,193 @@
+package testutil
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"math/rand"
+	"testing"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// Background selects the content a Fixture's logo is blended over.
+type Background int
+
+const (
+	// Flat fills the image with Fixture.Color.
+	Flat Background = iota
+	// Gradient ramps diagonally around Fixture.Color.
+	Gradient
+	// Texture overlays smooth waves and fine detail on Fixture.Color, closer
+	// to a photo than the other two.
+	Texture
+)
+
+func (b Background) String() string {
+	switch b {
+	case Flat:
+		return "flat"
+	case Gradient:
+		return "gradient"
+	case Texture:
+		return "texture"
+	default:
+		return fmt.Sprintf("Background(%d)", int(b))
+	}
+}
+
+// DefaultColor is the base color of fixtures that leave Color zero: a
+// mid-tone the white logo stands out on.
+var DefaultColor = color.RGBA{70, 110, 150, 255}
+
+// Fixture describes one generated test image. The same Fixture always
+// yields the same pixels and bytes.
+type Fixture struct {
+	// Name labels the fixture in subtests; Matrix fills it in.
+	Name          string
+	Width, Height int
+	Background    Background
+	// Color is the base color of the background; zero means DefaultColor.
+	Color color.RGBA
+	// Noise is the standard deviation of the Gaussian noise added to each
+	// channel of the background, in 8-bit levels.
+	Noise float64
+	// Seed seeds the noise.
+	Seed int64
+	// Watermark forward blends the Gemini logo at its standard placement.
+	Watermark bool
+	// JPEGQuality makes Encode write JPEG at this quality (1-100), after
+	// the logo is blended as Gemini does. Zero writes lossless PNG.
+	JPEGQuality int
+}
+
+// Format returns the format Encode writes, "png" or "jpeg".
+func (f Fixture) Format() string {
+	if f.JPEGQuality > 0 {
+		return "jpeg"
+	}
+	return "png"
+}
+
+// Image renders the fixture before encoding. Without Watermark it is the
+// clean reference the watermarked image was made from.
+func (f Fixture) Image() (*image.RGBA, error) {
+	if f.Width <= 0 || f.Height <= 0 {
+		return nil, fmt.Errorf("invalid fixture size %dx%d", f.Width, f.Height)
+	}
+	img := f.background()
+	if !f.Watermark {
+		return img, nil
+	}
+	return watermark.NewEngine().ApplyWatermark(img)
+}
+
+// Encode renders and encodes the fixture.
+func (f Fixture) Encode() ([]byte, error) {
+	img, err := f.Image()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if f.JPEGQuality > 0 {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: f.JPEGQuality})
+	} else {
+		err = png.Encode(&buf, img)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("encode %s fixture: %w", f.Format(), err)
+	}
+	return buf.Bytes(), nil
+}
+
+// Bytes is Encode for tests: it fails tb on error.
+func (f Fixture) Bytes(tb testing.TB) []byte {
+	tb.Helper()
+	data, err := f.Encode()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return data
+}
+
+// WatermarkedPNG returns a 1024x1024 textured PNG with the 48px logo, a
+// stand-in for a typical Gemini download.
+func WatermarkedPNG(tb testing.TB) []byte {
+	tb.Helper()
+	return Fixture{Width: 1024, Height: 1024, Background: Texture, Noise: 2, Watermark: true}.Bytes(tb)
+}
+
+// CleanJPEG returns a 1184x896 textured JPEG without a watermark.
+func CleanJPEG(tb testing.TB) []byte {
+	tb.Helper()
+	return Fixture{Width: 1184, Height: 896, Background: Texture, Noise: 2, JPEGQuality: 90}.Bytes(tb)
+}
+
+// Matrix returns fixtures spanning both logo sizes, every background,
+// several noise levels and JPEG qualities, each watermarked, plus clean
+// counterparts of a few of them.
+func Matrix() []Fixture {
+	sizes := []image.Point{{1024, 1024}, {1408, 768}, {1536, 1536}}
+	var fixtures []Fixture
+	for i, size := range sizes {
+		for _, bg := range []Background{Flat, Gradient, Texture} {
+			for _, enc := range []struct {
+				noise   float64
+				quality int
+			}{{0, 0}, {4, 0}, {2, 92}, {6, 80}} {
+				f := Fixture{
+					Width: size.X, Height: size.Y, Background: bg,
+					Noise: enc.noise, Seed: int64(i + 1), Watermark: true, JPEGQuality: enc.quality,
+				}
+				f.Name = fmt.Sprintf("%dx%d-%v-noise%g-%s", f.Width, f.Height, bg, f.Noise, f.Format())
+				if enc.quality > 0 {
+					f.Name += fmt.Sprintf("%d", enc.quality)
+				}
+				fixtures = append(fixtures, f)
+			}
+		}
+	}
+	for _, f := range fixtures {
+		if f.Background == Texture && f.Noise > 0 {
+			f.Watermark = false
+			f.Name = "clean-" + f.Name
+			fixtures = append(fixtures, f)
+		}
+	}
+	return fixtures
+}
+
+func (f Fixture) background() *image.RGBA {
+	base := f.Color
+	if base == (color.RGBA{}) {
+		base = DefaultColor
+	}
+	rng := rand.New(rand.NewSource(f.Seed))
+	img := image.NewRGBA(image.Rect(0, 0, f.Width, f.Height))
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			var shift float64
+			switch f.Background {
+			case Gradient:
+				shift = 60*(float64(x)/float64(f.Width)-0.5) + 40*(float64(y)/float64(f.Height)-0.5)
+			case Texture:
+				fx, fy := float64(x), float64(y)
+				shift = 40*math.Sin(fx/37) + 25*math.Cos(fy/53) + 12*math.Sin((fx+fy)/7)
+			}
+			p := img.Pix[img.PixOffset(x, y):]
+			for c, v := range [3]uint8{base.R, base.G, base.B} {
+				level := float64(v) + shift
+				if f.Noise > 0 {
+					level += rng.NormFloat64() * f.Noise
+				}
+				p[c] = uint8(math.Round(math.Max(0, math.Min(255, level))))
+			}
+			p[3] = 255
+		}
+	}
+	return img
+}
@@ -0,0 +1,92 @@
+package testutil
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	"github.com/gcslaoli/gemini-watermark-remover-go/imagecmp"
+)
+
+var fullMatrix = flag.Bool("matrix", false, "run TestMatrixDetection over every Matrix fixture")
+
+// matrixSample returns every fifth Matrix fixture. Five shares no factor
+// with the four encodings or three backgrounds Matrix nests, so the sample
+// still covers every size, background and encoding, and one clean image.
+func matrixSample() []Fixture {
+	all := Matrix()
+	if *fullMatrix {
+		return all
+	}
+	var sample []Fixture
+	for i := 0; i < len(all); i += 5 {
+		sample = append(sample, all[i])
+	}
+	return sample
+}
+
+func TestMatrixDetection(t *testing.T) {
+	for _, f := range matrixSample() {
+		t.Run(f.Name, func(t *testing.T) {
+			t.Parallel()
+			data := f.Bytes(t)
+			d, err := watermark.DetectResultBytes(data, watermark.GeminiProfile())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if d.Present != f.Watermark {
+				t.Fatalf("present = %v (score %.2f, correlation %.2f), want %v", d.Present, d.Score, d.Correlation, f.Watermark)
+			}
+			if !f.Watermark {
+				return
+			}
+
+			r, err := watermark.ProcessBytes(data, watermark.Options{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			after, err := watermark.DetectResultBytes(r.Output, watermark.GeminiProfile())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after.Present {
+				t.Errorf("still detected after removal (score %.2f)", after.Score)
+			}
+		})
+	}
+}
+
+func TestFixtureRoundTrip(t *testing.T) {
+	f := Fixture{Width: 1024, Height: 1024, Background: Gradient, Noise: 3, Seed: 7}
+	clean, err := f.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Watermark = true
+	data := f.Bytes(t)
+	if !bytes.Equal(data, f.Bytes(t)) {
+		t.Fatal("fixture bytes are not deterministic")
+	}
+
+	r, err := watermark.ProcessBytes(data, watermark.Options{})
+	if err != nil || !r.Present {
+		t.Fatalf("ProcessBytes: present %v, %v", r.Present, err)
+	}
+	restored, _, err := watermark.DecodeImageBytes(r.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := imagecmp.Diff(restored, clean, imagecmp.Options{MaxDelta: 2}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFixtureErrors(t *testing.T) {
+	if _, err := (Fixture{Width: 0, Height: 10}).Encode(); err == nil {
+		t.Error("empty fixture encoded")
+	}
+	if _, err := (Fixture{Width: 32, Height: 32, Watermark: true}).Encode(); err == nil {
+		t.Error("fixture too small for the logo encoded")
+	}
+}
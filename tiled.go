@@ -0,0 +1,458 @@
+package watermark
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+// ErrTiledUnsupported is returned by RemoveWatermarkTiled for inputs it
+// cannot stream. Callers can fall back to ProcessBytes for those.
+var ErrTiledUnsupported = errors.New("tiled processing unsupported for this image")
+
+// idatChunkSize bounds the IDAT chunks written by RemoveWatermarkTiled.
+const idatChunkSize = 1 << 16
+
+// pngHeader is the subset of IHDR needed to walk the pixel rows.
+type pngHeader struct {
+	width, height int
+	// bpp is the number of bytes per pixel: 3 for RGB, 4 for RGBA.
+	bpp int
+}
+
+// RemoveWatermarkTiled applies the default engine to a PNG streamed from src.
+func RemoveWatermarkTiled(dst io.Writer, src io.ReadSeeker) (present bool, score float64, info Info, err error) {
+	return Default().RemoveWatermarkTiled(dst, src)
+}
+
+// RemoveWatermarkTiled removes the Gemini watermark from a PNG streamed from
+// src to dst without decoding the whole image, so gigapixel panoramas can be
+// cleaned within a bounded memory footprint: two pixel rows plus the
+// watermark neighbourhood. src is read twice, once to detect and once to
+// rewrite the image data; every other chunk is copied verbatim. Nothing is
+// written when no watermark is detected.
+//
+// Only non-interlaced 8-bit RGB and RGBA PNGs can be streamed. Other inputs
+// fail with ErrTiledUnsupported. JS compatibility mode is not applied.
+func (e *Engine) RemoveWatermarkTiled(dst io.Writer, src io.ReadSeeker) (present bool, score float64, info Info, err error) {
+	defer recoverPanic("RemoveWatermarkTiled", nil, &err)
+	_, release, err := e.acquire(context.Background())
+	if err != nil {
+		return false, 0, Info{}, err
+	}
+	defer release()
+	hdr, err := readPNGHeader(src)
+	if err != nil {
+		return false, 0, Info{}, err
+	}
+
+	bounds := image.Rect(0, 0, hdr.width, hdr.height)
+	cfg, _ := GeminiProfile().config(hdr.width, hdr.height)
+	if _, err := calculateWatermarkRect(bounds, cfg); err != nil {
+		return false, 0, Info{}, err
+	}
+
+	// Pass 1: collect the bottom-right corner and run detection on it.
+	crop := detectionCrop(bounds, cfg)
+	region := image.NewNRGBA(crop)
+	idat, err := seekIDAT(src)
+	if err != nil {
+		return false, 0, Info{}, err
+	}
+	err = scanPNGRows(idat, hdr, func(y int, _ byte, row []byte) error {
+		if y < crop.Min.Y {
+			return nil
+		}
+		for x := crop.Min.X; x < crop.Max.X; x++ {
+			px := row[x*hdr.bpp:]
+			offset := region.PixOffset(x, y)
+			copy(region.Pix[offset:offset+3], px[:3])
+			region.Pix[offset+3] = 255
+			if hdr.bpp == 4 {
+				region.Pix[offset+3] = px[3]
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, 0, Info{}, err
+	}
+
+	present, score, info, err = detectPlacement(region, cfg, e.detectParams(GeminiProfile()))
+	if err != nil || !present {
+		return false, score, info, err
+	}
+
+	alphaMap, err := e.alphaForRect(info.Size, info.Position)
+	if err != nil {
+		return false, 0, Info{}, err
+	}
+
+	// Pass 2: copy every chunk, re-encoding the image data with the
+	// watermark rows reverse blended.
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return false, 0, Info{}, fmt.Errorf("rewind png: %w", err)
+	}
+	if err := e.rewritePNG(dst, src, hdr, alphaMap, info.Position); err != nil {
+		return false, 0, Info{}, err
+	}
+
+	return true, score, info, nil
+}
+
+// detectionCrop returns the bottom-right part of bounds that covers the
+// native and upscaled placements of cfg together with the bands that
+// detection samples around them.
+func detectionCrop(bounds image.Rectangle, cfg watermarkConfig) image.Rectangle {
+	area := image.Rectangle{Min: bounds.Max, Max: bounds.Max}
+	for _, c := range append([]watermarkConfig{cfg}, scaledConfigs(cfg)...) {
+		x := bounds.Max.X - c.MarginRight - c.LogoSize
+		y := bounds.Max.Y - c.MarginBottom - c.LogoSize
+		area = area.Union(image.Rect(x, y, x+c.LogoSize, y+c.LogoSize).Inset(-c.LogoSize))
+	}
+	area.Max = bounds.Max
+	return area.Intersect(bounds)
+}
+
+func scaledConfigs(cfg watermarkConfig) []watermarkConfig {
+	configs := make([]watermarkConfig, len(upscaleFactors))
+	for i, f := range upscaleFactors {
+		configs[i] = cfg.scaled(f)
+	}
+	return configs
+}
+
+// rewritePNG copies the PNG in src to dst, replacing the IDAT chunks with a
+// re-encoded stream in which rect is reverse blended.
+func (e *Engine) rewritePNG(dst io.Writer, src io.Reader, hdr pngHeader, alphaMap []float32, rect image.Rectangle) error {
+	// Signature and IHDR are unchanged.
+	if _, err := io.CopyN(dst, src, int64(len(pngSignature))+25); err != nil {
+		return fmt.Errorf("copy png header: %w", err)
+	}
+
+	stride := hdr.width * hdr.bpp
+	out, prevOut := make([]byte, stride), make([]byte, stride)
+	filtered := make([]byte, stride+1)
+
+	for {
+		length, typ, err := readChunkHeader(src)
+		if err != nil {
+			return err
+		}
+
+		if typ == "IDAT" {
+			idat := &idatReader{r: src, remaining: length}
+			cw := &chunkWriter{w: dst, typ: "IDAT"}
+			zw := zlib.NewWriter(cw)
+
+			err := scanPNGRows(idat, hdr, func(y int, filter byte, row []byte) error {
+				copy(out, row)
+				if y >= rect.Min.Y && y < rect.Max.Y {
+					e.blendRow(out, hdr.bpp, y, alphaMap, rect)
+				}
+				filtered[0] = filter
+				filterRow(filter, filtered[1:], out, prevOut, hdr.bpp)
+				out, prevOut = prevOut, out
+				_, err := zw.Write(filtered)
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			if err := zw.Close(); err != nil {
+				return err
+			}
+			if err := cw.flush(); err != nil {
+				return err
+			}
+
+			// Skip any trailing IDAT data to reach the next chunk.
+			if _, err := io.Copy(io.Discard, idat); err != nil {
+				return fmt.Errorf("read png data: %w", err)
+			}
+			length, typ = idat.nextLen, idat.nextType
+		}
+
+		var header [8]byte
+		binary.BigEndian.PutUint32(header[:], length)
+		copy(header[4:], typ)
+		if _, err := dst.Write(header[:]); err != nil {
+			return err
+		}
+		if _, err := io.CopyN(dst, src, int64(length)+4); err != nil {
+			return fmt.Errorf("copy %s chunk: %w", typ, err)
+		}
+		if typ == "IEND" {
+			return nil
+		}
+	}
+}
+
+// blendRow reverse blends the pixels of row y that fall inside rect. PNG
+// stores straight color, so no un-premultiplication is needed.
+func (e *Engine) blendRow(row []byte, bpp, y int, alphaMap []float32, rect image.Rectangle) {
+	logo := e.logo(GeminiProfile())
+	base := (y - rect.Min.Y) * rect.Dx()
+	for x := rect.Min.X; x < rect.Max.X; x++ {
+		alpha := float64(alphaMap[base+x-rect.Min.X])
+		if alpha < alphaThreshold {
+			continue
+		}
+		if alpha > maxAlpha {
+			alpha = maxAlpha
+		}
+		px := row[x*bpp:]
+		for c := 0; c < 3; c++ {
+			px[c] = reverseBlend(px[c], alpha, logo[c], e.rounding)
+		}
+	}
+}
+
+// readPNGHeader validates the signature and IHDR of a streamable PNG.
+func readPNGHeader(r io.Reader) (pngHeader, error) {
+	var buf [8 + 25]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return pngHeader{}, fmt.Errorf("read png header: %w", err)
+	}
+	if !bytes.Equal(buf[:8], pngSignature) {
+		return pngHeader{}, fmt.Errorf("%w: not a png", ErrTiledUnsupported)
+	}
+	if string(buf[12:16]) != "IHDR" {
+		return pngHeader{}, fmt.Errorf("invalid png: missing IHDR")
+	}
+
+	ihdr := buf[16:29]
+	hdr := pngHeader{
+		width:  int(binary.BigEndian.Uint32(ihdr[0:])),
+		height: int(binary.BigEndian.Uint32(ihdr[4:])),
+	}
+	depth, colorType, interlace := ihdr[8], ihdr[9], ihdr[12]
+
+	switch colorType {
+	case 2:
+		hdr.bpp = 3
+	case 6:
+		hdr.bpp = 4
+	default:
+		return pngHeader{}, fmt.Errorf("%w: png color type %d", ErrTiledUnsupported, colorType)
+	}
+	if depth != 8 {
+		return pngHeader{}, fmt.Errorf("%w: png bit depth %d", ErrTiledUnsupported, depth)
+	}
+	if interlace != 0 {
+		return pngHeader{}, fmt.Errorf("%w: interlaced png", ErrTiledUnsupported)
+	}
+	if hdr.width <= 0 || hdr.height <= 0 {
+		return pngHeader{}, fmt.Errorf("invalid image dimensions %dx%d", hdr.width, hdr.height)
+	}
+	return hdr, nil
+}
+
+// seekIDAT skips the chunks following IHDR up to the first IDAT and returns a
+// reader over the image data.
+func seekIDAT(r io.Reader) (*idatReader, error) {
+	for {
+		length, typ, err := readChunkHeader(r)
+		if err != nil {
+			return nil, err
+		}
+		if typ == "IDAT" {
+			return &idatReader{r: r, remaining: length}, nil
+		}
+		if typ == "IEND" {
+			return nil, fmt.Errorf("invalid png: no image data")
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(length)+4); err != nil {
+			return nil, fmt.Errorf("skip %s chunk: %w", typ, err)
+		}
+	}
+}
+
+func readChunkHeader(r io.Reader) (length uint32, typ string, err error) {
+	var buf [8]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return 0, "", fmt.Errorf("read png chunk: %w", err)
+	}
+	return binary.BigEndian.Uint32(buf[:4]), string(buf[4:]), nil
+}
+
+// idatReader concatenates the payloads of consecutive IDAT chunks. Once they
+// are exhausted it records the header of the chunk that follows.
+type idatReader struct {
+	r         io.Reader
+	remaining uint32
+	done      bool
+
+	nextLen  uint32
+	nextType string
+}
+
+func (d *idatReader) Read(p []byte) (int, error) {
+	for d.remaining == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		var crc [4]byte
+		if _, err := io.ReadFull(d.r, crc[:]); err != nil {
+			return 0, fmt.Errorf("read png chunk: %w", err)
+		}
+		length, typ, err := readChunkHeader(d.r)
+		if err != nil {
+			return 0, err
+		}
+		if typ != "IDAT" {
+			d.nextLen, d.nextType, d.done = length, typ, true
+			return 0, io.EOF
+		}
+		d.remaining = length
+	}
+
+	if uint32(len(p)) > d.remaining {
+		p = p[:d.remaining]
+	}
+	n, err := d.r.Read(p)
+	d.remaining -= uint32(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// chunkWriter splits written data into chunks of at most idatChunkSize.
+type chunkWriter struct {
+	w   io.Writer
+	typ string
+	buf []byte
+}
+
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	c.buf = append(c.buf, p...)
+	for len(c.buf) >= idatChunkSize {
+		if _, err := c.w.Write(pngChunk(c.typ, c.buf[:idatChunkSize])); err != nil {
+			return 0, err
+		}
+		c.buf = append(c.buf[:0], c.buf[idatChunkSize:]...)
+	}
+	return len(p), nil
+}
+
+func (c *chunkWriter) flush() error {
+	if len(c.buf) == 0 {
+		return nil
+	}
+	_, err := c.w.Write(pngChunk(c.typ, c.buf))
+	c.buf = c.buf[:0]
+	return err
+}
+
+// scanPNGRows inflates the image data and calls fn with each unfiltered row
+// and its original filter type. fn must not retain or modify row.
+func scanPNGRows(r io.Reader, hdr pngHeader, fn func(y int, filter byte, row []byte) error) error {
+	zr, err := zlib.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("inflate png data: %w", err)
+	}
+	defer zr.Close()
+
+	stride := hdr.width * hdr.bpp
+	cur, prev := make([]byte, stride+1), make([]byte, stride+1)
+
+	for y := 0; y < hdr.height; y++ {
+		if _, err := io.ReadFull(zr, cur); err != nil {
+			return fmt.Errorf("inflate png row %d: %w", y, err)
+		}
+		filter := cur[0]
+		if err := unfilterRow(filter, cur[1:], prev[1:], hdr.bpp); err != nil {
+			return err
+		}
+		if err := fn(y, filter, cur[1:]); err != nil {
+			return err
+		}
+		cur, prev = prev, cur
+	}
+	return nil
+}
+
+// unfilterRow reverses the PNG filter in place, given the previous
+// unfiltered row.
+func unfilterRow(filter byte, cur, prev []byte, bpp int) error {
+	switch filter {
+	case 0:
+	case 1:
+		for i := bpp; i < len(cur); i++ {
+			cur[i] += cur[i-bpp]
+		}
+	case 2:
+		for i := range cur {
+			cur[i] += prev[i]
+		}
+	case 3:
+		for i := range cur {
+			left := 0
+			if i >= bpp {
+				left = int(cur[i-bpp])
+			}
+			cur[i] += uint8((left + int(prev[i])) / 2)
+		}
+	case 4:
+		for i := range cur {
+			var left, upLeft uint8
+			if i >= bpp {
+				left, upLeft = cur[i-bpp], prev[i-bpp]
+			}
+			cur[i] += paeth(left, prev[i], upLeft)
+		}
+	default:
+		return fmt.Errorf("invalid png filter type %d", filter)
+	}
+	return nil
+}
+
+// filterRow applies the PNG filter to cur into dst, given the previous
+// unfiltered row.
+func filterRow(filter byte, dst, cur, prev []byte, bpp int) {
+	for i := range cur {
+		var left, upLeft uint8
+		if i >= bpp {
+			left, upLeft = cur[i-bpp], prev[i-bpp]
+		}
+		switch filter {
+		case 1:
+			dst[i] = cur[i] - left
+		case 2:
+			dst[i] = cur[i] - prev[i]
+		case 3:
+			dst[i] = cur[i] - uint8((int(left)+int(prev[i]))/2)
+		case 4:
+			dst[i] = cur[i] - paeth(left, prev[i], upLeft)
+		default:
+			dst[i] = cur[i]
+		}
+	}
+}
+
+// paeth is the PNG Paeth predictor.
+func paeth(a, b, c uint8) uint8 {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,66 @@
+package watermark
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/png"
+	"testing"
+)
+
+func TestRemoveWatermarkTiledMatchesFullDecode(t *testing.T) {
+	src := watermarkedRGBA(t, 320, 240, color.RGBA{R: 40, G: 60, B: 90, A: 255})
+
+	// A translucent pixel away from the watermark forces an RGBA PNG.
+	withAlpha := image.NewNRGBA(src.Bounds())
+	draw.Draw(withAlpha, withAlpha.Bounds(), src, image.Point{}, draw.Src)
+	withAlpha.SetNRGBA(3, 3, color.NRGBA{R: 1, G: 2, B: 3, A: 100})
+
+	for name, img := range map[string]image.Image{"rgb": src, "rgba": withAlpha} {
+		t.Run(name, func(t *testing.T) {
+			var in bytes.Buffer
+			if err := png.Encode(&in, img); err != nil {
+				t.Fatalf("encode: %v", err)
+			}
+
+			var out bytes.Buffer
+			present, _, info, err := RemoveWatermarkTiled(&out, bytes.NewReader(in.Bytes()))
+			if err != nil {
+				t.Fatalf("RemoveWatermarkTiled: %v", err)
+			}
+			if !present || info != WatermarkInfo(320, 240) {
+				t.Fatalf("unexpected detection present=%v info=%+v", present, info)
+			}
+
+			got, err := png.Decode(&out)
+			if err != nil {
+				t.Fatalf("decode output: %v", err)
+			}
+			want, err := RemoveWatermark(img)
+			if err != nil {
+				t.Fatalf("RemoveWatermark: %v", err)
+			}
+			if _, changed, _ := DiffImage(want, got, 1); !changed.Empty() {
+				t.Fatalf("tiled output differs from full decode in %v", changed)
+			}
+		})
+	}
+}
+
+func TestRemoveWatermarkTiledUnsupported(t *testing.T) {
+	var in bytes.Buffer
+	if err := png.Encode(&in, image.NewPaletted(image.Rect(0, 0, 320, 240), palette.Plan9)); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	var out bytes.Buffer
+	if _, _, _, err := RemoveWatermarkTiled(&out, bytes.NewReader(in.Bytes())); !errors.Is(err, ErrTiledUnsupported) {
+		t.Fatalf("expected ErrTiledUnsupported, got %v", err)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("expected no output, got %d bytes", out.Len())
+	}
+}
@@ -0,0 +1,94 @@
+//go:build !watermark_nov1
+
+package watermark
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+)
+
+// This file holds the v1 helpers that return results as bare tuples. They
+// are deprecated in favor of the Result and DetectionResult API and stay
+// until the next major version of the module; building with the
+// watermark_nov1 tag leaves them out, so remaining callers fail to compile.
+
+// RemoveWatermarkBase64 removes the watermark from a base64-encoded image. It
+// returns the cleaned image as base64 PNG, whether a watermark was detected,
+// the detection score, watermark info, and an error if any.
+//
+// Deprecated: Decode the base64 yourself and use ProcessBytes, whose Result
+// also carries the warnings, or Process in the api package.
+func RemoveWatermarkBase64(input string) (output string, present bool, score float64, info Info, err error) {
+	data, err := DecodeBase64Bytes(input)
+	if err != nil {
+		return "", false, 0, Info{}, err
+	}
+
+	bytesOut, present, score, info, err := RemoveWatermarkBytes(data)
+	if err != nil {
+		return "", false, 0, Info{}, err
+	}
+
+	if !present {
+		return "", false, score, info, nil
+	}
+
+	return base64.StdEncoding.EncodeToString(bytesOut), true, score, info, nil
+}
+
+// RemoveWatermarkBytes removes the watermark from raw image bytes. It returns
+// the cleaned PNG bytes when a watermark is detected, along with the detection
+// score and watermark info.
+//
+// Deprecated: Use ProcessBytes, where Options.Output OutputSource keeps
+// WebP and JPEG inputs in their format, or Process in the api package.
+func RemoveWatermarkBytes(input []byte) (output []byte, present bool, score float64, info Info, err error) {
+	return RemoveWatermarkBytesContext(context.Background(), input)
+}
+
+// RemoveWatermarkBytesContext is RemoveWatermarkBytes with cancellation:
+// waiting for the memory budget, decoding, removal and PNG encoding stop
+// once ctx is done, and ctx.Err() is returned.
+//
+// Deprecated: Use ProcessBytesContext, or Process in the api package.
+func RemoveWatermarkBytesContext(ctx context.Context, input []byte) (output []byte, present bool, score float64, info Info, err error) {
+	defer recoverPanic("RemoveWatermarkBytes", input, &err)
+	if len(input) == 0 {
+		return nil, false, 0, Info{}, fmt.Errorf("empty image data")
+	}
+
+	release, err := reserveDecode(ctx, input)
+	if err != nil {
+		return nil, false, 0, Info{}, err
+	}
+	defer release()
+
+	img, _, err := decodeContext(ctx, input)
+	if err != nil {
+		return nil, false, 0, Info{}, err
+	}
+
+	r, err := removeAndEncode(ctx, img, input, GeminiProfile(), "png", Options{})
+	return r.Output, r.Present, r.Score, r.Info, err
+}
+
+// DetectWatermarkBytes checks raw image bytes for the Gemini watermark without
+// performing any cleanup. It decodes the bytes into an image and delegates to
+// DetectWatermark for the score and placement details.
+//
+// Deprecated: Use DetectResultBytes, which also reports the correlation and
+// confidence, or Detect in the api package.
+func DetectWatermarkBytes(data []byte) (present bool, score float64, info Info, err error) {
+	return DetectWatermarkBytesContext(context.Background(), data)
+}
+
+// DetectWatermarkBytesContext is DetectWatermarkBytes with cancellation:
+// waiting for the memory budget and decoding stop once ctx is done, and
+// ctx.Err() is returned.
+//
+// Deprecated: Use DetectResultBytesContext, or Detect in the api package.
+func DetectWatermarkBytesContext(ctx context.Context, data []byte) (present bool, score float64, info Info, err error) {
+	r, err := DetectResultBytesContext(ctx, data, GeminiProfile())
+	return r.Present, r.Score, r.Info, err
+}
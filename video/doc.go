@@ -0,0 +1,8 @@
+// Package video routes raw video frames through the Gemini watermark remover.
+//
+// Decoding and encoding are left to ffmpeg: Filter consumes and produces raw
+// rgb24 frames, and Pipeline renders the ffmpeg invocations that feed it and
+// mux the original audio back in. With Pipeline.Region set only the
+// watermark corner of each frame is piped through the filter and overlaid
+// back on the original frames.
+package video
@@ -0,0 +1,228 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// Pipeline describes an ffmpeg → gwatermark → ffmpeg round trip for one
+// video file.
+type Pipeline struct {
+	// Input and Output are the source and destination video paths.
+	Input  string
+	Output string
+	// Width and Height are the decoded frame size.
+	Width  int
+	Height int
+	// FrameRate is passed to the encoder verbatim (e.g. "30000/1001").
+	// It defaults to the ffmpeg rawvideo default of 25 when empty.
+	FrameRate string
+	// FFmpeg and Gwatermark name the binaries; they default to "ffmpeg" and
+	// "gwatermark".
+	FFmpeg     string
+	Gwatermark string
+	// HWAccel selects an ffmpeg hardware decoder (e.g. "cuda") when set.
+	HWAccel string
+	// VideoCodec and its extra arguments default to libx264 with yuv420p.
+	VideoCodec string
+	CodecArgs  []string
+	// Region pipes only the corner box that can hold the watermark (see
+	// Region) through the filter instead of whole frames, and the encoder
+	// overlays the cleaned box on the original frames. The video stream is
+	// still re-encoded, since its pixels change, but the raw frame traffic
+	// and the per-frame filtering shrink to the corner. It is ignored for
+	// frames too small to carry a watermark.
+	Region bool
+}
+
+// region returns the box cropped from each frame and the profile that
+// places the watermark within it, when p.Region applies.
+func (p Pipeline) region() (image.Rectangle, watermark.Profile, bool) {
+	if !p.Region {
+		return image.Rectangle{}, watermark.Profile{}, false
+	}
+	return Region(p.Width, p.Height)
+}
+
+// FrameSize returns the size of the raw frames passed through the filter:
+// the region box with Region set, otherwise the full frame.
+func (p Pipeline) FrameSize() (width, height int) {
+	if r, _, ok := p.region(); ok {
+		return r.Dx(), r.Dy()
+	}
+	return p.Width, p.Height
+}
+
+// DecodeArgs returns the ffmpeg arguments that decode Input to rgb24 frames,
+// cropped to the region box with Region set, on stdout.
+func (p Pipeline) DecodeArgs() []string {
+	args := []string{"-v", "error"}
+	if p.HWAccel != "" {
+		args = append(args, "-hwaccel", p.HWAccel)
+	}
+	args = append(args, "-i", p.Input, "-map", "0:v:0")
+	if r, _, ok := p.region(); ok {
+		args = append(args, "-vf", fmt.Sprintf("crop=%d:%d:%d:%d", r.Dx(), r.Dy(), r.Min.X, r.Min.Y))
+	}
+	return append(args, "-f", "rawvideo", "-pix_fmt", "rgb24", "-")
+}
+
+// FilterArgs returns the gwatermark arguments for raw frame filter mode.
+func (p Pipeline) FilterArgs() []string {
+	width, height := p.FrameSize()
+	args := []string{"-rawvideo", fmt.Sprintf("%dx%d", width, height)}
+	if _, _, ok := p.region(); ok {
+		args = append(args, "-rawvideo-region", fmt.Sprintf("%dx%d", p.Width, p.Height))
+	}
+	return args
+}
+
+// EncodeArgs returns the ffmpeg arguments that read cleaned rgb24 frames
+// from stdin, take audio from Input untouched, and write Output. With Region
+// set the frames are region boxes, overlaid on the frames of Input resampled
+// to the same constant frame rate the decoder produced.
+func (p Pipeline) EncodeArgs() []string {
+	width, height := p.FrameSize()
+	args := []string{"-v", "error", "-y",
+		"-f", "rawvideo", "-pix_fmt", "rgb24", "-video_size", fmt.Sprintf("%dx%d", width, height),
+	}
+	if p.FrameRate != "" {
+		args = append(args, "-framerate", p.FrameRate)
+	}
+	args = append(args, "-i", "-", "-i", p.Input)
+	if r, _, ok := p.region(); ok {
+		rate := p.FrameRate
+		if rate == "" {
+			rate = "25"
+		}
+		graph := fmt.Sprintf("[1:v:0]fps=%s,setpts=PTS-STARTPTS[base];[0:v]setpts=PTS-STARTPTS[box];[base][box]overlay=%d:%d:shortest=1[v]",
+			rate, r.Min.X, r.Min.Y)
+		args = append(args, "-filter_complex", graph, "-map", "[v]", "-map", "1:a?")
+	} else {
+		args = append(args, "-map", "0:v:0", "-map", "1:a?")
+	}
+
+	codec := p.VideoCodec
+	if codec == "" {
+		codec = "libx264"
+	}
+	args = append(args, "-c:v", codec)
+	if p.CodecArgs != nil {
+		args = append(args, p.CodecArgs...)
+	} else {
+		args = append(args, "-pix_fmt", "yuv420p")
+	}
+
+	return append(args, "-c:a", "copy", "-shortest", p.Output)
+}
+
+// Command renders the full shell pipeline, quoting arguments for POSIX shells.
+func (p Pipeline) Command() string {
+	ffmpeg := p.FFmpeg
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+	gwatermark := p.Gwatermark
+	if gwatermark == "" {
+		gwatermark = "gwatermark"
+	}
+
+	stages := [][]string{
+		append([]string{ffmpeg}, p.DecodeArgs()...),
+		append([]string{gwatermark}, p.FilterArgs()...),
+		append([]string{ffmpeg}, p.EncodeArgs()...),
+	}
+
+	parts := make([]string, len(stages))
+	for i, stage := range stages {
+		quoted := make([]string, len(stage))
+		for j, arg := range stage {
+			quoted[j] = shellQuote(arg)
+		}
+		parts[i] = strings.Join(quoted, " ")
+	}
+	return strings.Join(parts, " | ")
+}
+
+// Probe fills Width, Height, and FrameRate from the first video stream of
+// Input using ffprobe.
+func (p *Pipeline) Probe(ctx context.Context, ffprobe string) error {
+	if ffprobe == "" {
+		ffprobe = "ffprobe"
+	}
+
+	cmd := exec.CommandContext(ctx, ffprobe, "-v", "error", "-select_streams", "v:0",
+		"-show_entries", "stream=width,height,r_frame_rate", "-of", "json", p.Input)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("ffprobe %s: %w: %s", p.Input, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var probe struct {
+		Streams []struct {
+			Width      int    `json:"width"`
+			Height     int    `json:"height"`
+			RFrameRate string `json:"r_frame_rate"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return fmt.Errorf("parse ffprobe output: %w", err)
+	}
+	if len(probe.Streams) == 0 {
+		return fmt.Errorf("%s has no video stream", p.Input)
+	}
+
+	s := probe.Streams[0]
+	p.Width, p.Height = s.Width, s.Height
+	if s.RFrameRate != "" && s.RFrameRate != "0/0" {
+		p.FrameRate = s.RFrameRate
+	}
+	return nil
+}
+
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=,+@", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// ParseSize parses a "WIDTHxHEIGHT" frame size.
+func ParseSize(s string) (width, height int, err error) {
+	w, h, ok := strings.Cut(strings.ToLower(s), "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid size %q, want WIDTHxHEIGHT", s)
+	}
+	width, err = strconv.Atoi(w)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid width in %q: %w", s, err)
+	}
+	height, err = strconv.Atoi(h)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid height in %q: %w", s, err)
+	}
+	if width <= 0 || height <= 0 {
+		return 0, 0, fmt.Errorf("invalid size %q", s)
+	}
+	return width, height, nil
+}
@@ -0,0 +1,36 @@
+package video
+
+import "testing"
+
+func TestPipelineCommand(t *testing.T) {
+	p := Pipeline{Input: "my clip.mp4", Output: "out.mp4", Width: 1920, Height: 1080, FrameRate: "30000/1001"}
+
+	want := "ffmpeg -v error -i 'my clip.mp4' -map 0:v:0 -f rawvideo -pix_fmt rgb24 - | " +
+		"gwatermark -rawvideo 1920x1080 | " +
+		"ffmpeg -v error -y -f rawvideo -pix_fmt rgb24 -video_size 1920x1080 -framerate 30000/1001 " +
+		"-i - -i 'my clip.mp4' -map 0:v:0 -map '1:a?' -c:v libx264 -pix_fmt yuv420p -c:a copy -shortest out.mp4"
+
+	if got := p.Command(); got != want {
+		t.Fatalf("unexpected command:\n got: %s\nwant: %s", got, want)
+	}
+}
+
+func TestPipelineCommandRegion(t *testing.T) {
+	p := Pipeline{Input: "clip.mp4", Output: "out.mp4", Width: 1920, Height: 1080, FrameRate: "30000/1001", Region: true}
+
+	want := "ffmpeg -v error -i clip.mp4 -map 0:v:0 -vf crop=320:320:1600:760 -f rawvideo -pix_fmt rgb24 - | " +
+		"gwatermark -rawvideo 320x320 -rawvideo-region 1920x1080 | " +
+		"ffmpeg -v error -y -f rawvideo -pix_fmt rgb24 -video_size 320x320 -framerate 30000/1001 " +
+		"-i - -i clip.mp4 -filter_complex '[1:v:0]fps=30000/1001,setpts=PTS-STARTPTS[base];[0:v]setpts=PTS-STARTPTS[box];[base][box]overlay=1600:760:shortest=1[v]' " +
+		"-map '[v]' -map '1:a?' -c:v libx264 -pix_fmt yuv420p -c:a copy -shortest out.mp4"
+
+	if got := p.Command(); got != want {
+		t.Fatalf("unexpected command:\n got: %s\nwant: %s", got, want)
+	}
+
+	// Frames too small for a watermark fall back to the full-frame pipeline.
+	small := Pipeline{Input: "clip.mp4", Output: "out.mp4", Width: 40, Height: 40, Region: true}
+	if w, h := small.FrameSize(); w != 40 || h != 40 {
+		t.Fatalf("FrameSize of a small region pipeline = %dx%d, want 40x40", w, h)
+	}
+}
@@ -0,0 +1,103 @@
+package video
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"io"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// Stats summarizes a Filter run.
+type Stats struct {
+	Frames  int
+	Cleaned int
+	// CacheHits counts frames that reused the previous detection decision.
+	CacheHits int
+}
+
+// Filter reads raw rgb24 frames of the given size from r, removes the
+// watermark from each, and writes the frames to w in the same format. Frames
+// without a detected watermark are passed through unchanged. Detection is
+// cached across frames with a watermark.FrameDetector.
+func Filter(r io.Reader, w io.Writer, width, height int) (Stats, error) {
+	return FilterProfile(r, w, width, height, watermark.GeminiProfile())
+}
+
+// FilterProfile is Filter with the watermark placed according to profile,
+// such as the one Region returns for frames cropped to the watermark corner.
+func FilterProfile(r io.Reader, w io.Writer, width, height int, profile watermark.Profile) (Stats, error) {
+	if width <= 0 || height <= 0 {
+		return Stats{}, fmt.Errorf("invalid frame size %dx%d", width, height)
+	}
+
+	var stats Stats
+	engine := watermark.NewEngine()
+	detector := watermark.NewFrameDetector()
+	detector.Profile = profile
+
+	buf := make([]byte, width*height*3)
+	frame := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	for {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				return stats, fmt.Errorf("frame %d: truncated input", stats.Frames)
+			}
+			return stats, fmt.Errorf("frame %d: %w", stats.Frames, err)
+		}
+
+		rgb24ToRGBA(frame, buf)
+
+		present, _, info, err := detector.Detect(frame)
+		if err != nil {
+			return stats, fmt.Errorf("frame %d: %w", stats.Frames, err)
+		}
+
+		if present {
+			// Remove at the detected placement, which covers the larger
+			// logo of upscaled footage.
+			cleaned, err := engine.RemoveWatermarkDepthInfo(frame, info, detector.Profile)
+			if err != nil {
+				return stats, fmt.Errorf("frame %d: %w", stats.Frames, err)
+			}
+			rgba, ok := cleaned.(*image.RGBA)
+			if !ok {
+				draw.Draw(frame, frame.Bounds(), cleaned, frame.Bounds().Min, draw.Src)
+				rgba = frame
+			}
+			rgbaToRGB24(buf, rgba)
+			stats.Cleaned++
+		}
+
+		if _, err := w.Write(buf); err != nil {
+			return stats, fmt.Errorf("frame %d: write: %w", stats.Frames, err)
+		}
+		stats.Frames++
+	}
+
+	stats.CacheHits, _ = detector.Stats()
+	return stats, nil
+}
+
+func rgb24ToRGBA(dst *image.RGBA, src []byte) {
+	for i, j := 0, 0; i < len(src); i, j = i+3, j+4 {
+		dst.Pix[j] = src[i]
+		dst.Pix[j+1] = src[i+1]
+		dst.Pix[j+2] = src[i+2]
+		dst.Pix[j+3] = 0xff
+	}
+}
+
+func rgbaToRGB24(dst []byte, src *image.RGBA) {
+	for i, j := 0, 0; i < len(dst); i, j = i+3, j+4 {
+		dst[i] = src.Pix[j]
+		dst[i+1] = src.Pix[j+1]
+		dst[i+2] = src.Pix[j+2]
+	}
+}
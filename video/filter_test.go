@@ -0,0 +1,69 @@
+package video
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+	xdraw "golang.org/x/image/draw"
+)
+
+func TestFilterPassesThroughCleanFrames(t *testing.T) {
+	const width, height = 320, 240
+
+	frame := make([]byte, width*height*3)
+	for i := range frame {
+		frame[i] = byte(i % 251)
+	}
+	input := bytes.Repeat(frame, 3)
+
+	var out bytes.Buffer
+	stats, err := Filter(bytes.NewReader(input), &out, width, height)
+	if err != nil {
+		t.Fatalf("Filter: %v", err)
+	}
+	if stats.Frames != 3 || stats.Cleaned != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if !bytes.Equal(out.Bytes(), input) {
+		t.Fatalf("clean frames were modified")
+	}
+
+	if _, err := Filter(bytes.NewReader(input[:len(input)-1]), &out, width, height); err == nil {
+		t.Fatalf("expected error for truncated input")
+	}
+}
+
+func TestFilterCleansUpscaledFrames(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 1100, 1100))
+	draw.Draw(src, src.Bounds(), &image.Uniform{C: color.RGBA{R: 35, G: 45, B: 70, A: 255}}, image.Point{}, draw.Src)
+	marked, err := watermark.ApplyWatermark(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upscaled := image.NewRGBA(image.Rect(0, 0, 2200, 2200))
+	xdraw.BiLinear.Scale(upscaled, upscaled.Bounds(), marked, marked.Bounds(), xdraw.Src, nil)
+	if present, _, info, _ := watermark.DetectWatermark(upscaled); !present || info.Size != 192 {
+		t.Fatalf("fixture: present=%v info=%+v, want the 192px logo", present, info)
+	}
+
+	frame := make([]byte, 2200*2200*3)
+	rgbaToRGB24(frame, upscaled)
+	var out bytes.Buffer
+	stats, err := Filter(bytes.NewReader(frame), &out, 2200, 2200)
+	if err != nil {
+		t.Fatalf("Filter: %v", err)
+	}
+	if stats.Frames != 1 || stats.Cleaned != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	cleaned := image.NewRGBA(upscaled.Bounds())
+	rgb24ToRGBA(cleaned, out.Bytes())
+	if present, score, _, _ := watermark.DetectWatermark(cleaned); present {
+		t.Fatalf("upscaled watermark still detected after filtering (score %.2f)", score)
+	}
+}
@@ -0,0 +1,52 @@
+package video
+
+import (
+	"image"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// regionScale is the largest post-generation upscale the corner region
+// covers, matching the 2x upscale that detection probes.
+const regionScale = 2
+
+// Region returns the bottom-right box of a width x height frame that holds
+// the Gemini watermark at its standard size or upscaled up to 2x, and the
+// profile that places the watermark within a frame cropped to that box. It
+// reports false when the frame is too small for a watermark.
+//
+// The box offset is even so that ffmpeg can crop and overlay it on
+// chroma-subsampled video without shifting it.
+func Region(width, height int) (image.Rectangle, watermark.Profile, bool) {
+	info, ok := watermark.GeminiProfile().Placement(width, height)
+	if !ok {
+		return image.Rectangle{}, watermark.Profile{}, false
+	}
+	right, bottom := width-info.Position.Max.X, height-info.Position.Max.Y
+
+	w := regionSpan(regionScale*(info.Size+right), width)
+	h := regionSpan(regionScale*(info.Size+bottom), height)
+	region := image.Rect(width-w, height-h, width, height)
+
+	// Margins are measured from the bottom-right corner, which the box
+	// shares with the frame, so the full-frame variant applies unchanged.
+	profile := watermark.Profile{
+		Name: "gemini-region",
+		Variants: []watermark.Variant{
+			{LogoSize: info.Size, MarginRight: right, MarginBottom: bottom},
+		},
+	}
+	return region, profile, true
+}
+
+// regionSpan grows span by one if needed to keep the box offset from the
+// frame origin even, and clamps it to the frame.
+func regionSpan(span, frame int) int {
+	if (frame-span)%2 != 0 {
+		span++
+	}
+	if span > frame {
+		return frame
+	}
+	return span
+}
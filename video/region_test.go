@@ -0,0 +1,78 @@
+package video
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+func TestRegion(t *testing.T) {
+	for _, tc := range []struct {
+		width, height int
+		want          image.Rectangle
+	}{
+		{1920, 1080, image.Rect(1600, 760, 1920, 1080)},
+		{1921, 1081, image.Rect(1600, 760, 1921, 1081)},
+		{640, 480, image.Rect(480, 320, 640, 480)},
+		{100, 100, image.Rect(0, 0, 100, 100)},
+	} {
+		region, _, ok := Region(tc.width, tc.height)
+		if !ok || region != tc.want {
+			t.Errorf("Region(%d, %d) = %v, %v, want %v", tc.width, tc.height, region, ok, tc.want)
+		}
+	}
+	if _, _, ok := Region(40, 40); ok {
+		t.Error("Region accepted a frame too small for a watermark")
+	}
+}
+
+func TestFilterProfileRegionMatchesFullFrame(t *testing.T) {
+	const width, height = 1920, 1080
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: color.RGBA{R: 50, G: 80, B: 40, A: 255}}, image.Point{}, draw.Src)
+	marked, err := watermark.ApplyWatermark(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := make([]byte, width*height*3)
+	rgbaToRGB24(full, marked)
+
+	var fullOut bytes.Buffer
+	if _, err := Filter(bytes.NewReader(full), &fullOut, width, height); err != nil {
+		t.Fatalf("Filter: %v", err)
+	}
+
+	region, profile, ok := Region(width, height)
+	if !ok {
+		t.Fatal("no region for 1920x1080")
+	}
+	box := make([]byte, 0, region.Dx()*region.Dy()*3)
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		row := (y*width + region.Min.X) * 3
+		box = append(box, full[row:row+region.Dx()*3]...)
+	}
+
+	var boxOut bytes.Buffer
+	stats, err := FilterProfile(bytes.NewReader(box), &boxOut, region.Dx(), region.Dy(), profile)
+	if err != nil {
+		t.Fatalf("FilterProfile: %v", err)
+	}
+	if stats.Cleaned != 1 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// Overlaying the cleaned box must reproduce the full-frame result.
+	cleaned := fullOut.Bytes()
+	for y := region.Min.Y; y < region.Max.Y; y++ {
+		row := (y*width + region.Min.X) * 3
+		got := boxOut.Bytes()[(y-region.Min.Y)*region.Dx()*3:][:region.Dx()*3]
+		if !bytes.Equal(got, cleaned[row:row+region.Dx()*3]) {
+			t.Fatalf("row %d of the cleaned box differs from the full-frame result", y)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package video
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+// Run executes the pipeline in-process: one ffmpeg decodes Input to raw
+// frames, Filter cleans them, and a second ffmpeg encodes the result while
+// stream-copying the original audio. Width and Height must be set, usually
+// via Probe. With Region set only the watermark corner of each frame goes
+// through the filter.
+//
+// The video stream is always re-encoded because its pixels change, even
+// with Region set; audio is never re-encoded.
+func Run(ctx context.Context, p Pipeline) (Stats, error) {
+	if p.Output == "" {
+		return Stats{}, fmt.Errorf("output path is required")
+	}
+	if p.Width <= 0 || p.Height <= 0 {
+		return Stats{}, fmt.Errorf("invalid frame size %dx%d, probe the input first", p.Width, p.Height)
+	}
+
+	ffmpeg := p.FFmpeg
+	if ffmpeg == "" {
+		ffmpeg = "ffmpeg"
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var decStderr, encStderr bytes.Buffer
+
+	dec := exec.CommandContext(ctx, ffmpeg, p.DecodeArgs()...)
+	dec.Stderr = &decStderr
+	frames, err := dec.StdoutPipe()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	enc := exec.CommandContext(ctx, ffmpeg, p.EncodeArgs()...)
+	enc.Stderr = &encStderr
+	sink, err := enc.StdinPipe()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	if err := dec.Start(); err != nil {
+		return Stats{}, fmt.Errorf("start decoder: %w", err)
+	}
+	if err := enc.Start(); err != nil {
+		cancel()
+		_ = dec.Wait()
+		return Stats{}, fmt.Errorf("start encoder: %w", err)
+	}
+
+	width, height := p.FrameSize()
+	profile := watermark.GeminiProfile()
+	if _, regionProfile, ok := p.region(); ok {
+		profile = regionProfile
+	}
+	stats, filterErr := FilterProfile(frames, sink, width, height, profile)
+	closeErr := sink.Close()
+	if filterErr != nil {
+		cancel()
+	}
+
+	decErr := dec.Wait()
+	encErr := enc.Wait()
+
+	switch {
+	case filterErr != nil:
+		return stats, filterErr
+	case decErr != nil:
+		return stats, fmt.Errorf("decoder: %w: %s", decErr, strings.TrimSpace(decStderr.String()))
+	case encErr != nil:
+		return stats, fmt.Errorf("encoder: %w: %s", encErr, strings.TrimSpace(encStderr.String()))
+	case closeErr != nil:
+		return stats, fmt.Errorf("close encoder input: %w", closeErr)
+	}
+
+	return stats, nil
+}
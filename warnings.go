@@ -0,0 +1,203 @@
+package watermark
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// WarningCode identifies a condition that did not stop processing but may
+// have affected the result.
+type WarningCode int
+
+const (
+	// WarnClipped means recovered pixel values fell outside 0-255 and were
+	// clamped, usually because the watermark was recompressed or resized, or
+	// the logo capture does not match it, or that pixels under the logo are
+	// saturated at 0 or 255, where the original cannot be told from a
+	// clipped one. Traces of the logo may remain.
+	WarnClipped WarningCode = iota + 1
+	// WarnLowCorrelation means the watermark was detected, but its shape
+	// matched the logo capture only just above the detection gate.
+	WarnLowCorrelation
+	// WarnMetadataDropped means the output lacks an ICC profile, EXIF or XMP
+	// packet the input carried, for example because the output format cannot
+	// hold it.
+	WarnMetadataDropped
+	// WarnInvisibleWatermark means the cleaned image likely still carries an
+	// invisible watermark, such as SynthID, which removing the visible logo
+	// does not touch. See DetectInvisibleWatermark.
+	WarnInvisibleWatermark
+	// WarnSalvaged means the input was truncated or damaged and only its
+	// intact rows were used; the rest of the output is blank. See
+	// Options.Salvage.
+	WarnSalvaged
+	// WarnForced means the watermark was removed at a placement the caller
+	// gave, such as the CLI's -x/-y/-size, without detection confirming a
+	// watermark there. Removing a logo that is not there darkens the area.
+	WarnForced
+)
+
+// warningCodes lists every code, for UnmarshalText.
+var warningCodes = []WarningCode{WarnClipped, WarnLowCorrelation, WarnMetadataDropped, WarnInvisibleWatermark, WarnSalvaged, WarnForced}
+
+// String returns the short name of the code, as used in JSON reports.
+func (c WarningCode) String() string {
+	switch c {
+	case WarnClipped:
+		return "clipped"
+	case WarnLowCorrelation:
+		return "low-correlation"
+	case WarnMetadataDropped:
+		return "metadata-dropped"
+	case WarnInvisibleWatermark:
+		return "invisible-watermark"
+	case WarnSalvaged:
+		return "salvaged"
+	case WarnForced:
+		return "forced"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText encodes the code as its short name.
+func (c WarningCode) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText decodes a code name written by MarshalText.
+func (c *WarningCode) UnmarshalText(text []byte) error {
+	for _, v := range warningCodes {
+		if v.String() == string(text) {
+			*c = v
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown warning code %q", text)
+}
+
+// Warning reports a WarningCode with a human-readable explanation.
+type Warning struct {
+	Code    WarningCode `json:"code"`
+	Message string      `json:"message"`
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%v: %s", w.Code, w.Message)
+}
+
+const (
+	// clipSlack is how far a recovered value may fall outside 0-255 before
+	// its pixel counts as clipped; compression noise alone moves values by a
+	// few levels.
+	clipSlack = 4
+	// saturatedAlpha is the logo opacity from which a channel observed at
+	// 0 or 255 counts as clipped: the edit that saturated it may have
+	// removed any amount of the logo's contribution.
+	saturatedAlpha = 0.1
+	// lowCorrelationFactor sets WarnLowCorrelation below this multiple of the
+	// correlation gate.
+	lowCorrelationFactor = 1.5
+)
+
+// RemovalWarnings reports WarnClipped and WarnLowCorrelation for removing
+// the watermark at info, as detected under profile p, from img with e.
+func (e *Engine) RemovalWarnings(img image.Image, p Profile, info Info) []Warning {
+	var warnings []Warning
+	bounds := img.Bounds()
+	cfg, ok := p.config(bounds.Dx(), bounds.Dy())
+	if !ok {
+		return nil
+	}
+
+	if n, err := e.clippedPixels(img, info, p); err == nil && n > 0 {
+		msg := fmt.Sprintf("%d of %d watermark pixels clipped", n, info.Position.Dx()*info.Position.Dy())
+		if e.inpaint != nil {
+			msg += ", inpainted"
+		}
+		warnings = append(warnings, Warning{WarnClipped, msg})
+	}
+
+	params := e.detectParams(p)
+	cfg.LogoSize = info.Size
+	if alpha, err := cfg.detectAlpha(e); err == nil && len(alpha) == info.Position.Dx()*info.Position.Dy() {
+		_, minCorr := params.gates()
+		if _, corr, err := scoreRect(img, info.Position, alpha, params.luminance); err == nil && corr < minCorr*lowCorrelationFactor {
+			warnings = append(warnings, Warning{WarnLowCorrelation,
+				fmt.Sprintf("correlation %.2f is close to the detection gate %.2f", corr, minCorr)})
+		}
+	}
+	return warnings
+}
+
+// clippedPixels counts the watermark pixels clippedMask marks.
+func (e *Engine) clippedPixels(img image.Image, info Info, p Profile) (int, error) {
+	bounds := img.Bounds()
+	alphaMap, logo, err := e.blendParams(p, bounds.Dx(), bounds.Dy(), info)
+	if err != nil {
+		return 0, err
+	}
+
+	clipped := 0
+	for _, c := range clippedMask(img, alphaMap, info.Position, logo) {
+		if c {
+			clipped++
+		}
+	}
+	return clipped, nil
+}
+
+// clippedMask marks, row by row over rect, the watermark pixels of img where
+// reverse blending recovers a channel more than clipSlack outside 0-255, or
+// where a channel is saturated at 0 or 255 under at least saturatedAlpha of
+// the logo. A saturated white pixel reverse blends to exactly 255 under a
+// white logo, so only the observed value can reveal it.
+func clippedMask(img image.Image, alphaMap []float32, rect image.Rectangle, logo [3]float64) []bool {
+	stride := rect.Dx()
+	clipped := make([]bool, stride*rect.Dy())
+	for row := 0; row < rect.Dy(); row++ {
+		for col := 0; col < stride; col++ {
+			alpha := float64(alphaMap[row*stride+col])
+			if alpha < alphaThreshold {
+				continue
+			}
+			alpha = min(alpha, maxAlpha)
+
+			c := color.NRGBAModel.Convert(img.At(rect.Min.X+col, rect.Min.Y+row)).(color.NRGBA)
+			for i, v := range [3]uint8{c.R, c.G, c.B} {
+				original := (float64(v) - alpha*logo[i]) / (1 - alpha)
+				saturated := (v == 0 || v == 255) && alpha >= saturatedAlpha
+				if saturated || original < -clipSlack || original > 255+clipSlack {
+					clipped[row*stride+col] = true
+					break
+				}
+			}
+		}
+	}
+	return clipped
+}
+
+// MetadataWarnings reports WarnMetadataDropped when output, encoded by o
+// from source, lacks an ICC profile, or unless StripMetadata is set EXIF or
+// XMP, that source carried.
+func (o Options) MetadataWarnings(source, output []byte) []Warning {
+	var dropped []string
+	if sourceICCProfile(source) != nil && sourceICCProfile(output) == nil {
+		dropped = append(dropped, "ICC profile")
+	}
+	if !o.StripMetadata {
+		in, out := ReadMetadata(source), ReadMetadata(output)
+		if in.EXIF != nil && out.EXIF == nil {
+			dropped = append(dropped, "EXIF")
+		}
+		if in.XMP != nil && out.XMP == nil {
+			dropped = append(dropped, "XMP")
+		}
+	}
+	if dropped == nil {
+		return nil
+	}
+	return []Warning{{WarnMetadataDropped, strings.Join(dropped, ", ") + " not carried over"}}
+}
@@ -0,0 +1,99 @@
+package watermark
+
+import (
+	"encoding/json"
+	"image/color"
+	"testing"
+)
+
+func TestRemovalWarnings(t *testing.T) {
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{R: 40, G: 60, B: 90, A: 255})
+	jpg, err := EncodeJPEGToBytes(img, 95)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	result, err := ProcessBytes(jpg, Options{})
+	if err != nil || !result.Present {
+		t.Fatalf("ProcessBytes: present %v, %v", result.Present, err)
+	}
+	if len(result.Warnings) != 0 {
+		t.Fatalf("unexpected warnings %v", result.Warnings)
+	}
+
+	// Darkening the logo makes reverse blending recover negative values.
+	info := result.Info
+	for y := info.Position.Min.Y; y < info.Position.Max.Y; y++ {
+		for x := info.Position.Min.X; x < info.Position.Max.X; x++ {
+			c := img.RGBAAt(x, y)
+			c.R, c.G, c.B = c.R/2, c.G/2, c.B/2
+			img.SetRGBA(x, y, c)
+		}
+	}
+	warnings := NewEngine().RemovalWarnings(img, GeminiProfile(), info)
+	if len(warnings) == 0 || warnings[0].Code != WarnClipped {
+		t.Fatalf("expected a clipped warning, got %v", warnings)
+	}
+}
+
+func TestMetadataWarnings(t *testing.T) {
+	jpg, err := EncodeJPEGToBytes(gradientNRGBA(8, 8, false), 90)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	source := WriteMetadata(jpg, Metadata{EXIF: exifWithThumbnail(1)})
+	png, err := EncodePNGToBytes(gradientNRGBA(8, 8, false))
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+
+	warnings := Options{}.MetadataWarnings(source, png)
+	if len(warnings) != 1 || warnings[0].Code != WarnMetadataDropped || warnings[0].Message != "EXIF not carried over" {
+		t.Fatalf("unexpected warnings %v", warnings)
+	}
+	if w := (Options{StripMetadata: true}).MetadataWarnings(source, png); w != nil {
+		t.Fatalf("stripped metadata reported as dropped: %v", w)
+	}
+	if w := (Options{}).MetadataWarnings(source, PreserveMetadata(png, source)); w != nil {
+		t.Fatalf("preserved metadata reported as dropped: %v", w)
+	}
+
+	data, err := json.Marshal(warnings[0])
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if want := `{"code":"metadata-dropped","message":"EXIF not carried over"}`; string(data) != want {
+		t.Fatalf("JSON %s, want %s", data, want)
+	}
+}
+
+func TestWarningCodeText(t *testing.T) {
+	for _, code := range warningCodes {
+		text, err := code.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var back WarningCode
+		if err := back.UnmarshalText(text); err != nil || back != code {
+			t.Errorf("%s: decoded %v, %v", text, back, err)
+		}
+	}
+
+	// A JSON report with warnings decodes back into the same warnings.
+	in := []Warning{{WarnClipped, "3 of 2304 watermark pixels clipped"}, {WarnForced, "removed at -x/-y/-size without detection"}}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []Warning
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+	if len(out) != len(in) || out[0] != in[0] || out[1] != in[1] {
+		t.Fatalf("round trip %v, want %v", out, in)
+	}
+
+	var code WarningCode
+	if err := code.UnmarshalText([]byte("unknown")); err == nil {
+		t.Error("unknown code decoded")
+	}
+}
@@ -0,0 +1,82 @@
+package watermark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// TimeoutError is returned by Watchdog when the work outlives its timeout.
+// It wraps context.DeadlineExceeded.
+type TimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("image processing did not finish within %v", e.Timeout)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// Watchdog runs fn with a context that expires after timeout and returns
+// when fn does or the timeout passes, whichever comes first, so one image
+// cannot hang a batch or a server. Work that checks its context, like the
+// Context variants of this package, stops by itself; a decoder spinning on
+// a malformed image without reading further is abandoned: Watchdog returns
+// while fn keeps running in the background, still holding its CPU and
+// memory, until it finishes on its own. Either way Watchdog returns a
+// *TimeoutError. fn must therefore not touch anything the caller uses after
+// a timeout, other than through its results. Panics in fn are returned as a
+// *PanicError. A timeout of zero or less runs fn directly. Use WatchdogDone
+// to learn when abandoned work stops.
+func Watchdog(ctx context.Context, timeout time.Duration, fn func(context.Context) error) error {
+	_, err := WatchdogDone(ctx, timeout, fn)
+	return err
+}
+
+// WatchdogDone is Watchdog that also returns a channel closed once fn has
+// returned. After a timeout that happens later than WatchdogDone returns, so
+// callers that bound concurrency, like a worker pool, can keep abandoned
+// work counted until it really stops.
+func WatchdogDone(ctx context.Context, timeout time.Duration, fn func(context.Context) error) (<-chan struct{}, error) {
+	finished := make(chan struct{})
+	if timeout <= 0 {
+		defer close(finished)
+		return finished, fn(ctx)
+	}
+	parent := ctx
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		defer close(finished)
+		defer func() {
+			if v := recover(); v != nil {
+				done <- &PanicError{Op: "Watchdog", Value: v, Stack: debug.Stack()}
+			}
+		}()
+		done <- fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			return finished, err
+		}
+	case <-ctx.Done():
+	}
+	if parent.Err() != nil {
+		return finished, parent.Err()
+	}
+	if ctx.Err() == nil {
+		// fn failed with a deadline of its own.
+		return finished, err
+	}
+	return finished, &TimeoutError{Timeout: timeout}
+}
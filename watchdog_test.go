@@ -0,0 +1,93 @@
+package watermark
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	if err := Watchdog(context.Background(), time.Second, func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("quick work: %v", err)
+	}
+	errBad := errors.New("bad image")
+	if err := Watchdog(context.Background(), time.Second, func(context.Context) error { return errBad }); err != errBad {
+		t.Fatalf("failing work: %v", err)
+	}
+
+	// A decoder spinning without checking its context is abandoned.
+	release := make(chan struct{})
+	defer close(release)
+	start := time.Now()
+	err := Watchdog(context.Background(), 20*time.Millisecond, func(context.Context) error {
+		<-release
+		return nil
+	})
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) || !errors.Is(err, context.DeadlineExceeded) || timeout.Timeout != 20*time.Millisecond {
+		t.Fatalf("stuck work: err = %v, want a TimeoutError", err)
+	}
+	if waited := time.Since(start); waited > time.Second {
+		t.Errorf("stuck work returned after %v", waited)
+	}
+
+	// Work that honors its context reports the same error.
+	err = Watchdog(context.Background(), 20*time.Millisecond, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.As(err, &timeout) {
+		t.Fatalf("cancelable work: err = %v, want a TimeoutError", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Watchdog(ctx, time.Second, func(ctx context.Context) error { return ctx.Err() }); !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled caller: err = %v", err)
+	}
+
+	var panicErr *PanicError
+	if err := Watchdog(context.Background(), time.Second, func(context.Context) error { panic("decoder bug") }); !errors.As(err, &panicErr) {
+		t.Fatalf("panicking work: err = %v, want a PanicError", err)
+	}
+
+	ran := false
+	if err := Watchdog(context.Background(), 0, func(context.Context) error { ran = true; return nil }); err != nil || !ran {
+		t.Fatalf("no timeout: ran %v, err %v", ran, err)
+	}
+}
+
+func TestWatchdogDone(t *testing.T) {
+	release := make(chan struct{})
+	done, err := WatchdogDone(context.Background(), 20*time.Millisecond, func(context.Context) error {
+		<-release
+		return nil
+	})
+	var timeout *TimeoutError
+	if !errors.As(err, &timeout) {
+		t.Fatalf("stuck work: err = %v, want a TimeoutError", err)
+	}
+	select {
+	case <-done:
+		t.Fatalf("done closed while the abandoned work still runs")
+	default:
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("done not closed after the work returned")
+	}
+
+	done, err = WatchdogDone(context.Background(), 0, func(context.Context) error { return nil })
+	if err != nil {
+		t.Fatalf("no timeout: %v", err)
+	}
+	select {
+	case <-done:
+	default:
+		t.Fatalf("done open after work that ran directly")
+	}
+}
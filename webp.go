@@ -0,0 +1,209 @@
+package watermark
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+// DefaultWebPQuality is used for lossy WebP when WebPOptions.Quality is
+// outside 1-100.
+const DefaultWebPQuality = 90
+
+// WebPOptions configures EncodeWebP. A nil *WebPOptions means lossless.
+type WebPOptions struct {
+	// Lossy selects VP8 compression instead of lossless VP8L. Alpha is
+	// always stored losslessly.
+	Lossy bool
+	// Quality is the lossy quality (1-100); it is ignored for lossless.
+	Quality int
+}
+
+// ErrNoWebPEncoder is returned for WebP output when no encoder has been
+// registered with RegisterWebPEncoder.
+var ErrNoWebPEncoder = errors.New("webp encoding unavailable: import github.com/gcslaoli/gemini-watermark-remover-go/webpenc")
+
+// WebPEncoder encodes img, whose Pix is tightly packed straight alpha
+// starting at the origin, as a WebP file. o.Quality is already in 1-100
+// for lossy output.
+type WebPEncoder func(img *image.NRGBA, o WebPOptions) ([]byte, error)
+
+var webpEncoder WebPEncoder
+
+// RegisterWebPEncoder installs the encoder behind EncodeWebP, WebP output
+// and animated WebP cleaning. The core package ships none, so that it
+// builds wherever Go does; the webpenc package registers libwebp when
+// imported:
+//
+//	import _ "github.com/gcslaoli/gemini-watermark-remover-go/webpenc"
+//
+// RegisterWebPEncoder is meant to be called from an init function.
+func RegisterWebPEncoder(enc WebPEncoder) {
+	webpEncoder = enc
+}
+
+// EncodeWebP writes the provided image to the writer as WebP with the
+// registered encoder, or fails with ErrNoWebPEncoder. Lossless output
+// decodes to exactly the input pixels; lossy output keeps alpha exact and
+// compresses the colors.
+func EncodeWebP(w io.Writer, img image.Image, o *WebPOptions) error {
+	data, err := encodeWebPBytes(img, o)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// encodeWebPBytes returns img encoded as a WebP file per o.
+func encodeWebPBytes(img image.Image, o *WebPOptions) ([]byte, error) {
+	if webpEncoder == nil {
+		return nil, ErrNoWebPEncoder
+	}
+	var opts WebPOptions
+	if o != nil && o.Lossy {
+		opts = WebPOptions{Lossy: true, Quality: o.Quality}
+		if opts.Quality < 1 || opts.Quality > 100 {
+			opts.Quality = DefaultWebPQuality
+		}
+	}
+	// Encoders read Pix as a tightly packed, straight-alpha buffer whatever
+	// the image type, stride or origin, so always give them one.
+	nrgba := cloneToNRGBA(img)
+	if b := nrgba.Bounds(); b.Min != (image.Point{}) {
+		nrgba.Rect = b.Sub(b.Min)
+	}
+	data, err := webpEncoder(nrgba, opts)
+	if err != nil {
+		return nil, fmt.Errorf("encode webp: %w", err)
+	}
+	return data, nil
+}
+
+// webpImageChunks returns the ALPH, VP8 and VP8L chunks of a still WebP
+// file, serialized, as an ANMF frame holds them.
+func webpImageChunks(data []byte) []byte {
+	var chunks []byte
+	webpChunks(data, func(fourcc string, body []byte) bool {
+		switch fourcc {
+		case "ALPH", "VP8 ", "VP8L":
+			chunks = append(chunks, webpChunk(fourcc, body)...)
+		}
+		return true
+	})
+	return chunks
+}
+
+// vp8xPayload returns the extended format header with the given feature
+// flags.
+func vp8xPayload(flags byte, width, height int) []byte {
+	p := make([]byte, 10)
+	p[0] = flags
+	w, h := width-1, height-1
+	p[4], p[5], p[6] = byte(w), byte(w>>8), byte(w>>16)
+	p[7], p[8], p[9] = byte(h), byte(h>>8), byte(h>>16)
+	return p
+}
+
+// webpChunk serializes a RIFF chunk, padded to an even length.
+func webpChunk(fourcc string, data []byte) []byte {
+	chunk := make([]byte, 8, 9+len(data))
+	copy(chunk, fourcc)
+	binary.LittleEndian.PutUint32(chunk[4:], uint32(len(data)))
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// riffWebP wraps chunks in the RIFF WEBP header.
+func riffWebP(chunks ...[]byte) []byte {
+	size := 4
+	for _, c := range chunks {
+		size += len(c)
+	}
+	out := make([]byte, 0, 8+size)
+	out = append(out, "RIFF"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(size))
+	out = append(out, "WEBP"...)
+	for _, c := range chunks {
+		out = append(out, c...)
+	}
+	return out
+}
+
+// webpChunks calls fn for each chunk of a RIFF WEBP file, with its FourCC
+// and data, until fn returns false.
+func webpChunks(data []byte, fn func(fourcc string, body []byte) bool) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return
+	}
+	for pos := 12; pos+8 <= len(data); {
+		length := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		end := pos + 8 + length
+		if length < 0 || end > len(data) {
+			return
+		}
+		if !fn(string(data[pos:pos+4]), data[pos+8:end]) {
+			return
+		}
+		pos = end + length%2
+	}
+}
+
+// webpLossy reports whether a WebP file stores its colors with lossy VP8.
+func webpLossy(data []byte) bool {
+	lossy := false
+	webpChunks(data, func(fourcc string, _ []byte) bool {
+		lossy = fourcc == "VP8 "
+		return !lossy && fourcc != "VP8L"
+	})
+	return lossy
+}
+
+// webpICCProfile returns the ICC profile of a WebP ICCP chunk, or nil.
+func webpICCProfile(data []byte) []byte {
+	var profile []byte
+	webpChunks(data, func(fourcc string, body []byte) bool {
+		if fourcc == "ICCP" {
+			profile = body
+		}
+		return profile == nil
+	})
+	return profile
+}
+
+// webpSize returns the canvas size from the first header or image chunk of a
+// WebP file.
+func webpSize(data []byte) (width, height int, ok bool) {
+	webpChunks(data, func(fourcc string, body []byte) bool {
+		switch fourcc {
+		case "VP8X":
+			if len(body) >= 10 {
+				width = 1 + (int(body[4]) | int(body[5])<<8 | int(body[6])<<16)
+				height = 1 + (int(body[7]) | int(body[8])<<8 | int(body[9])<<16)
+				ok = true
+			}
+		case "VP8L":
+			if len(body) >= 5 && body[0] == 0x2f {
+				bits := binary.LittleEndian.Uint32(body[1:])
+				width, height = int(bits&0x3fff)+1, int(bits>>14&0x3fff)+1
+				ok = true
+			}
+		case "VP8 ":
+			if len(body) >= 10 && bytes.Equal(body[3:6], []byte{0x9d, 0x01, 0x2a}) {
+				width = int(binary.LittleEndian.Uint16(body[6:]) & 0x3fff)
+				height = int(binary.LittleEndian.Uint16(body[8:]) & 0x3fff)
+				ok = true
+			}
+		default:
+			return true
+		}
+		return false
+	})
+	return width, height, ok
+}
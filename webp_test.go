@@ -0,0 +1,264 @@
+package watermark
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+
+	libwebp "github.com/gen2brain/webp"
+	xwebp "golang.org/x/image/webp"
+)
+
+// gradientNRGBA returns a w x h image with smooth gradients, some texture and,
+// when translucent is set, varying alpha.
+func gradientNRGBA(w, h int, translucent bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: uint8(x * 255 / w), G: uint8(y * 255 / h), B: uint8(96 + (x*7+y*13)%9), A: 255}
+			if translucent {
+				c.A = uint8(255 - (x+y)%5*50)
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func decodeWebP(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, format, err := DecodeImageBytes(data)
+	if err != nil || format != "webp" {
+		t.Fatalf("decode: %q, %v", format, err)
+	}
+	return img
+}
+
+func TestEncodeWebPLossless(t *testing.T) {
+	// The largest size has matches farther back than distance codes reach.
+	for _, size := range []image.Point{{1, 1}, {17, 9}, {130, 67}, {1100, 1000}} {
+		for _, translucent := range []bool{false, true} {
+			src := gradientNRGBA(size.X, size.Y, translucent)
+			data, err := EncodeWebPToBytes(src, nil)
+			if err != nil {
+				t.Fatalf("%v: encode: %v", size, err)
+			}
+			if webpLossy(data) {
+				t.Fatalf("%v: lossless output stored as VP8", size)
+			}
+			got := decodeWebP(t, data)
+			for y := 0; y < size.Y; y++ {
+				for x := 0; x < size.X; x++ {
+					if c := color.NRGBAModel.Convert(got.At(x, y)); c != src.NRGBAAt(x, y) {
+						t.Fatalf("%v translucent=%v: pixel (%d,%d) = %v, want %v", size, translucent, x, y, c, src.NRGBAAt(x, y))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeWebPLossy(t *testing.T) {
+	for _, translucent := range []bool{false, true} {
+		src := gradientNRGBA(150, 90, translucent)
+		data, err := EncodeWebPToBytes(src, &WebPOptions{Lossy: true, Quality: 90})
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		if !webpLossy(data) {
+			t.Fatalf("lossy output not stored as VP8")
+		}
+
+		got := decodeWebP(t, data)
+		var total, samples int
+		for y := 0; y < 90; y++ {
+			for x := 0; x < 150; x++ {
+				c := color.NRGBAModel.Convert(got.At(x, y)).(color.NRGBA)
+				want := src.NRGBAAt(x, y)
+				if c.A != want.A {
+					t.Fatalf("translucent=%v: alpha at (%d,%d) = %d, want %d", translucent, x, y, c.A, want.A)
+				}
+				// Colors of translucent pixels pass through premultiplied
+				// alpha, so compare opaque-equivalent values.
+				for _, d := range []uint8{absDiff(c.R, want.R), absDiff(c.G, want.G), absDiff(c.B, want.B)} {
+					total += int(d)
+					samples++
+				}
+			}
+		}
+		if mean := float64(total) / float64(samples); mean > 4 {
+			t.Fatalf("translucent=%v: mean channel error %.2f", translucent, mean)
+		}
+	}
+}
+
+// Re-encoding a decoded lossy WebP at the same quality should not drift
+// colors, since the encoder inverts the decoder's YCbCr conversion.
+func TestEncodeWebPLossyStable(t *testing.T) {
+	first, err := EncodeWebPToBytes(gradientNRGBA(64, 64, false), &WebPOptions{Lossy: true, Quality: 90})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	once := decodeWebP(t, first)
+	second, err := EncodeWebPToBytes(once, &WebPOptions{Lossy: true, Quality: 90})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	twice := decodeWebP(t, second)
+	var total, samples int
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			a := color.RGBAModel.Convert(once.At(x, y)).(color.RGBA)
+			b := color.RGBAModel.Convert(twice.At(x, y)).(color.RGBA)
+			for _, d := range []uint8{absDiff(a.R, b.R), absDiff(a.G, b.G), absDiff(a.B, b.B)} {
+				total += int(d)
+				samples++
+			}
+		}
+	}
+	if mean := float64(total) / float64(samples); mean > 1.5 {
+		t.Fatalf("second generation drifted by %.2f per channel", mean)
+	}
+}
+
+func TestProcessBytesKeepsWebPMode(t *testing.T) {
+	img := watermarkedRGBA(t, 320, 240, color.RGBA{R: 40, G: 60, B: 90, A: 255})
+	for _, o := range []*WebPOptions{nil, {Lossy: true}} {
+		data, err := EncodeWebPToBytes(img, o)
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		r, err := ProcessBytes(data, Options{Output: OutputSource})
+		if err != nil || !r.Present {
+			t.Fatalf("ProcessBytes: present %v, %v", r.Present, err)
+		}
+		out := r.Output
+		if _, format, err := DecodeImageBytes(out); err != nil || format != "webp" {
+			t.Fatalf("output decodes as %q, %v", format, err)
+		}
+		if webpLossy(out) != (o != nil) {
+			t.Fatalf("lossy = %v, want %v", webpLossy(out), o != nil)
+		}
+	}
+}
+
+// The WebP encoder registers a decoder of its own; decoding must still
+// return the x/image/webp image types, which detection and the halo
+// suppression tell lossy inputs apart by.
+func TestDecodeWebPUsesXImage(t *testing.T) {
+	data, err := EncodeWebPToBytes(gradientNRGBA(32, 32, false), &WebPOptions{Lossy: true})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	img, format, err := DecodeImageBytes(data)
+	if err != nil || format != "webp" {
+		t.Fatalf("DecodeImageBytes = %q, %v", format, err)
+	}
+	if _, ok := img.(*image.YCbCr); !ok {
+		t.Fatalf("decoded %T, want *image.YCbCr", img)
+	}
+}
+
+// libwebp stores lossy colors in studio range; decoding must expand it, or
+// saturated colors come back several levels off.
+func TestDecodeLossyWebPColors(t *testing.T) {
+	for _, want := range []color.NRGBA{{200, 50, 100, 255}, {120, 40, 40, 255}, {250, 250, 250, 255}, {10, 10, 10, 255}} {
+		src := image.NewNRGBA(image.Rect(0, 0, 32, 32))
+		draw.Draw(src, src.Bounds(), &image.Uniform{C: want}, image.Point{}, draw.Src)
+		data, err := EncodeWebPToBytes(src, &WebPOptions{Lossy: true})
+		if err != nil {
+			t.Fatalf("encode: %v", err)
+		}
+		got := color.NRGBAModel.Convert(decodeWebP(t, data).At(16, 16)).(color.NRGBA)
+		if absDiff(got.R, want.R) > 2 || absDiff(got.G, want.G) > 2 || absDiff(got.B, want.B) > 2 {
+			t.Fatalf("%v decoded as %v", want, got)
+		}
+	}
+}
+
+// Lossy decodes must match libwebp's own RGB conversion, which the animation
+// decoder of the encoder package returns, up to chroma upsampling. Without
+// the studio-range expansion x/image/webp is several levels off.
+func TestDecodeLossyWebPMatchesLibwebp(t *testing.T) {
+	data, err := EncodeWebPToBytes(gradientNRGBA(128, 128, false), &WebPOptions{Lossy: true})
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	all, err := libwebp.DecodeAll(bytes.NewReader(data))
+	if err != nil || len(all.Image) != 1 {
+		t.Fatalf("reference decode: %v", err)
+	}
+	ref := all.Image[0]
+
+	raw, err := xwebp.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("x/image/webp: %v", err)
+	}
+	if mean, _ := meanMaxDiff(raw, ref); mean < 3 {
+		t.Fatalf("x/image/webp is within %.2f levels of libwebp; the test no longer exercises the range expansion", mean)
+	}
+
+	mean, maxDiff := meanMaxDiff(decodeWebP(t, data), ref)
+	if mean > 1.5 || maxDiff > 8 {
+		t.Fatalf("decode differs from libwebp by %.2f levels on average, %d at most", mean, maxDiff)
+	}
+}
+
+// meanMaxDiff returns the mean and largest absolute RGB difference between
+// two images of the same bounds.
+func meanMaxDiff(a, b image.Image) (mean float64, maxDiff int) {
+	var sum, n int
+	bounds := b.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			p := color.NRGBAModel.Convert(a.At(x, y)).(color.NRGBA)
+			q := color.NRGBAModel.Convert(b.At(x, y)).(color.NRGBA)
+			for _, d := range []int{int(p.R) - int(q.R), int(p.G) - int(q.G), int(p.B) - int(q.B)} {
+				d = max(d, -d)
+				sum += d
+				maxDiff = max(maxDiff, d)
+				n++
+			}
+		}
+	}
+	return float64(sum) / float64(n), maxDiff
+}
+
+// The encoder must not read sub-images or premultiplied buffers raw.
+func TestEncodeWebPSubImage(t *testing.T) {
+	src := gradientNRGBA(64, 64, true)
+	rgba := image.NewRGBA(src.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), src, image.Point{}, draw.Src)
+	sub := rgba.SubImage(image.Rect(10, 20, 50, 44))
+	data, err := EncodeWebPToBytes(sub, nil)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	got := decodeWebP(t, data)
+	if got.Bounds().Size() != sub.Bounds().Size() {
+		t.Fatalf("size %v, want %v", got.Bounds().Size(), sub.Bounds().Size())
+	}
+	b := sub.Bounds()
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			g := color.NRGBAModel.Convert(got.At(x, y))
+			w := color.NRGBAModel.Convert(sub.At(b.Min.X+x, b.Min.Y+y))
+			if g != w {
+				t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, g, w)
+			}
+		}
+	}
+}
+
+func TestEncodeWebPWithoutEncoder(t *testing.T) {
+	saved := webpEncoder
+	defer RegisterWebPEncoder(saved)
+	RegisterWebPEncoder(nil)
+
+	if _, err := EncodeWebPToBytes(gradientNRGBA(8, 8, false), nil); !errors.Is(err, ErrNoWebPEncoder) {
+		t.Fatalf("EncodeWebPToBytes without an encoder: err = %v, want ErrNoWebPEncoder", err)
+	}
+}
@@ -0,0 +1,304 @@
+package watermark
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// webpAnimationFlag is the VP8X feature flag of animated WebP files.
+const webpAnimationFlag = 0x02
+
+// webpAnimation is a demuxed animated WebP file.
+type webpAnimation struct {
+	Width, Height int
+	Frames        []webpFrame
+	// head and tail hold the serialized chunks before the first and after
+	// the last ANMF chunk (VP8X, ICCP and ANIM; EXIF and XMP), which muxing
+	// copies unchanged.
+	head, tail [][]byte
+}
+
+// webpFrame is one ANMF frame of an animated WebP file.
+type webpFrame struct {
+	// X and Y are the frame offset on the canvas; both are even.
+	X, Y          int
+	Width, Height int
+	// Duration is the display time in milliseconds.
+	Duration int
+	// NoBlend replaces the canvas pixels instead of alpha-blending over them.
+	NoBlend bool
+	// Dispose clears the frame rectangle to transparent after display.
+	Dispose bool
+	// Data holds the serialized frame chunks: ALPH and VP8, or VP8L.
+	Data []byte
+}
+
+// IsAnimatedWebP reports whether data is an animated WebP file. Decode and
+// DecodeImageBytes cannot read those; ProcessBytes cleans them frame by
+// frame.
+func IsAnimatedWebP(data []byte) bool {
+	return webpAnimated(data)
+}
+
+// webpAnimated reports whether data is an animated WebP file.
+func webpAnimated(data []byte) bool {
+	animated := false
+	webpChunks(data, func(fourcc string, body []byte) bool {
+		animated = fourcc == "VP8X" && len(body) > 0 && body[0]&webpAnimationFlag != 0
+		return false
+	})
+	return animated
+}
+
+// parseWebPAnimation demuxes an animated WebP file.
+func parseWebPAnimation(data []byte) (*webpAnimation, error) {
+	if !webpAnimated(data) {
+		return nil, fmt.Errorf("not an animated webp")
+	}
+	a := &webpAnimation{}
+	a.Width, a.Height, _ = webpSize(data)
+
+	var err error
+	webpChunks(data, func(fourcc string, body []byte) bool {
+		if fourcc != "ANMF" {
+			if len(a.Frames) == 0 {
+				a.head = append(a.head, webpChunk(fourcc, body))
+			} else {
+				a.tail = append(a.tail, webpChunk(fourcc, body))
+			}
+			return true
+		}
+		if len(body) < 16 {
+			err = fmt.Errorf("frame %d: truncated ANMF chunk", len(a.Frames))
+			return false
+		}
+		u24 := func(b []byte) int { return int(b[0]) | int(b[1])<<8 | int(b[2])<<16 }
+		a.Frames = append(a.Frames, webpFrame{
+			X:        2 * u24(body[0:]),
+			Y:        2 * u24(body[3:]),
+			Width:    1 + u24(body[6:]),
+			Height:   1 + u24(body[9:]),
+			Duration: u24(body[12:]),
+			NoBlend:  body[15]&0x02 != 0,
+			Dispose:  body[15]&0x01 != 0,
+			Data:     body[16:],
+		})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(a.Frames) == 0 {
+		return nil, fmt.Errorf("animated webp has no frames")
+	}
+	return a, nil
+}
+
+// bytes muxes the animation back into a WebP file.
+func (a *webpAnimation) bytes() []byte {
+	chunks := append([][]byte{}, a.head...)
+	for _, f := range a.Frames {
+		chunks = append(chunks, webpChunk("ANMF", f.payload()))
+	}
+	return riffWebP(append(chunks, a.tail...)...)
+}
+
+// payload returns the ANMF chunk data of f.
+func (f webpFrame) payload() []byte {
+	p := make([]byte, 16, 16+len(f.Data))
+	put24 := func(b []byte, v int) { b[0], b[1], b[2] = byte(v), byte(v>>8), byte(v>>16) }
+	put24(p[0:], f.X/2)
+	put24(p[3:], f.Y/2)
+	put24(p[6:], f.Width-1)
+	put24(p[9:], f.Height-1)
+	put24(p[12:], f.Duration)
+	if f.NoBlend {
+		p[15] |= 0x02
+	}
+	if f.Dispose {
+		p[15] |= 0x01
+	}
+	return append(p, f.Data...)
+}
+
+// bounds returns the frame rectangle on the canvas.
+func (f webpFrame) bounds() image.Rectangle {
+	return image.Rect(f.X, f.Y, f.X+f.Width, f.Y+f.Height)
+}
+
+// lossy reports whether the frame stores its colors with lossy VP8.
+func (f webpFrame) lossy() bool {
+	return webpLossy(riffWebP(f.Data))
+}
+
+// decode decodes the frame bitstream into an image at the origin.
+func (f webpFrame) decode() (image.Image, error) {
+	file := riffWebP(f.Data)
+	hasAlpha := false
+	webpChunks(file, func(fourcc string, _ []byte) bool {
+		hasAlpha = fourcc == "ALPH"
+		return !hasAlpha
+	})
+	if hasAlpha {
+		file = riffWebP(webpChunk("VP8X", vp8xPayload(0x10, f.Width, f.Height)), f.Data)
+	}
+	img, _, err := decodeImage(bytes.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("decode frame: %w", err)
+	}
+	if img.Bounds().Dx() != f.Width || img.Bounds().Dy() != f.Height {
+		return nil, fmt.Errorf("frame bitstream is %v, ANMF header says %dx%d", img.Bounds().Size(), f.Width, f.Height)
+	}
+	return img, nil
+}
+
+// encodeWebPFrame returns the frame chunks of img, lossless VP8L or lossy
+// VP8 with an ALPH chunk when img has translucent pixels.
+func encodeWebPFrame(img image.Image, lossy bool, quality int) ([]byte, error) {
+	var o *WebPOptions
+	if lossy {
+		o = &WebPOptions{Lossy: true, Quality: quality}
+	}
+	data, err := encodeWebPBytes(img, o)
+	if err != nil {
+		return nil, err
+	}
+	return webpImageChunks(data), nil
+}
+
+// processWebPAnimation removes the watermark from every frame of an animated
+// WebP. As with GIF, detection runs on the composited canvas while cleaning
+// only rewrites the frame's own pixels. Cleaned frames are re-encoded in
+// their original kind, lossy when the frame or opts.WebPLossy asks for it;
+// all other frames and chunks, including timing, loop count and metadata,
+// are copied unchanged. Cancelling ctx stops processing before the next
+// frame.
+func processWebPAnimation(ctx context.Context, data []byte, opts Options) (Result, error) {
+	engine := Default()
+	engine.report(StageDecode, 0)
+	anim, err := parseWebPAnimation(data)
+	if err != nil {
+		return Result{}, fmt.Errorf("decode webp: %w", err)
+	}
+	engine.report(StageDecode, 100)
+
+	detector := NewFrameDetector()
+	detector.Profile = opts.profile()
+	canvas := image.NewRGBA(image.Rect(0, 0, anim.Width, anim.Height))
+
+	result := Result{Format: "webp"}
+	frames := anim.Frames
+	anim.Frames = nil
+	engine.report(StageBlend, 0)
+	for i, frame := range frames {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+
+		fr := FrameResult{Index: i}
+		img, err := frame.decode()
+		if err == nil {
+			op := draw.Over
+			if frame.NoBlend {
+				op = draw.Src
+			}
+			draw.Draw(canvas, frame.bounds(), img, image.Point{}, op)
+			err = cleanWebPFrame(engine, detector, canvas, &frame, img, opts, &fr)
+		}
+		if err != nil {
+			switch opts.FrameErrorPolicy {
+			case FrameSkip:
+				fr.Outcome = FrameSkipped
+			case FrameCopyOriginal:
+				fr.Outcome = FrameCopied
+				frame = frames[i]
+			default:
+				return Result{}, fmt.Errorf("frame %d: %w", i, err)
+			}
+			fr.Err = err
+		}
+
+		if fr.Outcome != FrameSkipped {
+			anim.Frames = append(anim.Frames, frame)
+		}
+		result.addFrame(fr)
+		engine.report(StageBlend, float64(i+1)*100/float64(len(frames)))
+
+		if frame.Dispose {
+			draw.Draw(canvas, frame.bounds(), image.Transparent, image.Point{}, draw.Src)
+		}
+	}
+
+	if !result.Present {
+		return result, nil
+	}
+	if len(anim.Frames) == 0 {
+		return Result{}, fmt.Errorf("all %d frames were skipped", len(frames))
+	}
+	engine.report(StageEncode, 0)
+	result.Output = anim.bytes()
+	engine.report(StageEncode, 100)
+	return result, nil
+}
+
+// cleanWebPFrame detects the watermark on the composited canvas and, when
+// present, reverse blends the opaque frame pixels that overlap it and
+// re-encodes the frame.
+func cleanWebPFrame(e *Engine, d *FrameDetector, canvas *image.RGBA, f *webpFrame, img image.Image, opts Options, fr *FrameResult) error {
+	present, score, info, err := d.Detect(canvas)
+	if err != nil {
+		return err
+	}
+
+	fr.Present, fr.Score, fr.Info = present, score, info
+	if !present {
+		fr.Outcome = FrameUnchanged
+		return nil
+	}
+	fr.Outcome = FrameCleaned
+
+	rect := info.Position
+	overlap := rect.Intersect(f.bounds())
+	if overlap.Empty() {
+		// An earlier frame showing through has been cleaned already.
+		return nil
+	}
+	alphaMap, logo, err := e.blendParams(d.Profile, canvas.Bounds().Dx(), canvas.Bounds().Dy(), info)
+	if err != nil {
+		return err
+	}
+
+	cleaned := cloneToNRGBA(img)
+	for y := overlap.Min.Y; y < overlap.Max.Y; y++ {
+		for x := overlap.Min.X; x < overlap.Max.X; x++ {
+			alpha := float64(alphaMap[(y-rect.Min.Y)*rect.Dx()+(x-rect.Min.X)])
+			if alpha < alphaThreshold {
+				continue
+			}
+			alpha = min(alpha, maxAlpha)
+
+			c := cleaned.NRGBAAt(x-f.X, y-f.Y)
+			if c.A != 0xff {
+				// Translucent pixels mix with frames already cleaned.
+				continue
+			}
+			cleaned.SetNRGBA(x-f.X, y-f.Y, color.NRGBA{
+				R: reverseBlend(c.R, alpha, logo[0], e.rounding),
+				G: reverseBlend(c.G, alpha, logo[1], e.rounding),
+				B: reverseBlend(c.B, alpha, logo[2], e.rounding),
+				A: 0xff,
+			})
+		}
+	}
+
+	chunks, err := encodeWebPFrame(cleaned, f.lossy() || opts.WebPLossy, opts.WebPQuality)
+	if err != nil {
+		return fmt.Errorf("encode frame: %w", err)
+	}
+	f.Data = chunks
+	return nil
+}
@@ -0,0 +1,99 @@
+package watermark
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"testing"
+)
+
+// muxWebPAnimation builds an animated WebP of the given frames on a
+// width x height canvas that loops forever.
+func muxWebPAnimation(width, height int, frames []webpFrame) []byte {
+	anim := &webpAnimation{
+		Width:  width,
+		Height: height,
+		Frames: frames,
+		head: [][]byte{
+			webpChunk("VP8X", vp8xPayload(webpAnimationFlag|0x10, width, height)),
+			webpChunk("ANIM", binary.LittleEndian.AppendUint16([]byte{0, 0, 0, 0}, 0)),
+		},
+	}
+	return anim.bytes()
+}
+
+func testWebPFrame(t *testing.T, img image.Image, at image.Point, lossy bool, duration int) webpFrame {
+	t.Helper()
+	data, err := encodeWebPFrame(img, lossy, 95)
+	if err != nil {
+		t.Fatalf("encode frame: %v", err)
+	}
+	b := img.Bounds()
+	return webpFrame{X: at.X, Y: at.Y, Width: b.Dx(), Height: b.Dy(), Duration: duration, Data: data}
+}
+
+func TestProcessBytesAnimatedWebP(t *testing.T) {
+	const width, height = 320, 240
+	first := watermarkedRGBA(t, width, height, color.RGBA{R: 30, G: 60, B: 120, A: 255})
+	third := watermarkedRGBA(t, width, height, color.RGBA{R: 120, G: 40, B: 40, A: 255})
+	patch := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	frames := []webpFrame{
+		testWebPFrame(t, first, image.Point{}, false, 100),
+		testWebPFrame(t, patch, image.Pt(10, 10), false, 50),
+		testWebPFrame(t, third, image.Point{}, true, 200),
+		{Width: width, Height: height, Duration: 80, Data: webpChunk("VP8L", []byte("corrupt"))},
+	}
+	input := muxWebPAnimation(width, height, frames)
+	if !webpAnimated(input) {
+		t.Fatalf("muxed file not recognized as animated")
+	}
+
+	if _, err := ProcessBytes(input, Options{}); err == nil {
+		t.Fatalf("expected the corrupt frame to abort by default")
+	}
+	result, err := ProcessBytes(input, Options{FrameErrorPolicy: FrameCopyOriginal})
+	if err != nil {
+		t.Fatalf("ProcessBytes: %v", err)
+	}
+	if !result.Present || result.Format != "webp" || len(result.Frames) != 4 {
+		t.Fatalf("unexpected result: present %v format %q frames %d", result.Present, result.Format, len(result.Frames))
+	}
+	wantOutcomes := []FrameOutcome{FrameCleaned, FrameCleaned, FrameCleaned, FrameCopied}
+	for i, fr := range result.Frames {
+		if fr.Outcome != wantOutcomes[i] {
+			t.Fatalf("frame %d outcome %v, want %v", i, fr.Outcome, wantOutcomes[i])
+		}
+	}
+
+	out, err := parseWebPAnimation(result.Output)
+	if err != nil {
+		t.Fatalf("output does not demux: %v", err)
+	}
+	if len(out.Frames) != 4 {
+		t.Fatalf("output has %d frames", len(out.Frames))
+	}
+	for i, f := range out.Frames {
+		if f.Duration != frames[i].Duration || f.bounds() != frames[i].bounds() {
+			t.Fatalf("frame %d timing or geometry changed: %+v", i, f)
+		}
+	}
+	if !bytes.Equal(out.Frames[1].Data, frames[1].Data) || !bytes.Equal(out.Frames[3].Data, frames[3].Data) {
+		t.Fatalf("frames without watermark pixels were re-encoded")
+	}
+	if !out.Frames[2].lossy() || out.Frames[0].lossy() {
+		t.Fatalf("frame kinds not preserved")
+	}
+
+	rect := result.Info.Position
+	for i, bg := range map[int]color.RGBA{0: {R: 30, G: 60, B: 120}, 2: {R: 120, G: 40, B: 40}} {
+		img, err := out.Frames[i].decode()
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		c := color.NRGBAModel.Convert(img.At(rect.Min.X+rect.Dx()/2, rect.Min.Y+rect.Dy()/2)).(color.NRGBA)
+		if absDiff(c.R, bg.R) > 6 || absDiff(c.G, bg.G) > 6 || absDiff(c.B, bg.B) > 6 {
+			t.Fatalf("frame %d: logo center %v, want about %v", i, c, bg)
+		}
+	}
+}
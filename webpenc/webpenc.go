@@ -0,0 +1,44 @@
+// Package webpenc registers a libwebp-based WebP encoder with the watermark
+// package when imported:
+//
+//	import _ "github.com/gcslaoli/gemini-watermark-remover-go/webpenc"
+//
+// Encoding runs libwebp through github.com/gen2brain/webp, as WebAssembly
+// or, where available, a system libwebp loaded without cgo. It lives apart
+// from the core package because that dependency does not build on every
+// platform Go supports.
+//
+// github.com/gen2brain/webp's init also registers a "webp" format with
+// image.Decode, so importing this package affects image.Decode for WebP
+// anywhere in the program: it may return that decoder's *image.NYCbCrA,
+// whose studio-range planes convert to slightly wrong colors, rather than
+// the x/image/webp types. watermark.Decode always uses x/image/webp with the
+// colors corrected.
+package webpenc
+
+import (
+	"bytes"
+	"image"
+
+	"github.com/gen2brain/webp"
+
+	watermark "github.com/gcslaoli/gemini-watermark-remover-go"
+)
+
+func init() {
+	watermark.RegisterWebPEncoder(encode)
+}
+
+// encode is the watermark.WebPEncoder: exact lossless VP8L, or lossy VP8
+// at o.Quality with alpha kept lossless.
+func encode(img *image.NRGBA, o watermark.WebPOptions) ([]byte, error) {
+	opts := webp.Options{Lossless: true, Exact: true}
+	if o.Lossy {
+		opts = webp.Options{Quality: o.Quality, Method: webp.DefaultMethod}
+	}
+	var buf bytes.Buffer
+	if err := webp.Encode(&buf, img, opts); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
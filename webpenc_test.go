@@ -0,0 +1,5 @@
+package watermark_test
+
+// The root package ships no WebP encoder; register libwebp for the tests
+// that write WebP, as programs do.
+import _ "github.com/gcslaoli/gemini-watermark-remover-go/webpenc"